@@ -1,26 +1,381 @@
 package aarch64
 
+/*
+	Instructions
+
+	The operands of the below instructions follow the same convention as the
+	x86_64 assembler: sources first and the destination last, so that
+	ADD(a, b, dest) computes dest = a + b and SUB(a, b, dest) computes
+	dest = a - b.
+
+	The opcodes are matched on the width of the registers, so passing W
+	registers selects the 32 bit variant of an instruction and X registers
+	the 64 bit variant.
+*/
+
 import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
 	"github.com/bspaans/jit-compiler/asm/aarch64/opcodes"
 	"github.com/bspaans/jit-compiler/lib"
 )
 
-func ADD(src, dest, val lib.Operand) lib.Instruction {
-	return opcodes.OpcodesToInstruction("add", opcodes.ADD, dest, src, val)
+func ADD(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("add", opcodes.ADD, src2, src1, dest)
+}
+
+func ADDS(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("adds", opcodes.ADDS, src2, src1, dest)
+}
+
+// Loads the address of the label into dest. The label has to be within 1MB.
+func ADR(label, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("adr", opcodes.ADR, label, dest)
+}
+
+// Bitwise and. src2 can also be an immediate, but only if it's a bitmask,
+// i.e. a repeating pattern of a rotated run of ones, like 0xff or 0xf0f0f0f0.
+func AND(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("and", opcodes.AND, src2, src1, dest)
+}
+
+// Bitwise and, setting the N and Z flags.
+func ANDS(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("ands", opcodes.ANDS, src2, src1, dest)
+}
+
+// Arithmetic shift right by an immediate or by the amount in a register.
+func ASR(src, amount, dest lib.Operand) lib.Instruction {
+	if _, ok := amount.(*encoding.Register); ok {
+		return opcodes.OpcodesToInstruction("asrv", opcodes.ASRV, amount, src, dest)
+	}
+	bits := uint8(dest.Width()) * 8
+	return SBFM(encoding.Uint8(shiftAmount(amount, bits)), encoding.Uint8(bits-1), src, dest)
+}
+
+func B(label lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("b", opcodes.B, label)
+}
+
+// Branches to the label if the condition holds.
+func B_cond(cond encoding.Condition, label lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("b."+cond.String(), opcodes.B_cond, label, cond)
+}
+
+// Branches to the label and stores the return address in x30.
+func BL(label lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("bl", opcodes.BL, label)
+}
+
+// Branches to the address in the register and stores the return address in x30.
+func BLR(reg lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("blr", opcodes.BLR, reg)
+}
+
+func BR(reg lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("br", opcodes.BR, reg)
+}
+
+// Breakpoint. Raises SIGTRAP on Linux; used to trap on failed runtime checks.
+func BRK(imm lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("brk", opcodes.BRK, imm)
+}
+
+// Branches to the label if the register is not zero.
+func CBNZ(reg, label lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("cbnz", opcodes.CBNZ, label, reg)
+}
+
+// Branches to the label if the register is zero.
+func CBZ(reg, label lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("cbz", opcodes.CBZ, label, reg)
+}
+
+// Sets the flags for src1 - src2
+func CMP(src1, src2 lib.Operand) lib.Instruction {
+	return SUBS(src1, src2, zeroRegister(src1))
+}
+
+// dest = cond ? src1 : src2 + 1
+func CSINC(cond encoding.Condition, src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("csinc", opcodes.CSINC, src2, cond, src1, dest)
+}
+
+// dest = cond ? src1 : src2
+func CSEL(cond encoding.Condition, src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("csel", opcodes.CSEL, src2, cond, src1, dest)
+}
+
+// dest = cond ? 1 : 0
+func CSET(cond encoding.Condition, dest lib.Operand) lib.Instruction {
+	zr := zeroRegister(dest)
+	return CSINC(cond.Invert(), zr, zr, dest)
+}
+
+// Bitwise exclusive or. See AND for the immediates that can be used.
+func EOR(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("eor", opcodes.EOR, src2, src1, dest)
+}
+
+func FADD(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("fadd", opcodes.FADD, src2, src1, dest)
+}
+
+// Compares two floating point registers. If either of them is NaN the
+// result is unordered, which sets the C and V flags.
+func FCMP(src1, src2 lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("fcmp", opcodes.FCMP, src2, src1)
+}
+
+// Convert float64 to signed integer, rounding towards zero
+func FCVTZS(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("fcvtzs", opcodes.FCVTZS, src, dest)
+}
+
+// Convert float64 to unsigned integer, rounding towards zero
+func FCVTZU(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("fcvtzu", opcodes.FCVTZU, src, dest)
+}
+
+func FDIV(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("fdiv", opcodes.FDIV, src2, src1, dest)
+}
+
+// Moves between floating point registers, or copies the bits between a
+// floating point and a general purpose register.
+func FMOV(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("fmov", opcodes.FMOV, src, dest)
+}
+
+func FMUL(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("fmul", opcodes.FMUL, src2, src1, dest)
+}
+
+func FSUB(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("fsub", opcodes.FSUB, src2, src1, dest)
+}
+
+// Loads a pair of registers from consecutive memory. The offset has to be a
+// multiple of the register size, in the range -64..63 after scaling.
+func LDP(src, dest1, dest2 lib.Operand) lib.Instruction {
+	return opcodes.PairOpcodesToInstruction("ldp", opcodes.LDP, dest1, dest2, src)
+}
+
+// Loads 4 or 8 bytes, depending on the destination register. Loads into W
+// registers clear the upper 32 bits.
+func LDR(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("ldr", opcodes.LDR, src, dest)
+}
+
+// Loads a byte and zero extends it.
+func LDRB(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("ldrb", opcodes.LDRB, src, dest)
+}
+
+// Loads two bytes and zero extends them.
+func LDRH(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("ldrh", opcodes.LDRH, src, dest)
+}
+
+// Shift left by an immediate or by the amount in a register. Shifts by a
+// register use the amount modulo the register size.
+func LSL(src, amount, dest lib.Operand) lib.Instruction {
+	if _, ok := amount.(*encoding.Register); ok {
+		return opcodes.OpcodesToInstruction("lslv", opcodes.LSLV, amount, src, dest)
+	}
+	bits := uint8(dest.Width()) * 8
+	shift := shiftAmount(amount, bits)
+	return UBFM(encoding.Uint8((bits-shift)%bits), encoding.Uint8(bits-1-shift), src, dest)
+}
+
+// Logical shift right by an immediate or by the amount in a register.
+func LSR(src, amount, dest lib.Operand) lib.Instruction {
+	if _, ok := amount.(*encoding.Register); ok {
+		return opcodes.OpcodesToInstruction("lsrv", opcodes.LSRV, amount, src, dest)
+	}
+	bits := uint8(dest.Width()) * 8
+	return UBFM(encoding.Uint8(shiftAmount(amount, bits)), encoding.Uint8(bits-1), src, dest)
+}
+
+// dest = src3 + src1 * src2
+func MADD(src1, src2, src3, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("madd", opcodes.MADD, src2, src3, src1, dest)
+}
+
+// Copies a register. Copies from and to the stack pointer are encoded as
+// an ADD, because register 31 means the zero register in ORR.
+func MOV(src, dest lib.Operand) lib.Instruction {
+	s, d := src.(*encoding.Register), dest.(*encoding.Register)
+	if s == encoding.SP || d == encoding.SP || s == encoding.WSP || d == encoding.WSP {
+		return ADD(src, encoding.Uint64(0), dest)
+	}
+	return ORR(zeroRegister(dest), src, dest)
+}
+
+// Moves a 16 bit immediate, shifted left by shift, into dest, keeping the
+// other bits.
+func MOVK(imm, shift, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("movk", opcodes.MOVK, shift, imm, dest)
+}
+
+// Moves the inverse of a 16 bit immediate, shifted left by shift, into dest.
+func MOVN(imm, shift, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("movn", opcodes.MOVN, shift, imm, dest)
+}
+
+// Moves a 16 bit immediate, shifted left by shift, into dest, clearing the
+// other bits.
+func MOVZ(imm, shift, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("movz", opcodes.MOVZ, shift, imm, dest)
+}
+
+// dest = src3 - src1 * src2
+func MSUB(src1, src2, src3, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("msub", opcodes.MSUB, src2, src3, src1, dest)
+}
+
+func MUL(src1, src2, dest lib.Operand) lib.Instruction {
+	return MADD(src1, src2, zeroRegister(dest), dest)
+}
+
+// Bitwise or. See AND for the immediates that can be used.
+func ORR(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("orr", opcodes.ORR, src2, src1, dest)
+}
+
+// Returns to the address in x30.
+func RET() lib.Instruction {
+	return opcodes.OpcodesToInstruction("ret", opcodes.RET, encoding.X30)
+}
+
+// Signed bitfield move (see SXTB, SXTH, SXTW)
+func SBFM(immr, imms, src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("sbfm", opcodes.SBFM, immr, imms, src, dest)
+}
+
+// Convert signed integer to float64
+func SCVTF(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("scvtf", opcodes.SCVTF, src, dest)
+}
+
+func SDIV(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("sdiv", opcodes.SDIV, src2, src1, dest)
+}
+
+// Stores a pair of registers in consecutive memory (see LDP).
+func STP(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.PairOpcodesToInstruction("stp", opcodes.STP, src1, src2, dest)
+}
+
+// Stores 4 or 8 bytes, depending on the source register.
+func STR(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("str", opcodes.STR, dest, src)
+}
+
+// Stores the lowest byte of a W register.
+func STRB(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("strb", opcodes.STRB, dest, src)
+}
+
+// Stores the lowest two bytes of a W register.
+func STRH(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("strh", opcodes.STRH, dest, src)
+}
+
+func SUB(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("sub", opcodes.SUB, src2, src1, dest)
+}
+
+func SUBS(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("subs", opcodes.SUBS, src2, src1, dest)
+}
+
+// Supervisor call. On Linux the syscall number goes in x8 and the arguments
+// in x0-x5.
+func SVC(imm lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("svc", opcodes.SVC, imm)
+}
+
+// Sign extend the lowest byte of src into dest.
+func SXTB(src, dest lib.Operand) lib.Instruction {
+	return SBFM(encoding.Uint8(0), encoding.Uint8(7), bitfieldSource(src, dest), dest)
+}
+
+// Sign extend the lowest two bytes of src into dest.
+func SXTH(src, dest lib.Operand) lib.Instruction {
+	return SBFM(encoding.Uint8(0), encoding.Uint8(15), bitfieldSource(src, dest), dest)
+}
+
+// Sign extend the lowest four bytes of src into the X register dest.
+func SXTW(src, dest lib.Operand) lib.Instruction {
+	return SBFM(encoding.Uint8(0), encoding.Uint8(31), bitfieldSource(src, dest), dest)
+}
+
+// Sets the N and Z flags for src1 & src2
+func TST(src1, src2 lib.Operand) lib.Instruction {
+	return ANDS(src1, src2, zeroRegister(src1))
+}
+
+// Unsigned bitfield move (see UXTB, UXTH, LSL, LSR)
+func UBFM(immr, imms, src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("ubfm", opcodes.UBFM, immr, imms, src, dest)
+}
+
+// Convert unsigned integer to float64
+func UCVTF(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("ucvtf", opcodes.UCVTF, src, dest)
+}
+
+func UDIV(src1, src2, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("udiv", opcodes.UDIV, src2, src1, dest)
+}
+
+// Zero extend the lowest byte of src into the W register dest.
+func UXTB(src, dest lib.Operand) lib.Instruction {
+	return UBFM(encoding.Uint8(0), encoding.Uint8(7), src, dest)
 }
 
-func ADDS(src, dest, val lib.Operand) lib.Instruction {
-	return opcodes.OpcodesToInstruction("adds", opcodes.ADDS, dest, src, val)
+// Zero extend the lowest two bytes of src into the W register dest.
+func UXTH(src, dest lib.Operand) lib.Instruction {
+	return UBFM(encoding.Uint8(0), encoding.Uint8(15), src, dest)
 }
 
-func MOVK(val, dest lib.Operand) lib.Instruction {
-	return opcodes.OpcodesToInstruction("movk", opcodes.MOVK, val, dest)
+// Returns the zero register with the same width as the operand.
+func zeroRegister(op lib.Operand) *encoding.Register {
+	if op.Width() == lib.QUADWORD {
+		return encoding.XZR
+	}
+	return encoding.WZR
 }
 
-func SUB(src, dest, val lib.Operand) lib.Instruction {
-	return opcodes.OpcodesToInstruction("sub", opcodes.SUB, dest, src, val)
+// The bitfield instructions need the source and destination to have the
+// same width.
+func bitfieldSource(src, dest lib.Operand) lib.Operand {
+	if reg, ok := src.(*encoding.Register); ok {
+		return reg.ForOperandWidth(dest.Width())
+	}
+	return src
 }
 
-func SUBS(src, dest, val lib.Operand) lib.Instruction {
-	return opcodes.OpcodesToInstruction("subs", opcodes.SUBS, dest, src, val)
+// Returns the immediate shift amount, which has to be smaller than the
+// number of bits in the register.
+func shiftAmount(amount lib.Operand, bits uint8) uint8 {
+	var shift uint64
+	switch a := amount.(type) {
+	case encoding.Uint8:
+		shift = uint64(a)
+	case encoding.Uint16:
+		shift = uint64(a)
+	case encoding.Uint32:
+		shift = uint64(a)
+	case encoding.Uint64:
+		shift = uint64(a)
+	default:
+		panic(fmt.Errorf("unsupported shift amount: %s", amount))
+	}
+	if shift >= uint64(bits) {
+		panic(fmt.Errorf("shift amount %d out of range for %d bit register", shift, bits))
+	}
+	return uint8(shift)
 }
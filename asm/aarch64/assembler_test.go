@@ -0,0 +1,139 @@
+package aarch64
+
+import (
+	"testing"
+
+	. "github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func Test_Encode(t *testing.T) {
+	label := lib.NewFixedLabel("l", 64)
+	cases := []struct {
+		Instruction lib.Instruction
+		Expected    string
+	}{
+		{ADD(X1, X2, X0), "  20 00 02 8b"},                       // add x0, x1, x2
+		{ADD(W1, W2, W0), "  20 00 02 0b"},                       // add w0, w1, w2
+		{ADD(SP, Uint64(16), SP), "  ff 43 00 91"},               // add sp, sp, #16
+		{ADD(X1, Uint64(4095), X0), "  20 fc 3f 91"},             // add x0, x1, #4095
+		{ADDS(X1, X2, X0), "  20 00 02 ab"},                      // adds x0, x1, x2
+		{SUB(X1, X2, X0), "  20 00 02 cb"},                       // sub x0, x1, x2
+		{SUB(W1, W2, W0), "  20 00 02 4b"},                       // sub w0, w1, w2
+		{SUB(SP, Uint64(32), SP), "  ff 83 00 d1"},               // sub sp, sp, #32
+		{SUBS(W3, Uint64(1), W4), "  64 04 00 71"},               // subs w4, w3, #1
+		{CMP(X1, X2), "  3f 00 02 eb"},                           // cmp x1, x2
+		{CMP(W1, W2), "  3f 00 02 6b"},                           // cmp w1, w2
+		{AND(X1, X2, X0), "  20 00 02 8a"},                       // and x0, x1, x2
+		{ORR(W1, W2, W0), "  20 00 02 2a"},                       // orr w0, w1, w2
+		{EOR(X1, X2, X0), "  20 00 02 ca"},                       // eor x0, x1, x2
+		{MOV(X9, X0), "  e0 03 09 aa"},                           // mov x0, x9
+		{MOV(W9, W0), "  e0 03 09 2a"},                           // mov w0, w9
+		{MOV(SP, X29), "  fd 03 00 91"},                          // mov x29, sp
+		{MOV(X29, SP), "  bf 03 00 91"},                          // mov sp, x29
+		{MOVZ(Uint64(0x1234), Shift(0), X0), "  80 46 82 d2"},    // movz x0, #0x1234
+		{MOVZ(Uint64(0x1234), Shift(16), W3), "  83 46 a2 52"},   // movz w3, #0x1234, lsl #16
+		{MOVK(Uint64(0xffff), Shift(48), X5), "  e5 ff ff f2"},   // movk x5, #0xffff, lsl #48
+		{MOVN(Uint64(0), Shift(0), X5), "  05 00 80 92"},         // movn x5, #0
+		{MUL(X1, X2, X0), "  20 7c 02 9b"},                       // mul x0, x1, x2
+		{MUL(W1, W2, W0), "  20 7c 02 1b"},                       // mul w0, w1, w2
+		{MADD(X1, X2, X3, X0), "  20 0c 02 9b"},                  // madd x0, x1, x2, x3
+		{SDIV(X1, X2, X0), "  20 0c c2 9a"},                      // sdiv x0, x1, x2
+		{UDIV(W1, W2, W0), "  20 08 c2 1a"},                      // udiv w0, w1, w2
+		{CSET(LT, X0), "  e0 a7 9f 9a"},                          // cset x0, lt
+		{CSET(EQ, W3), "  e3 17 9f 1a"},                          // cset w3, eq
+		{CSINC(NE, X1, X2, X0), "  20 14 82 9a"},                 // csinc x0, x1, x2, ne
+		{UXTB(W1, W0), "  20 1c 00 53"},                          // uxtb w0, w1
+		{UXTH(W1, W0), "  20 3c 00 53"},                          // uxth w0, w1
+		{SXTB(W1, W0), "  20 1c 00 13"},                          // sxtb w0, w1
+		{SXTH(W1, X0), "  20 3c 40 93"},                          // sxth x0, w1
+		{SXTW(W1, X0), "  20 7c 40 93"},                          // sxtw x0, w1
+		{LSL(X1, Uint8(3), X0), "  20 f0 7d d3"},                 // lsl x0, x1, #3
+		{LSL(W1, Uint8(2), W0), "  20 74 1e 53"},                 // lsl w0, w1, #2
+		{LDR(&DisplacedRegister{X1, 8}, X0), "  20 04 40 f9"},    // ldr x0, [x1, #8]
+		{LDR(&DisplacedRegister{X1, -8}, X0), "  20 80 5f f8"},   // ldur x0, [x1, #-8]
+		{LDR(&DisplacedRegister{X29, 4}, W0), "  a0 07 40 b9"},   // ldr w0, [x29, #4]
+		{LDR(&PreIndexedRegister{SP, -16}, X0), "  e0 0f 5f f8"}, // ldr x0, [sp, #-16]!
+		{LDR(&PostIndexedRegister{SP, 16}, X0), "  e0 07 41 f8"}, // ldr x0, [sp], #16
+		{LDR(&IndexedRegister{X1, X2, 3}, X0), "  20 78 62 f8"},  // ldr x0, [x1, x2, lsl #3]
+		{LDR(&IndexedRegister{X1, X2, 0}, X0), "  20 68 62 f8"},  // ldr x0, [x1, x2]
+		{LDR(&IndexedRegister{X1, X2, 2}, W0), "  20 78 62 b8"},  // ldr w0, [x1, x2, lsl #2]
+		{LDR(&DisplacedRegister{X1, 16}, D0), "  20 08 40 fd"},   // ldr d0, [x1, #16]
+		{LDR(&IndexedRegister{X1, X2, 3}, D3), "  23 78 62 fc"},  // ldr d3, [x1, x2, lsl #3]
+		{LDRB(&IndexedRegister{X1, X2, 0}, W0), "  20 68 62 38"}, // ldrb w0, [x1, x2]
+		{LDRB(&DisplacedRegister{X1, 3}, W0), "  20 0c 40 39"},   // ldrb w0, [x1, #3]
+		{LDRH(&IndexedRegister{X1, X2, 1}, W0), "  20 78 62 78"}, // ldrh w0, [x1, x2, lsl #1]
+		{LDRH(&DisplacedRegister{X1, 2}, W0), "  20 04 40 79"},   // ldrh w0, [x1, #2]
+		{STR(X0, &DisplacedRegister{X1, 8}), "  20 04 00 f9"},    // str x0, [x1, #8]
+		{STR(X0, &DisplacedRegister{X1, -24}), "  20 80 1e f8"},  // stur x0, [x1, #-24]
+		{STR(X0, &PreIndexedRegister{SP, -16}), "  e0 0f 1f f8"}, // str x0, [sp, #-16]!
+		{STR(D1, &PreIndexedRegister{SP, -16}), "  e1 0f 1f fc"}, // str d1, [sp, #-16]!
+		{STR(W0, &IndexedRegister{X1, X2, 2}), "  20 78 22 b8"},  // str w0, [x1, x2, lsl #2]
+		{STR(D0, &DisplacedRegister{X29, 24}), "  a0 0f 00 fd"},  // str d0, [x29, #24]
+		{STRB(W0, &IndexedRegister{X1, X2, 0}), "  20 68 22 38"}, // strb w0, [x1, x2]
+		{STRH(W0, &IndexedRegister{X1, X2, 1}), "  20 78 22 78"}, // strh w0, [x1, x2, lsl #1]
+		{BLR(X16), "  00 02 3f d6"},                              // blr x16
+		{BR(X16), "  00 02 1f d6"},                               // br x16
+		{RET(), "  c0 03 5f d6"},                                 // ret
+		{SVC(Uint64(0)), "  01 00 00 d4"},                        // svc #0
+		{BRK(Uint64(1)), "  20 00 20 d4"},                        // brk #0x1
+		{FADD(D1, D2, D0), "  20 28 62 1e"},                      // fadd d0, d1, d2
+		{FSUB(D1, D2, D0), "  20 38 62 1e"},                      // fsub d0, d1, d2
+		{FMUL(D1, D2, D0), "  20 08 62 1e"},                      // fmul d0, d1, d2
+		{FDIV(D1, D2, D0), "  20 18 62 1e"},                      // fdiv d0, d1, d2
+		{FMOV(D1, D0), "  20 40 60 1e"},                          // fmov d0, d1
+		{FMOV(X1, D0), "  20 00 67 9e"},                          // fmov d0, x1
+		{FMOV(D1, X0), "  20 00 66 9e"},                          // fmov x0, d1
+		{FCMP(D1, D2), "  20 20 62 1e"},                          // fcmp d1, d2
+		{SCVTF(X1, D0), "  20 00 62 9e"},                         // scvtf d0, x1
+		{UCVTF(X1, D0), "  20 00 63 9e"},                         // ucvtf d0, x1
+		{FCVTZS(D1, X0), "  20 00 78 9e"},                        // fcvtzs x0, d1
+		{FCVTZU(D1, X0), "  20 00 79 9e"},                        // fcvtzu x0, d1
+		{B(label), "  10 00 00 14"},                              // b #64
+		{B_cond(NE, label), "  01 02 00 54"},                     // b.ne #64
+		{CBZ(W3, label), "  03 02 00 34"},                        // cbz w3, #64
+		{CBNZ(X3, label), "  03 02 00 b5"},                       // cbnz x3, #64
+		{ADR(label, X0), "  00 02 00 10"},                        // adr x0, #64
+		{ADR(lib.NewFixedLabel("l", -3), X0), "  e0 ff ff 30"},   // adr x0, #-3
+		{BL(label), "  10 00 00 94"},                             // bl #64
+
+		{AND(X1, Uint64(0xff), X0), "  20 1c 40 92"},                   // and x0, x1, #0xff
+		{AND(W1, Uint64(0xf0f0f0f0), W0), "  20 cc 04 12"},             // and w0, w1, #0xf0f0f0f0
+		{AND(X1, Uint64(0x8000000000000001), X0), "  20 04 41 92"},     // and x0, x1, #0x8000000000000001
+		{ORR(X1, Uint64(0xffff0000), X0), "  20 3c 70 b2"},             // orr x0, x1, #0xffff0000
+		{ORR(X1, Uint64(0x5555555555555555), X0), "  20 f0 00 b2"},     // orr x0, x1, #0x5555555555555555
+		{EOR(W1, Uint64(1), W0), "  20 00 00 52"},                      // eor w0, w1, #0x1
+		{ANDS(W1, W2, W0), "  20 00 02 6a"},                            // ands w0, w1, w2
+		{TST(X1, Uint64(7)), "  3f 08 40 f2"},                          // tst x1, #0x7
+		{TST(W1, W2), "  3f 00 02 6a"},                                 // tst w1, w2
+		{LSR(X1, Uint8(3), X0), "  20 fc 43 d3"},                       // lsr x0, x1, #3
+		{LSR(W1, Uint8(31), W0), "  20 7c 1f 53"},                      // lsr w0, w1, #31
+		{ASR(X1, Uint8(63), X0), "  20 fc 7f 93"},                      // asr x0, x1, #63
+		{ASR(W1, Uint8(4), W0), "  20 7c 04 13"},                       // asr w0, w1, #4
+		{LSL(X1, X2, X0), "  20 20 c2 9a"},                             // lsl x0, x1, x2
+		{LSR(W1, W2, W0), "  20 24 c2 1a"},                             // lsr w0, w1, w2
+		{ASR(X1, X2, X0), "  20 28 c2 9a"},                             // asr x0, x1, x2
+		{CSEL(LT, X1, X2, X0), "  20 b0 82 9a"},                        // csel x0, x1, x2, lt
+		{CSEL(EQ, W1, W2, W0), "  20 00 82 1a"},                        // csel w0, w1, w2, eq
+		{MSUB(X1, X2, X3, X0), "  20 8c 02 9b"},                        // msub x0, x1, x2, x3
+		{LDP(&DisplacedRegister{SP, 16}, X29, X30), "  fd 7b 41 a9"},   // ldp x29, x30, [sp, #16]
+		{LDP(&PostIndexedRegister{SP, 16}, X29, X30), "  fd 7b c1 a8"}, // ldp x29, x30, [sp], #16
+		{STP(X29, X30, &PreIndexedRegister{SP, -16}), "  fd 7b bf a9"}, // stp x29, x30, [sp, #-16]!
+		{STP(W1, W2, &DisplacedRegister{X0, -256}), "  01 08 20 29"},   // stp w1, w2, [x0, #-256]
+		{LDP(&DisplacedRegister{X0, 4}, W1, W2), "  01 88 40 29"},      // ldp w1, w2, [x0, #4]
+		{STP(D8, D9, &DisplacedRegister{SP, 504}), "  e8 a7 1f 6d"},    // stp d8, d9, [sp, #504]
+		{LDP(&PreIndexedRegister{X1, -8}, D8, D9), "  28 a4 ff 6d"},    // ldp d8, d9, [x1, #-8]!
+	}
+	for _, c := range cases {
+		if err := lib.Instructions([]lib.Instruction{c.Instruction}).Resolve(0); err != nil {
+			t.Fatal(err)
+		}
+		unit, err := c.Instruction.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.String() != c.Expected {
+			t.Fatal("Expecting", c.Expected, "got", unit, "in", c.Instruction)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package encoding
+
+import "math/bits"
+
+// Encodes the immediate as a logical immediate for a register of regSize
+// bits, returning N:immr:imms. Logical immediates are a run of ones, rotated
+// right by immr, in an element of 2, 4, 8, 16, 32 or 64 bits that gets
+// repeated across the register. Zero and all ones can't be encoded.
+func EncodeBitmaskImmediate(imm uint64, regSize uint) (uint64, bool) {
+	if regSize == 32 {
+		if imm>>32 != 0 {
+			return 0, false
+		}
+		imm |= imm << 32
+	}
+	if imm == 0 || imm == ^uint64(0) {
+		return 0, false
+	}
+
+	// Find the smallest element that repeats
+	size := uint(64)
+	for size > 2 {
+		half := size / 2
+		mask := (uint64(1) << half) - 1
+		if imm&mask != (imm>>half)&mask {
+			break
+		}
+		size = half
+	}
+	mask := ^uint64(0) >> (64 - size)
+	imm &= mask
+
+	// Find the rotation and the number of ones
+	var rotation, ones uint
+	if isShiftedMask(imm) {
+		rotation = uint(bits.TrailingZeros64(imm))
+		ones = uint(bits.TrailingZeros64(^(imm >> rotation)))
+	} else {
+		// The run of ones wraps around the end of the element
+		imm |= ^mask
+		if !isShiftedMask(^imm) {
+			return 0, false
+		}
+		leadingOnes := uint(bits.LeadingZeros64(^imm))
+		rotation = 64 - leadingOnes
+		ones = leadingOnes + uint(bits.TrailingZeros64(^imm)) - (64 - size)
+	}
+	immr := (size - rotation) & (size - 1)
+	// The element size is encoded in the high bits of N:imms as 1..10xxxx
+	nimms := (^(size - 1) << 1) | (ones - 1)
+	n := ((nimms >> 6) & 1) ^ 1
+	return uint64(n)<<12 | uint64(immr)<<6 | uint64(nimms&0x3f), true
+}
+
+// Returns whether the value is a single, non-empty run of ones.
+func isShiftedMask(v uint64) bool {
+	if v == 0 {
+		return false
+	}
+	filled := v | (v - 1)
+	return (filled+1)&filled == 0
+}
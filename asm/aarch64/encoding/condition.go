@@ -1,5 +1,7 @@
 package encoding
 
+import "github.com/bspaans/jit-compiler/lib"
+
 type Condition uint8
 
 const (
@@ -34,3 +36,21 @@ const (
 	// Always
 	AL Condition = 0b1110
 )
+
+var conditionNames = []string{"eq", "ne", "hs", "lo", "mi", "pl", "vs", "vc", "hi", "ls", "ge", "lt", "gt", "le", "al", "nv"}
+
+func (c Condition) Type() lib.Type {
+	return lib.T_Uint8
+}
+func (c Condition) String() string {
+	return conditionNames[c&0xf]
+}
+func (c Condition) Width() lib.Size {
+	return lib.BYTE
+}
+
+// Returns the condition that holds when c doesn't. For floating point
+// comparisons the inverse also holds when the operands are unordered.
+func (c Condition) Invert() Condition {
+	return c ^ 1
+}
@@ -95,3 +95,17 @@ func (i Int32) Encode() []uint8 {
 func (t Int32) Width() lib.Size {
 	return lib.DOUBLE
 }
+
+// The number of bits an immediate gets shifted left, e.g. the hw field of
+// MOVZ and MOVK.
+type Shift uint8
+
+func (i Shift) Type() lib.Type {
+	return lib.T_Uint8
+}
+func (i Shift) String() string {
+	return fmt.Sprintf("lsl #%d", i)
+}
+func (t Shift) Width() lib.Size {
+	return lib.BYTE
+}
@@ -0,0 +1,82 @@
+package encoding
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// A memory operand at a fixed offset from a base register, e.g. [x29, #16]
+type DisplacedRegister struct {
+	Register     *Register
+	Displacement int32
+}
+
+func (d *DisplacedRegister) Type() lib.Type {
+	return lib.T_DisplacedRegister
+}
+func (d *DisplacedRegister) String() string {
+	if d.Displacement == 0 {
+		return fmt.Sprintf("[%s]", d.Register.String())
+	}
+	return fmt.Sprintf("[%s, #%d]", d.Register.String(), d.Displacement)
+}
+func (d *DisplacedRegister) Width() lib.Size {
+	return lib.QUADWORD
+}
+
+// A memory operand that updates the base register before the access,
+// e.g. [sp, #-16]!
+type PreIndexedRegister struct {
+	Register     *Register
+	Displacement int32
+}
+
+func (d *PreIndexedRegister) Type() lib.Type {
+	return lib.T_DisplacedRegister
+}
+func (d *PreIndexedRegister) String() string {
+	return fmt.Sprintf("[%s, #%d]!", d.Register.String(), d.Displacement)
+}
+func (d *PreIndexedRegister) Width() lib.Size {
+	return lib.QUADWORD
+}
+
+// A memory operand that updates the base register after the access,
+// e.g. [sp], #16
+type PostIndexedRegister struct {
+	Register     *Register
+	Displacement int32
+}
+
+func (d *PostIndexedRegister) Type() lib.Type {
+	return lib.T_DisplacedRegister
+}
+func (d *PostIndexedRegister) String() string {
+	return fmt.Sprintf("[%s], #%d", d.Register.String(), d.Displacement)
+}
+func (d *PostIndexedRegister) Width() lib.Size {
+	return lib.QUADWORD
+}
+
+// A memory operand with the address in a base register plus an index
+// register that is optionally shifted left by the log2 of the size of the
+// access, e.g. [x0, x1, lsl #3]
+type IndexedRegister struct {
+	Register *Register
+	Index    *Register
+	Shift    uint8
+}
+
+func (d *IndexedRegister) Type() lib.Type {
+	return lib.T_SIBRegister
+}
+func (d *IndexedRegister) String() string {
+	if d.Shift == 0 {
+		return fmt.Sprintf("[%s, %s]", d.Register.String(), d.Index.String())
+	}
+	return fmt.Sprintf("[%s, %s, lsl #%d]", d.Register.String(), d.Index.String(), d.Shift)
+}
+func (d *IndexedRegister) Width() lib.Size {
+	return lib.QUADWORD
+}
@@ -13,6 +13,8 @@ type Opcode struct {
 	Operands []OpcodeChunk
 }
 
+// Encodes the opcode. The operands are given in the same order as the
+// non-exact chunks in the Opcode, i.e. most significant bits first.
 func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 	result := uint32(0)
 	offset := 0
@@ -20,27 +22,18 @@ func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 	for i := len(o.Operands) - 1; i >= 0; i-- {
 		op := o.Operands[i]
 		value := op.Value
-		switch op.OperandType {
-		case OT_Exact:
-		case OT_ImmediateValue:
-			operand := ops[operandIx]
-			switch n := operand.(type) {
-			case Uint64:
-				value = uint64(n)
-			default:
-				return nil, fmt.Errorf("Expecting immediate value in %s, got %s", o.String(), ops)
+		if op.OperandType != OT_Exact {
+			if operandIx < 0 {
+				return nil, fmt.Errorf("Missing operand in %s, got %s", o.String(), ops)
 			}
-			operandIx--
-		case OT_Register64, OT_Register32:
-			operand := ops[operandIx]
-			if reg, ok := operand.(*Register); ok {
-				value = uint64(reg.Encode())
-				operandIx--
-			} else {
-				return nil, fmt.Errorf("Expecting register in %s, got %s", o.String(), ops)
+			v, err := op.encode(ops[operandIx])
+			if err != nil {
+				return nil, fmt.Errorf("%s in %s, got %s", err.Error(), o.String(), ops)
 			}
+			value = v
+			operandIx--
 		}
-		result = result + (uint32(value) << offset)
+		result = result + (uint32(value&op.mask()) << offset)
 		offset += int(op.Size)
 	}
 	bytes := make([]byte, 4)
@@ -59,22 +52,17 @@ func (o *Opcode) GetOperands() []OperandType {
 }
 
 func (o *Opcode) MatchesOperands(operands []lib.Operand) bool {
-	expected := o.GetOperands()
-	if len(expected) != len(operands) {
+	chunks := []OpcodeChunk{}
+	for _, op := range o.Operands {
+		if op.OperandType != OT_Exact {
+			chunks = append(chunks, op)
+		}
+	}
+	if len(chunks) != len(operands) {
 		return false
 	}
-	for i, exp := range expected {
-		op := operands[i]
-		switch exp {
-		case OT_Register64:
-			if op.Type() != lib.T_Register || op.Width() != lib.QUADWORD {
-				return false
-			}
-		case OT_ImmediateValue:
-			if op.Type() != lib.T_Uint64 {
-				return false
-			}
-		default:
+	for i, chunk := range chunks {
+		if operands[i] == nil || !chunk.matches(operands[i]) {
 			return false
 		}
 	}
@@ -84,7 +72,9 @@ func (o *Opcode) MatchesOperands(operands []lib.Operand) bool {
 func (o *Opcode) String() string {
 	args := []string{}
 	for _, ops := range o.Operands {
-		args = append(args, ops.String())
+		if ops.OperandType != OT_Exact {
+			args = append(args, ops.String())
+		}
 	}
 	return o.Name + " " + strings.Join(args, ", ")
 }
@@ -97,8 +87,44 @@ const (
 	OT_Register32     OperandType = iota
 	OT_Register64     OperandType = iota
 	OT_ImmediateValue OperandType = iota
+	// A double precision floating point register, e.g. d0
+	OT_FloatRegister64 OperandType = iota
+	// The amount a 16 bit immediate gets shifted left, e.g. lsl #16
+	OT_Shift OperandType = iota
+	// A condition code, e.g. eq
+	OT_Condition OperandType = iota
+	// A word aligned, PC relative label
+	OT_Label OperandType = iota
+	// A byte aligned, PC relative label as used by ADR (immlo:10000:immhi)
+	OT_LabelADR OperandType = iota
+	// [Xn, #imm]; the unsigned immediate is scaled by the size of the access
+	OT_MemoryOffset OperandType = iota
+	// [Xn, #imm]; a signed, unscaled 9 bit immediate
+	OT_MemoryUnscaled OperandType = iota
+	// [Xn, #imm]!
+	OT_MemoryPreIndex OperandType = iota
+	// [Xn], #imm
+	OT_MemoryPostIndex OperandType = iota
+	// [Xn, Xm, lsl #amount]
+	OT_MemoryRegisterOffset OperandType = iota
+	// A logical immediate, i.e. a rotated run of ones repeated across the
+	// register, encoded as N:immr:imms. Value holds the register size in bits.
+	OT_BitmaskImmediate OperandType = iota
+	// The signed 7 bit immediate of [Xn, #imm], scaled by the size of each
+	// register in a load or store pair.
+	OT_MemoryPairOffset OperandType = iota
+	// The signed 7 bit immediate of [Xn, #imm]!
+	OT_MemoryPairPreIndex OperandType = iota
+	// The signed 7 bit immediate of [Xn], #imm
+	OT_MemoryPairPostIndex OperandType = iota
+	// The base register Xn of a memory operand. The load and store pair
+	// instructions encode the immediate and the base register separately.
+	OT_MemoryBase OperandType = iota
 )
 
+// An OpcodeChunk describes a number of consecutive bits in an
+// instruction. Chunks that aren't OT_Exact get their value from an operand.
+// For memory operands Value holds the size of the access in bytes.
 type OpcodeChunk struct {
 	OperandType
 	Size  uint8 // Size of encoding in bits
@@ -106,16 +132,250 @@ type OpcodeChunk struct {
 }
 
 var (
-	OP_Xd    = OpcodeChunk{OT_Register64, 5, 0}
-	OP_Xn    = OpcodeChunk{OT_Register64, 5, 0}
-	OP_Xm    = OpcodeChunk{OT_Register64, 5, 0}
-	OP_Wd    = OpcodeChunk{OT_Register32, 5, 0}
-	OP_Wn    = OpcodeChunk{OT_Register32, 5, 0}
-	OP_Wm    = OpcodeChunk{OT_Register32, 5, 0}
-	OP_Imm12 = OpcodeChunk{OT_ImmediateValue, 12, 0}
-	OP_Imm16 = OpcodeChunk{OT_ImmediateValue, 16, 0}
+	OP_Xd    = OpcodeChunk{OperandType: OT_Register64, Size: 5}
+	OP_Xn    = OpcodeChunk{OperandType: OT_Register64, Size: 5}
+	OP_Xm    = OpcodeChunk{OperandType: OT_Register64, Size: 5}
+	OP_Xa    = OpcodeChunk{OperandType: OT_Register64, Size: 5}
+	OP_Wd    = OpcodeChunk{OperandType: OT_Register32, Size: 5}
+	OP_Wn    = OpcodeChunk{OperandType: OT_Register32, Size: 5}
+	OP_Wm    = OpcodeChunk{OperandType: OT_Register32, Size: 5}
+	OP_Wa    = OpcodeChunk{OperandType: OT_Register32, Size: 5}
+	OP_Dd    = OpcodeChunk{OperandType: OT_FloatRegister64, Size: 5}
+	OP_Dn    = OpcodeChunk{OperandType: OT_FloatRegister64, Size: 5}
+	OP_Dm    = OpcodeChunk{OperandType: OT_FloatRegister64, Size: 5}
+	OP_Imm6  = OpcodeChunk{OperandType: OT_ImmediateValue, Size: 6}
+	OP_Imm12 = OpcodeChunk{OperandType: OT_ImmediateValue, Size: 12}
+	OP_Imm16 = OpcodeChunk{OperandType: OT_ImmediateValue, Size: 16}
+	OP_Shift = OpcodeChunk{OperandType: OT_Shift, Size: 2}
+	OP_Cond  = OpcodeChunk{OperandType: OT_Condition, Size: 4}
+	OP_Rel19 = OpcodeChunk{OperandType: OT_Label, Size: 19}
+	OP_Rel26 = OpcodeChunk{OperandType: OT_Label, Size: 26}
+	OP_ADR   = OpcodeChunk{OperandType: OT_LabelADR, Size: 26}
+	OP_Rn    = OpcodeChunk{OperandType: OT_MemoryBase, Size: 5}
+
+	OP_Bitmask32 = OpcodeChunk{OperandType: OT_BitmaskImmediate, Size: 13, Value: 32}
+	OP_Bitmask64 = OpcodeChunk{OperandType: OT_BitmaskImmediate, Size: 13, Value: 64}
 )
 
 func OP_Exact(size uint8, value uint64, description ...string) OpcodeChunk {
-	return OpcodeChunk{OT_Exact, size, value}
+	return OpcodeChunk{OperandType: OT_Exact, Size: size, Value: value}
+}
+
+// [Xn, #imm] for an access of the given number of bytes, encoded as imm12:Rn.
+// Offsets that are negative or unaligned need the unscaled encoding.
+func OP_Mem(bytes uint64) OpcodeChunk {
+	return OpcodeChunk{OperandType: OT_MemoryOffset, Size: 17, Value: bytes}
+}
+func OP_MemUnscaled() OpcodeChunk {
+	return OpcodeChunk{OperandType: OT_MemoryUnscaled, Size: 16}
+}
+func OP_MemPreIndex() OpcodeChunk {
+	return OpcodeChunk{OperandType: OT_MemoryPreIndex, Size: 16}
+}
+func OP_MemPostIndex() OpcodeChunk {
+	return OpcodeChunk{OperandType: OT_MemoryPostIndex, Size: 16}
+}
+func OP_MemRegister(bytes uint64) OpcodeChunk {
+	return OpcodeChunk{OperandType: OT_MemoryRegisterOffset, Size: 16, Value: bytes}
+}
+
+// The immediate part of [Xn, #imm], [Xn, #imm]! and [Xn], #imm in a load or
+// store pair of registers of the given number of bytes (see OP_Rn).
+func OP_MemPair(bytes uint64) OpcodeChunk {
+	return OpcodeChunk{OperandType: OT_MemoryPairOffset, Size: 7, Value: bytes}
+}
+func OP_MemPairPreIndex(bytes uint64) OpcodeChunk {
+	return OpcodeChunk{OperandType: OT_MemoryPairPreIndex, Size: 7, Value: bytes}
+}
+func OP_MemPairPostIndex(bytes uint64) OpcodeChunk {
+	return OpcodeChunk{OperandType: OT_MemoryPairPostIndex, Size: 7, Value: bytes}
+}
+
+func (c OpcodeChunk) mask() uint64 {
+	return (uint64(1) << c.Size) - 1
+}
+
+// Returns the value of an immediate operand.
+func immediate(op lib.Operand) (uint64, bool) {
+	switch n := op.(type) {
+	case Uint8:
+		return uint64(n), true
+	case Uint16:
+		return uint64(n), true
+	case Uint32:
+		return uint64(n), true
+	case Uint64:
+		return uint64(n), true
+	}
+	return 0, false
+}
+
+func (c OpcodeChunk) matches(op lib.Operand) bool {
+	switch c.OperandType {
+	case OT_Register32, OT_Register64:
+		reg, ok := op.(*Register)
+		if !ok || reg.Float {
+			return false
+		}
+		if c.OperandType == OT_Register32 {
+			return reg.Size == lib.DOUBLE
+		}
+		return reg.Size == lib.QUADWORD
+	case OT_FloatRegister64:
+		reg, ok := op.(*Register)
+		return ok && reg.Float
+	case OT_ImmediateValue:
+		v, ok := immediate(op)
+		return ok && v <= c.mask()
+	case OT_Shift:
+		s, ok := op.(Shift)
+		return ok && s%16 == 0 && uint64(s/16) <= c.mask()
+	case OT_Condition:
+		_, ok := op.(Condition)
+		return ok
+	case OT_Label, OT_LabelADR:
+		return op.Type() == lib.T_Label
+	case OT_MemoryOffset:
+		m, ok := op.(*DisplacedRegister)
+		return ok && m.Displacement >= 0 && uint64(m.Displacement)%c.Value == 0 && uint64(m.Displacement)/c.Value < 4096
+	case OT_MemoryUnscaled:
+		m, ok := op.(*DisplacedRegister)
+		return ok && m.Displacement >= -256 && m.Displacement < 256
+	case OT_MemoryPreIndex:
+		m, ok := op.(*PreIndexedRegister)
+		return ok && m.Displacement >= -256 && m.Displacement < 256
+	case OT_MemoryPostIndex:
+		m, ok := op.(*PostIndexedRegister)
+		return ok && m.Displacement >= -256 && m.Displacement < 256
+	case OT_MemoryRegisterOffset:
+		m, ok := op.(*IndexedRegister)
+		return ok && m.Index.Size == lib.QUADWORD && (m.Shift == 0 || uint64(1)<<m.Shift == c.Value)
+	case OT_BitmaskImmediate:
+		v, ok := immediate(op)
+		if !ok {
+			return false
+		}
+		_, ok = EncodeBitmaskImmediate(v, uint(c.Value))
+		return ok
+	case OT_MemoryPairOffset, OT_MemoryPairPreIndex, OT_MemoryPairPostIndex:
+		displacement, ok := c.pairDisplacement(op)
+		return ok && displacement%int32(c.Value) == 0 && displacement/int32(c.Value) >= -64 && displacement/int32(c.Value) < 64
+	case OT_MemoryBase:
+		_, ok := memoryBase(op)
+		return ok
+	}
+	return false
+}
+
+// Returns the displacement of a memory operand if it has the addressing mode
+// of the chunk.
+func (c OpcodeChunk) pairDisplacement(op lib.Operand) (int32, bool) {
+	switch m := op.(type) {
+	case *DisplacedRegister:
+		return m.Displacement, c.OperandType == OT_MemoryPairOffset
+	case *PreIndexedRegister:
+		return m.Displacement, c.OperandType == OT_MemoryPairPreIndex
+	case *PostIndexedRegister:
+		return m.Displacement, c.OperandType == OT_MemoryPairPostIndex
+	}
+	return 0, false
+}
+
+func memoryBase(op lib.Operand) (*Register, bool) {
+	switch m := op.(type) {
+	case *DisplacedRegister:
+		return m.Register, true
+	case *PreIndexedRegister:
+		return m.Register, true
+	case *PostIndexedRegister:
+		return m.Register, true
+	}
+	return nil, false
+}
+
+// Returns the value for the bits in the chunk.
+func (c OpcodeChunk) encode(op lib.Operand) (uint64, error) {
+	switch c.OperandType {
+	case OT_Register32, OT_Register64, OT_FloatRegister64:
+		if reg, ok := op.(*Register); ok {
+			return uint64(reg.Encode()), nil
+		}
+		return 0, fmt.Errorf("Expecting register")
+	case OT_ImmediateValue:
+		if v, ok := immediate(op); ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("Expecting immediate value")
+	case OT_Shift:
+		if s, ok := op.(Shift); ok {
+			return uint64(s / 16), nil
+		}
+		return 0, fmt.Errorf("Expecting shift")
+	case OT_Condition:
+		if cond, ok := op.(Condition); ok {
+			return uint64(cond), nil
+		}
+		return 0, fmt.Errorf("Expecting condition")
+	case OT_Label, OT_LabelADR:
+		displacement := int64(0)
+		if d, ok := op.(Int32); ok {
+			displacement = int64(d)
+		} else if op.Type() != lib.T_Label {
+			return 0, fmt.Errorf("Expecting label")
+		}
+		if c.OperandType == OT_LabelADR {
+			if displacement < -(1<<20) || displacement >= 1<<20 {
+				return 0, fmt.Errorf("Label out of range")
+			}
+			return (uint64(displacement&0b11) << 24) | (0b10000 << 19) | (uint64(displacement>>2) & 0x7ffff), nil
+		}
+		if displacement%4 != 0 {
+			return 0, fmt.Errorf("Label not word aligned")
+		}
+		if displacement < -(2<<c.Size) || displacement >= 2<<c.Size {
+			return 0, fmt.Errorf("Label out of range")
+		}
+		return uint64(displacement >> 2), nil
+	case OT_MemoryOffset:
+		if m, ok := op.(*DisplacedRegister); ok {
+			return (uint64(m.Displacement)/c.Value)<<5 | uint64(m.Register.Encode()), nil
+		}
+	case OT_MemoryUnscaled:
+		if m, ok := op.(*DisplacedRegister); ok {
+			return (uint64(m.Displacement)&0x1ff)<<7 | 0b00<<5 | uint64(m.Register.Encode()), nil
+		}
+	case OT_MemoryPreIndex:
+		if m, ok := op.(*PreIndexedRegister); ok {
+			return (uint64(m.Displacement)&0x1ff)<<7 | 0b11<<5 | uint64(m.Register.Encode()), nil
+		}
+	case OT_MemoryPostIndex:
+		if m, ok := op.(*PostIndexedRegister); ok {
+			return (uint64(m.Displacement)&0x1ff)<<7 | 0b01<<5 | uint64(m.Register.Encode()), nil
+		}
+	case OT_MemoryRegisterOffset:
+		if m, ok := op.(*IndexedRegister); ok {
+			s := uint64(0)
+			if m.Shift != 0 {
+				s = 1
+			}
+			// option 011 is lsl (uxtx)
+			return uint64(m.Index.Encode())<<11 | 0b011<<8 | s<<7 | 0b10<<5 | uint64(m.Register.Encode()), nil
+		}
+	case OT_BitmaskImmediate:
+		if v, ok := immediate(op); ok {
+			if bits, ok := EncodeBitmaskImmediate(v, uint(c.Value)); ok {
+				return bits, nil
+			}
+			return 0, fmt.Errorf("Immediate can't be encoded as a bitmask")
+		}
+	case OT_MemoryPairOffset, OT_MemoryPairPreIndex, OT_MemoryPairPostIndex:
+		if displacement, ok := c.pairDisplacement(op); ok {
+			return uint64(displacement / int32(c.Value)), nil
+		}
+	case OT_MemoryBase:
+		if reg, ok := memoryBase(op); ok {
+			return uint64(reg.Encode()), nil
+		}
+	}
+	return 0, fmt.Errorf("Unexpected operand %s for %s", op, c.OperandType)
 }
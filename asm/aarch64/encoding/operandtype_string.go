@@ -12,11 +12,26 @@ func _() {
 	_ = x[OT_Register32-1]
 	_ = x[OT_Register64-2]
 	_ = x[OT_ImmediateValue-3]
+	_ = x[OT_FloatRegister64-4]
+	_ = x[OT_Shift-5]
+	_ = x[OT_Condition-6]
+	_ = x[OT_Label-7]
+	_ = x[OT_LabelADR-8]
+	_ = x[OT_MemoryOffset-9]
+	_ = x[OT_MemoryUnscaled-10]
+	_ = x[OT_MemoryPreIndex-11]
+	_ = x[OT_MemoryPostIndex-12]
+	_ = x[OT_MemoryRegisterOffset-13]
+	_ = x[OT_BitmaskImmediate-14]
+	_ = x[OT_MemoryPairOffset-15]
+	_ = x[OT_MemoryPairPreIndex-16]
+	_ = x[OT_MemoryPairPostIndex-17]
+	_ = x[OT_MemoryBase-18]
 }
 
-const _OperandType_name = "OT_ExactOT_Register32OT_Register64OT_ImmediateValue"
+const _OperandType_name = "OT_ExactOT_Register32OT_Register64OT_ImmediateValueOT_FloatRegister64OT_ShiftOT_ConditionOT_LabelOT_LabelADROT_MemoryOffsetOT_MemoryUnscaledOT_MemoryPreIndexOT_MemoryPostIndexOT_MemoryRegisterOffsetOT_BitmaskImmediateOT_MemoryPairOffsetOT_MemoryPairPreIndexOT_MemoryPairPostIndexOT_MemoryBase"
 
-var _OperandType_index = [...]uint8{0, 8, 21, 34, 51}
+var _OperandType_index = [...]uint16{0, 8, 21, 34, 51, 69, 77, 89, 97, 108, 123, 140, 157, 175, 198, 217, 236, 257, 279, 292}
 
 func (i OperandType) String() string {
 	if i < 0 || i >= OperandType(len(_OperandType_index)-1) {
@@ -8,6 +8,7 @@ type Register struct {
 	Name     string
 	Register uint8
 	Size     Size
+	Float    bool // a SIMD and floating point register
 }
 
 func NewRegister(name string, register uint8, size Size) *Register {
@@ -18,6 +19,15 @@ func NewRegister(name string, register uint8, size Size) *Register {
 	}
 }
 
+func NewFloatRegister(name string, register uint8, size Size) *Register {
+	return &Register{
+		Name:     name,
+		Register: register,
+		Size:     size,
+		Float:    true,
+	}
+}
+
 func (r *Register) Encode() uint8 {
 	return r.Register
 }
@@ -30,24 +40,43 @@ func (r *Register) Type() Type {
 func (r *Register) Width() Size {
 	return r.Size
 }
+
+// Returns the W register for operands of 32 bits or less, and the X
+// register otherwise. There are no 8 and 16 bit general purpose registers.
 func (r *Register) ForOperandWidth(w Size) *Register {
-	// TODO: support more sizes
-	if w == DOUBLE {
+	if r.Float {
+		return r
+	}
+	if w == BYTE || w == WORD || w == DOUBLE {
 		return r.Get32BitRegister()
 	}
-	return r
+	return r.Get64BitRegister()
 }
 func (r *Register) Get32BitRegister() *Register {
+	if r.Register == 31 {
+		if r == SP || r == WSP {
+			return WSP
+		}
+		return WZR
+	}
 	return Registers32[r.Register]
 }
+func (r *Register) Get64BitRegister() *Register {
+	if r.Register == 31 {
+		if r == SP || r == WSP {
+			return SP
+		}
+		return XZR
+	}
+	return Registers64[r.Register]
+}
 
 func Get64BitRegisterByIndex(ix uint8) *Register {
 	return Registers64[ix]
 }
 
-// TODO
 func GetFloatingPointRegisterByIndex(ix uint8) *Register {
-	return nil
+	return FloatRegisters64[ix]
 }
 
 var Registers64 []*Register = []*Register{
@@ -64,6 +93,14 @@ var Registers32 []*Register = []*Register{
 	W25, W26, W27, W28, W29, W30,
 }
 
+// The lower 64 bits of the SIMD and floating point registers
+var FloatRegisters64 []*Register = []*Register{
+	D0, D1, D2, D3, D4, D5, D6, D7, D8,
+	D9, D10, D11, D12, D13, D14, D15, D16,
+	D17, D18, D19, D20, D21, D22, D23, D24,
+	D25, D26, D27, D28, D29, D30, D31,
+}
+
 var (
 	X0  *Register = NewRegister("x0", 0, QUADWORD)
 	X1  *Register = NewRegister("x1", 1, QUADWORD)
@@ -99,8 +136,8 @@ var (
 
 	// TODO program counter
 
-	SP  *Register = NewRegister("sp", 31, QUADWORD) // stack pointer. MUST BE 16 byte aligned when accessing memory
-	XZR *Register = NewRegister("zr", 31, QUADWORD) // zero register
+	SP  *Register = NewRegister("sp", 31, QUADWORD)  // stack pointer. MUST BE 16 byte aligned when accessing memory
+	XZR *Register = NewRegister("xzr", 31, QUADWORD) // zero register
 
 	W0  *Register = NewRegister("w0", 0, DOUBLE)
 	W1  *Register = NewRegister("w1", 1, DOUBLE)
@@ -136,5 +173,36 @@ var (
 	WSP *Register = NewRegister("wsp", 31, DOUBLE) // current stack pointer
 	WZR *Register = NewRegister("wzr", 31, DOUBLE) // zero register
 
-	// TODO simd and FP registers
+	D0  *Register = NewFloatRegister("d0", 0, QUADWORD)
+	D1  *Register = NewFloatRegister("d1", 1, QUADWORD)
+	D2  *Register = NewFloatRegister("d2", 2, QUADWORD)
+	D3  *Register = NewFloatRegister("d3", 3, QUADWORD)
+	D4  *Register = NewFloatRegister("d4", 4, QUADWORD)
+	D5  *Register = NewFloatRegister("d5", 5, QUADWORD)
+	D6  *Register = NewFloatRegister("d6", 6, QUADWORD)
+	D7  *Register = NewFloatRegister("d7", 7, QUADWORD)
+	D8  *Register = NewFloatRegister("d8", 8, QUADWORD)
+	D9  *Register = NewFloatRegister("d9", 9, QUADWORD)
+	D10 *Register = NewFloatRegister("d10", 10, QUADWORD)
+	D11 *Register = NewFloatRegister("d11", 11, QUADWORD)
+	D12 *Register = NewFloatRegister("d12", 12, QUADWORD)
+	D13 *Register = NewFloatRegister("d13", 13, QUADWORD)
+	D14 *Register = NewFloatRegister("d14", 14, QUADWORD)
+	D15 *Register = NewFloatRegister("d15", 15, QUADWORD)
+	D16 *Register = NewFloatRegister("d16", 16, QUADWORD)
+	D17 *Register = NewFloatRegister("d17", 17, QUADWORD)
+	D18 *Register = NewFloatRegister("d18", 18, QUADWORD)
+	D19 *Register = NewFloatRegister("d19", 19, QUADWORD)
+	D20 *Register = NewFloatRegister("d20", 20, QUADWORD)
+	D21 *Register = NewFloatRegister("d21", 21, QUADWORD)
+	D22 *Register = NewFloatRegister("d22", 22, QUADWORD)
+	D23 *Register = NewFloatRegister("d23", 23, QUADWORD)
+	D24 *Register = NewFloatRegister("d24", 24, QUADWORD)
+	D25 *Register = NewFloatRegister("d25", 25, QUADWORD)
+	D26 *Register = NewFloatRegister("d26", 26, QUADWORD)
+	D27 *Register = NewFloatRegister("d27", 27, QUADWORD)
+	D28 *Register = NewFloatRegister("d28", 28, QUADWORD)
+	D29 *Register = NewFloatRegister("d29", 29, QUADWORD)
+	D30 *Register = NewFloatRegister("d30", 30, QUADWORD)
+	D31 *Register = NewFloatRegister("d31", 31, QUADWORD)
 )
@@ -14,6 +14,173 @@ var ADD = []*Opcode{
 var ADDS = []*Opcode{
 	ADDS_Wd_Wn_imm12,
 	ADDS_Xd_Xn_imm12,
+	ADDS_Wd_Wn_Wm,
+	ADDS_Xd_Xn_Xm,
+}
+
+var ADR = []*Opcode{
+	ADR_Xd_label,
+}
+
+var AND = []*Opcode{
+	AND_Wd_Wn_imm,
+	AND_Xd_Xn_imm,
+	AND_Wd_Wn_Wm,
+	AND_Xd_Xn_Xm,
+}
+
+var ANDS = []*Opcode{
+	ANDS_Wd_Wn_imm,
+	ANDS_Xd_Xn_imm,
+	ANDS_Wd_Wn_Wm,
+	ANDS_Xd_Xn_Xm,
+}
+
+var ASRV = []*Opcode{
+	ASRV_Wd_Wn_Wm,
+	ASRV_Xd_Xn_Xm,
+}
+
+var B = []*Opcode{
+	B_label,
+}
+
+var B_cond = []*Opcode{
+	B_cond_label,
+}
+
+var BL = []*Opcode{
+	BL_label,
+}
+
+var BLR = []*Opcode{
+	BLR_Xn,
+}
+
+var BR = []*Opcode{
+	BR_Xn,
+}
+
+var BRK = []*Opcode{
+	BRK_imm16,
+}
+
+var CBNZ = []*Opcode{
+	CBNZ_Wt_label,
+	CBNZ_Xt_label,
+}
+
+var CBZ = []*Opcode{
+	CBZ_Wt_label,
+	CBZ_Xt_label,
+}
+
+var CSINC = []*Opcode{
+	CSINC_Wd_Wn_Wm_cond,
+	CSINC_Xd_Xn_Xm_cond,
+}
+
+var CSEL = []*Opcode{
+	CSEL_Wd_Wn_Wm_cond,
+	CSEL_Xd_Xn_Xm_cond,
+}
+
+var EOR = []*Opcode{
+	EOR_Wd_Wn_imm,
+	EOR_Xd_Xn_imm,
+	EOR_Wd_Wn_Wm,
+	EOR_Xd_Xn_Xm,
+}
+
+var FADD = []*Opcode{
+	FADD_Dd_Dn_Dm,
+}
+
+var FCMP = []*Opcode{
+	FCMP_Dn_Dm,
+}
+
+var FCVTZS = []*Opcode{
+	FCVTZS_Xd_Dn,
+}
+
+var FCVTZU = []*Opcode{
+	FCVTZU_Xd_Dn,
+}
+
+var FDIV = []*Opcode{
+	FDIV_Dd_Dn_Dm,
+}
+
+var FMOV = []*Opcode{
+	FMOV_Dd_Dn,
+	FMOV_Dd_Xn,
+	FMOV_Xd_Dn,
+}
+
+var FMUL = []*Opcode{
+	FMUL_Dd_Dn_Dm,
+}
+
+var FSUB = []*Opcode{
+	FSUB_Dd_Dn_Dm,
+}
+
+var LDP = []*Opcode{
+	LDP_Wt_Wt2_mem,
+	LDP_Wt_Wt2_memPre,
+	LDP_Wt_Wt2_memPost,
+	LDP_Xt_Xt2_mem,
+	LDP_Xt_Xt2_memPre,
+	LDP_Xt_Xt2_memPost,
+	LDP_Dt_Dt2_mem,
+	LDP_Dt_Dt2_memPre,
+	LDP_Dt_Dt2_memPost,
+}
+
+var LDR = []*Opcode{
+	LDR_Wt_mem,
+	LDR_Wt_memU,
+	LDR_Wt_memPre,
+	LDR_Wt_memPost,
+	LDR_Wt_memReg,
+	LDR_Xt_mem,
+	LDR_Xt_memU,
+	LDR_Xt_memPre,
+	LDR_Xt_memPost,
+	LDR_Xt_memReg,
+	LDR_Dt_mem,
+	LDR_Dt_memU,
+	LDR_Dt_memPre,
+	LDR_Dt_memPost,
+	LDR_Dt_memReg,
+}
+
+var LDRB = []*Opcode{
+	LDRB_Wt_mem,
+	LDRB_Wt_memU,
+	LDRB_Wt_memReg,
+}
+
+var LDRH = []*Opcode{
+	LDRH_Wt_mem,
+	LDRH_Wt_memU,
+	LDRH_Wt_memReg,
+}
+
+var LSLV = []*Opcode{
+	LSLV_Wd_Wn_Wm,
+	LSLV_Xd_Xn_Xm,
+}
+
+var LSRV = []*Opcode{
+	LSRV_Wd_Wn_Wm,
+	LSRV_Xd_Xn_Xm,
+}
+
+var MADD = []*Opcode{
+	MADD_Wd_Wn_Wm_Wa,
+	MADD_Xd_Xn_Xm_Xa,
 }
 
 var MOVK = []*Opcode{
@@ -21,6 +188,88 @@ var MOVK = []*Opcode{
 	MOVK_Xd_imm16,
 }
 
+var MOVN = []*Opcode{
+	MOVN_Wd_imm16,
+	MOVN_Xd_imm16,
+}
+
+var MOVZ = []*Opcode{
+	MOVZ_Wd_imm16,
+	MOVZ_Xd_imm16,
+}
+
+var MSUB = []*Opcode{
+	MSUB_Wd_Wn_Wm_Wa,
+	MSUB_Xd_Xn_Xm_Xa,
+}
+
+var ORR = []*Opcode{
+	ORR_Wd_Wn_imm,
+	ORR_Xd_Xn_imm,
+	ORR_Wd_Wn_Wm,
+	ORR_Xd_Xn_Xm,
+}
+
+var RET = []*Opcode{
+	RET_Xn,
+}
+
+var SBFM = []*Opcode{
+	SBFM_Wd_Wn_immr_imms,
+	SBFM_Xd_Xn_immr_imms,
+}
+
+var SCVTF = []*Opcode{
+	SCVTF_Dd_Xn,
+}
+
+var SDIV = []*Opcode{
+	SDIV_Wd_Wn_Wm,
+	SDIV_Xd_Xn_Xm,
+}
+
+var STP = []*Opcode{
+	STP_Wt_Wt2_mem,
+	STP_Wt_Wt2_memPre,
+	STP_Wt_Wt2_memPost,
+	STP_Xt_Xt2_mem,
+	STP_Xt_Xt2_memPre,
+	STP_Xt_Xt2_memPost,
+	STP_Dt_Dt2_mem,
+	STP_Dt_Dt2_memPre,
+	STP_Dt_Dt2_memPost,
+}
+
+var STR = []*Opcode{
+	STR_Wt_mem,
+	STR_Wt_memU,
+	STR_Wt_memPre,
+	STR_Wt_memPost,
+	STR_Wt_memReg,
+	STR_Xt_mem,
+	STR_Xt_memU,
+	STR_Xt_memPre,
+	STR_Xt_memPost,
+	STR_Xt_memReg,
+	STR_Dt_mem,
+	STR_Dt_memU,
+	STR_Dt_memPre,
+	STR_Dt_memPost,
+	STR_Dt_memReg,
+}
+
+var STRB = []*Opcode{
+	STRB_Wt_mem,
+	STRB_Wt_memU,
+	STRB_Wt_memReg,
+}
+
+var STRH = []*Opcode{
+	STRH_Wt_mem,
+	STRH_Wt_memU,
+	STRH_Wt_memReg,
+}
+
 var SUB = []*Opcode{
 	SUB_Wd_Wn_imm12,
 	SUB_Xd_Xn_imm12,
@@ -31,4 +280,24 @@ var SUB = []*Opcode{
 var SUBS = []*Opcode{
 	SUBS_Wd_Wn_imm12,
 	SUBS_Xd_Xn_imm12,
+	SUBS_Wd_Wn_Wm,
+	SUBS_Xd_Xn_Xm,
+}
+
+var SVC = []*Opcode{
+	SVC_imm16,
+}
+
+var UBFM = []*Opcode{
+	UBFM_Wd_Wn_immr_imms,
+	UBFM_Xd_Xn_immr_imms,
+}
+
+var UCVTF = []*Opcode{
+	UCVTF_Dd_Xn,
+}
+
+var UDIV = []*Opcode{
+	UDIV_Wd_Wn_Wm,
+	UDIV_Xd_Xn_Xm,
 }
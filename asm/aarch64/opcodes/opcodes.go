@@ -12,15 +12,174 @@ var (
 
 	ADDS_Wd_Wn_imm12 = &Opcode{"adds", []OpcodeChunk{OP_Exact(10, 0b001_100010_0), OP_Imm12, OP_Wn, OP_Wd}}
 	ADDS_Xd_Xn_imm12 = &Opcode{"adds", []OpcodeChunk{OP_Exact(10, 0b101_100010_0), OP_Imm12, OP_Xn, OP_Xd}}
+	ADDS_Wd_Wn_Wm    = &Opcode{"adds", []OpcodeChunk{OP_Exact(11, 0b001_01011_000), OP_Wm, OP_Exact(6, 0), OP_Wn, OP_Wd}}
+	ADDS_Xd_Xn_Xm    = &Opcode{"adds", []OpcodeChunk{OP_Exact(11, 0b101_01011_000), OP_Xm, OP_Exact(6, 0), OP_Xn, OP_Xd}}
 
-	MOVK_Wd_imm16 = &Opcode{"movk", []OpcodeChunk{OP_Exact(11, 0b011_100101_00), OP_Imm16, OP_Wd}}
-	MOVK_Xd_imm16 = &Opcode{"movk", []OpcodeChunk{OP_Exact(11, 0b111_100101_00), OP_Imm16, OP_Xd}}
+	ADR_Xd_label = &Opcode{"adr", []OpcodeChunk{OP_Exact(1, 0), OP_ADR, OP_Xd}}
+
+	AND_Wd_Wn_imm = &Opcode{"and", []OpcodeChunk{OP_Exact(9, 0b000_100100), OP_Bitmask32, OP_Wn, OP_Wd}}
+	AND_Xd_Xn_imm = &Opcode{"and", []OpcodeChunk{OP_Exact(9, 0b100_100100), OP_Bitmask64, OP_Xn, OP_Xd}}
+	AND_Wd_Wn_Wm  = &Opcode{"and", []OpcodeChunk{OP_Exact(11, 0b000_01010_000), OP_Wm, OP_Exact(6, 0), OP_Wn, OP_Wd}}
+	AND_Xd_Xn_Xm  = &Opcode{"and", []OpcodeChunk{OP_Exact(11, 0b100_01010_000), OP_Xm, OP_Exact(6, 0), OP_Xn, OP_Xd}}
+
+	ANDS_Wd_Wn_imm = &Opcode{"ands", []OpcodeChunk{OP_Exact(9, 0b011_100100), OP_Bitmask32, OP_Wn, OP_Wd}}
+	ANDS_Xd_Xn_imm = &Opcode{"ands", []OpcodeChunk{OP_Exact(9, 0b111_100100), OP_Bitmask64, OP_Xn, OP_Xd}}
+	ANDS_Wd_Wn_Wm  = &Opcode{"ands", []OpcodeChunk{OP_Exact(11, 0b011_01010_000), OP_Wm, OP_Exact(6, 0), OP_Wn, OP_Wd}}
+	ANDS_Xd_Xn_Xm  = &Opcode{"ands", []OpcodeChunk{OP_Exact(11, 0b111_01010_000), OP_Xm, OP_Exact(6, 0), OP_Xn, OP_Xd}}
+
+	ASRV_Wd_Wn_Wm = &Opcode{"asrv", []OpcodeChunk{OP_Exact(11, 0b0_0_0_11010110), OP_Wm, OP_Exact(6, 0b0010_10), OP_Wn, OP_Wd}}
+	ASRV_Xd_Xn_Xm = &Opcode{"asrv", []OpcodeChunk{OP_Exact(11, 0b1_0_0_11010110), OP_Xm, OP_Exact(6, 0b0010_10), OP_Xn, OP_Xd}}
+
+	B_label      = &Opcode{"b", []OpcodeChunk{OP_Exact(6, 0b000101), OP_Rel26}}
+	B_cond_label = &Opcode{"b", []OpcodeChunk{OP_Exact(8, 0b01010100), OP_Rel19, OP_Exact(1, 0), OP_Cond}}
+	BL_label     = &Opcode{"bl", []OpcodeChunk{OP_Exact(6, 0b100101), OP_Rel26}}
+	BLR_Xn       = &Opcode{"blr", []OpcodeChunk{OP_Exact(22, 0b1101011_0_0_01_11111_0000_0_0), OP_Xn, OP_Exact(5, 0)}}
+	BR_Xn        = &Opcode{"br", []OpcodeChunk{OP_Exact(22, 0b1101011_0_0_00_11111_0000_0_0), OP_Xn, OP_Exact(5, 0)}}
+	BRK_imm16    = &Opcode{"brk", []OpcodeChunk{OP_Exact(11, 0b11010100_001), OP_Imm16, OP_Exact(5, 0b000_00)}}
+
+	CBNZ_Wt_label = &Opcode{"cbnz", []OpcodeChunk{OP_Exact(8, 0b0_011010_1), OP_Rel19, OP_Wd}}
+	CBNZ_Xt_label = &Opcode{"cbnz", []OpcodeChunk{OP_Exact(8, 0b1_011010_1), OP_Rel19, OP_Xd}}
+	CBZ_Wt_label  = &Opcode{"cbz", []OpcodeChunk{OP_Exact(8, 0b0_011010_0), OP_Rel19, OP_Wd}}
+	CBZ_Xt_label  = &Opcode{"cbz", []OpcodeChunk{OP_Exact(8, 0b1_011010_0), OP_Rel19, OP_Xd}}
+
+	CSINC_Wd_Wn_Wm_cond = &Opcode{"csinc", []OpcodeChunk{OP_Exact(11, 0b0_0_0_11010100), OP_Wm, OP_Cond, OP_Exact(2, 0b01), OP_Wn, OP_Wd}}
+	CSINC_Xd_Xn_Xm_cond = &Opcode{"csinc", []OpcodeChunk{OP_Exact(11, 0b1_0_0_11010100), OP_Xm, OP_Cond, OP_Exact(2, 0b01), OP_Xn, OP_Xd}}
+
+	CSEL_Wd_Wn_Wm_cond = &Opcode{"csel", []OpcodeChunk{OP_Exact(11, 0b0_0_0_11010100), OP_Wm, OP_Cond, OP_Exact(2, 0b00), OP_Wn, OP_Wd}}
+	CSEL_Xd_Xn_Xm_cond = &Opcode{"csel", []OpcodeChunk{OP_Exact(11, 0b1_0_0_11010100), OP_Xm, OP_Cond, OP_Exact(2, 0b00), OP_Xn, OP_Xd}}
+
+	EOR_Wd_Wn_imm = &Opcode{"eor", []OpcodeChunk{OP_Exact(9, 0b010_100100), OP_Bitmask32, OP_Wn, OP_Wd}}
+	EOR_Xd_Xn_imm = &Opcode{"eor", []OpcodeChunk{OP_Exact(9, 0b110_100100), OP_Bitmask64, OP_Xn, OP_Xd}}
+	EOR_Wd_Wn_Wm  = &Opcode{"eor", []OpcodeChunk{OP_Exact(11, 0b010_01010_000), OP_Wm, OP_Exact(6, 0), OP_Wn, OP_Wd}}
+	EOR_Xd_Xn_Xm  = &Opcode{"eor", []OpcodeChunk{OP_Exact(11, 0b110_01010_000), OP_Xm, OP_Exact(6, 0), OP_Xn, OP_Xd}}
+
+	FADD_Dd_Dn_Dm = &Opcode{"fadd", []OpcodeChunk{OP_Exact(11, 0b000_11110_01_1), OP_Dm, OP_Exact(6, 0b0010_10), OP_Dn, OP_Dd}}
+	FCMP_Dn_Dm    = &Opcode{"fcmp", []OpcodeChunk{OP_Exact(11, 0b000_11110_01_1), OP_Dm, OP_Exact(6, 0b00_1000), OP_Dn, OP_Exact(5, 0)}}
+	FCVTZS_Xd_Dn  = &Opcode{"fcvtzs", []OpcodeChunk{OP_Exact(22, 0b1_00_11110_01_1_11_000_000000), OP_Dn, OP_Xd}}
+	FCVTZU_Xd_Dn  = &Opcode{"fcvtzu", []OpcodeChunk{OP_Exact(22, 0b1_00_11110_01_1_11_001_000000), OP_Dn, OP_Xd}}
+	FDIV_Dd_Dn_Dm = &Opcode{"fdiv", []OpcodeChunk{OP_Exact(11, 0b000_11110_01_1), OP_Dm, OP_Exact(6, 0b0001_10), OP_Dn, OP_Dd}}
+	FMOV_Dd_Dn    = &Opcode{"fmov", []OpcodeChunk{OP_Exact(22, 0b000_11110_01_1_0000_00_10000), OP_Dn, OP_Dd}}
+	FMOV_Dd_Xn    = &Opcode{"fmov", []OpcodeChunk{OP_Exact(22, 0b1_00_11110_01_1_00_111_000000), OP_Xn, OP_Dd}}
+	FMOV_Xd_Dn    = &Opcode{"fmov", []OpcodeChunk{OP_Exact(22, 0b1_00_11110_01_1_00_110_000000), OP_Dn, OP_Xd}}
+	FMUL_Dd_Dn_Dm = &Opcode{"fmul", []OpcodeChunk{OP_Exact(11, 0b000_11110_01_1), OP_Dm, OP_Exact(6, 0b0000_10), OP_Dn, OP_Dd}}
+	FSUB_Dd_Dn_Dm = &Opcode{"fsub", []OpcodeChunk{OP_Exact(11, 0b000_11110_01_1), OP_Dm, OP_Exact(6, 0b0011_10), OP_Dn, OP_Dd}}
+
+	// The memory operand of the pair instructions is passed twice; once for
+	// the immediate and once for the base register.
+	LDP_Wt_Wt2_mem     = &Opcode{"ldp", []OpcodeChunk{OP_Exact(10, 0b00_101_0_010_1), OP_MemPair(4), OP_Wm, OP_Rn, OP_Wd}}
+	LDP_Wt_Wt2_memPre  = &Opcode{"ldp", []OpcodeChunk{OP_Exact(10, 0b00_101_0_011_1), OP_MemPairPreIndex(4), OP_Wm, OP_Rn, OP_Wd}}
+	LDP_Wt_Wt2_memPost = &Opcode{"ldp", []OpcodeChunk{OP_Exact(10, 0b00_101_0_001_1), OP_MemPairPostIndex(4), OP_Wm, OP_Rn, OP_Wd}}
+	LDP_Xt_Xt2_mem     = &Opcode{"ldp", []OpcodeChunk{OP_Exact(10, 0b10_101_0_010_1), OP_MemPair(8), OP_Xm, OP_Rn, OP_Xd}}
+	LDP_Xt_Xt2_memPre  = &Opcode{"ldp", []OpcodeChunk{OP_Exact(10, 0b10_101_0_011_1), OP_MemPairPreIndex(8), OP_Xm, OP_Rn, OP_Xd}}
+	LDP_Xt_Xt2_memPost = &Opcode{"ldp", []OpcodeChunk{OP_Exact(10, 0b10_101_0_001_1), OP_MemPairPostIndex(8), OP_Xm, OP_Rn, OP_Xd}}
+	LDP_Dt_Dt2_mem     = &Opcode{"ldp", []OpcodeChunk{OP_Exact(10, 0b01_101_1_010_1), OP_MemPair(8), OP_Dm, OP_Rn, OP_Dd}}
+	LDP_Dt_Dt2_memPre  = &Opcode{"ldp", []OpcodeChunk{OP_Exact(10, 0b01_101_1_011_1), OP_MemPairPreIndex(8), OP_Dm, OP_Rn, OP_Dd}}
+	LDP_Dt_Dt2_memPost = &Opcode{"ldp", []OpcodeChunk{OP_Exact(10, 0b01_101_1_001_1), OP_MemPairPostIndex(8), OP_Dm, OP_Rn, OP_Dd}}
+
+	LDR_Wt_mem     = &Opcode{"ldr", []OpcodeChunk{OP_Exact(10, 0b10_111_0_01_01), OP_Mem(4), OP_Wd}}
+	LDR_Wt_memU    = &Opcode{"ldur", []OpcodeChunk{OP_Exact(11, 0b10_111_0_00_01_0), OP_MemUnscaled(), OP_Wd}}
+	LDR_Wt_memPre  = &Opcode{"ldr", []OpcodeChunk{OP_Exact(11, 0b10_111_0_00_01_0), OP_MemPreIndex(), OP_Wd}}
+	LDR_Wt_memPost = &Opcode{"ldr", []OpcodeChunk{OP_Exact(11, 0b10_111_0_00_01_0), OP_MemPostIndex(), OP_Wd}}
+	LDR_Wt_memReg  = &Opcode{"ldr", []OpcodeChunk{OP_Exact(11, 0b10_111_0_00_01_1), OP_MemRegister(4), OP_Wd}}
+	LDR_Xt_mem     = &Opcode{"ldr", []OpcodeChunk{OP_Exact(10, 0b11_111_0_01_01), OP_Mem(8), OP_Xd}}
+	LDR_Xt_memU    = &Opcode{"ldur", []OpcodeChunk{OP_Exact(11, 0b11_111_0_00_01_0), OP_MemUnscaled(), OP_Xd}}
+	LDR_Xt_memPre  = &Opcode{"ldr", []OpcodeChunk{OP_Exact(11, 0b11_111_0_00_01_0), OP_MemPreIndex(), OP_Xd}}
+	LDR_Xt_memPost = &Opcode{"ldr", []OpcodeChunk{OP_Exact(11, 0b11_111_0_00_01_0), OP_MemPostIndex(), OP_Xd}}
+	LDR_Xt_memReg  = &Opcode{"ldr", []OpcodeChunk{OP_Exact(11, 0b11_111_0_00_01_1), OP_MemRegister(8), OP_Xd}}
+	LDR_Dt_mem     = &Opcode{"ldr", []OpcodeChunk{OP_Exact(10, 0b11_111_1_01_01), OP_Mem(8), OP_Dd}}
+	LDR_Dt_memU    = &Opcode{"ldur", []OpcodeChunk{OP_Exact(11, 0b11_111_1_00_01_0), OP_MemUnscaled(), OP_Dd}}
+	LDR_Dt_memPre  = &Opcode{"ldr", []OpcodeChunk{OP_Exact(11, 0b11_111_1_00_01_0), OP_MemPreIndex(), OP_Dd}}
+	LDR_Dt_memPost = &Opcode{"ldr", []OpcodeChunk{OP_Exact(11, 0b11_111_1_00_01_0), OP_MemPostIndex(), OP_Dd}}
+	LDR_Dt_memReg  = &Opcode{"ldr", []OpcodeChunk{OP_Exact(11, 0b11_111_1_00_01_1), OP_MemRegister(8), OP_Dd}}
+	LDRB_Wt_mem    = &Opcode{"ldrb", []OpcodeChunk{OP_Exact(10, 0b00_111_0_01_01), OP_Mem(1), OP_Wd}}
+	LDRB_Wt_memU   = &Opcode{"ldurb", []OpcodeChunk{OP_Exact(11, 0b00_111_0_00_01_0), OP_MemUnscaled(), OP_Wd}}
+	LDRB_Wt_memReg = &Opcode{"ldrb", []OpcodeChunk{OP_Exact(11, 0b00_111_0_00_01_1), OP_MemRegister(1), OP_Wd}}
+	LDRH_Wt_mem    = &Opcode{"ldrh", []OpcodeChunk{OP_Exact(10, 0b01_111_0_01_01), OP_Mem(2), OP_Wd}}
+	LDRH_Wt_memU   = &Opcode{"ldurh", []OpcodeChunk{OP_Exact(11, 0b01_111_0_00_01_0), OP_MemUnscaled(), OP_Wd}}
+	LDRH_Wt_memReg = &Opcode{"ldrh", []OpcodeChunk{OP_Exact(11, 0b01_111_0_00_01_1), OP_MemRegister(2), OP_Wd}}
+
+	LSLV_Wd_Wn_Wm = &Opcode{"lslv", []OpcodeChunk{OP_Exact(11, 0b0_0_0_11010110), OP_Wm, OP_Exact(6, 0b0010_00), OP_Wn, OP_Wd}}
+	LSLV_Xd_Xn_Xm = &Opcode{"lslv", []OpcodeChunk{OP_Exact(11, 0b1_0_0_11010110), OP_Xm, OP_Exact(6, 0b0010_00), OP_Xn, OP_Xd}}
+	LSRV_Wd_Wn_Wm = &Opcode{"lsrv", []OpcodeChunk{OP_Exact(11, 0b0_0_0_11010110), OP_Wm, OP_Exact(6, 0b0010_01), OP_Wn, OP_Wd}}
+	LSRV_Xd_Xn_Xm = &Opcode{"lsrv", []OpcodeChunk{OP_Exact(11, 0b1_0_0_11010110), OP_Xm, OP_Exact(6, 0b0010_01), OP_Xn, OP_Xd}}
+
+	MADD_Wd_Wn_Wm_Wa = &Opcode{"madd", []OpcodeChunk{OP_Exact(11, 0b0_00_11011_000), OP_Wm, OP_Exact(1, 0), OP_Wa, OP_Wn, OP_Wd}}
+	MADD_Xd_Xn_Xm_Xa = &Opcode{"madd", []OpcodeChunk{OP_Exact(11, 0b1_00_11011_000), OP_Xm, OP_Exact(1, 0), OP_Xa, OP_Xn, OP_Xd}}
+
+	MSUB_Wd_Wn_Wm_Wa = &Opcode{"msub", []OpcodeChunk{OP_Exact(11, 0b0_00_11011_000), OP_Wm, OP_Exact(1, 1), OP_Wa, OP_Wn, OP_Wd}}
+	MSUB_Xd_Xn_Xm_Xa = &Opcode{"msub", []OpcodeChunk{OP_Exact(11, 0b1_00_11011_000), OP_Xm, OP_Exact(1, 1), OP_Xa, OP_Xn, OP_Xd}}
+
+	MOVK_Wd_imm16 = &Opcode{"movk", []OpcodeChunk{OP_Exact(9, 0b0_11_100101), OP_Shift, OP_Imm16, OP_Wd}}
+	MOVK_Xd_imm16 = &Opcode{"movk", []OpcodeChunk{OP_Exact(9, 0b1_11_100101), OP_Shift, OP_Imm16, OP_Xd}}
+	MOVN_Wd_imm16 = &Opcode{"movn", []OpcodeChunk{OP_Exact(9, 0b0_00_100101), OP_Shift, OP_Imm16, OP_Wd}}
+	MOVN_Xd_imm16 = &Opcode{"movn", []OpcodeChunk{OP_Exact(9, 0b1_00_100101), OP_Shift, OP_Imm16, OP_Xd}}
+	MOVZ_Wd_imm16 = &Opcode{"movz", []OpcodeChunk{OP_Exact(9, 0b0_10_100101), OP_Shift, OP_Imm16, OP_Wd}}
+	MOVZ_Xd_imm16 = &Opcode{"movz", []OpcodeChunk{OP_Exact(9, 0b1_10_100101), OP_Shift, OP_Imm16, OP_Xd}}
+
+	ORR_Wd_Wn_imm = &Opcode{"orr", []OpcodeChunk{OP_Exact(9, 0b001_100100), OP_Bitmask32, OP_Wn, OP_Wd}}
+	ORR_Xd_Xn_imm = &Opcode{"orr", []OpcodeChunk{OP_Exact(9, 0b101_100100), OP_Bitmask64, OP_Xn, OP_Xd}}
+	ORR_Wd_Wn_Wm  = &Opcode{"orr", []OpcodeChunk{OP_Exact(11, 0b001_01010_000), OP_Wm, OP_Exact(6, 0), OP_Wn, OP_Wd}}
+	ORR_Xd_Xn_Xm  = &Opcode{"orr", []OpcodeChunk{OP_Exact(11, 0b101_01010_000), OP_Xm, OP_Exact(6, 0), OP_Xn, OP_Xd}}
+
+	RET_Xn = &Opcode{"ret", []OpcodeChunk{OP_Exact(22, 0b1101011_0_0_10_11111_0000_0_0), OP_Xn, OP_Exact(5, 0)}}
+
+	SBFM_Wd_Wn_immr_imms = &Opcode{"sbfm", []OpcodeChunk{OP_Exact(10, 0b0_00_100110_0), OP_Imm6, OP_Imm6, OP_Wn, OP_Wd}}
+	SBFM_Xd_Xn_immr_imms = &Opcode{"sbfm", []OpcodeChunk{OP_Exact(10, 0b1_00_100110_1), OP_Imm6, OP_Imm6, OP_Xn, OP_Xd}}
+
+	SCVTF_Dd_Xn = &Opcode{"scvtf", []OpcodeChunk{OP_Exact(22, 0b1_00_11110_01_1_00_010_000000), OP_Xn, OP_Dd}}
+
+	SDIV_Wd_Wn_Wm = &Opcode{"sdiv", []OpcodeChunk{OP_Exact(11, 0b0_0_0_11010110), OP_Wm, OP_Exact(6, 0b00001_1), OP_Wn, OP_Wd}}
+	SDIV_Xd_Xn_Xm = &Opcode{"sdiv", []OpcodeChunk{OP_Exact(11, 0b1_0_0_11010110), OP_Xm, OP_Exact(6, 0b00001_1), OP_Xn, OP_Xd}}
+
+	STP_Wt_Wt2_mem     = &Opcode{"stp", []OpcodeChunk{OP_Exact(10, 0b00_101_0_010_0), OP_MemPair(4), OP_Wm, OP_Rn, OP_Wd}}
+	STP_Wt_Wt2_memPre  = &Opcode{"stp", []OpcodeChunk{OP_Exact(10, 0b00_101_0_011_0), OP_MemPairPreIndex(4), OP_Wm, OP_Rn, OP_Wd}}
+	STP_Wt_Wt2_memPost = &Opcode{"stp", []OpcodeChunk{OP_Exact(10, 0b00_101_0_001_0), OP_MemPairPostIndex(4), OP_Wm, OP_Rn, OP_Wd}}
+	STP_Xt_Xt2_mem     = &Opcode{"stp", []OpcodeChunk{OP_Exact(10, 0b10_101_0_010_0), OP_MemPair(8), OP_Xm, OP_Rn, OP_Xd}}
+	STP_Xt_Xt2_memPre  = &Opcode{"stp", []OpcodeChunk{OP_Exact(10, 0b10_101_0_011_0), OP_MemPairPreIndex(8), OP_Xm, OP_Rn, OP_Xd}}
+	STP_Xt_Xt2_memPost = &Opcode{"stp", []OpcodeChunk{OP_Exact(10, 0b10_101_0_001_0), OP_MemPairPostIndex(8), OP_Xm, OP_Rn, OP_Xd}}
+	STP_Dt_Dt2_mem     = &Opcode{"stp", []OpcodeChunk{OP_Exact(10, 0b01_101_1_010_0), OP_MemPair(8), OP_Dm, OP_Rn, OP_Dd}}
+	STP_Dt_Dt2_memPre  = &Opcode{"stp", []OpcodeChunk{OP_Exact(10, 0b01_101_1_011_0), OP_MemPairPreIndex(8), OP_Dm, OP_Rn, OP_Dd}}
+	STP_Dt_Dt2_memPost = &Opcode{"stp", []OpcodeChunk{OP_Exact(10, 0b01_101_1_001_0), OP_MemPairPostIndex(8), OP_Dm, OP_Rn, OP_Dd}}
+
+	STR_Wt_mem     = &Opcode{"str", []OpcodeChunk{OP_Exact(10, 0b10_111_0_01_00), OP_Mem(4), OP_Wd}}
+	STR_Wt_memU    = &Opcode{"stur", []OpcodeChunk{OP_Exact(11, 0b10_111_0_00_00_0), OP_MemUnscaled(), OP_Wd}}
+	STR_Wt_memPre  = &Opcode{"str", []OpcodeChunk{OP_Exact(11, 0b10_111_0_00_00_0), OP_MemPreIndex(), OP_Wd}}
+	STR_Wt_memPost = &Opcode{"str", []OpcodeChunk{OP_Exact(11, 0b10_111_0_00_00_0), OP_MemPostIndex(), OP_Wd}}
+	STR_Wt_memReg  = &Opcode{"str", []OpcodeChunk{OP_Exact(11, 0b10_111_0_00_00_1), OP_MemRegister(4), OP_Wd}}
+	STR_Xt_mem     = &Opcode{"str", []OpcodeChunk{OP_Exact(10, 0b11_111_0_01_00), OP_Mem(8), OP_Xd}}
+	STR_Xt_memU    = &Opcode{"stur", []OpcodeChunk{OP_Exact(11, 0b11_111_0_00_00_0), OP_MemUnscaled(), OP_Xd}}
+	STR_Xt_memPre  = &Opcode{"str", []OpcodeChunk{OP_Exact(11, 0b11_111_0_00_00_0), OP_MemPreIndex(), OP_Xd}}
+	STR_Xt_memPost = &Opcode{"str", []OpcodeChunk{OP_Exact(11, 0b11_111_0_00_00_0), OP_MemPostIndex(), OP_Xd}}
+	STR_Xt_memReg  = &Opcode{"str", []OpcodeChunk{OP_Exact(11, 0b11_111_0_00_00_1), OP_MemRegister(8), OP_Xd}}
+	STR_Dt_mem     = &Opcode{"str", []OpcodeChunk{OP_Exact(10, 0b11_111_1_01_00), OP_Mem(8), OP_Dd}}
+	STR_Dt_memU    = &Opcode{"stur", []OpcodeChunk{OP_Exact(11, 0b11_111_1_00_00_0), OP_MemUnscaled(), OP_Dd}}
+	STR_Dt_memPre  = &Opcode{"str", []OpcodeChunk{OP_Exact(11, 0b11_111_1_00_00_0), OP_MemPreIndex(), OP_Dd}}
+	STR_Dt_memPost = &Opcode{"str", []OpcodeChunk{OP_Exact(11, 0b11_111_1_00_00_0), OP_MemPostIndex(), OP_Dd}}
+	STR_Dt_memReg  = &Opcode{"str", []OpcodeChunk{OP_Exact(11, 0b11_111_1_00_00_1), OP_MemRegister(8), OP_Dd}}
+	STRB_Wt_mem    = &Opcode{"strb", []OpcodeChunk{OP_Exact(10, 0b00_111_0_01_00), OP_Mem(1), OP_Wd}}
+	STRB_Wt_memU   = &Opcode{"sturb", []OpcodeChunk{OP_Exact(11, 0b00_111_0_00_00_0), OP_MemUnscaled(), OP_Wd}}
+	STRB_Wt_memReg = &Opcode{"strb", []OpcodeChunk{OP_Exact(11, 0b00_111_0_00_00_1), OP_MemRegister(1), OP_Wd}}
+	STRH_Wt_mem    = &Opcode{"strh", []OpcodeChunk{OP_Exact(10, 0b01_111_0_01_00), OP_Mem(2), OP_Wd}}
+	STRH_Wt_memU   = &Opcode{"sturh", []OpcodeChunk{OP_Exact(11, 0b01_111_0_00_00_0), OP_MemUnscaled(), OP_Wd}}
+	STRH_Wt_memReg = &Opcode{"strh", []OpcodeChunk{OP_Exact(11, 0b01_111_0_00_00_1), OP_MemRegister(2), OP_Wd}}
 
 	SUB_Wd_Wn_imm12 = &Opcode{"sub", []OpcodeChunk{OP_Exact(10, 0b010_100010_0), OP_Imm12, OP_Wn, OP_Wd}}
 	SUB_Xd_Xn_imm12 = &Opcode{"sub", []OpcodeChunk{OP_Exact(10, 0b110_100010_0), OP_Imm12, OP_Xn, OP_Xd}}
-	SUB_Wd_Wn_Wm    = &Opcode{"sub", []OpcodeChunk{OP_Exact(10, 0b010_01011_00_0), OP_Wm, OP_Exact(6, 0), OP_Wn, OP_Wd}}
-	SUB_Xd_Xn_Xm    = &Opcode{"sub", []OpcodeChunk{OP_Exact(10, 0b110_01011_00_0), OP_Xm, OP_Exact(6, 0), OP_Xn, OP_Xd}}
+	SUB_Wd_Wn_Wm    = &Opcode{"sub", []OpcodeChunk{OP_Exact(11, 0b010_01011_000), OP_Wm, OP_Exact(6, 0), OP_Wn, OP_Wd}}
+	SUB_Xd_Xn_Xm    = &Opcode{"sub", []OpcodeChunk{OP_Exact(11, 0b110_01011_000), OP_Xm, OP_Exact(6, 0), OP_Xn, OP_Xd}}
 
 	SUBS_Wd_Wn_imm12 = &Opcode{"subs", []OpcodeChunk{OP_Exact(10, 0b011_100010_0), OP_Imm12, OP_Wn, OP_Wd}}
 	SUBS_Xd_Xn_imm12 = &Opcode{"subs", []OpcodeChunk{OP_Exact(10, 0b111_100010_0), OP_Imm12, OP_Xn, OP_Xd}}
+	SUBS_Wd_Wn_Wm    = &Opcode{"subs", []OpcodeChunk{OP_Exact(11, 0b011_01011_000), OP_Wm, OP_Exact(6, 0), OP_Wn, OP_Wd}}
+	SUBS_Xd_Xn_Xm    = &Opcode{"subs", []OpcodeChunk{OP_Exact(11, 0b111_01011_000), OP_Xm, OP_Exact(6, 0), OP_Xn, OP_Xd}}
+
+	SVC_imm16 = &Opcode{"svc", []OpcodeChunk{OP_Exact(11, 0b11010100_000), OP_Imm16, OP_Exact(5, 0b000_01)}}
+
+	UBFM_Wd_Wn_immr_imms = &Opcode{"ubfm", []OpcodeChunk{OP_Exact(10, 0b0_10_100110_0), OP_Imm6, OP_Imm6, OP_Wn, OP_Wd}}
+	UBFM_Xd_Xn_immr_imms = &Opcode{"ubfm", []OpcodeChunk{OP_Exact(10, 0b1_10_100110_1), OP_Imm6, OP_Imm6, OP_Xn, OP_Xd}}
+
+	UCVTF_Dd_Xn = &Opcode{"ucvtf", []OpcodeChunk{OP_Exact(22, 0b1_00_11110_01_1_00_011_000000), OP_Xn, OP_Dd}}
+
+	UDIV_Wd_Wn_Wm = &Opcode{"udiv", []OpcodeChunk{OP_Exact(11, 0b0_0_0_11010110), OP_Wm, OP_Exact(6, 0b00001_0), OP_Wn, OP_Wd}}
+	UDIV_Xd_Xn_Xm = &Opcode{"udiv", []OpcodeChunk{OP_Exact(11, 0b1_0_0_11010110), OP_Xm, OP_Exact(6, 0b00001_0), OP_Xn, OP_Xd}}
 )
@@ -17,6 +17,11 @@ func OpcodesToInstruction(name string, opcodes []*Opcode, operands ...lib.Operan
 	if err != nil {
 		panic(err)
 	}
+	for _, op := range operands {
+		if label, ok := op.(*lib.Label); ok {
+			return NewRelativeInstruction(name, opcode, operands, label)
+		}
+	}
 	return NewOpcodeInstruction(name, opcode, operands)
 }
 
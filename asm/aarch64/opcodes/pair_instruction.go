@@ -0,0 +1,32 @@
+package opcodes
+
+import (
+	. "github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// A load or store of a pair of registers, e.g. LDP and STP. The immediate
+// and the base register of the memory operand aren't next to each other in
+// the encoding, so the opcodes take the memory operand twice.
+type pairInstruction struct {
+	*opcodeInstruction
+	Registers [2]lib.Operand
+	Memory    lib.Operand
+}
+
+func PairOpcodesToInstruction(name string, opcodes []*Opcode, rt1, rt2, mem lib.Operand) lib.Instruction {
+	operands := []lib.Operand{mem, rt2, mem, rt1}
+	opcode, err := resolveOpcode(name, opcodes, operands)
+	if err != nil {
+		panic(err)
+	}
+	return &pairInstruction{
+		opcodeInstruction: NewOpcodeInstruction(name, opcode, operands),
+		Registers:         [2]lib.Operand{rt1, rt2},
+		Memory:            mem,
+	}
+}
+
+func (p *pairInstruction) String() string {
+	return p.Name + " " + p.Registers[0].String() + ", " + p.Registers[1].String() + ", " + p.Memory.String()
+}
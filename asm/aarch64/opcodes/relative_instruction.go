@@ -0,0 +1,60 @@
+package opcodes
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// An instruction that refers to a label, e.g. a branch or ADR. The label is
+// replaced by the displacement from the start of the instruction once the
+// addresses are known (see lib.Instructions.Resolve). Instructions are
+// always four bytes long, so resolving never changes the length.
+type relativeInstruction struct {
+	*opcodeInstruction
+	Label        *lib.Label
+	Displacement int
+}
+
+func NewRelativeInstruction(name string, o *Opcode, args []lib.Operand, label *lib.Label) lib.Instruction {
+	return &relativeInstruction{
+		opcodeInstruction: NewOpcodeInstruction(name, o, args),
+		Label:             label,
+	}
+}
+
+func (o *relativeInstruction) Encode() (lib.MachineCode, error) {
+	operands := make([]lib.Operand, len(o.Operands))
+	for i, op := range o.Operands {
+		if op == o.Label {
+			operands[i] = Int32(o.Displacement)
+		} else {
+			operands[i] = op
+		}
+	}
+	return o.Opcode.Encode(operands)
+}
+
+func (o *relativeInstruction) Resolve(address int, labels lib.LabelAddresses) (bool, error) {
+	target, err := labels.Get(o.Label)
+	if err != nil {
+		return false, fmt.Errorf("%s in %s", err.Error(), o.String())
+	}
+	o.Displacement = target - address
+	return false, nil
+}
+
+// Returns the relocation for an ADR. Branches only refer to labels in the
+// same code, so they never need relocating.
+func (o *relativeInstruction) Relocation() (*lib.Relocation, error) {
+	for _, chunk := range o.Opcode.Operands {
+		if chunk.OperandType == OT_LabelADR {
+			return &lib.Relocation{Type: lib.R_ADR21, Label: o.Label}, nil
+		}
+	}
+	if o.Label.Fixed {
+		return nil, fmt.Errorf("Can't relocate %s", o.String())
+	}
+	return nil, nil
+}
@@ -38,6 +38,12 @@ func CMP_immediate(v uint64, dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("cmp", opcodes.CMP, 2, dest, encoding.Uint32(v))
 }
 
+// Compare the float64 in dest with src and set ZF, PF and CF. All three are
+// set if either value is NaN.
+func COMISD(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("comisd", opcodes.COMISD, 2, dest, src)
+}
+
 // Convert signed integer to scalar double-precision floating point (float64)
 func CVTSI2SD(src, dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("cvtsi2sd", opcodes.CVTSI2SD, 2, dest, src)
@@ -136,6 +142,12 @@ func JNL(dest lib.Operand) lib.Instruction {
 func JNLE(dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("jnle", opcodes.JNLE, 1, dest)
 }
+func JNP(dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("jnp", opcodes.JNP, 1, dest)
+}
+func JP(dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("jp", opcodes.JP, 1, dest)
+}
 func JMP(dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("jmp", opcodes.JMP, 1, dest)
 }
@@ -176,6 +188,9 @@ func IMUL2(src, dest lib.Operand) lib.Instruction {
 func MUL(src lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("mul", opcodes.MUL, 1, src)
 }
+func NOT(dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("not", opcodes.NOT, 1, dest)
+}
 func OR(src, dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("or", opcodes.OR, 2, dest, src)
 }
@@ -191,6 +206,16 @@ func PUSHFQ() lib.Instruction {
 func RETURN() lib.Instruction {
 	return opcodes.OpcodeToInstruction("return", opcodes.RETURN, 0)
 }
+
+// Arithmetic shift right by an immediate value
+func SAR(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("sar", opcodes.SAR, 2, dest, src)
+}
+
+// Arithmetic shift right by %cl
+func SAR_CL(dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("sar", opcodes.SAR_CL, 1, dest)
+}
 func SETA(dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("seta", opcodes.SETA, 1, dest)
 }
@@ -224,19 +249,45 @@ func SETGE(dest lib.Operand) lib.Instruction {
 func SETNE(dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("setne", opcodes.SETNE, 1, dest)
 }
+func SETNP(dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("setnp", opcodes.SETNP, 1, dest)
+}
+func SETP(dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("setp", opcodes.SETP, 1, dest)
+}
 func SUB(src, dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("sub", opcodes.SUB, 2, dest, src)
 }
 func SHL(src, dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("shl", opcodes.SHL, 2, dest, src)
 }
+
+// Shift left by %cl
+func SHL_CL(dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("shl", opcodes.SHL_CL, 1, dest)
+}
 func SHR(src, dest lib.Operand) lib.Instruction {
 	return opcodes.OpcodesToInstruction("shr", opcodes.SHR, 2, dest, src)
 }
+
+// Logical shift right by %cl
+func SHR_CL(dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("shr", opcodes.SHR_CL, 1, dest)
+}
 func SYSCALL() lib.Instruction {
 	return opcodes.OpcodeToInstruction("syscall", opcodes.SYSCALL, 0)
 }
 
+// Like COMISD, but only signals an invalid operation for signalling NaNs.
+func UCOMISD(src, dest lib.Operand) lib.Instruction {
+	return opcodes.OpcodesToInstruction("ucomisd", opcodes.UCOMISD, 2, dest, src)
+}
+
+// Raises an invalid opcode exception. Used to trap on failed runtime checks.
+func UD2() lib.Instruction {
+	return opcodes.OpcodeToInstruction("ud2", opcodes.UD2, 0)
+}
+
 // Add packed byte integers from op1 (register), and op2 (register or address)
 // and store in dest.
 func VPADDB(op1, op2, dest lib.Operand) lib.Instruction {
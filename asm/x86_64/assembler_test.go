@@ -145,6 +145,108 @@ func Test_MOV(t *testing.T) {
 	if unit.String() != expected {
 		t.Fatal("Expecting", expected, "got", unit)
 	}
+
+	unit, err = (MOV(encoding.Ecx, encoding.Edx)).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "  8b d1"
+	if unit.String() != expected {
+		t.Fatal("Expecting", expected, "got", unit)
+	}
+	unit, err = (MOV(encoding.Ecx, encoding.R9d)).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "  44 8b c9"
+	if unit.String() != expected {
+		t.Fatal("Expecting", expected, "got", unit)
+	}
+
+	// Both the REX and the non-REX form match; the choice shouldn't depend
+	// on map iteration order.
+	for i := 0; i < 20; i++ {
+		unit, err = (MOV(encoding.Uint8(0x01), encoding.Dl)).Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = "  b2 01"
+		if unit.String() != expected {
+			t.Fatal("Expecting", expected, "got", unit)
+		}
+	}
+}
+
+func Test_COMISD(t *testing.T) {
+	unit, err := (COMISD(encoding.Xmm1, encoding.Xmm0)).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "  66 0f 2f c1"
+	if unit.String() != expected {
+		t.Fatal("Expecting", expected, "got", unit)
+	}
+	unit, err = (UCOMISD(encoding.Xmm1, encoding.Xmm0)).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "  66 0f 2e c1"
+	if unit.String() != expected {
+		t.Fatal("Expecting", expected, "got", unit)
+	}
+	unit, err = (UCOMISD(&encoding.DisplacedRegister{encoding.Rbp, 0xf8}, encoding.Xmm2)).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "  66 0f 2e 55 f8"
+	if unit.String() != expected {
+		t.Fatal("Expecting", expected, "got", unit)
+	}
+
+	// The 0x66 prefix has to come before the REX prefix
+	cases := []struct {
+		instr    lib.Instruction
+		expected string
+	}{
+		{UCOMISD(&encoding.DisplacedRegister{encoding.R12, 0x8}, encoding.Xmm0), "  66 41 0f 2e 44 24 08"},
+		{COMISD(&encoding.IndirectRegister{encoding.R9}, encoding.Xmm1), "  66 41 0f 2f 09"},
+		{COMISD(&encoding.IndirectRegister{encoding.Rax}, encoding.Xmm12), "  66 44 0f 2f 20"},
+		{COMISD(encoding.Xmm9, encoding.Xmm8), "  66 45 0f 2f c1"},
+		{UCOMISD(encoding.Xmm2, encoding.Xmm10), "  66 44 0f 2e d2"},
+		{UCOMISD(encoding.Xmm15, encoding.Xmm3), "  66 41 0f 2e df"},
+	}
+	for _, c := range cases {
+		unit, err := c.instr.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.String() != c.expected {
+			t.Fatal("Expecting", c.expected, "got", unit, "for", c.instr)
+		}
+	}
+}
+
+func Test_Shifts(t *testing.T) {
+	cases := []struct {
+		instr    lib.Instruction
+		expected string
+	}{
+		{NOT(encoding.Rax), "  48 f7 d0"},
+		{NOT(encoding.Ecx), "  f7 d1"},
+		{SHL_CL(encoding.Rax), "  48 d3 e0"},
+		{SHR_CL(encoding.Rdx), "  48 d3 ea"},
+		{SAR_CL(encoding.R9), "  49 d3 f9"},
+		{SAR(encoding.Uint8(3), encoding.Rax), "  48 c1 f8 03"},
+	}
+	for _, c := range cases {
+		unit, err := c.instr.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.String() != c.expected {
+			t.Fatal("Expecting", c.expected, "got", unit, "for", c.instr)
+		}
+	}
 }
 
 func Test_JMP(t *testing.T) {
@@ -187,6 +289,116 @@ func Test_SIB_Addressing(t *testing.T) {
 	}
 }
 
+func Test_Displaced_Addressing(t *testing.T) {
+	table := [][]interface{}{
+		[]interface{}{encoding.Rax, &encoding.DisplacedRegister{encoding.Rbp, 0xf8}, "  48 89 45 f8"},
+		[]interface{}{&encoding.DisplacedRegister{encoding.Rbp, 0xf8}, encoding.Rcx, "  48 8b 4d f8"},
+		[]interface{}{encoding.Rax, &encoding.DisplacedRegister32{encoding.Rbp, -0x100}, "  48 89 85 00 ff ff ff"},
+		[]interface{}{&encoding.DisplacedRegister32{encoding.Rbp, -0x100}, encoding.R9, "  4c 8b 8d 00 ff ff ff"},
+		[]interface{}{encoding.Xmm1, &encoding.DisplacedRegister{encoding.Rbp, 0xf0}, "  f2 0f 11 4d f0"},
+		[]interface{}{&encoding.DisplacedRegister{encoding.Rbp, 0xf0}, encoding.Xmm1, "  66 48 0f 6e 4d f0"},
+	}
+	for _, testCase := range table {
+		unit, err := MOV(testCase[0].(lib.Operand), testCase[1].(lib.Operand)).Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.String() != testCase[2].(string) {
+			t.Error("Expecting", testCase[2].(string), "got", unit, "in mov", testCase[0], testCase[1])
+		}
+	}
+}
+
+func Test_Labels(t *testing.T) {
+	padding := func(n int) []lib.Instruction {
+		result := []lib.Instruction{}
+		for i := 0; i < n; i++ {
+			result = append(result, INC(encoding.Rax))
+		}
+		return result
+	}
+	label := func() *lib.Label {
+		return lib.NewLabel("label")
+	}
+	table := []func() ([]lib.Instruction, string){
+		func() ([]lib.Instruction, string) {
+			l := label()
+			return []lib.Instruction{JMP(l), INC(encoding.Rax), lib.DefineLabel(l)}, "  eb 03 48 ff c0"
+		},
+		func() ([]lib.Instruction, string) {
+			l := label()
+			return []lib.Instruction{lib.DefineLabel(l), INC(encoding.Rax), JNE(l)}, "  48 ff c0 75 fb"
+		},
+		func() ([]lib.Instruction, string) {
+			l := label()
+			return []lib.Instruction{CALL(l), lib.DefineLabel(l)}, "  e8 00 00 00 00"
+		},
+		func() ([]lib.Instruction, string) {
+			l := label()
+			return []lib.Instruction{LEA(&encoding.RIPRelative{Label: l}, encoding.Rax), INC(encoding.Rax), lib.DefineLabel(l)}, lib.MachineCode([]uint8{0x48, 0x8d, 0x05, 0x03, 0x00, 0x00, 0x00, 0x48, 0xff, 0xc0}).String()
+		},
+	}
+	for _, testCase := range table {
+		instr, expected := testCase()
+		unit, err := lib.CompileInstruction(instr, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit.String() != expected {
+			t.Error("Expecting", expected, "got", unit, "in", lib.Instructions(instr))
+		}
+	}
+
+	// Jumps that don't fit in a rel8 get relaxed to a rel32
+	l := label()
+	instr := append([]lib.Instruction{JNE(l), JMP(l)}, padding(43)...)
+	instr = append(instr, lib.DefineLabel(l))
+	if err := lib.Instructions(instr).Resolve(0); err != nil {
+		t.Fatal(err)
+	}
+	unit, err := lib.Instructions(instr[:2]).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := lib.MachineCode([]uint8{0x0f, 0x85, 0x86, 0x00, 0x00, 0x00, 0xe9, 0x81, 0x00, 0x00, 0x00}).String()
+	if unit.String() != expected {
+		t.Fatal("Expecting", expected, "got", unit)
+	}
+
+	// Undefined labels are an error
+	if err := lib.Instructions([]lib.Instruction{JMP(label())}).Resolve(0); err == nil {
+		t.Fatal("Expecting an error for an undefined label")
+	}
+}
+
+func Test_Disassemble(t *testing.T) {
+	instr := []lib.Instruction{
+		MOV(encoding.Uint64(2), encoding.Rax),
+		MOV(encoding.Uint64(3), encoding.R14),
+		ADD(encoding.R14, encoding.Rax),
+		MOV(encoding.Rax, &encoding.DisplacedRegister{encoding.Rsp, 8}),
+		MOV(&encoding.DisplacedRegister32{encoding.Rbp, -0x100}, encoding.Xmm1),
+		LEA(&encoding.RIPRelative{Displacement: 0x10}, encoding.Rcx),
+		JNE(encoding.Uint8(0xf0)),
+		UD2(),
+		RETURN(),
+	}
+	code, err := lib.CompileInstruction(instr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Disassemble(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != lib.Instructions(instr).String() {
+		t.Fatal("Expecting", lib.Instructions(instr), "got", result)
+	}
+	if _, err := Disassemble(code[:len(code)-2]); err == nil {
+		t.Fatal("Expecting an error for truncated machine code")
+	}
+}
+
 func Test_Execute(t *testing.T) {
 	units := [][]lib.Instruction{
 		[]lib.Instruction{
@@ -0,0 +1,23 @@
+package x86_64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64/opcodes"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Decodes machine code back into instructions. Only the opcodes that the
+// assembler knows about (see opcodes.Opcodes) can be decoded.
+func Disassemble(code lib.MachineCode) (lib.Instructions, error) {
+	result := lib.Instructions{}
+	for address := 0; address < len(code); {
+		instr, length, err := opcodes.DecodeInstruction(code[address:])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode instruction at 0x%x: %s", address, err.Error())
+		}
+		result = append(result, instr)
+		address += length
+	}
+	return result, nil
+}
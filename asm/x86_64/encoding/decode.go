@@ -0,0 +1,326 @@
+package encoding
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Decode is the inverse of Encode. It checks whether the machine code starts
+// with an instance of this opcode and, if so, returns its operands in the
+// same form that Encode expects them, together with the number of bytes
+// that were read. An error is returned if the code doesn't match the opcode.
+func (o *Opcode) Decode(code []uint8) ([]lib.Operand, int, error) {
+	d := &decoder{code: code}
+	for _, p := range o.Prefixes {
+		if err := d.expect(p); err != nil {
+			return nil, 0, err
+		}
+	}
+	if o.HasExtension(VEX128) || o.HasExtension(VEX256) {
+		if err := d.vexPrefix(o); err != nil {
+			return nil, 0, err
+		}
+	} else if o.HasExtension(Rex) || o.HasExtension(RexW) {
+		b, err := d.next()
+		if err != nil {
+			return nil, 0, err
+		}
+		d.rex = DecodeREXPrefix(b)
+		if d.rex == nil || d.rex.W != o.HasExtension(RexW) {
+			return nil, 0, fmt.Errorf("Expecting REX prefix for %s", o.String())
+		}
+		d.r, d.x, d.b = d.rex.R, d.rex.X, d.rex.B
+	} else if len(d.code) > d.pos {
+		// The prefix is optional when it only extends the registers. A
+		// prefix without any bits set belongs to the opcodes with Rex.
+		if rex := DecodeREXPrefix(d.code[d.pos]); rex != nil && !rex.W && (rex.R || rex.X || rex.B) {
+			d.pos++
+			d.rex = rex
+			d.r, d.x, d.b = rex.R, rex.X, rex.B
+		}
+	}
+
+	plusRegister := uint8(0)
+	for i, op := range o.Opcode {
+		b, err := d.next()
+		if err != nil {
+			return nil, 0, err
+		}
+		if i == len(o.Opcode)-1 && o.hasOperandEncoding(Opcode_plus_rd_r) {
+			plusRegister = b & 7
+			b = b &^ 7
+		}
+		if b != op {
+			return nil, 0, fmt.Errorf("Opcode mismatch for %s", o.String())
+		}
+	}
+
+	if o.hasModRM() {
+		b, err := d.next()
+		if err != nil {
+			return nil, 0, err
+		}
+		d.modrm = DecodeModRM(b)
+		if !o.hasOperandEncoding(ModRM_reg_r) && !o.hasOperandEncoding(ModRM_reg_rw) {
+			for i, ext := range []OpcodeExtensions{Slash0, Slash1, Slash2, Slash3, Slash4, Slash5, Slash6, Slash7} {
+				if o.HasExtension(ext) && d.modrm.Reg != uint8(i) {
+					return nil, 0, fmt.Errorf("Opcode extension mismatch for %s", o.String())
+				}
+			}
+		}
+	}
+
+	// The immediate values come after the ModRM, SIB and displacement
+	// bytes, so they are decoded in a second pass.
+	result := make([]lib.Operand, len(o.Operands))
+	for i, operand := range o.Operands {
+		var err error
+		switch operand.Encoding {
+		case ModRM_rm_r, ModRM_rm_rw:
+			result[i], err = d.rmOperand(operand.Type)
+		case ModRM_reg_r, ModRM_reg_rw:
+			result[i], err = operand.Type.register(d.modrm.Reg|extension(d.r), d.rex != nil)
+		case VEX_vvvv:
+			result[i], err = operand.Type.register(15-d.vex.Source, false)
+		case Opcode_plus_rd_r:
+			result[i], err = operand.Type.register(plusRegister|extension(d.b), d.rex != nil)
+		case ImmediateValue:
+			continue
+		default:
+			err = fmt.Errorf("Unsupported encoding [%s] in %s", operand.Encoding.String(), o.String())
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	for i, operand := range o.Operands {
+		if operand.Encoding == ImmediateValue {
+			value, err := d.immediate(operand.Type)
+			if err != nil {
+				return nil, 0, err
+			}
+			result[i] = value
+		}
+	}
+	return result, d.pos, nil
+}
+
+func (o *Opcode) hasOperandEncoding(encoding OperandEncoding) bool {
+	for _, operand := range o.Operands {
+		if operand.Encoding == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Opcode) hasModRM() bool {
+	for _, ext := range o.OpcodeExtensions {
+		if (ext >= Slash0 && ext <= Slash7) || ext == SlashR {
+			return true
+		}
+	}
+	return o.hasOperandEncoding(ModRM_rm_r) || o.hasOperandEncoding(ModRM_rm_rw) ||
+		o.hasOperandEncoding(ModRM_reg_r) || o.hasOperandEncoding(ModRM_reg_rw)
+}
+
+// Returns the register with the given index for an operand of this type.
+// Without a REX prefix the byte registers 4-7 refer to %ah, %ch, %dh and %bh.
+func (o OperandType) register(ix uint8, rex bool) (*Register, error) {
+	switch o {
+	case OT_r8, OT_rm8:
+		if !rex && ix >= 4 && ix <= 7 {
+			return []*Register{Ah, Ch, Dh, Bh}[ix-4], nil
+		}
+		return Registers8[ix], nil
+	case OT_r16, OT_rm16:
+		return Registers16[ix], nil
+	case OT_r32, OT_rm32:
+		return Registers32[ix], nil
+	case OT_r64, OT_rm64:
+		return Registers64[ix], nil
+	case OT_xmm1, OT_xmm1m64, OT_xmm2, OT_xmm2m64, OT_xmm2m128:
+		return Registers128[ix], nil
+	case OT_ymm1, OT_ymm2, OT_ymm2m128:
+		return Registers256[ix], nil
+	}
+	return nil, fmt.Errorf("Operand type %s can't be a register", o.String())
+}
+
+// Memory operands carry the width of the operand in their (base) register.
+func (o OperandType) memoryRegisters() []*Register {
+	switch o {
+	case OT_rm8:
+		return Registers8
+	case OT_rm16, OT_m16:
+		return Registers16
+	case OT_rm32, OT_m32:
+		return Registers32
+	}
+	return Registers64
+}
+
+func extension(b bool) uint8 {
+	if b {
+		return 8
+	}
+	return 0
+}
+
+type decoder struct {
+	code  []uint8
+	pos   int
+	rex   *REXPrefix
+	vex   *VEXPrefix
+	modrm *ModRM
+	// The register extension bits from either the REX or the VEX prefix.
+	r, x, b bool
+}
+
+func (d *decoder) next() (uint8, error) {
+	if d.pos >= len(d.code) {
+		return 0, fmt.Errorf("Unexpected end of machine code")
+	}
+	d.pos++
+	return d.code[d.pos-1], nil
+}
+
+func (d *decoder) bytes(n int) ([]uint8, error) {
+	if d.pos+n > len(d.code) {
+		return nil, fmt.Errorf("Unexpected end of machine code")
+	}
+	d.pos += n
+	return d.code[d.pos-n : d.pos], nil
+}
+
+func (d *decoder) expect(b uint8) error {
+	got, err := d.next()
+	if err != nil {
+		return err
+	}
+	if got != b {
+		return fmt.Errorf("Expecting 0x%x got 0x%x", b, got)
+	}
+	return nil
+}
+
+func (d *decoder) vexPrefix(o *Opcode) error {
+	vex, length := DecodeVEXPrefix(d.code[d.pos:])
+	if vex == nil {
+		return fmt.Errorf("Expecting VEX prefix for %s", o.String())
+	}
+	d.pos += length
+	pp, mmmmm := VEXOpcodeExtension_None, VEXLegacyByte_None
+	for _, ext := range o.OpcodeExtensions {
+		switch ext {
+		case VEX_66:
+			pp = VEXOpcodeExtension_66
+		case VEX_f2:
+			pp = VEXOpcodeExtension_f2
+		case VEX_f3:
+			pp = VEXOpcodeExtension_f3
+		case VEX_0f:
+			mmmmm = VEXLegacyByte_0f
+		case VEX_0f_38:
+			mmmmm = VEXLegacyByte_0f_38
+		case VEX_0f_3a:
+			mmmmm = VEXLegacyByte_0f_3a
+		}
+	}
+	if vex.L != o.HasExtension(VEX256) || vex.VEXOpcodeExtension != pp || vex.VEXLegacyByte != mmmmm {
+		return fmt.Errorf("VEX prefix mismatch for %s", o.String())
+	}
+	d.vex = vex
+	d.r, d.x, d.b = !vex.R, !vex.X, !vex.B
+	return nil
+}
+
+// Decodes the r/m operand, including the SIB byte and the displacement.
+func (d *decoder) rmOperand(ty OperandType) (lib.Operand, error) {
+	mode, rm := d.modrm.Mode, d.modrm.RM
+	if mode == DirectRegisterMode {
+		return ty.register(rm|extension(d.b), d.rex != nil)
+	}
+	registers := ty.memoryRegisters()
+	if mode == IndirectRegisterMode && rm == 5 {
+		displacement, err := d.bytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return &RIPRelative{Displacement: Int32(binary.LittleEndian.Uint32(displacement))}, nil
+	}
+	base := rm | extension(d.b)
+	if rm == SIBFollowsRM {
+		b, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		sib := DecodeSIB(b)
+		index := sib.Index | extension(d.x)
+		base = sib.Base | extension(d.b)
+		if mode == IndirectRegisterMode && sib.Base == 5 {
+			return nil, fmt.Errorf("Unsupported SIB addressing without base register")
+		}
+		// An index of 4 means that there is no index register, which is
+		// used to address relative to %rsp and %r12.
+		if index != 4 {
+			if mode == IndirectRegisterMode {
+				return &SIBRegister{Registers64[base], Registers64[index], sib.Scale}, nil
+			} else if mode == IndirectRegisterByteDisplacedMode {
+				displacement, err := d.next()
+				if err != nil {
+					return nil, err
+				}
+				// See the special case for register 13 in Opcode.Encode
+				if base == 13 && displacement == 0 {
+					return &SIBRegister{Registers64[base], Registers64[index], sib.Scale}, nil
+				}
+				return &DisplacedSIBRegister{sib.Scale, Registers64[index], Registers64[base], displacement}, nil
+			}
+			return nil, fmt.Errorf("Unsupported SIB addressing with 32 bit displacement")
+		}
+	}
+	if mode == IndirectRegisterMode {
+		return &IndirectRegister{registers[base]}, nil
+	} else if mode == IndirectRegisterByteDisplacedMode {
+		displacement, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		return &DisplacedRegister{registers[base], displacement}, nil
+	}
+	displacement, err := d.bytes(4)
+	if err != nil {
+		return nil, err
+	}
+	return &DisplacedRegister32{registers[base], Int32(binary.LittleEndian.Uint32(displacement))}, nil
+}
+
+func (d *decoder) immediate(ty OperandType) (lib.Operand, error) {
+	switch ty {
+	case OT_imm8, OT_rel8:
+		b, err := d.next()
+		return Uint8(b), err
+	case OT_imm16, OT_rel16:
+		b, err := d.bytes(2)
+		if err != nil {
+			return nil, err
+		}
+		return Uint16(binary.LittleEndian.Uint16(b)), nil
+	case OT_imm32, OT_rel32:
+		b, err := d.bytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return Uint32(binary.LittleEndian.Uint32(b)), nil
+	case OT_imm64:
+		b, err := d.bytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return Uint64(binary.LittleEndian.Uint64(b)), nil
+	}
+	return nil, fmt.Errorf("Operand type %s is not an immediate value", ty.String())
+}
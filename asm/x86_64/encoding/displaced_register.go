@@ -19,3 +19,22 @@ func (t *DisplacedRegister) Type() lib.Type {
 func (t *DisplacedRegister) String() string {
 	return fmt.Sprintf("0x%x(%s)", t.Displacement, t.Register.String())
 }
+
+// A register with a signed 32 bit displacement, e.g. -0x100(%rbp). Shares
+// the lib.T_DisplacedRegister type so that it resolves to the same opcodes
+// as DisplacedRegister.
+type DisplacedRegister32 struct {
+	*Register
+	Displacement Int32
+}
+
+func (t *DisplacedRegister32) Type() lib.Type {
+	return lib.T_DisplacedRegister
+}
+
+func (t *DisplacedRegister32) String() string {
+	if t.Displacement < 0 {
+		return fmt.Sprintf("-0x%x(%s)", int(t.Displacement)*-1, t.Register.String())
+	}
+	return fmt.Sprintf("0x%x(%s)", t.Displacement, t.Register.String())
+}
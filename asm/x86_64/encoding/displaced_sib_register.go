@@ -21,3 +21,7 @@ func (t *DisplacedSIBRegister) Type() lib.Type {
 func (t *DisplacedSIBRegister) String() string {
 	return fmt.Sprintf("0x%x(%s, %s, %s)", t.Displacement, t.Base.String(), t.Index.String(), t.Scale.String())
 }
+
+func (t *DisplacedSIBRegister) Width() lib.Size {
+	return t.Base.Width()
+}
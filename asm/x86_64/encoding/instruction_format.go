@@ -33,8 +33,9 @@ func (i *InstructionFormat) SetModRM(mode Mode, rm, reg uint8) {
 }
 
 func (i *InstructionFormat) SetDisplacement(op lib.Operand, displacement []uint8) {
-	// Not sure why this is needed, but it is
-	if _, ok := op.(*Register); ok && op.(*Register) == Rsp {
+	// An RM of 4 means that a SIB byte follows, so rsp and r12 are
+	// addressed with a SIB byte that has them as the base and no index.
+	if reg, ok := op.(*Register); ok && reg.Register&7 == 4 {
 		i.Displacement = append(i.Displacement, 0x24)
 	}
 	for _, d := range displacement {
@@ -32,3 +32,7 @@ func (m *ModRM) Encode() uint8 {
 	result += (m.Reg & 7) << 3
 	return result + (uint8(m.Mode) << 6)
 }
+
+func DecodeModRM(b uint8) *ModRM {
+	return &ModRM{Mode(b >> 6), b & 7, (b >> 3) & 7}
+}
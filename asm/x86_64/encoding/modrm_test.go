@@ -38,3 +38,16 @@ func Test_EncodeModRM(t *testing.T) {
 		}
 	}
 }
+
+func Test_DecodeModRM(t *testing.T) {
+	for _, modrm := range []*ModRM{
+		NewModRM(DirectRegisterMode, 1, 7),
+		NewModRM(IndirectRegisterMode, 5, 0),
+		NewModRM(IndirectRegisterByteDisplacedMode, SIBFollowsRM, 3),
+	} {
+		unit := DecodeModRM(modrm.Encode())
+		if *unit != *modrm {
+			t.Fatal("Expecting", modrm, "got", unit)
+		}
+	}
+}
@@ -240,6 +240,14 @@ func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 		}
 		exts[ext] = true
 	}
+	// Opcodes that don't ask for a REX prefix still need one to address
+	// r8-r15 and xmm8-xmm15, unless they have a VEX prefix instead.
+	needsREX := func(extended bool) bool {
+		if extended && instr.REXPrefix == nil && instr.VEXPrefix == nil {
+			instr.REXPrefix = &REXPrefix{}
+		}
+		return instr.REXPrefix != nil
+	}
 	for i, opcodeOperand := range o.Operands {
 		op := ops[i]
 		if opcodeOperand.TypeCheck(op) {
@@ -251,7 +259,7 @@ func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 					}
 					instr.ModRM.Mode = DirectRegisterMode
 					instr.ModRM.RM = oper.Encode()
-					if exts[RexW] || exts[Rex] {
+					if needsREX(oper.Register > 7) {
 						instr.REXPrefix.B = oper.Register > 7
 					} else if exts[VEX128] || exts[VEX256] {
 						instr.VEXPrefix.B = oper.Register <= 7
@@ -262,14 +270,14 @@ func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 						instr.ModRM.Mode = DirectRegisterMode
 					}
 					instr.ModRM.Reg = oper.Encode()
-					if exts[RexW] || exts[Rex] {
+					if needsREX(oper.Register > 7) {
 						instr.REXPrefix.R = oper.Register > 7
 					} else if exts[VEX128] || exts[VEX256] {
 						instr.VEXPrefix.R = oper.Register <= 7
 					}
 				} else if opcodeOperand.Encoding == Opcode_plus_rd_r {
 					instr.Opcode[0] += op.(*Register).Register & 7
-					if exts[RexW] || exts[Rex] {
+					if needsREX(op.(*Register).Register > 7) {
 						instr.REXPrefix.B = op.(*Register).Register > 7
 					}
 				} else if opcodeOperand.Encoding == VEX_vvvv {
@@ -283,27 +291,27 @@ func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 					return nil, fmt.Errorf("Unsupported encoding [%s] in %s", opcodeOperand.Encoding.String(), o.String())
 				}
 			} else if op.Type() == lib.T_DisplacedRegister {
-				oper := op.(*DisplacedRegister)
+				oper, mode, displacement := displacedRegister(op)
 				if opcodeOperand.Encoding == ModRM_rm_r || opcodeOperand.Encoding == ModRM_rm_rw {
 					if instr.ModRM == nil {
 						instr.ModRM = &ModRM{}
 					}
-					instr.ModRM.Mode = IndirectRegisterByteDisplacedMode
+					instr.ModRM.Mode = mode
 					instr.ModRM.RM = oper.Encode()
-					instr.SetDisplacement(oper.Register, []uint8{oper.Displacement})
+					instr.SetDisplacement(oper, displacement)
 
-					if exts[RexW] || exts[Rex] {
-						instr.REXPrefix.B = oper.Register.Register > 7
+					if needsREX(oper.Register > 7) {
+						instr.REXPrefix.B = oper.Register > 7
 					}
 				} else if opcodeOperand.Encoding == ModRM_reg_r || opcodeOperand.Encoding == ModRM_reg_rw {
 					if instr.ModRM == nil {
 						instr.ModRM = &ModRM{}
-						instr.ModRM.Mode = IndirectRegisterByteDisplacedMode
+						instr.ModRM.Mode = mode
 					}
 					instr.ModRM.Reg = oper.Encode()
-					instr.SetDisplacement(oper.Register, []uint8{oper.Displacement})
-					if exts[RexW] || exts[Rex] {
-						instr.REXPrefix.R = oper.Register.Register > 7
+					instr.SetDisplacement(oper, displacement)
+					if needsREX(oper.Register > 7) {
+						instr.REXPrefix.R = oper.Register > 7
 					}
 				} else {
 					return nil, fmt.Errorf("Unsupported encoding [%d] in %s", opcodeOperand.Encoding, o.String())
@@ -317,7 +325,7 @@ func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 					instr.ModRM.Mode = IndirectRegisterMode
 					instr.ModRM.RM = oper.Encode()
 
-					if exts[RexW] || exts[Rex] {
+					if needsREX(oper.Register.Register > 7) {
 						instr.REXPrefix.B = oper.Register.Register > 7
 					}
 				} else if opcodeOperand.Encoding == ModRM_reg_r || opcodeOperand.Encoding == ModRM_reg_rw {
@@ -326,7 +334,7 @@ func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 						instr.ModRM.Mode = IndirectRegisterMode
 					}
 					instr.ModRM.Reg = oper.Encode()
-					if exts[RexW] || exts[Rex] {
+					if needsREX(oper.Register.Register > 7) {
 						instr.REXPrefix.R = oper.Register.Register > 7
 					}
 				} else {
@@ -358,7 +366,7 @@ func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 					instr.ModRM.Mode = IndirectRegisterMode
 					instr.ModRM.RM = SIBFollowsRM
 					instr.SIB = NewSIB(oper.Scale, oper.Index.Encode(), oper.Register.Encode())
-					if exts[RexW] || exts[Rex] {
+					if needsREX(oper.Index.Register > 7 || oper.Register.Register > 7) {
 						instr.REXPrefix.X = oper.Index.Register > 7
 						instr.REXPrefix.B = oper.Register.Register > 7
 					}
@@ -400,6 +408,16 @@ func (o *Opcode) Encode(ops []lib.Operand) ([]uint8, error) {
 	return instr.Encode(), nil
 }
 
+// Returns the base register, the ModRM mode and the displacement bytes for
+// both the 8 and 32 bit displaced register forms.
+func displacedRegister(op lib.Operand) (*Register, Mode, []uint8) {
+	if oper, ok := op.(*DisplacedRegister32); ok {
+		return oper.Register, IndirectRegisterDoubleDisplacedMode, oper.Displacement.Encode()
+	}
+	oper := op.(*DisplacedRegister)
+	return oper.Register, IndirectRegisterByteDisplacedMode, []uint8{oper.Displacement}
+}
+
 func (o *Opcode) String() string {
 	args := []string{}
 	for _, ops := range o.Operands {
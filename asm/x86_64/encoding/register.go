@@ -87,17 +87,17 @@ var Registers8 []*Register = []*Register{
 
 var Registers128 []*Register = []*Register{
 	Xmm0, Xmm1, Xmm2, Xmm3, Xmm4, Xmm5, Xmm6, Xmm7,
-	Xmm8, Xmm9, Xmm10, Xmm11, Xmm11, Xmm12, Xmm13, Xmm14, Xmm15,
+	Xmm8, Xmm9, Xmm10, Xmm11, Xmm12, Xmm13, Xmm14, Xmm15,
 }
 
 var Registers256 []*Register = []*Register{
 	Ymm0, Ymm1, Ymm2, Ymm3, Ymm4, Ymm5, Ymm6, Ymm7,
-	Ymm8, Ymm9, Ymm10, Ymm11, Ymm11, Ymm12, Ymm13, Ymm14, Ymm15,
+	Ymm8, Ymm9, Ymm10, Ymm11, Ymm12, Ymm13, Ymm14, Ymm15,
 }
 
 var Registers512 []*Register = []*Register{
 	Zmm0, Zmm1, Zmm2, Zmm3, Zmm4, Zmm5, Zmm6, Zmm7,
-	Zmm8, Zmm9, Zmm10, Zmm11, Zmm11, Zmm12, Zmm13, Zmm14, Zmm15,
+	Zmm8, Zmm9, Zmm10, Zmm11, Zmm12, Zmm13, Zmm14, Zmm15,
 }
 
 var (
@@ -37,3 +37,16 @@ func (r *REXPrefix) Encode() uint8 {
 	}
 	return result + (1 << 6)
 }
+
+// Returns nil if the byte is not a REX prefix.
+func DecodeREXPrefix(b uint8) *REXPrefix {
+	if b&0xf0 != 0x40 {
+		return nil
+	}
+	return &REXPrefix{
+		W: b&(1<<3) != 0,
+		R: b&(1<<2) != 0,
+		X: b&(1<<1) != 0,
+		B: b&1 != 0,
+	}
+}
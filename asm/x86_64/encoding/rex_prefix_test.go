@@ -30,3 +30,18 @@ func Test_EncodeREXPrefix(t *testing.T) {
 		t.Fatal("Expecting", expected, "got", unit)
 	}
 }
+
+func Test_DecodeREXPrefix(t *testing.T) {
+	for _, rex := range []*REXPrefix{
+		NewREXPrefix(true, false, false, true),
+		NewREXPrefix(false, true, true, false),
+	} {
+		unit := DecodeREXPrefix(rex.Encode())
+		if unit == nil || *unit != *rex {
+			t.Fatal("Expecting", rex, "got", unit)
+		}
+	}
+	if unit := DecodeREXPrefix(0x8b); unit != nil {
+		t.Fatal("Expecting nil got", unit)
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"github.com/bspaans/jit-compiler/lib"
 )
 
-// Get address relative to instruction pointer
+// Get address relative to instruction pointer. If a Label is given, the
+// displacement is filled in when the instructions are resolved (see
+// lib.Instructions.Resolve).
 type RIPRelative struct {
 	Displacement Int32
+	Label        *lib.Label
 }
 
 func (t *RIPRelative) Type() lib.Type {
@@ -16,6 +19,9 @@ func (t *RIPRelative) Type() lib.Type {
 }
 
 func (t *RIPRelative) String() string {
+	if t.Label != nil {
+		return fmt.Sprintf("%s(%%rip)", t.Label.String())
+	}
 	if t.Displacement < 0 {
 		return fmt.Sprintf("-$0x%x(%%rip)", int(t.Displacement)*-1)
 	} else {
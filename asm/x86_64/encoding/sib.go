@@ -48,3 +48,7 @@ func (s *SIB) Encode() uint8 {
 	result += (uint8(s.Scale) << 6)
 	return result
 }
+
+func DecodeSIB(b uint8) *SIB {
+	return &SIB{Scale(b >> 6), (b >> 3) & 7, b & 7}
+}
@@ -28,3 +28,11 @@ func Test_ScaleString(t *testing.T) {
 		t.Fatal("Expecting 8, got", Scale8.String())
 	}
 }
+
+func Test_DecodeSIB(t *testing.T) {
+	sib := NewSIB(Scale8, 7, 1)
+	unit := DecodeSIB(sib.Encode())
+	if *unit != *sib {
+		t.Fatal("Expecting", sib, "got", unit)
+	}
+}
@@ -128,3 +128,34 @@ func (v *VEXPrefix) Encode() []uint8 {
 	byte2 += uint8(v.VEXOpcodeExtension)
 	return []uint8{byte0, byte1, byte2}
 }
+
+// Decodes a two or three byte VEX prefix. Returns the prefix and its length,
+// or nil if the code doesn't start with a VEX prefix. Like in the encoded
+// form the R, X, B and Source fields are inverted.
+func DecodeVEXPrefix(code []uint8) (*VEXPrefix, int) {
+	if len(code) >= 2 && code[0] == 0xc5 {
+		return &VEXPrefix{
+			R:                  code[1]&(1<<7) != 0,
+			X:                  true,
+			B:                  true,
+			W:                  false,
+			Source:             (code[1] >> 3) & 0xf,
+			L:                  code[1]&(1<<2) != 0,
+			VEXOpcodeExtension: VEXOpcodeExtension(code[1] & 3),
+			VEXLegacyByte:      VEXLegacyByte_0f,
+		}, 2
+	}
+	if len(code) >= 3 && code[0] == 0xc4 {
+		return &VEXPrefix{
+			R:                  code[1]&(1<<7) != 0,
+			X:                  code[1]&(1<<6) != 0,
+			B:                  code[1]&(1<<5) != 0,
+			VEXLegacyByte:      VEXLegacyByte(code[1] & 0x1f),
+			W:                  code[2]&(1<<7) != 0,
+			Source:             (code[2] >> 3) & 0xf,
+			L:                  code[2]&(1<<2) != 0,
+			VEXOpcodeExtension: VEXOpcodeExtension(code[2] & 3),
+		}, 3
+	}
+	return nil, 0
+}
@@ -0,0 +1,108 @@
+package opcodes
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// All the opcodes, in the order in which the disassembler tries them.
+// Opcodes that share an encoding (e.g. JBE and JNA) decode to the first one
+// in the list.
+var Opcodes = []*Opcode{
+	ADD_rm8_r8, ADD_r8_rm8, ADD_rm16_r16, ADD_r16_rm16, ADD_rm32_r32,
+	ADD_r32_rm32, ADD_rm64_r64, ADD_r64_rm64, ADD_rm64_imm32,
+	ADDPD_xmm1_xmm2m128,
+	ADDSD_xmm1_xmm2m64,
+	AND_r8_rm8, AND_r8_rm8_no_rex, AND_rm8_r8, AND_rm8_r8_no_rex,
+	AND_rm16_r16, AND_r16_rm16, AND_rm32_r32, AND_r32_rm32, AND_rm64_r64,
+	AND_r64_rm64, AND_rm64_imm8,
+	CALL_rel32, CALL_rm64,
+	CMP_rm8_imm8, CMP_rm8_imm8_no_rex, CMP_rm64_imm32, CMP_r8_rm8,
+	CMP_r8_rm8_no_rex, CMP_rm8_r8, CMP_rm8_r8_no_rex, CMP_r16_rm16,
+	CMP_rm16_r16, CMP_r32_rm32, CMP_rm32_r32, CMP_r64_rm64, CMP_rm64_r64,
+	COMISD_xmm1_xmm2m64,
+	CVTSI2SD_xmm1_rm64,
+	CVTSD2SI_r64_xmm1m64,
+	CVTTSD2SI_r64_xmm1m64,
+	CBW, CWD, CDQ, CQO,
+	DEC_rm64,
+	DIV_rm8, DIV_rm16, DIV_rm32, DIV_rm64,
+	DIVSD_xmm1_xmm2m64,
+	IDIV_rm8, IDIV_rm8_no_rex, IDIV_rm16, IDIV_rm32, IDIV_rm64,
+	INC_rm64,
+	IMUL_rm8, IMUL_rm8_no_rex, IMUL_rm16, IMUL_rm32, IMUL_rm64, IMUL_r64_rm64,
+	JMP_rel8, JMP_rel32, JMP_rm64,
+	JA_rel8, JA_rel32, JAE_rel8, JAE_rel32, JB_rel8, JB_rel32, JBE_rel8,
+	JBE_rel32, JE_rel8, JE_rel32, JG_rel8, JG_rel32, JGE_rel8, JGE_rel32,
+	JL_rel8, JL_rel32, JLE_rel8, JLE_rel32, JNA_rel8, JNA_rel32, JNAE_rel8,
+	JNAE_rel32, JNB_rel8, JNB_rel32, JNBE_rel8, JNBE_rel32, JNE_rel8,
+	JNE_rel32, JNG_rel8, JNG_rel32, JNGE_rel8, JNGE_rel32, JNL_rel8,
+	JNL_rel32, JNLE_rel8, JNLE_rel32, JNP_rel8, JNP_rel32, JP_rel8, JP_rel32,
+	LEA_r64_m,
+	MOV_rm8_r8, MOV_r8_rm8, MOV_r8_imm8_no_rex, MOV_r8_imm8, MOV_rm16_r16,
+	MOV_rm16_r16_no_rex, MOV_r16_rm16, MOV_r16_rm16_no_rex, MOV_r16_imm16,
+	MOV_r16_imm16_no_rex, MOV_r32_imm32, MOV_r32_imm32_no_rex, MOV_rm32_r32,
+	MOV_rm32_r32_no_rex, MOV_r32_rm32, MOV_r32_rm32_no_rex,
+	MOV_rm64_r64, MOV_r64_rm64, MOV_r64_imm64, MOV_rm64_imm32,
+	MOVQ_xmm_rm64, MOVQ_r64_xmm,
+	MOVSD_xmm1m64_xmm2,
+	MOVSX_r16_rm8, MOVSX_r32_rm8, MOVSX_r32_rm16, MOVSX_r64_rm8,
+	MOVSX_r64_rm16, MOVSX_r64_rm32,
+	MOVZX_r16_rm8, MOVZX_r32_rm8, MOVZX_r64_rm8, MOVZX_r32_rm16,
+	MOVZX_r64_rm16,
+	MUL_rm8, MUL_rm16, MUL_rm32, MUL_rm64,
+	MULSD_xmm1_xmm2m64,
+	NOT_rm8, NOT_rm8_no_rex, NOT_rm16, NOT_rm32, NOT_rm64,
+	OR_r8_rm8, OR_r8_rm8_no_rex, OR_rm8_r8, OR_rm8_r8_no_rex, OR_rm16_r16,
+	OR_r16_rm16, OR_rm32_r32, OR_r32_rm32, OR_rm64_r64, OR_r64_rm64,
+	PUSH_imm32, PUSH_r64,
+	PUSHFQ,
+	POP_r64,
+	RETURN,
+	SAR_rm8_imm8, SAR_rm8_imm8_no_rex, SAR_rm16_imm8, SAR_rm32_imm8, SAR_rm64_imm8,
+	SAR_rm8_cl, SAR_rm8_cl_no_rex, SAR_rm16_cl, SAR_rm32_cl, SAR_rm64_cl,
+	SETA_rm8, SETA_rm8_no_rex, SETAE_rm8, SETAE_rm8_no_rex, SETB_rm8,
+	SETB_rm8_no_rex, SETBE_rm8, SETBE_rm8_no_rex, SETC_rm8, SETE_rm8,
+	SETE_rm8_no_rex, SETL_rm8, SETL_rm8_no_rex, SETLE_rm8, SETLE_rm8_no_rex,
+	SETG_rm8, SETG_rm8_no_rex, SETGE_rm8, SETGE_rm8_no_rex, SETNE_rm8,
+	SETNP_rm8, SETNP_rm8_no_rex, SETP_rm8, SETP_rm8_no_rex,
+	SHL_rm8_imm8, SHL_rm8_imm8_no_rex, SHL_rm16_imm8, SHL_rm32_imm8,
+	SHL_rm64_imm8,
+	SHL_rm8_cl, SHL_rm8_cl_no_rex, SHL_rm16_cl, SHL_rm32_cl, SHL_rm64_cl,
+	SHR_rm8_imm8, SHR_rm8_imm8_no_rex, SHR_rm16_imm8, SHR_rm32_imm8,
+	SHR_rm64_imm8,
+	SHR_rm8_cl, SHR_rm8_cl_no_rex, SHR_rm16_cl, SHR_rm32_cl, SHR_rm64_cl,
+	SUB_rm8_imm8, SUB_r8_rm8, SUB_rm8_r8, SUB_rm16_r16, SUB_r16_rm16,
+	SUB_rm32_r32, SUB_r32_rm32, SUB_rm64_r64, SUB_r64_rm64, SUB_rm64_imm8,
+	SUB_rm64_imm32,
+	SUBSD_xmm1_xmm2m64,
+	SYSCALL,
+	UCOMISD_xmm1_xmm2m64,
+	UD2,
+	VPADDB_xmm1_xmm2_xmm3m128, VPADDB_ymm1_ymm2_ymm3m128,
+	VPADDW_xmm1_xmm2_xmm3m128, VPADDW_ymm1_ymm2_ymm3m128,
+	VPADDD_xmm1_xmm2_xmm3m128, VPADDD_ymm1_ymm2_ymm3m128,
+	VPADDQ_xmm1_xmm2_xmm3m128, VPADDQ_ymm1_ymm2_ymm3m128,
+	VPAND_xmm1_xmm2_xmm3m128, VPAND_ymm1_ymm2_ymm3m128,
+	VPOR_xmm1_xmm2_xmm3m128, VPOR_ymm1_ymm2_ymm3m128,
+	XOR_r8_rm8, XOR_r8_rm8_no_rex, XOR_rm8_r8, XOR_rm8_r8_no_rex,
+	XOR_r16_rm16, XOR_rm16_r16, XOR_r32_rm32, XOR_rm32_r32, XOR_rm64_imm32,
+	XOR_rm64_r64, XOR_r64_rm64,
+}
+
+// Decodes the instruction at the start of the machine code. Returns the
+// instruction and its length in bytes.
+func DecodeInstruction(code []uint8) (lib.Instruction, int, error) {
+	for _, opcode := range Opcodes {
+		operands, length, err := opcode.Decode(code)
+		if err == nil {
+			return OpcodeToInstruction(opcode.Name, opcode, len(operands), operands...), length, nil
+		}
+	}
+	if len(code) > 15 {
+		code = code[:15]
+	}
+	return nil, 0, fmt.Errorf("Unknown instruction: %s", lib.MachineCode(code).String())
+}
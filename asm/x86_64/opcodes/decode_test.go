@@ -0,0 +1,132 @@
+package opcodes
+
+import (
+	"testing"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Operands for every operand type, covering the different registers and
+// addressing modes. Not every opcode supports every operand, e.g. %ah can't
+// be used in opcodes that have a REX prefix, so the ones that don't encode
+// are skipped.
+var roundTripOperands = map[encoding.OperandType][]lib.Operand{
+	encoding.OT_rel8:  []lib.Operand{encoding.Uint8(0xfe)},
+	encoding.OT_rel32: []lib.Operand{encoding.Uint32(0x12345678)},
+	encoding.OT_imm8:  []lib.Operand{encoding.Uint8(0x12)},
+	encoding.OT_imm16: []lib.Operand{encoding.Uint16(0x1234)},
+	encoding.OT_imm32: []lib.Operand{encoding.Uint32(0x12345678)},
+	encoding.OT_imm64: []lib.Operand{encoding.Uint64(0x123456789abcdef0)},
+	encoding.OT_r8:    []lib.Operand{encoding.Dl, encoding.R10b, encoding.Ah, encoding.Sil},
+	encoding.OT_r16:   []lib.Operand{encoding.Dx, encoding.R10w},
+	encoding.OT_r32:   []lib.Operand{encoding.Edx, encoding.R10d},
+	encoding.OT_r64:   []lib.Operand{encoding.Rdx, encoding.R10},
+	encoding.OT_rm8: []lib.Operand{encoding.Cl, encoding.R9b, encoding.Bh, encoding.Dil,
+		&encoding.IndirectRegister{encoding.Bl},
+		&encoding.DisplacedRegister{encoding.Bpl, 0x10},
+	},
+	encoding.OT_rm16: []lib.Operand{encoding.Cx, encoding.R9w,
+		&encoding.IndirectRegister{encoding.Bx},
+		&encoding.DisplacedRegister{encoding.Bp, 0x10},
+	},
+	encoding.OT_rm32: []lib.Operand{encoding.Ecx, encoding.R9d,
+		&encoding.IndirectRegister{encoding.Ebx},
+		&encoding.DisplacedRegister{encoding.Ebp, 0x10},
+	},
+	encoding.OT_rm64: []lib.Operand{encoding.Rcx, encoding.R9,
+		&encoding.IndirectRegister{encoding.Rbx},
+		&encoding.DisplacedRegister{encoding.Rbp, 0xf8},
+		&encoding.DisplacedRegister{encoding.Rsp, 0x8},
+		&encoding.DisplacedRegister{encoding.R12, 0x8},
+		&encoding.DisplacedRegister32{encoding.R14, -0x100},
+		&encoding.SIBRegister{encoding.Rcx, encoding.Rax, encoding.Scale8},
+		&encoding.SIBRegister{encoding.R13, encoding.R9, encoding.Scale4},
+		&encoding.RIPRelative{Displacement: -0x20},
+	},
+	encoding.OT_m: []lib.Operand{
+		&encoding.DisplacedRegister{encoding.Rbp, 0xf8},
+		&encoding.RIPRelative{Displacement: 0x20},
+	},
+	encoding.OT_xmm1: []lib.Operand{encoding.Xmm3, encoding.Xmm11},
+	encoding.OT_xmm2: []lib.Operand{encoding.Xmm4, encoding.Xmm12},
+	encoding.OT_xmm1m64: []lib.Operand{encoding.Xmm5,
+		&encoding.DisplacedRegister{encoding.Rbp, 0xf0},
+		&encoding.SIBRegister{encoding.Rcx, encoding.Rax, encoding.Scale8},
+		&encoding.RIPRelative{Displacement: 0x20},
+	},
+	encoding.OT_xmm2m64: []lib.Operand{encoding.Xmm6, encoding.Xmm14,
+		&encoding.DisplacedRegister{encoding.Rbp, 0xf0},
+		&encoding.DisplacedRegister{encoding.R12, 0x8},
+		&encoding.SIBRegister{encoding.Rcx, encoding.Rax, encoding.Scale8},
+		&encoding.RIPRelative{Displacement: 0x20},
+	},
+	encoding.OT_xmm2m128: []lib.Operand{encoding.Xmm7},
+	encoding.OT_ymm1:     []lib.Operand{encoding.Ymm1},
+	encoding.OT_ymm2:     []lib.Operand{encoding.Ymm2},
+	encoding.OT_ymm2m128: []lib.Operand{encoding.Ymm3},
+}
+
+func Test_Decode_RoundTrip(t *testing.T) {
+	for _, opcode := range Opcodes {
+		combinations := 1
+		for _, operand := range opcode.Operands {
+			if len(roundTripOperands[operand.Type]) > combinations {
+				combinations = len(roundTripOperands[operand.Type])
+			}
+		}
+		encoded := 0
+		for c := 0; c < combinations; c++ {
+			operands := []lib.Operand{}
+			for _, operand := range opcode.Operands {
+				options := roundTripOperands[operand.Type]
+				if len(options) == 0 {
+					t.Fatal("Missing round trip operands for", operand.Type, "in", opcode)
+				}
+				operands = append(operands, options[c%len(options)])
+			}
+			if len(operands) > 0 && OpcodesToOpcodeMaps([]*encoding.Opcode{opcode}, len(operands)).ResolveOpcode(operands) != opcode {
+				continue
+			}
+			instr := OpcodeToInstruction(opcode.Name, opcode, len(operands), operands...)
+			code, err := instr.Encode()
+			if err != nil {
+				t.Fatal(err, "in", instr)
+			}
+			encoded++
+			decoded, length, err := DecodeInstruction(code)
+			if err != nil {
+				t.Fatal(err, "in", instr, code)
+			}
+			if length != len(code) {
+				t.Fatal("Expecting length", len(code), "got", length, "in", instr, code)
+			}
+			reencoded, err := decoded.Encode()
+			if err != nil {
+				t.Fatal(err, "in", decoded, "decoded from", instr)
+			}
+			if reencoded.String() != code.String() {
+				t.Fatal("Expecting", code, "got", reencoded, "in", decoded, "decoded from", instr)
+			}
+			for i, operand := range decoded.(*opcodeMapsInstruction).Operands {
+				if operand.String() != operands[i].String() || operand.Type() != operands[i].Type() {
+					t.Fatal("Expecting operand", operands[i], "got", operand, "in", decoded, "decoded from", instr)
+				}
+			}
+		}
+		if encoded == 0 {
+			t.Error("No operands could be encoded for", opcode)
+		}
+	}
+}
+
+func Test_DecodeInstruction_unknown(t *testing.T) {
+	_, _, err := DecodeInstruction([]uint8{0x0f, 0xff})
+	if err == nil {
+		t.Fatal("Expecting an error")
+	}
+	_, _, err = DecodeInstruction([]uint8{0x48, 0x8b})
+	if err == nil {
+		t.Fatal("Expecting an error for truncated machine code")
+	}
+}
@@ -27,8 +27,9 @@ var AND = []*Opcode{
 	AND_rm32_r32,
 	AND_r64_rm64,
 	AND_rm64_r64,
+	AND_rm64_imm8,
 }
-var CALL = []*Opcode{CALL_rm64}
+var CALL = []*Opcode{CALL_rel32, CALL_rm64}
 var CMP = []*Opcode{
 	CMP_rm8_imm8,
 	CMP_rm8_imm8_no_rex,
@@ -44,6 +45,7 @@ var CMP = []*Opcode{
 	CMP_rm64_r64,
 	CMP_rm64_imm32,
 }
+var COMISD = []*Opcode{COMISD_xmm1_xmm2m64}
 var CVTSI2SD = []*Opcode{CVTSI2SD_xmm1_rm64}
 var CVTSD2SI = []*Opcode{CVTSD2SI_r64_xmm1m64}
 var CVTTSD2SI = []*Opcode{CVTTSD2SI_r64_xmm1m64}
@@ -80,35 +82,37 @@ var MUL = []*Opcode{
 }
 var INC = []*Opcode{INC_rm64}
 var JMP = []*Opcode{JMP_rel8, JMP_rel32, JMP_rm64}
-var JA = []*Opcode{JA_rel8}
-var JAE = []*Opcode{JAE_rel8}
-var JB = []*Opcode{JB_rel8}
-var JBE = []*Opcode{JBE_rel8}
-var JE = []*Opcode{JE_rel8}
-var JG = []*Opcode{JG_rel8}
-var JGE = []*Opcode{JGE_rel8}
-var JL = []*Opcode{JL_rel8}
-var JLE = []*Opcode{JLE_rel8}
-var JNA = []*Opcode{JNA_rel8}
-var JNAE = []*Opcode{JNAE_rel8}
-var JNB = []*Opcode{JNB_rel8}
-var JNBE = []*Opcode{JNBE_rel8}
-var JNE = []*Opcode{JNE_rel8}
-var JNG = []*Opcode{JNG_rel8}
-var JNGE = []*Opcode{JNGE_rel8}
-var JNL = []*Opcode{JNL_rel8}
-var JNLE = []*Opcode{JNLE_rel8}
+var JA = []*Opcode{JA_rel8, JA_rel32}
+var JAE = []*Opcode{JAE_rel8, JAE_rel32}
+var JB = []*Opcode{JB_rel8, JB_rel32}
+var JBE = []*Opcode{JBE_rel8, JBE_rel32}
+var JE = []*Opcode{JE_rel8, JE_rel32}
+var JG = []*Opcode{JG_rel8, JG_rel32}
+var JGE = []*Opcode{JGE_rel8, JGE_rel32}
+var JL = []*Opcode{JL_rel8, JL_rel32}
+var JLE = []*Opcode{JLE_rel8, JLE_rel32}
+var JNA = []*Opcode{JNA_rel8, JNA_rel32}
+var JNAE = []*Opcode{JNAE_rel8, JNAE_rel32}
+var JNB = []*Opcode{JNB_rel8, JNB_rel32}
+var JNBE = []*Opcode{JNBE_rel8, JNBE_rel32}
+var JNE = []*Opcode{JNE_rel8, JNE_rel32}
+var JNG = []*Opcode{JNG_rel8, JNG_rel32}
+var JNGE = []*Opcode{JNGE_rel8, JNGE_rel32}
+var JNL = []*Opcode{JNL_rel8, JNL_rel32}
+var JNLE = []*Opcode{JNLE_rel8, JNLE_rel32}
+var JNP = []*Opcode{JNP_rel8, JNP_rel32}
+var JP = []*Opcode{JP_rel8, JP_rel32}
 var LEA = []*Opcode{LEA_r64_m}
 var MOV = []*Opcode{
 	MOV_r8_imm8_no_rex,
 	MOV_rm8_r8, MOV_r8_rm8, MOV_r8_imm8,
-	MOV_rm16_r16, MOV_r16_rm16,
-	MOV_r16_imm16,
-	MOV_r32_imm32,
-	MOV_rm32_r32, MOV_r32_rm32,
+	MOV_rm16_r16, MOV_rm16_r16_no_rex, MOV_r16_rm16, MOV_r16_rm16_no_rex,
+	MOV_r16_imm16, MOV_r16_imm16_no_rex,
+	MOV_r32_imm32, MOV_r32_imm32_no_rex,
+	MOV_rm32_r32, MOV_rm32_r32_no_rex, MOV_r32_rm32, MOV_r32_rm32_no_rex,
 	MOV_rm64_r64, MOV_r64_rm64,
 	MOV_r64_imm64, MOV_rm64_imm32,
-	MOVQ_xmm_rm64, MOVSD_xmm1m64_xmm2,
+	MOVQ_xmm_rm64, MOVSD_xmm1m64_xmm2, MOVQ_r64_xmm,
 }
 var MOVSX = []*Opcode{
 	MOVSX_r16_rm8,
@@ -126,6 +130,13 @@ var MOVZX = []*Opcode{
 	MOVZX_r64_rm16,
 }
 
+var NOT = []*Opcode{
+	NOT_rm8,
+	NOT_rm8_no_rex,
+	NOT_rm16,
+	NOT_rm32,
+	NOT_rm64,
+}
 var OR = []*Opcode{
 	OR_r8_rm8,
 	OR_r8_rm8_no_rex,
@@ -140,6 +151,20 @@ var OR = []*Opcode{
 }
 var POP = []*Opcode{POP_r64}
 var PUSH = []*Opcode{PUSH_imm32, PUSH_r64}
+var SAR = []*Opcode{
+	SAR_rm8_imm8,
+	SAR_rm8_imm8_no_rex,
+	SAR_rm16_imm8,
+	SAR_rm32_imm8,
+	SAR_rm64_imm8,
+}
+var SAR_CL = []*Opcode{
+	SAR_rm8_cl,
+	SAR_rm8_cl_no_rex,
+	SAR_rm16_cl,
+	SAR_rm32_cl,
+	SAR_rm64_cl,
+}
 var SETA = []*Opcode{
 	SETA_rm8,
 	SETA_rm8_no_rex,
@@ -162,6 +187,14 @@ var SETE = []*Opcode{
 	SETE_rm8_no_rex,
 }
 var SETNE = []*Opcode{SETNE_rm8}
+var SETNP = []*Opcode{
+	SETNP_rm8,
+	SETNP_rm8_no_rex,
+}
+var SETP = []*Opcode{
+	SETP_rm8,
+	SETP_rm8_no_rex,
+}
 var SETL = []*Opcode{
 	SETL_rm8,
 	SETL_rm8_no_rex,
@@ -186,6 +219,13 @@ var SHL = []*Opcode{
 	SHL_rm32_imm8,
 	SHL_rm64_imm8,
 }
+var SHL_CL = []*Opcode{
+	SHL_rm8_cl,
+	SHL_rm8_cl_no_rex,
+	SHL_rm16_cl,
+	SHL_rm32_cl,
+	SHL_rm64_cl,
+}
 var SHR = []*Opcode{
 	SHR_rm8_imm8,
 	SHR_rm8_imm8_no_rex,
@@ -193,6 +233,13 @@ var SHR = []*Opcode{
 	SHR_rm32_imm8,
 	SHR_rm64_imm8,
 }
+var SHR_CL = []*Opcode{
+	SHR_rm8_cl,
+	SHR_rm8_cl_no_rex,
+	SHR_rm16_cl,
+	SHR_rm32_cl,
+	SHR_rm64_cl,
+}
 var SUB = []*Opcode{
 	SUB_rm8_imm8, SUB_rm64_imm8,
 	SUB_r8_rm8,
@@ -202,6 +249,7 @@ var SUB = []*Opcode{
 	SUBSD_xmm1_xmm2m64,
 }
 
+var UCOMISD = []*Opcode{UCOMISD_xmm1_xmm2m64}
 var VPADDB = []*Opcode{
 	VPADDB_xmm1_xmm2_xmm3m128,
 	VPADDB_ymm1_ymm2_ymm3m128,
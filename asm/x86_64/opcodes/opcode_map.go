@@ -22,15 +22,20 @@ func (o OpcodeMap) add(ty lib.Type, si lib.Size, op *Opcode) {
 
 type OpcodeMaps []OpcodeMap
 
+// Returns the opcode that encodes the operands, or nil if there is none.
+// The choice has to be deterministic, because the length of an instruction
+// is computed before it gets encoded (see lib.Instructions.Resolve). When
+// both a REX and a non-REX form match, the shorter non-REX form is used.
 func (o OpcodeMaps) ResolveOpcode(operands []lib.Operand) *Opcode {
 	picks := map[*Opcode]bool{}
+	// The candidates in the order in which they were declared
+	opcodes := []*Opcode{}
 
 	for i, opcodeMap := range o {
 		oper := operands[i]
 		if oper == nil {
 			return nil
 		}
-		reg, isRegister := oper.(*Register)
 		matches := opcodeMap[oper.Type()][oper.Width()]
 		if len(matches) == 0 {
 			return nil
@@ -40,13 +45,16 @@ func (o OpcodeMaps) ResolveOpcode(operands []lib.Operand) *Opcode {
 			if (oper == encoding.Ah || oper == encoding.Ch || oper == encoding.Dh || oper == encoding.Bh) && (opcode.HasExtension(Rex) || opcode.HasExtension(RexW)) {
 				continue
 			}
-			if (oper == encoding.Spl || oper == encoding.Bpl || oper == encoding.Sil || oper == encoding.Dil ||
-				(isRegister && reg.Register >= 8)) && !(opcode.HasExtension(Rex) || opcode.HasExtension(RexW) || opcode.HasExtension(VEX128) || opcode.HasExtension(VEX256)) {
+			// Registers r8-r15 get a REX prefix when the opcode is encoded,
+			// but these registers are only addressable with one.
+			if (oper == encoding.Spl || oper == encoding.Bpl || oper == encoding.Sil || oper == encoding.Dil) &&
+				!(opcode.HasExtension(Rex) || opcode.HasExtension(RexW) || opcode.HasExtension(VEX128) || opcode.HasExtension(VEX256)) {
 				continue
 			}
 
 			if i == 0 {
 				newPick[opcode] = true
+				opcodes = append(opcodes, opcode)
 			} else {
 				if picks[opcode] {
 					newPick[opcode] = true
@@ -55,19 +63,23 @@ func (o OpcodeMaps) ResolveOpcode(operands []lib.Operand) *Opcode {
 		}
 		picks = newPick
 	}
-	opcodes := []*Opcode{}
-	for pick, _ := range picks {
-		opcodes = append(opcodes, pick)
+	candidates := []*Opcode{}
+	for _, opcode := range opcodes {
+		if picks[opcode] {
+			candidates = append(candidates, opcode)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
 	}
 
-	sort.Slice(opcodes, func(i, j int) bool {
-		return opcodes[i].Operands[0].Type < opcodes[j].Operands[0].Type
-
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Operands[0].Type != candidates[j].Operands[0].Type {
+			return candidates[i].Operands[0].Type < candidates[j].Operands[0].Type
+		}
+		return !candidates[i].HasExtension(Rex) && candidates[j].HasExtension(Rex)
 	})
-	for _, _ = range picks {
-		return opcodes[0]
-	}
-	return nil
+	return candidates[0]
 }
 
 func NewOpcodeMap() OpcodeMap {
@@ -166,12 +178,14 @@ func OpcodesToOpcodeMap(opcodes []*Opcode, operand int) OpcodeMap {
 			opcodeMap.add(lib.T_Register, lib.OWORD, opcode)
 			opcodeMap.add(lib.T_Register, lib.QUADWORD, opcode)
 			opcodeMap.add(lib.T_IndirectRegister, lib.QUADWORD, opcode)
+			opcodeMap.add(lib.T_DisplacedRegister, lib.QUADWORD, opcode)
 			opcodeMap.add(lib.T_RIPRelative, lib.QUADWORD, opcode)
 			opcodeMap.add(lib.T_SIBRegister, lib.QUADWORD, opcode)
 		} else if opcode.Operands[operand].Type == OT_xmm2m64 {
 			opcodeMap.add(lib.T_Register, lib.OWORD, opcode)
 			opcodeMap.add(lib.T_Register, lib.QUADWORD, opcode)
 			opcodeMap.add(lib.T_IndirectRegister, lib.QUADWORD, opcode)
+			opcodeMap.add(lib.T_DisplacedRegister, lib.QUADWORD, opcode)
 			opcodeMap.add(lib.T_RIPRelative, lib.QUADWORD, opcode)
 			opcodeMap.add(lib.T_SIBRegister, lib.QUADWORD, opcode)
 		} else if opcode.Operands[operand].Type == OT_xmm2m128 {
@@ -182,6 +196,8 @@ func OpcodesToOpcodeMap(opcodes []*Opcode, operand int) OpcodeMap {
 			opcodeMap.add(lib.T_Register, lib.YWORD, opcode)
 		} else if opcode.Operands[operand].Type == OT_ymm2 {
 			opcodeMap.add(lib.T_Register, lib.YWORD, opcode)
+		} else if opcode.Operands[operand].Type == OT_ymm2m128 {
+			opcodeMap.add(lib.T_Register, lib.YWORD, opcode)
 		}
 	}
 	return opcodeMap
@@ -134,6 +134,19 @@ var (
 			OpcodeOperand{OT_rm64, ModRM_rm_r},
 		},
 	}
+	// r/m64 AND imm8 (sign-extended)
+	AND_rm64_imm8 = &Opcode{"and", []uint8{}, []uint8{0x83}, []OpcodeExtensions{RexW, Slash4, ImmediateByte},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm64, ModRM_rm_rw},
+			OpcodeOperand{OT_imm8, ImmediateValue},
+		},
+	}
+	// Call near, relative, displacement relative to next instruction
+	CALL_rel32 = &Opcode{"call", []uint8{}, []uint8{0xe8}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	CALL_rm64 = &Opcode{"call", []uint8{}, []uint8{0xff}, []OpcodeExtensions{Slash2},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rm64, ModRM_rm_rw},
@@ -217,6 +230,13 @@ var (
 			OpcodeOperand{OT_r64, ModRM_reg_r},
 		},
 	}
+	// Compare low double-precision floating-point values in xmm1 and xmm2/mem64 and set the EFLAGS flags accordingly (ZF, PF and CF are set if unordered)
+	COMISD_xmm1_xmm2m64 = &Opcode{"comisd", []uint8{0x66}, []uint8{0x0f, 0x2f}, []OpcodeExtensions{SlashR},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_xmm1, ModRM_reg_r},
+			OpcodeOperand{OT_xmm2m64, ModRM_rm_r},
+		},
+	}
 	// Convert Doubleword integer to Scalar Double-precision floating-point value
 	CVTSI2SD_xmm1_rm64 = &Opcode{"cvtsi2sd", []uint8{0xf2}, []uint8{0x0f, 0x2a}, []OpcodeExtensions{RexW, SlashR},
 		[]OpcodeOperand{
@@ -366,108 +386,240 @@ var (
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if above (CF=0 or ZF=0) (for unsigned)
+	JA_rel32 = &Opcode{"ja", []uint8{}, []uint8{0x0f, 0x87}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if above or equal (CF=0) (for unsigned)
 	JAE_rel8 = &Opcode{"jae", []uint8{}, []uint8{0x73}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if above or equal (CF=0) (for unsigned)
+	JAE_rel32 = &Opcode{"jae", []uint8{}, []uint8{0x0f, 0x83}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if below (CF=1)
 	JB_rel8 = &Opcode{"jb", []uint8{}, []uint8{0x72}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if below (CF=1)
+	JB_rel32 = &Opcode{"jb", []uint8{}, []uint8{0x0f, 0x82}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if below (CF=1 or ZF=0)
 	JBE_rel8 = &Opcode{"jbe", []uint8{}, []uint8{0x76}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if below (CF=1 or ZF=0)
+	JBE_rel32 = &Opcode{"jbe", []uint8{}, []uint8{0x0f, 0x86}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if equal (ZF=1)
 	JE_rel8 = &Opcode{"je", []uint8{}, []uint8{0x74}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if equal (ZF=1)
+	JE_rel32 = &Opcode{"je", []uint8{}, []uint8{0x0f, 0x84}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if greater (ZF=0 and SF=OF) (for signed)
 	JG_rel8 = &Opcode{"jg", []uint8{}, []uint8{0x7f}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if greater (ZF=0 and SF=OF) (for signed)
+	JG_rel32 = &Opcode{"jg", []uint8{}, []uint8{0x0f, 0x8f}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if greater or equal (SF=OF) (for signed)
 	JGE_rel8 = &Opcode{"jge", []uint8{}, []uint8{0x7d}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if greater or equal (SF=OF) (for signed)
+	JGE_rel32 = &Opcode{"jge", []uint8{}, []uint8{0x0f, 0x8d}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if less (SF!=OF) (for signed)
 	JL_rel8 = &Opcode{"jl", []uint8{}, []uint8{0x7c}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if less (SF!=OF) (for signed)
+	JL_rel32 = &Opcode{"jl", []uint8{}, []uint8{0x0f, 0x8c}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if less or equal (SF!=OF) (for signed)
 	JLE_rel8 = &Opcode{"jle", []uint8{}, []uint8{0x7e}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if less or equal (SF!=OF) (for signed)
+	JLE_rel32 = &Opcode{"jle", []uint8{}, []uint8{0x0f, 0x8e}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if not above (ZF=0)
 	JNA_rel8 = &Opcode{"jna", []uint8{}, []uint8{0x76}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if not above (ZF=0)
+	JNA_rel32 = &Opcode{"jna", []uint8{}, []uint8{0x0f, 0x86}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if not above or equal (CF=1)
 	JNAE_rel8 = &Opcode{"jnae", []uint8{}, []uint8{0x72}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if not above or equal (CF=1)
+	JNAE_rel32 = &Opcode{"jnae", []uint8{}, []uint8{0x0f, 0x82}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if not below (CF=0)
 	JNB_rel8 = &Opcode{"jnb", []uint8{}, []uint8{0x73}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if not below (CF=0)
+	JNB_rel32 = &Opcode{"jnb", []uint8{}, []uint8{0x0f, 0x83}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if not below or equal (CF=0 or ZF=0)
 	JNBE_rel8 = &Opcode{"jnbe", []uint8{}, []uint8{0x77}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if not below or equal (CF=0 or ZF=0)
+	JNBE_rel32 = &Opcode{"jnbe", []uint8{}, []uint8{0x0f, 0x87}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if not equal (ZF=0)
 	JNE_rel8 = &Opcode{"jne", []uint8{}, []uint8{0x75}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if not equal (ZF=0)
+	JNE_rel32 = &Opcode{"jne", []uint8{}, []uint8{0x0f, 0x85}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if not greater (ZF=1 or SF!=0)
 	JNG_rel8 = &Opcode{"jng", []uint8{}, []uint8{0x7e}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if not greater (ZF=1 or SF!=0)
+	JNG_rel32 = &Opcode{"jng", []uint8{}, []uint8{0x0f, 0x8e}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if not greater or equal (SF!=0)
 	JNGE_rel8 = &Opcode{"jnge", []uint8{}, []uint8{0x7c}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if not greater or equal (SF!=0)
+	JNGE_rel32 = &Opcode{"jnge", []uint8{}, []uint8{0x0f, 0x8c}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if not less (SF=OF)
 	JNL_rel8 = &Opcode{"jnl", []uint8{}, []uint8{0x7d}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if not less (SF=OF)
+	JNL_rel32 = &Opcode{"jnl", []uint8{}, []uint8{0x0f, 0x8d}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	// Jump short if not less or equal (ZF=0 and SF=OF)
 	JNLE_rel8 = &Opcode{"jnle", []uint8{}, []uint8{0x7f}, []OpcodeExtensions{ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rel8, ImmediateValue},
 		},
 	}
+	// Jump near if not less or equal (ZF=0 and SF=OF)
+	JNLE_rel32 = &Opcode{"jnle", []uint8{}, []uint8{0x0f, 0x8f}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
+	// Jump short if not parity (PF=0)
+	JNP_rel8 = &Opcode{"jnp", []uint8{}, []uint8{0x7b}, []OpcodeExtensions{ImmediateByte},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel8, ImmediateValue},
+		},
+	}
+	// Jump near if not parity (PF=0)
+	JNP_rel32 = &Opcode{"jnp", []uint8{}, []uint8{0x0f, 0x8b}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
+	// Jump short if parity (PF=1)
+	JP_rel8 = &Opcode{"jp", []uint8{}, []uint8{0x7a}, []OpcodeExtensions{ImmediateByte},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel8, ImmediateValue},
+		},
+	}
+	// Jump near if parity (PF=1)
+	JP_rel32 = &Opcode{"jp", []uint8{}, []uint8{0x0f, 0x8a}, []OpcodeExtensions{ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rel32, ImmediateValue},
+		},
+	}
 	LEA_r64_m = &Opcode{"lea", []uint8{}, []uint8{0x8d}, []OpcodeExtensions{RexW, SlashR},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_r64, ModRM_reg_rw},
@@ -498,37 +650,73 @@ var (
 			OpcodeOperand{OT_imm8, ImmediateValue},
 		},
 	}
-	MOV_rm16_r16 = &Opcode{"mov", []uint8{0x66}, []uint8{0x89}, []OpcodeExtensions{SlashR},
+	MOV_rm16_r16 = &Opcode{"mov", []uint8{0x66}, []uint8{0x89}, []OpcodeExtensions{Rex, SlashR},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm16, ModRM_rm_rw},
+			OpcodeOperand{OT_r16, ModRM_reg_r},
+		},
+	}
+	MOV_rm16_r16_no_rex = &Opcode{"mov", []uint8{0x66}, []uint8{0x89}, []OpcodeExtensions{SlashR},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rm16, ModRM_rm_rw},
 			OpcodeOperand{OT_r16, ModRM_reg_r},
 		},
 	}
-	MOV_r16_rm16 = &Opcode{"mov", []uint8{0x66}, []uint8{0x8b}, []OpcodeExtensions{SlashR},
+	MOV_r16_rm16 = &Opcode{"mov", []uint8{0x66}, []uint8{0x8b}, []OpcodeExtensions{Rex, SlashR},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_r16, ModRM_reg_rw},
+			OpcodeOperand{OT_rm16, ModRM_rm_r},
+		},
+	}
+	MOV_r16_rm16_no_rex = &Opcode{"mov", []uint8{0x66}, []uint8{0x8b}, []OpcodeExtensions{SlashR},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_r16, ModRM_reg_rw},
 			OpcodeOperand{OT_rm16, ModRM_rm_r},
 		},
 	}
-	MOV_r16_imm16 = &Opcode{"mov", []uint8{0x66}, []uint8{0xb8}, []OpcodeExtensions{ImmediateWord},
+	MOV_r16_imm16 = &Opcode{"mov", []uint8{0x66}, []uint8{0xb8}, []OpcodeExtensions{Rex, ImmediateWord},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_r16, Opcode_plus_rd_r},
+			OpcodeOperand{OT_imm16, ImmediateValue},
+		},
+	}
+	MOV_r16_imm16_no_rex = &Opcode{"mov", []uint8{0x66}, []uint8{0xb8}, []OpcodeExtensions{ImmediateWord},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_r16, Opcode_plus_rd_r},
 			OpcodeOperand{OT_imm16, ImmediateValue},
 		},
 	}
-	MOV_r32_imm32 = &Opcode{"mov", []uint8{}, []uint8{0xb8}, []OpcodeExtensions{ImmediateDouble},
+	MOV_r32_imm32 = &Opcode{"mov", []uint8{}, []uint8{0xb8}, []OpcodeExtensions{Rex, ImmediateDouble},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_r32, Opcode_plus_rd_r},
+			OpcodeOperand{OT_imm32, ImmediateValue},
+		},
+	}
+	MOV_r32_imm32_no_rex = &Opcode{"mov", []uint8{}, []uint8{0xb8}, []OpcodeExtensions{ImmediateDouble},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_r32, Opcode_plus_rd_r},
 			OpcodeOperand{OT_imm32, ImmediateValue},
 		},
 	}
-	MOV_rm32_r32 = &Opcode{"mov", []uint8{}, []uint8{0x89}, []OpcodeExtensions{SlashR},
+	MOV_rm32_r32 = &Opcode{"mov", []uint8{}, []uint8{0x89}, []OpcodeExtensions{Rex, SlashR},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm32, ModRM_rm_rw},
+			OpcodeOperand{OT_r32, ModRM_reg_r},
+		},
+	}
+	MOV_rm32_r32_no_rex = &Opcode{"mov", []uint8{}, []uint8{0x89}, []OpcodeExtensions{SlashR},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rm32, ModRM_rm_rw},
 			OpcodeOperand{OT_r32, ModRM_reg_r},
 		},
 	}
-	MOV_r32_rm32 = &Opcode{"mov", []uint8{}, []uint8{0x8b}, []OpcodeExtensions{SlashR},
+	MOV_r32_rm32 = &Opcode{"mov", []uint8{}, []uint8{0x8b}, []OpcodeExtensions{Rex, SlashR},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_r32, ModRM_reg_rw},
+			OpcodeOperand{OT_rm32, ModRM_rm_r},
+		},
+	}
+	MOV_r32_rm32_no_rex = &Opcode{"mov", []uint8{}, []uint8{0x8b}, []OpcodeExtensions{SlashR},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_r32, ModRM_reg_rw},
 			OpcodeOperand{OT_rm32, ModRM_rm_r},
@@ -564,6 +752,13 @@ var (
 			OpcodeOperand{OT_rm64, ModRM_rm_r},
 		},
 	}
+	// Only used for registers; memory is written with MOVSD instead.
+	MOVQ_r64_xmm = &Opcode{"movq", []uint8{0x66}, []uint8{0x0f, 0x7e}, []OpcodeExtensions{RexW, SlashR},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_r64, ModRM_rm_rw},
+			OpcodeOperand{OT_xmm1, ModRM_reg_r},
+		},
+	}
 	// Move or Merge Scalar Double-Precision Floating-Point Value
 	MOVSD_xmm1m64_xmm2 = &Opcode{"movsd", []uint8{}, []uint8{0xf2, 0x0f, 0x11}, []OpcodeExtensions{SlashR},
 		[]OpcodeOperand{
@@ -670,6 +865,32 @@ var (
 			OpcodeOperand{OT_xmm2m64, ModRM_rm_r},
 		},
 	}
+	// One's complement negation
+	NOT_rm8 = &Opcode{"not", []uint8{}, []uint8{0xf6}, []OpcodeExtensions{Rex, Slash2},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+		},
+	}
+	NOT_rm8_no_rex = &Opcode{"not", []uint8{}, []uint8{0xf6}, []OpcodeExtensions{Slash2},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+		},
+	}
+	NOT_rm16 = &Opcode{"not", []uint8{0x66}, []uint8{0xf7}, []OpcodeExtensions{Slash2},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm16, ModRM_rm_rw},
+		},
+	}
+	NOT_rm32 = &Opcode{"not", []uint8{}, []uint8{0xf7}, []OpcodeExtensions{Slash2},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm32, ModRM_rm_rw},
+		},
+	}
+	NOT_rm64 = &Opcode{"not", []uint8{}, []uint8{0xf7}, []OpcodeExtensions{RexW, Slash2},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm64, ModRM_rm_rw},
+		},
+	}
 	// Logical OR
 	OR_r8_rm8 = &Opcode{"or", []uint8{}, []uint8{0x0a}, []OpcodeExtensions{Rex, SlashR},
 		[]OpcodeOperand{
@@ -753,6 +974,63 @@ var (
 	RETURN = &Opcode{"return", []uint8{}, []uint8{0xc3}, []OpcodeExtensions{},
 		[]OpcodeOperand{},
 	}
+	// Signed divide by 2, imm8 times (arithmetic shift right)
+	SAR_rm8_imm8 = &Opcode{"sar", []uint8{}, []uint8{0xc0}, []OpcodeExtensions{Rex, Slash7, ImmediateByte},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+			OpcodeOperand{OT_imm8, ImmediateValue},
+		},
+	}
+	SAR_rm8_imm8_no_rex = &Opcode{"sar", []uint8{}, []uint8{0xc0}, []OpcodeExtensions{Slash7, ImmediateByte},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+			OpcodeOperand{OT_imm8, ImmediateValue},
+		},
+	}
+	SAR_rm16_imm8 = &Opcode{"sar", []uint8{0x66}, []uint8{0xc1}, []OpcodeExtensions{Slash7, ImmediateByte},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm16, ModRM_rm_rw},
+			OpcodeOperand{OT_imm8, ImmediateValue},
+		},
+	}
+	SAR_rm32_imm8 = &Opcode{"sar", []uint8{}, []uint8{0xc1}, []OpcodeExtensions{Slash7, ImmediateByte},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm32, ModRM_rm_rw},
+			OpcodeOperand{OT_imm8, ImmediateValue},
+		},
+	}
+	SAR_rm64_imm8 = &Opcode{"sar", []uint8{}, []uint8{0xc1}, []OpcodeExtensions{RexW, Slash7, ImmediateByte},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm64, ModRM_rm_rw},
+			OpcodeOperand{OT_imm8, ImmediateValue},
+		},
+	}
+	// Signed divide by 2, %cl times (arithmetic shift right)
+	SAR_rm8_cl = &Opcode{"sar", []uint8{}, []uint8{0xd2}, []OpcodeExtensions{Rex, Slash7},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+		},
+	}
+	SAR_rm8_cl_no_rex = &Opcode{"sar", []uint8{}, []uint8{0xd2}, []OpcodeExtensions{Slash7},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+		},
+	}
+	SAR_rm16_cl = &Opcode{"sar", []uint8{0x66}, []uint8{0xd3}, []OpcodeExtensions{Slash7},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm16, ModRM_rm_rw},
+		},
+	}
+	SAR_rm32_cl = &Opcode{"sar", []uint8{}, []uint8{0xd3}, []OpcodeExtensions{Slash7},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm32, ModRM_rm_rw},
+		},
+	}
+	SAR_rm64_cl = &Opcode{"sar", []uint8{}, []uint8{0xd3}, []OpcodeExtensions{RexW, Slash7},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm64, ModRM_rm_rw},
+		},
+	}
 	// Set byte if above (CF=0, ZF=0)
 	SETA_rm8 = &Opcode{"seta", []uint8{}, []uint8{0x0f, 0x97}, []OpcodeExtensions{Rex},
 		[]OpcodeOperand{
@@ -866,6 +1144,28 @@ var (
 			OpcodeOperand{OT_rm8, ModRM_rm_r},
 		},
 	}
+	// Set byte if not parity (PF=0)
+	SETNP_rm8 = &Opcode{"setnp", []uint8{}, []uint8{0x0f, 0x9b}, []OpcodeExtensions{Rex},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_r},
+		},
+	}
+	SETNP_rm8_no_rex = &Opcode{"setnp", []uint8{}, []uint8{0x0f, 0x9b}, []OpcodeExtensions{},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_r},
+		},
+	}
+	// Set byte if parity (PF=1)
+	SETP_rm8 = &Opcode{"setp", []uint8{}, []uint8{0x0f, 0x9a}, []OpcodeExtensions{Rex},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_r},
+		},
+	}
+	SETP_rm8_no_rex = &Opcode{"setp", []uint8{}, []uint8{0x0f, 0x9a}, []OpcodeExtensions{},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_r},
+		},
+	}
 	SHL_rm8_imm8 = &Opcode{"shl", []uint8{}, []uint8{0xc0}, []OpcodeExtensions{RexW, Slash4, ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rm8, ModRM_rm_rw},
@@ -896,6 +1196,32 @@ var (
 			OpcodeOperand{OT_imm8, ImmediateValue},
 		},
 	}
+	// Multiply by 2, %cl times (shift left)
+	SHL_rm8_cl = &Opcode{"shl", []uint8{}, []uint8{0xd2}, []OpcodeExtensions{Rex, Slash4},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+		},
+	}
+	SHL_rm8_cl_no_rex = &Opcode{"shl", []uint8{}, []uint8{0xd2}, []OpcodeExtensions{Slash4},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+		},
+	}
+	SHL_rm16_cl = &Opcode{"shl", []uint8{0x66}, []uint8{0xd3}, []OpcodeExtensions{Slash4},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm16, ModRM_rm_rw},
+		},
+	}
+	SHL_rm32_cl = &Opcode{"shl", []uint8{}, []uint8{0xd3}, []OpcodeExtensions{Slash4},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm32, ModRM_rm_rw},
+		},
+	}
+	SHL_rm64_cl = &Opcode{"shl", []uint8{}, []uint8{0xd3}, []OpcodeExtensions{RexW, Slash4},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm64, ModRM_rm_rw},
+		},
+	}
 	SHR_rm8_imm8 = &Opcode{"shr", []uint8{}, []uint8{0xc0}, []OpcodeExtensions{RexW, Slash5, ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rm8, ModRM_rm_rw},
@@ -926,6 +1252,32 @@ var (
 			OpcodeOperand{OT_imm8, ImmediateValue},
 		},
 	}
+	// Unsigned divide by 2, %cl times (logical shift right)
+	SHR_rm8_cl = &Opcode{"shr", []uint8{}, []uint8{0xd2}, []OpcodeExtensions{Rex, Slash5},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+		},
+	}
+	SHR_rm8_cl_no_rex = &Opcode{"shr", []uint8{}, []uint8{0xd2}, []OpcodeExtensions{Slash5},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm8, ModRM_rm_rw},
+		},
+	}
+	SHR_rm16_cl = &Opcode{"shr", []uint8{0x66}, []uint8{0xd3}, []OpcodeExtensions{Slash5},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm16, ModRM_rm_rw},
+		},
+	}
+	SHR_rm32_cl = &Opcode{"shr", []uint8{}, []uint8{0xd3}, []OpcodeExtensions{Slash5},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm32, ModRM_rm_rw},
+		},
+	}
+	SHR_rm64_cl = &Opcode{"shr", []uint8{}, []uint8{0xd3}, []OpcodeExtensions{RexW, Slash5},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_rm64, ModRM_rm_rw},
+		},
+	}
 	SUB_rm8_imm8 = &Opcode{"sub", []uint8{}, []uint8{0x80}, []OpcodeExtensions{Rex, Slash5, ImmediateByte},
 		[]OpcodeOperand{
 			OpcodeOperand{OT_rm8, ModRM_rm_rw},
@@ -1001,6 +1353,17 @@ var (
 	SYSCALL = &Opcode{"syscall", []uint8{}, []uint8{0x0f, 0x05}, []OpcodeExtensions{},
 		[]OpcodeOperand{},
 	}
+	// Raise an invalid opcode exception
+	UD2 = &Opcode{"ud2", []uint8{}, []uint8{0x0f, 0x0b}, []OpcodeExtensions{},
+		[]OpcodeOperand{},
+	}
+	// Compare low double-precision floating-point values in xmm1 and xmm2/mem64 and set the EFLAGS flags accordingly; only signals on signaling NaNs
+	UCOMISD_xmm1_xmm2m64 = &Opcode{"ucomisd", []uint8{0x66}, []uint8{0x0f, 0x2e}, []OpcodeExtensions{SlashR},
+		[]OpcodeOperand{
+			OpcodeOperand{OT_xmm1, ModRM_reg_r},
+			OpcodeOperand{OT_xmm2m64, ModRM_rm_r},
+		},
+	}
 	// Add packed byte integers from xmm2, and xmm3/m128 and store in xmm1.
 	VPADDB_xmm1_xmm2_xmm3m128 = &Opcode{"vpaddb", []uint8{}, []uint8{0xfc}, []OpcodeExtensions{VEX128, VEX_66, VEX_0f, VEX_WIG, SlashR},
 		[]OpcodeOperand{
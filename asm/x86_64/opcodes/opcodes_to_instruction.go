@@ -14,6 +14,11 @@ func OpcodeToInstruction(name string, opcode *Opcode, argCount int, operands ...
 
 func OpcodesToInstruction(name string, opcodes []*Opcode, argCount int, operands ...lib.Operand) lib.Instruction {
 	maps := OpcodesToOpcodeMaps(opcodes, argCount)
+	for _, op := range operands {
+		if label := operandLabel(op); label != nil {
+			return NewRelativeInstruction(name, maps, operands, opcodes, label)
+		}
+	}
 	return NewOpcodeMapsInstruction(name, maps, operands, opcodes)
 }
 
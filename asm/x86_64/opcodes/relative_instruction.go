@@ -0,0 +1,135 @@
+package opcodes
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// An instruction that refers to a label, either directly as the target of a
+// jump or call, or through a RIP relative memory operand. The label is
+// replaced by the displacement once the addresses are known (see
+// lib.Instructions.Resolve). Jumps start out with their short rel8 encoding
+// and are relaxed to rel32 if the label is out of range.
+type relativeInstruction struct {
+	*opcodeMapsInstruction
+	Label        *lib.Label
+	Near         bool
+	Displacement int
+}
+
+func NewRelativeInstruction(name string, maps OpcodeMaps, operands []lib.Operand, opcodes []*Opcode, label *lib.Label) lib.Instruction {
+	return &relativeInstruction{
+		opcodeMapsInstruction: &opcodeMapsInstruction{name, maps, operands, opcodes},
+		Label:                 label,
+	}
+}
+
+// Returns the label that the operand refers to, if any.
+func operandLabel(op lib.Operand) *lib.Label {
+	switch v := op.(type) {
+	case *lib.Label:
+		return v
+	case *RIPRelative:
+		return v.Label
+	}
+	return nil
+}
+
+// Replaces the label with the current displacement.
+func (o *relativeInstruction) resolvedOperands(near bool) []lib.Operand {
+	result := make([]lib.Operand, len(o.Operands))
+	for i, op := range o.Operands {
+		if operandLabel(op) == nil {
+			result[i] = op
+		} else if op.Type() == lib.T_RIPRelative {
+			result[i] = &RIPRelative{Displacement: Int32(o.Displacement)}
+		} else if near {
+			result[i] = Uint32(uint32(int32(o.Displacement)))
+		} else {
+			result[i] = Uint8(uint8(int8(o.Displacement)))
+		}
+	}
+	return result
+}
+
+func (o *relativeInstruction) operands() []lib.Operand {
+	if !o.Near {
+		short := o.resolvedOperands(false)
+		if o.opcodeMaps.ResolveOpcode(short) != nil {
+			return short
+		}
+		// There's no rel8 encoding, e.g. for call
+		o.Near = true
+	}
+	return o.resolvedOperands(true)
+}
+
+func (o *relativeInstruction) Encode() (lib.MachineCode, error) {
+	return (&opcodeMapsInstruction{o.Name, o.opcodeMaps, o.operands(), o.Opcodes}).Encode()
+}
+
+func (o *relativeInstruction) Resolve(address int, labels lib.LabelAddresses) (bool, error) {
+	target, err := labels.Get(o.Label)
+	if err != nil {
+		return false, fmt.Errorf("%s in %s", err.Error(), o.String())
+	}
+	before, err := lib.Instruction_Length(o)
+	if err != nil {
+		return false, err
+	}
+	length := before
+	o.Displacement = target - (address + length)
+	if !o.Near && (o.Displacement < -128 || o.Displacement > 127) {
+		o.Near = true
+		length, err = lib.Instruction_Length(o)
+		if err != nil {
+			return false, err
+		}
+		o.Displacement = target - (address + length)
+	}
+	return length != before, nil
+}
+
+// Returns the relocation for a RIP relative operand. The displacement is
+// encoded in the four bytes in front of the immediate, if there is one, and
+// is relative to the end of the instruction.
+func (o *relativeInstruction) Relocation() (*lib.Relocation, error) {
+	immediate := 0
+	relative := false
+	for _, op := range o.Operands {
+		switch op.(type) {
+		case *RIPRelative:
+			relative = true
+		case Uint8, Uint16, Uint32, Uint64, Float64:
+			immediate += int(op.Width())
+		}
+	}
+	if !relative {
+		return nil, nil
+	}
+	length, err := lib.Instruction_Length(o)
+	if err != nil {
+		return nil, err
+	}
+	return &lib.Relocation{
+		Type:   lib.R_PCRelative32,
+		Offset: length - 4 - immediate,
+		Addend: -4 - immediate,
+		Label:  o.Label,
+	}, nil
+}
+
+func (o *relativeInstruction) String() string {
+	opcode := o.opcodeMaps.ResolveOpcode(o.operands())
+	args := []string{}
+	for i := len(o.Operands) - 1; i >= 0; i-- {
+		args = append(args, o.Operands[i].String())
+	}
+	if opcode == nil {
+		return fmt.Sprintf("<unmatched %s instruction: %s %s>", o.Name, o.Name, strings.Join(args, ", "))
+	}
+	return opcode.Name + " " + strings.Join(args, ", ")
+}
@@ -0,0 +1,127 @@
+package elf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// The tags of the entries in the .dynamic section, which tell the dynamic
+// linker where to find the other dynamic sections.
+type DynamicTag int64
+
+const (
+	// Marks the end of the .dynamic section.
+	DT_NULL DynamicTag = 0
+	// The string table offset of the name of a needed library.
+	DT_NEEDED DynamicTag = 1
+	// The address of the symbol hash table.
+	DT_HASH DynamicTag = 4
+	// The address of the dynamic string table.
+	DT_STRTAB DynamicTag = 5
+	// The address of the dynamic symbol table.
+	DT_SYMTAB DynamicTag = 6
+	// The address of the relocation table with explicit addends.
+	DT_RELA DynamicTag = 7
+	// The total size in bytes of the DT_RELA table.
+	DT_RELASZ DynamicTag = 8
+	// The size in bytes of a DT_RELA entry.
+	DT_RELAENT DynamicTag = 9
+	// The size in bytes of the dynamic string table.
+	DT_STRSZ DynamicTag = 10
+	// The size in bytes of a dynamic symbol table entry.
+	DT_SYMENT DynamicTag = 11
+	// Filled in by the dynamic linker, for use by debuggers.
+	DT_DEBUG DynamicTag = 21
+)
+
+const DynSize64 = 16
+
+// An entry in the .dynamic section.
+type Dyn struct {
+	Tag   DynamicTag
+	Value uint64
+}
+
+func (d *Dyn) Encode(header *ELFHeader) ([]byte, error) {
+	buffer := bytes.NewBuffer([]byte{})
+	for _, field := range []interface{}{d.Tag, d.Value} {
+		if err := binary.Write(buffer, header.GetByteOrder(), field); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+func ParseDyn(header *ELFHeader, r *bytes.Reader) (*Dyn, error) {
+	result := &Dyn{}
+	byteOrder := header.GetByteOrder()
+	if err := binary.Read(r, byteOrder, &result.Tag); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, byteOrder, &result.Value); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Returns the entries of a SHT_DYNAMIC section, up to DT_NULL.
+func (s *Section) GetDynamicEntries() ([]*Dyn, error) {
+	result := []*Dyn{}
+	r := bytes.NewReader(s.Data)
+	for r.Len() > 0 {
+		dyn, err := ParseDyn(s.header, r)
+		if err != nil {
+			return nil, err
+		}
+		if dyn.Tag == DT_NULL {
+			break
+		}
+		result = append(result, dyn)
+	}
+	return result, nil
+}
+
+// Returns the path of the dynamic linker for the machine.
+func Interpreter(machine ELFMachine) (string, error) {
+	switch machine {
+	case EM_X86_64:
+		return "/lib64/ld-linux-x86-64.so.2", nil
+	case EM_AARCH64:
+		return "/lib/ld-linux-aarch64.so.1", nil
+	}
+	return "", fmt.Errorf("Dynamic linking is not supported for %s", machine)
+}
+
+func globalDataRelocation(machine ELFMachine) RelocationType {
+	if machine == EM_AARCH64 {
+		return R_AARCH64_GLOB_DAT
+	}
+	return R_X86_64_GLOB_DAT
+}
+
+// The relocation that stores the address of a symbol in eight bytes of data.
+func absoluteRelocation(machine ELFMachine) RelocationType {
+	if machine == EM_AARCH64 {
+		return R_AARCH64_ABS64
+	}
+	return R_X86_64_64
+}
+
+// Returns a symbol hash table with a single bucket, which chains all the
+// symbols together. That makes lookups linear, but there are only a few
+// symbols and the dynamic linker doesn't need to compute the hashes.
+func encodeHashTable(header *ELFHeader, symbols int) ([]byte, error) {
+	// nbucket, nchain and the bucket, which starts at the last symbol
+	words := []uint32{1, uint32(symbols), uint32(symbols - 1)}
+	// The chain of the null symbol and of the first symbol end at index 0
+	words = append(words, 0)
+	for i := 1; i < symbols; i++ {
+		words = append(words, uint32(i-1))
+	}
+	buffer := bytes.NewBuffer([]byte{})
+	if err := binary.Write(buffer, header.GetByteOrder(), words); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -2,6 +2,7 @@ package elf
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 
@@ -67,20 +68,28 @@ func ParseELFFile(path string) (*ELF, error) {
 	return ParseELF(bytes.NewReader(f))
 }
 
-// Encodes the ELF header, program header table and section header table (TODO).
-// Does not copy .Data!
+// Encodes the ELF header and the program header table, which directly
+// follows it. The section header table is only encoded by Encode, which
+// also sets its offset. Does not copy .Data!
 func (e *ELF) EncodeHeaders() ([]byte, error) {
 	headerSize := 64
+	e.ELFHeader.HeaderSize = uint16(headerSize)
 	e.ELFHeader.ProgramHeaderEntrySize = ProgramHeaderSize64
-	e.ELFHeader.SectionHeaderEntrySize = 64
+	e.ELFHeader.SectionHeaderEntrySize = SectionHeaderSize64
 	e.ELFHeader.ProgramHeaderNumberOfEntries = uint16(len(e.ProgramHeaders))
 	e.ELFHeader.SectionHeaderNumberOfEntries = uint16(len(e.Sections))
-	e.ELFHeader.ProgramHeaderTableOffset = Elf64_Off(headerSize)
+	if len(e.ProgramHeaders) > 0 {
+		e.ELFHeader.ProgramHeaderTableOffset = Elf64_Off(headerSize)
+	} else {
+		e.ELFHeader.ProgramHeaderTableOffset = 0
+	}
 	programHeaders, err := e.ProgramHeaders.Encode(e.ELFHeader)
 	if err != nil {
 		return nil, err
 	}
-	e.ELFHeader.SectionHeaderTableOffset = 0 // Elf64_Off(headerSize + len(programHeaders))
+	if len(e.Sections) == 0 {
+		e.ELFHeader.SectionHeaderTableOffset = 0
+	}
 	header, err := e.ELFHeader.Encode()
 	if err != nil {
 		return nil, err
@@ -92,6 +101,107 @@ func (e *ELF) EncodeHeaders() ([]byte, error) {
 	return result, nil
 }
 
+// Encodes the whole file: the headers, followed by the data of the sections
+// and the section header table. The first section should be the SHT_NULL
+// section (see NewNullSection) and one of the sections should be the
+// .shstrtab section, into which the section names get written.
+//
+// The sections are placed in order, each aligned to its AddrAlign. A
+// section with an Offset beyond the end of the previous one is placed at
+// that Offset instead, so that it can be mapped by a program header. The
+// Size and Offset of the sections get updated.
+func (e *ELF) Encode() ([]byte, error) {
+	names := NewStringTable([]byte{0})
+	nameIndices := make([]uint32, len(e.Sections))
+	shstrndx := -1
+	for i, section := range e.Sections {
+		if section.Type != SHT_NULL {
+			nameIndices[i] = names.Add(section.Name)
+		}
+		if section.Name == ".shstrtab" {
+			shstrndx = i
+		}
+	}
+	if len(e.Sections) > 0 {
+		if shstrndx < 0 {
+			return nil, errors.New("Missing .shstrtab section")
+		}
+		e.Sections[shstrndx].Data = names.Bytes()
+		e.ELFHeader.Shstrndx = uint16(shstrndx)
+	}
+
+	offset := uint64(64) + uint64(len(e.ProgramHeaders))*uint64(ProgramHeaderSize64)
+	for _, section := range e.Sections {
+		if section.Type == SHT_NULL {
+			continue
+		}
+		offset = alignTo(offset, section.AddrAlign)
+		if uint64(section.Offset) > offset {
+			offset = uint64(section.Offset)
+		}
+		section.Offset = Elf64_Off(offset)
+		if section.Type != SHT_NOBITS {
+			section.Size = uint64(len(section.Data))
+			offset += section.Size
+		}
+	}
+	offset = alignTo(offset, 8)
+	if len(e.Sections) > 0 {
+		e.ELFHeader.SectionHeaderTableOffset = Elf64_Off(offset)
+	}
+
+	result, err := e.EncodeHeaders()
+	if err != nil {
+		return nil, err
+	}
+	for _, section := range e.Sections {
+		if section.Type == SHT_NULL || section.Type == SHT_NOBITS {
+			continue
+		}
+		result = append(result, make([]byte, int(section.Offset)-len(result))...)
+		result = append(result, section.Data...)
+	}
+	result = append(result, make([]byte, int(offset)-len(result))...)
+	for i, section := range e.Sections {
+		header := &SectionHeader{
+			Name:      nameIndices[i],
+			Type:      section.Type,
+			Flags:     section.Flags,
+			Addr:      section.Addr,
+			Offset:    section.Offset,
+			Size:      section.Size,
+			Link:      section.Link,
+			Info:      section.Info,
+			AddrAlign: section.AddrAlign,
+			EntSize:   section.EntSize,
+		}
+		b, err := header.Encode(e.ELFHeader)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, b...)
+	}
+	return result, nil
+}
+
+// Returns the index of the section in the section header table, or -1 if
+// it's not there.
+func (e *ELF) SectionIndex(name string) int {
+	for i, s := range e.Sections {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func alignTo(offset, alignment uint64) uint64 {
+	if alignment <= 1 {
+		return offset
+	}
+	return (offset + alignment - 1) / alignment * alignment
+}
+
 func (e *ELF) String() string {
 	result := e.ELFHeader.String()
 	for _, header := range e.ProgramHeaders {
@@ -114,6 +224,7 @@ func (e *ELF) GetSection(name string) *Section {
 
 // Demo function.
 // Don't use this in anything serious: it puts data in an executable segment.
+// See CreateExecutable instead.
 func CreateTinyBinary(m lib.MachineCode, path string) error {
 	elf := NewELF()
 	elf.ELFHeader = NewELFHeader()
@@ -138,14 +249,6 @@ func CreateTinyBinary(m lib.MachineCode, path string) error {
 		return err
 	}
 	result = append(result, m...)
-	fmt.Println(len(result), size)
-
-	elfReloaded, err := ParseELF(bytes.NewReader(result))
-	if err != nil {
-		return err
-	}
-	fmt.Println(elfReloaded.String())
-
 	return ioutil.WriteFile(path, result, 0755)
 }
 
@@ -48,18 +48,19 @@ const (
 type ELFMachine uint16
 
 const (
-	EM_NONE   ELFMachine = 0x0000 // An unknown machine
-	EM_M32    ELFMachine = 0x0001 // AT&T WE 32100
-	EM_SPARC  ELFMachine = 0x0002 // Sun Microsystems SPARC
-	EM_386    ELFMachine = 0x0003 // Intel 80386
-	EM_68K    ELFMachine = 0x0004 // Motorola 68000
-	EM_88K    ELFMachine = 0x0005 // Motorola 88000
-	EM_860    ELFMachine = 0x0007 // Intel 80860
-	EM_MIPS   ELFMachine = 0x0008 // MIPS RS3000 (big-endian only)
-	EM_PPC    ELFMachine = 0x0014 // PowerPC
-	EM_PPC64  ELFMachine = 0x0015 // PowerPC 64-bit
-	EM_ARM    ELFMachine = 0x0028 // Advanced RISC Machines
-	EM_X86_64 ELFMachine = 0x003e // AMD x86-64
+	EM_NONE    ELFMachine = 0x0000 // An unknown machine
+	EM_M32     ELFMachine = 0x0001 // AT&T WE 32100
+	EM_SPARC   ELFMachine = 0x0002 // Sun Microsystems SPARC
+	EM_386     ELFMachine = 0x0003 // Intel 80386
+	EM_68K     ELFMachine = 0x0004 // Motorola 68000
+	EM_88K     ELFMachine = 0x0005 // Motorola 88000
+	EM_860     ELFMachine = 0x0007 // Intel 80860
+	EM_MIPS    ELFMachine = 0x0008 // MIPS RS3000 (big-endian only)
+	EM_PPC     ELFMachine = 0x0014 // PowerPC
+	EM_PPC64   ELFMachine = 0x0015 // PowerPC 64-bit
+	EM_ARM     ELFMachine = 0x0028 // Advanced RISC Machines
+	EM_X86_64  ELFMachine = 0x003e // AMD x86-64
+	EM_AARCH64 ELFMachine = 0x00b7 // ARM 64-bit
 )
 
 //go:generate stringer -type=ELFVersion
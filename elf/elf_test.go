@@ -3,7 +3,14 @@ package elf
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"testing"
+
+	"github.com/bspaans/jit-compiler/lib"
 )
 
 func Test_ELF_parse_header_sad(t *testing.T) {
@@ -106,3 +113,367 @@ func Test_ELF_encode(t *testing.T) {
 		t.Errorf("Wrong ph offset %v", parsedHeader.ProgramHeaderTableOffset)
 	}
 }
+
+func Test_ELF_encode_object(t *testing.T) {
+
+	object := &Object{
+		Machine: EM_X86_64,
+		Text: []uint8{
+			0x48, 0x8d, 0x05, 0x00, 0x00, 0x00, 0x00, // lea rax, [rip+0]
+			0x48, 0x8b, 0x00, // mov rax, [rax]
+			0xc3, // ret
+		},
+		Data:      []uint8{1, 0, 0, 0, 0, 0, 0, 0, 53, 0, 0, 0, 0, 0, 0, 0},
+		Functions: []*ObjectFunction{&ObjectFunction{"second", 0, 11}},
+		Relocations: []*ObjectRelocation{
+			&ObjectRelocation{R_X86_64_PC32, 3, DataSection, 8 - 4},
+		},
+	}
+	b, err := object.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	elf, err := ParseELF(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elf.Type != ET_REL {
+		t.Errorf("Wrong type %v", elf.Type)
+	}
+	text := elf.GetSection(".text")
+	if text == nil || !bytes.Equal(text.Data, object.Text) {
+		t.Fatalf("Wrong .text section %v", text)
+	}
+	if text.Flags != SHF_ALLOC|SHF_EXECINSTR {
+		t.Errorf("Wrong .text flags %v", text.Flags)
+	}
+	data := elf.GetSection(".data")
+	if data == nil || !bytes.Equal(data.Data, object.Data) {
+		t.Fatalf("Wrong .data section %v", data)
+	}
+	symbols, err := elf.GetSection(".symtab").GetSymbolTable(elf.GetSection(".strtab").GetStringTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := symbols.GetSymbol("second")
+	if second == nil {
+		t.Fatal("Missing symbol for function")
+	}
+	if second.Binding != SB_GLOBAL || second.Type != STT_FUNC || second.Size != 11 || int(second.Shndx) != elf.SectionIndex(".text") {
+		t.Errorf("Wrong symbol %s", second)
+	}
+	relocations, err := elf.GetSection(".rela.text").GetRelocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relocations) != 1 {
+		t.Fatalf("Expecting one relocation, got %d", len(relocations))
+	}
+	r := relocations[0]
+	target := symbols.Symbols[r.Symbol]
+	if r.Offset != 3 || r.Type != R_X86_64_PC32 || r.Addend != 4 || target.Type != STT_SECTION || int(target.Shndx) != elf.SectionIndex(".data") {
+		t.Errorf("Wrong relocation %v", r)
+	}
+}
+
+func Test_ELF_encode_object_function_outside_text(t *testing.T) {
+	object := &Object{
+		Machine:   EM_X86_64,
+		Text:      []uint8{0xc3},
+		Functions: []*ObjectFunction{&ObjectFunction{"f", 0, 2}},
+	}
+	if _, err := object.Encode(); err == nil {
+		t.Fatal("Expecting an error for a function outside of .text")
+	}
+}
+
+func Test_ELF_encode_object_imports(t *testing.T) {
+	object := &Object{
+		Machine: EM_X86_64,
+		Text: []uint8{
+			0xff, 0x15, 0x00, 0x00, 0x00, 0x00, // call [rip+0]
+			0xc3, // ret
+		},
+		Functions:   []*ObjectFunction{&ObjectFunction{"f", 0, 7}},
+		Relocations: []*ObjectRelocation{&ObjectRelocation{R_X86_64_PC32, 2, GOTSection, 8 - 4}},
+		Imports:     []string{"malloc", "cos"},
+	}
+	b, err := object.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	elf, err := ParseELF(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := elf.GetSection(".data.rel.ro")
+	if got == nil || got.Size != 16 {
+		t.Fatalf("Expecting an entry for each import, got %v", got)
+	}
+	symbols, err := elf.GetSection(".symtab").GetSymbolTable(elf.GetSection(".strtab").GetStringTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	relocations, err := elf.GetSection(".rela.text").GetRelocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := symbols.Symbols[relocations[0].Symbol]
+	if len(relocations) != 1 || target.Type != STT_SECTION || int(target.Shndx) != elf.SectionIndex(".data.rel.ro") {
+		t.Errorf("Wrong relocations %v", relocations)
+	}
+	relocations, err = elf.GetSection(".rela.data.rel.ro").GetRelocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relocations) != 2 || relocations[1].Offset != 8 || relocations[1].Type != R_X86_64_64 {
+		t.Fatalf("Wrong relocations %v", relocations)
+	}
+	cos := symbols.Symbols[relocations[1].Symbol]
+	if cos.GetName() != "cos" || cos.Binding != SB_GLOBAL || cos.Shndx != SHN_UNDEF {
+		t.Errorf("Wrong symbol %s", cos)
+	}
+
+	object.Imports = nil
+	if _, err := object.Encode(); err == nil {
+		t.Fatal("Expecting an error for a relocation to a missing import")
+	}
+}
+
+func Test_ELF_encode_executable(t *testing.T) {
+
+	executable := &Executable{
+		Machine: EM_X86_64,
+		Text: []uint8{
+			0x48, 0x8d, 0x05, 0x00, 0x00, 0x00, 0x00, // lea rax, [rip+0]
+			0xc3, // ret
+		},
+		Data:        []uint8{1, 2, 3},
+		BSSSize:     13,
+		Entry:       7,
+		Relocations: []*ObjectRelocation{&ObjectRelocation{R_X86_64_PC32, 3, DataSection, 8 - 4}},
+	}
+	b, err := executable.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	elf, err := ParseELF(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elf.Type != ET_EXEC {
+		t.Errorf("Wrong type %v", elf.Type)
+	}
+	text, data, bss := elf.GetSection(".text"), elf.GetSection(".data"), elf.GetSection(".bss")
+	if text == nil || data == nil || bss == nil {
+		t.Fatal("Missing sections")
+	}
+	if elf.Entry != text.Addr+7 {
+		t.Errorf("Wrong entry 0x%x", elf.Entry)
+	}
+	if len(elf.ProgramHeaders) != 2 {
+		t.Fatalf("Expecting two program headers, got %d", len(elf.ProgramHeaders))
+	}
+	code, rw := elf.ProgramHeaders[0], elf.ProgramHeaders[1]
+	if code.Type != PT_LOAD || code.Flags != PF_RX || code.SegmentVirtualAddress+Elf64_Addr(code.Memsize) != text.Addr+8 {
+		t.Errorf("Wrong code segment %s", code)
+	}
+	if rw.Type != PT_LOAD || rw.Flags != PF_RW || rw.SegmentVirtualAddress != data.Addr || rw.Filesize != 3 || rw.Memsize != 16 {
+		t.Errorf("Wrong data segment %s", rw)
+	}
+	if bss.Type != SHT_NOBITS || bss.Addr != data.Addr+3 || bss.Size != 13 {
+		t.Errorf("Wrong .bss section %s", bss)
+	}
+	// The displacement is relative to the end of the lea
+	displacement := int64(int32(elf.GetByteOrder().Uint32(text.Data[3:])))
+	if int64(text.Addr)+7+displacement != int64(data.Addr)+8 {
+		t.Errorf("Wrong displacement %d", displacement)
+	}
+	if executable.Text[3] != 0 {
+		t.Error("Expecting the relocations to be applied to a copy of the text")
+	}
+}
+
+func Test_ELF_encode_dynamic_executable(t *testing.T) {
+
+	executable := &Executable{
+		Machine: EM_X86_64,
+		Text: []uint8{
+			0xff, 0x15, 0x00, 0x00, 0x00, 0x00, // call [rip+0]
+			0xc3, // ret
+		},
+		Entry:       0,
+		Relocations: []*ObjectRelocation{&ObjectRelocation{R_X86_64_PC32, 2, GOTSection, 8 - 4}},
+		Imports:     []string{"malloc", "cos"},
+		Libraries:   []string{"libc.so.6", "libm.so.6"},
+	}
+	b, err := executable.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	elf, err := ParseELF(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []PHType{}
+	for _, ph := range elf.ProgramHeaders {
+		types = append(types, ph.Type)
+	}
+	expected := []PHType{PT_PHDR, PT_INTERP, PT_LOAD, PT_LOAD, PT_DYNAMIC, PT_GNU_STACK}
+	if fmt.Sprint(types) != fmt.Sprint(expected) {
+		t.Fatalf("Expecting program headers %v, got %v", expected, types)
+	}
+	text, got := elf.GetSection(".text"), elf.GetSection(".got")
+	interp, dynsym, dynstr := elf.GetSection(".interp"), elf.GetSection(".dynsym"), elf.GetSection(".dynstr")
+	if text == nil || got == nil || interp == nil || dynsym == nil || dynstr == nil {
+		t.Fatal("Missing sections")
+	}
+	if string(interp.Data) != "/lib64/ld-linux-x86-64.so.2\x00" {
+		t.Errorf("Wrong interpreter %q", interp.Data)
+	}
+	if got.Size != 16 {
+		t.Errorf("Expecting a .got entry for each import, got %d bytes", got.Size)
+	}
+	symbols, err := dynsym.GetSymbolTable(dynstr.GetStringTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols.Symbols) != 3 || symbols.Symbols[2].GetName() != "cos" || symbols.Symbols[2].Shndx != SHN_UNDEF {
+		t.Errorf("Wrong dynamic symbols %v", symbols.Symbols)
+	}
+	relocations, err := elf.GetSection(".rela.dyn").GetRelocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(relocations) != 2 || relocations[1].Offset != got.Addr+8 || relocations[1].Symbol != 2 || relocations[1].Type != R_X86_64_GLOB_DAT {
+		t.Errorf("Wrong relocations %v", relocations)
+	}
+	// The call goes through the second .got entry
+	displacement := int64(int32(elf.GetByteOrder().Uint32(text.Data[2:])))
+	if int64(text.Addr)+6+displacement != int64(got.Addr)+8 {
+		t.Errorf("Wrong displacement %d", displacement)
+	}
+}
+
+// Compiles the C source into a shared object with the system's C compiler,
+// skipping the test if there is none.
+func compileSharedObject(t *testing.T, source string) string {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("Calling native code is only supported on amd64")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("No C compiler found")
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.c"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "libtest.so")
+	output, err := exec.Command(cc, "-shared", "-fPIC", "-nostdlib", "-O1", "-o", path, filepath.Join(dir, "test.c")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %s", err.Error(), output)
+	}
+	return path
+}
+
+func Test_LoadSharedObject(t *testing.T) {
+	path := compileSharedObject(t, `
+		long counter = 41;
+		extern long external(long);
+		extern long missing(long) __attribute__((weak));
+		long next(void) { return ++counter; }
+		long call_external(long x) { return external(x) + 1; }
+		long has_missing(void) { return missing != 0; }
+		static long triple_impl(long x) { return x * 3; }
+		static void *resolve_triple(void) { return triple_impl; }
+		long triple(long) __attribute__((ifunc("resolve_triple")));
+	`)
+	if _, err := LoadSharedObject(path, nil); err == nil {
+		t.Fatal("Expecting an error for the undefined symbol")
+	}
+	first, err := LoadSharedObject(path, func(name string) (uintptr, bool) {
+		return 0, name == "external"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	next, err := first.Lookup("next")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The external function of the second copy is the next of the first
+	second, err := LoadSharedObject(path, func(name string) (uintptr, bool) {
+		return next, name == "external"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	units := []struct {
+		Function string
+		Expected uint64
+	}{
+		{"next", 42},
+		{"next", 43},
+		{"call_external", 43},
+		{"has_missing", 0},
+		{"triple", 15},
+	}
+	for _, u := range units {
+		address, err := second.Lookup(u.Function)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, _, err := lib.CallSysV(address, []uint64{5}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != u.Expected {
+			t.Errorf("Expecting %d from %s, got %d", u.Expected, u.Function, result)
+		}
+	}
+	if _, err := second.Lookup("triple_impl"); err == nil {
+		t.Error("Expecting an error for a local symbol")
+	}
+}
+
+func Test_LoadLibrary_glibc(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("Needs amd64")
+	}
+	if _, err := FindLibrary("libm.so.6"); err != nil {
+		t.Skip("Needs glibc's libm")
+	}
+	so, err := LoadLibrary("libm.so.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := []struct {
+		Function string
+		Arg      float64
+		Expected float64
+	}{
+		{"cos", 0, 1},
+		{"cos", math.Pi, -1},
+		{"cos", 1e300, math.Cos(1e300)},
+		// Sets errno
+		{"cos", math.Inf(1), math.NaN()},
+		{"exp", 1000, math.Inf(1)},
+	}
+	for _, u := range units {
+		address, err := so.Lookup(u.Function)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, result, err := lib.CallSysV(address, nil, []float64{u.Arg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != u.Expected && !(math.IsNaN(result) && math.IsNaN(u.Expected)) {
+			t.Errorf("Expecting %v from %s(%v), got %v", u.Expected, u.Function, u.Arg, result)
+		}
+	}
+}
@@ -20,6 +20,7 @@ func _() {
 	_ = x[EM_PPC64-21]
 	_ = x[EM_ARM-40]
 	_ = x[EM_X86_64-62]
+	_ = x[EM_AARCH64-183]
 }
 
 const (
@@ -28,6 +29,7 @@ const (
 	_ELFMachine_name_2 = "EM_PPCEM_PPC64"
 	_ELFMachine_name_3 = "EM_ARM"
 	_ELFMachine_name_4 = "EM_X86_64"
+	_ELFMachine_name_5 = "EM_AARCH64"
 )
 
 var (
@@ -50,6 +52,8 @@ func (i ELFMachine) String() string {
 		return _ELFMachine_name_3
 	case i == 62:
 		return _ELFMachine_name_4
+	case i == 183:
+		return _ELFMachine_name_5
 	default:
 		return "ELFMachine(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
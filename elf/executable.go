@@ -0,0 +1,375 @@
+package elf
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+// The address that executables get loaded at.
+const ExecutableBaseAddress Elf64_Addr = 0x400000
+
+// The contents of an executable (ET_EXEC). The .text, .rodata and .data
+// sections each get their own PT_LOAD segment, so that only the code is
+// executable and only the data is writable.
+//
+// Executables with Imports are dynamically linked against the Libraries:
+// the dynamic linker fills in a .got entry with the address of each of the
+// imported functions before the program starts.
+type Executable struct {
+	Machine      ELFMachine
+	Text         []byte
+	ReadOnlyData []byte
+	Data         []byte
+	// The size of the zero initialised data, which directly follows the
+	// initialised Data in memory.
+	BSSSize uint64
+	// The offset of the entry point in Text.
+	Entry uint64
+	// The references from Text to the other sections, which get resolved
+	// when the executable is encoded. Relocations to the DataSection can
+	// also refer to the .bss, as it is part of the same segment, and
+	// relocations to the GOTSection refer to the entries for the Imports.
+	Relocations []*ObjectRelocation
+	// The names of the functions that get looked up in the Libraries, in
+	// the order of their .got entries.
+	Imports   []string
+	Libraries []string
+}
+
+// Returns the page size that the segments are aligned to, which is the
+// largest page size supported by the machine.
+func pageSize(machine ELFMachine) uint64 {
+	if machine == EM_AARCH64 {
+		return 0x10000
+	}
+	return 0x1000
+}
+
+// Places the sections one after another from offset, each aligned to its
+// AddrAlign, and returns the offset in memory of the end of the last one.
+func layoutSections(offset uint64, sections ...*Section) uint64 {
+	for _, s := range sections {
+		offset = alignTo(offset, s.AddrAlign)
+		s.Offset = Elf64_Off(offset)
+		s.Addr = ExecutableBaseAddress + Elf64_Addr(offset)
+		if s.Type == SHT_NOBITS {
+			offset += s.Size
+		} else {
+			offset += uint64(len(s.Data))
+		}
+	}
+	return offset
+}
+
+// Returns the ELF file for the executable with the segments:
+//
+//	R-X  ELF header, program headers and .text
+//	R--  .rodata
+//	RW-  .data and .bss
+//
+// The read-only and the writable segments are left out when they're empty.
+// Every segment starts on a new page, both in the file and in memory.
+//
+// Dynamically linked executables also get a PT_INTERP, PT_DYNAMIC and
+// PT_GNU_STACK segment. Their dynamic sections are placed before the .text
+// (.interp .hash .dynsym .dynstr .rela.dyn) and before the .data (.dynamic
+// .got).
+func (x *Executable) ELF() (*ELF, error) {
+	e := NewELF()
+	e.ELFHeader = NewELFHeader()
+	e.Type = ET_EXEC
+	e.Machine = x.Machine
+	page := pageSize(x.Machine)
+	dynamic := len(x.Imports) > 0
+
+	text := NewTextSection()
+	text.Data = x.Text
+	text.AddrAlign = 16
+	rodata := NewReadOnlyDataSection()
+	rodata.Data = x.ReadOnlyData
+	rodata.AddrAlign = 16
+	data := NewDataSection()
+	data.Data = x.Data
+	data.AddrAlign = 16
+	// The .bss directly follows the .data, so that relocations into the data
+	// can refer to it as well.
+	bss := NewBSSSection()
+	bss.AddrAlign = 1
+	bss.Size = x.BSSSize
+
+	segments := 1
+	if len(x.ReadOnlyData) > 0 {
+		segments++
+	}
+	if len(x.Data) > 0 || x.BSSSize > 0 || dynamic {
+		segments++
+	}
+	d := &dynamicSections{}
+	if dynamic {
+		var err error
+		d, err = x.dynamicSections(e.ELFHeader)
+		if err != nil {
+			return nil, err
+		}
+		// PT_PHDR, PT_INTERP, PT_DYNAMIC and PT_GNU_STACK
+		segments += 4
+	}
+	headers := uint64(64) + uint64(segments)*uint64(ProgramHeaderSize64)
+
+	codeSections := append(d.code(), text)
+	end := layoutSections(headers, codeSections...)
+	textEnd := end
+	if len(x.ReadOnlyData) > 0 {
+		end = layoutSections(alignTo(end, page), rodata)
+	} else {
+		// The empty section still needs a place in the file
+		layoutSections(end, rodata)
+	}
+	dataSections := append(d.data(), data, bss)
+	dataStart := alignTo(end, page)
+	dataEnd := layoutSections(dataStart, dataSections...)
+
+	e.Sections = []*Section{NewNullSection()}
+	e.Sections = append(e.Sections, codeSections...)
+	e.Sections = append(e.Sections, rodata)
+	e.Sections = append(e.Sections, dataSections...)
+	e.Sections = append(e.Sections, NewSectionHeaderStringSection())
+
+	e.ProgramHeaders = ProgramHeaderTable{}
+	if dynamic {
+		phdr := NewProgramHeader(PT_PHDR, PF_R)
+		phdr.Offset = 64
+		phdr.Filesize = headers - 64
+		phdr.Memsize = phdr.Filesize
+		phdr.Align = 8
+		interp := NewProgramHeader(PT_INTERP, PF_R)
+		interp.Offset = d.interp.Offset
+		interp.Filesize = uint64(len(d.interp.Data))
+		interp.Memsize = interp.Filesize
+		interp.Align = 1
+		e.ProgramHeaders = append(e.ProgramHeaders, phdr, interp)
+	}
+	code := NewProgramHeader(PT_LOAD, PF_RX)
+	code.Offset = 0
+	code.Filesize = textEnd
+	code.Memsize = code.Filesize
+	code.Align = page
+	e.ProgramHeaders = append(e.ProgramHeaders, code)
+	if len(x.ReadOnlyData) > 0 {
+		ph := NewProgramHeader(PT_LOAD, PF_R)
+		ph.Offset = rodata.Offset
+		ph.Filesize = uint64(len(x.ReadOnlyData))
+		ph.Memsize = ph.Filesize
+		ph.Align = page
+		e.ProgramHeaders = append(e.ProgramHeaders, ph)
+	}
+	if len(x.Data) > 0 || x.BSSSize > 0 || dynamic {
+		ph := NewProgramHeader(PT_LOAD, PF_RW)
+		ph.Offset = Elf64_Off(dataStart)
+		ph.Memsize = dataEnd - dataStart
+		ph.Filesize = ph.Memsize - x.BSSSize
+		ph.Align = page
+		e.ProgramHeaders = append(e.ProgramHeaders, ph)
+	}
+	if dynamic {
+		ph := NewProgramHeader(PT_DYNAMIC, PF_RW)
+		ph.Offset = d.dynamic.Offset
+		ph.Filesize = uint64(len(d.dynamic.Data))
+		ph.Memsize = ph.Filesize
+		ph.Align = 8
+		// Without Offset, Filesize and Memsize
+		stack := NewProgramHeader(PT_GNU_STACK, PF_RW)
+		stack.Align = 16
+		e.ProgramHeaders = append(e.ProgramHeaders, ph, stack)
+	}
+	for _, ph := range e.ProgramHeaders {
+		if ph.Type != PT_GNU_STACK {
+			ph.SegmentVirtualAddress = ExecutableBaseAddress + Elf64_Addr(ph.Offset)
+			ph.SegmentPhysicalAddress = ph.SegmentVirtualAddress
+		}
+	}
+	e.Entry = text.Addr + Elf64_Addr(x.Entry)
+
+	targets := map[ObjectSection]Elf64_Addr{
+		TextSection:         text.Addr,
+		ReadOnlyDataSection: rodata.Addr,
+		DataSection:         data.Addr,
+	}
+	if dynamic {
+		targets[GOTSection] = d.got.Addr
+		if err := d.encode(e, x); err != nil {
+			return nil, err
+		}
+	}
+	// Relocate a copy, so that the Text can be encoded again.
+	text.Data = append([]byte{}, x.Text...)
+	for _, r := range x.Relocations {
+		if r.Offset >= uint64(len(x.Text)) {
+			return nil, fmt.Errorf("Relocation at 0x%x is outside of the .text section", r.Offset)
+		}
+		target, ok := targets[r.Section]
+		if !ok {
+			return nil, fmt.Errorf("Relocation at 0x%x refers to a missing section", r.Offset)
+		}
+		place := int64(text.Addr) + int64(r.Offset)
+		value := int64(target) + r.Addend - place
+		if err := applyRelocation(x.Machine, r.Type, e.GetByteOrder(), text.Data[r.Offset:], value); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// The sections that the dynamic linker uses to load the libraries and to
+// fill in the .got
+type dynamicSections struct {
+	interp  *Section
+	hash    *Section
+	dynsym  *Section
+	dynstr  *Section
+	rela    *Section
+	dynamic *Section
+	got     *Section
+}
+
+// Returns the sections with the symbols and the strings, and reserves the
+// space for the sections that refer to addresses (see encode).
+func (x *Executable) dynamicSections(header *ELFHeader) (*dynamicSections, error) {
+	interpreter, err := Interpreter(x.Machine)
+	if err != nil {
+		return nil, err
+	}
+	d := &dynamicSections{
+		interp:  NewSection(".interp", SHT_PROGBITS, SHF_ALLOC),
+		hash:    NewSection(".hash", SHT_HASH, SHF_ALLOC),
+		dynsym:  NewSection(".dynsym", SHT_DYNSYM, SHF_ALLOC),
+		dynstr:  NewSection(".dynstr", SHT_STRTAB, SHF_ALLOC),
+		rela:    NewSection(".rela.dyn", SHT_RELA, SHF_ALLOC),
+		dynamic: NewSection(".dynamic", SHT_DYNAMIC, SHF_ALLOC|SHF_WRITE),
+		got:     NewSection(".got", SHT_PROGBITS, SHF_ALLOC|SHF_WRITE),
+	}
+	d.interp.Data = append([]byte(interpreter), 0)
+	d.interp.AddrAlign = 1
+
+	strings := NewStringTable([]byte{0})
+	for _, library := range x.Libraries {
+		strings.Add(library)
+	}
+	symbols := []*Symbol{NewSymbol("", SB_LOCAL, STT_NOTYPE, SHN_UNDEF, 0, 0)}
+	for _, name := range x.Imports {
+		symbols = append(symbols, NewSymbol(name, SB_GLOBAL, STT_FUNC, SHN_UNDEF, 0, 0))
+	}
+	d.dynsym.Data, err = NewSymbolTable(symbols).Encode(header, strings)
+	if err != nil {
+		return nil, err
+	}
+	d.dynsym.AddrAlign = 8
+	d.dynsym.EntSize = SymbolSize64
+	d.dynsym.Info = 1
+	d.dynstr.Data = strings.Bytes()
+	d.dynstr.AddrAlign = 1
+	d.hash.Data, err = encodeHashTable(header, len(symbols))
+	if err != nil {
+		return nil, err
+	}
+	d.hash.AddrAlign = 8
+	d.hash.EntSize = 4
+
+	d.rela.Data = make([]byte, len(x.Imports)*RelaSize64)
+	d.rela.AddrAlign = 8
+	d.rela.EntSize = RelaSize64
+	// DT_NEEDED for each library, nine other entries and DT_NULL
+	d.dynamic.Data = make([]byte, (len(x.Libraries)+10)*DynSize64)
+	d.dynamic.AddrAlign = 8
+	d.dynamic.EntSize = DynSize64
+	d.got.Data = make([]byte, len(x.Imports)*8)
+	d.got.AddrAlign = 8
+	return d, nil
+}
+
+// The sections in the code segment, which come before the .text
+func (d *dynamicSections) code() []*Section {
+	if d.interp == nil {
+		return []*Section{}
+	}
+	return []*Section{d.interp, d.hash, d.dynsym, d.dynstr, d.rela}
+}
+
+// The sections in the writable segment, which come before the .data
+func (d *dynamicSections) data() []*Section {
+	if d.dynamic == nil {
+		return []*Section{}
+	}
+	return []*Section{d.dynamic, d.got}
+}
+
+// Encodes the .rela.dyn and .dynamic sections, after the sections have been
+// given their addresses. Also links the sections together.
+func (d *dynamicSections) encode(e *ELF, x *Executable) error {
+	header := e.ELFHeader
+	d.hash.Link = uint32(e.SectionIndex(".dynsym"))
+	d.dynsym.Link = uint32(e.SectionIndex(".dynstr"))
+	d.rela.Link = uint32(e.SectionIndex(".dynsym"))
+	d.dynamic.Link = uint32(e.SectionIndex(".dynstr"))
+
+	rela := []byte{}
+	for i := range x.Imports {
+		entry := &Rela{
+			Offset: d.got.Addr + Elf64_Addr(8*i),
+			Symbol: uint32(i + 1),
+			Type:   globalDataRelocation(x.Machine),
+		}
+		b, err := entry.Encode(header)
+		if err != nil {
+			return err
+		}
+		rela = append(rela, b...)
+	}
+	d.rela.Data = rela
+
+	entries := []*Dyn{}
+	offset := uint64(1)
+	for _, library := range x.Libraries {
+		entries = append(entries, &Dyn{DT_NEEDED, offset})
+		offset += uint64(len(library)) + 1
+	}
+	entries = append(entries,
+		&Dyn{DT_HASH, uint64(d.hash.Addr)},
+		&Dyn{DT_STRTAB, uint64(d.dynstr.Addr)},
+		&Dyn{DT_SYMTAB, uint64(d.dynsym.Addr)},
+		&Dyn{DT_RELA, uint64(d.rela.Addr)},
+		&Dyn{DT_RELASZ, uint64(len(d.rela.Data))},
+		&Dyn{DT_RELAENT, RelaSize64},
+		&Dyn{DT_STRSZ, uint64(len(d.dynstr.Data))},
+		&Dyn{DT_SYMENT, SymbolSize64},
+		&Dyn{DT_DEBUG, 0},
+		&Dyn{DT_NULL, 0},
+	)
+	dynamic := []byte{}
+	for _, entry := range entries {
+		b, err := entry.Encode(header)
+		if err != nil {
+			return err
+		}
+		dynamic = append(dynamic, b...)
+	}
+	d.dynamic.Data = dynamic
+	return nil
+}
+
+func (x *Executable) Encode() ([]byte, error) {
+	e, err := x.ELF()
+	if err != nil {
+		return nil, err
+	}
+	return e.Encode()
+}
+
+// Writes the executable to path.
+func CreateExecutable(x *Executable, path string) error {
+	result, err := x.Encode()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, result, 0755)
+}
@@ -0,0 +1,150 @@
+package elf
+
+import (
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Returns whether the library is glibc's libc or its dynamic linker, which
+// only work after they have set up the process themselves.
+func isGlibc(name string) bool {
+	if name == "libc.so.6" {
+		return true
+	}
+	interpreter, err := Interpreter(hostMachine())
+	return err == nil && filepath.Base(interpreter) == name
+}
+
+var (
+	standIns = map[string]SymbolResolver{}
+	// The offsets from the thread pointer of the stand-ins for thread local
+	// variables.
+	standInTLS  = map[string]uintptr{}
+	trapAddress uintptr
+)
+
+// Returns a resolver for stand-ins for the symbols of glibc's libc or
+// dynamic linker, for processes that aren't linked against them. The other
+// glibc libraries, like libm, only need them to report errors and to pick
+// the implementations for the CPU:
+//
+//   - Functions trap when they're called.
+//   - Variables are zeroed, e.g. the CPU features in the dynamic linker's
+//     _rtld_global_ro, so that the baseline implementations get picked.
+//   - Thread local variables like errno are stored in the spare thread local
+//     storage slots of the Go runtime, which is only supported on amd64.
+func glibcStandIn(name string) (SymbolResolver, error) {
+	if resolve, ok := standIns[name]; ok {
+		return resolve, nil
+	}
+	path, err := FindLibrary(name)
+	if err != nil {
+		return nil, err
+	}
+	_, e, err := readSharedObject(path)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := dynamicSymbols(e)
+	if err != nil {
+		return nil, err
+	}
+	if trapAddress == 0 {
+		if trapAddress, err = mapTrap(); err != nil {
+			return nil, err
+		}
+	}
+	variables := map[string][]uint64{}
+	resolve := func(symbol string) (uintptr, bool) {
+		s := symbols.GetSymbol(symbol)
+		if s == nil || s.Shndx == SHN_UNDEF || s.Binding == SB_LOCAL {
+			return 0, false
+		}
+		switch s.Type {
+		case STT_FUNC, STT_GNU_IFUNC:
+			return trapAddress, true
+		case STT_OBJECT:
+			if _, ok := variables[symbol]; !ok {
+				variables[symbol] = make([]uint64, s.Size/8+1)
+			}
+			return uintptr(unsafe.Pointer(&variables[symbol][0])), true
+		case STT_TLS:
+			return standInThreadLocal(symbol, s.Size)
+		}
+		return 0, false
+	}
+	standIns[name] = resolve
+	return resolve, nil
+}
+
+// The Go runtime points the thread pointer (fs) of its threads at the
+// second of their thread local storage slots (see runtime.m.tls) on amd64,
+// and only uses the first one, at -8(fs). That leaves five slots.
+const spareTLSSlots = 5
+
+func standInThreadLocal(name string, size uint64) (uintptr, bool) {
+	if offset, ok := standInTLS[name]; ok {
+		return offset, true
+	}
+	if hostMachine() != EM_X86_64 || size > 8 || len(standInTLS) == spareTLSSlots {
+		return 0, false
+	}
+	offset := uintptr(len(standInTLS) * 8)
+	standInTLS[name] = offset
+	return offset, true
+}
+
+// Maps a page with an instruction that traps, for the stand-ins of
+// functions.
+func mapTrap() (uintptr, error) {
+	memory, err := syscall.Mmap(-1, 0, syscall.Getpagesize(), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return 0, err
+	}
+	if hostMachine() == EM_AARCH64 {
+		copy(memory, []byte{0x20, 0x00, 0x20, 0xd4}) // brk #1
+	} else {
+		copy(memory, []byte{0x0f, 0x0b}) // ud2
+	}
+	if err := syscall.Mprotect(memory, syscall.PROT_READ|syscall.PROT_EXEC); err != nil {
+		syscall.Munmap(memory)
+		return 0, err
+	}
+	return uintptr(unsafe.Pointer(&memory[0])), nil
+}
+
+// Finds the thread local storage of glibc's libc after the dynamic linker
+// has mapped it, so that other libraries can refer to errno: its address is
+// returned by __errno_location, and the thread pointer, which points at the
+// thread control block on amd64, by pthread_self.
+func (s *SharedObject) findGlibcTLS() {
+	errno := s.symbols.GetSymbol("errno")
+	if errno == nil || errno.Type != STT_TLS || hostMachine() != EM_X86_64 {
+		return
+	}
+	errnoLocation, err := s.Lookup("__errno_location")
+	if err != nil {
+		return
+	}
+	pthreadSelf, err := s.Lookup("pthread_self")
+	if err != nil {
+		return
+	}
+	// Both have to be called on the same thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	address, _, err := lib.CallSysV(errnoLocation, nil, nil)
+	if err != nil {
+		return
+	}
+	threadPointer, _, err := lib.CallSysV(pthreadSelf, nil, nil)
+	if err != nil {
+		return
+	}
+	s.tlsOffset = uintptr(address - threadPointer - uint64(errno.Value))
+	s.hasTLS = true
+}
@@ -0,0 +1,589 @@
+package elf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// A SharedObject is a shared library (ET_DYN) that has been loaded into the
+// memory of the process, without the help of the dynamic linker, so that
+// its functions can be called from compiled code (see LoadSharedObject).
+type SharedObject struct {
+	Path string
+	// The address that the library has been loaded at, which gets added to
+	// the addresses in the file.
+	Base    uintptr
+	memory  []byte
+	symbols *SymbolTable
+	// The protection of each page of the memory.
+	protection []int
+	// Set if the library has been mapped by the dynamic linker instead (see
+	// mappedLibrary).
+	mapped bool
+	// The offset of the thread local storage of the library from the
+	// thread pointer, if it's known.
+	tlsOffset uintptr
+	hasTLS    bool
+}
+
+// Returns the address of a symbol that a shared object refers to but
+// doesn't define, or false if it's unknown. For a thread local variable it
+// returns the offset of the variable from the thread pointer instead.
+type SymbolResolver func(name string) (uintptr, bool)
+
+// Returns the machine of the process.
+func hostMachine() ELFMachine {
+	if runtime.GOARCH == "arm64" {
+		return EM_AARCH64
+	}
+	return EM_X86_64
+}
+
+// Maps the shared object at path into memory and applies its relocations.
+// The symbols that it doesn't define itself are looked up with resolve;
+// unknown weak symbols are left at zero.
+//
+// This is a much simpler loader than the system's dynamic linker: it
+// doesn't support symbol versions or lazy binding, the library can't define
+// thread local variables, and it doesn't run the initialisers of the
+// library. That's enough for self-contained libraries, but not for e.g.
+// glibc's libc (see LoadLibrary).
+func LoadSharedObject(path string, resolve SymbolResolver) (*SharedObject, error) {
+	file, e, err := readSharedObject(path)
+	if err != nil {
+		return nil, err
+	}
+	return loadSharedObject(path, file, e, resolve)
+}
+
+func readSharedObject(path string) ([]byte, *ELF, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	e, err := ParseELF(bytes.NewReader(file))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse %s: %s", path, err.Error())
+	}
+	return file, e, nil
+}
+
+func loadSharedObject(path string, file []byte, e *ELF, resolve SymbolResolver) (*SharedObject, error) {
+	var err error
+	if e.Type != ET_DYN {
+		return nil, fmt.Errorf("%s is not a shared object", path)
+	}
+	if e.Machine != hostMachine() {
+		return nil, fmt.Errorf("%s is for %s, not %s", path, e.Machine, hostMachine())
+	}
+	result := &SharedObject{Path: path}
+	if result.symbols, err = dynamicSymbols(e); err != nil {
+		return nil, err
+	}
+	if err := result.mapSegments(e, file); err != nil {
+		return nil, err
+	}
+	// The segments are protected first, because the resolvers of indirect
+	// functions get called during the relocation.
+	if err := result.protectSegments(e); err != nil {
+		syscall.Munmap(result.memory)
+		return nil, err
+	}
+	if err := result.relocate(e, resolve); err != nil {
+		syscall.Munmap(result.memory)
+		return nil, fmt.Errorf("Failed to relocate %s: %s", path, err.Error())
+	}
+	return result, nil
+}
+
+// Returns the symbols in the SHT_DYNSYM section.
+func dynamicSymbols(e *ELF) (*SymbolTable, error) {
+	dynsym := getSectionOfType(e, SHT_DYNSYM)
+	if dynsym == nil {
+		return NewSymbolTable([]*Symbol{}), nil
+	}
+	return dynsym.GetSymbolTable(e.Sections[dynsym.Link].GetStringTable())
+}
+
+func getSectionOfType(e *ELF, typ SHType) *Section {
+	for _, s := range e.Sections {
+		if s.Type == typ {
+			return s
+		}
+	}
+	return nil
+}
+
+// Maps writable memory for all the PT_LOAD segments and copies them in.
+func (s *SharedObject) mapSegments(e *ELF, file []byte) error {
+	size := uint64(0)
+	for _, ph := range e.ProgramHeaders {
+		if ph.Type == PT_LOAD && uint64(ph.SegmentVirtualAddress)+ph.Memsize > size {
+			size = uint64(ph.SegmentVirtualAddress) + ph.Memsize
+		}
+	}
+	if size == 0 {
+		return fmt.Errorf("%s doesn't have any loadable segments", s.Path)
+	}
+	size = alignTo(size, uint64(syscall.Getpagesize()))
+	memory, err := syscall.Mmap(-1, 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return fmt.Errorf("Failed to map %s: %s", s.Path, err.Error())
+	}
+	s.memory = memory
+	s.Base = uintptr(unsafe.Pointer(&memory[0]))
+	for _, ph := range e.ProgramHeaders {
+		if ph.Type != PT_LOAD {
+			continue
+		}
+		if uint64(ph.Offset)+ph.Filesize > uint64(len(file)) || ph.Filesize > ph.Memsize {
+			syscall.Munmap(memory)
+			return fmt.Errorf("Segment at 0x%x is outside of %s", ph.Offset, s.Path)
+		}
+		copy(memory[ph.SegmentVirtualAddress:], file[ph.Offset:uint64(ph.Offset)+ph.Filesize])
+	}
+	return nil
+}
+
+// Gives every page the protection of the segments that it's part of.
+func (s *SharedObject) protectSegments(e *ELF) error {
+	pageSize := uint64(syscall.Getpagesize())
+	protection := make([]int, uint64(len(s.memory))/pageSize)
+	for _, ph := range e.ProgramHeaders {
+		if ph.Type != PT_LOAD || ph.Memsize == 0 {
+			continue
+		}
+		prot := 0
+		if ph.Flags&PF_R != 0 {
+			prot |= syscall.PROT_READ
+		}
+		if ph.Flags&PF_W != 0 {
+			prot |= syscall.PROT_WRITE
+		}
+		if ph.Flags&PF_X != 0 {
+			prot |= syscall.PROT_EXEC
+		}
+		start := uint64(ph.SegmentVirtualAddress) / pageSize
+		end := (uint64(ph.SegmentVirtualAddress) + ph.Memsize + pageSize - 1) / pageSize
+		for page := start; page < end; page++ {
+			protection[page] |= prot
+		}
+	}
+	for page := 0; page < len(protection); {
+		end := page + 1
+		for end < len(protection) && protection[end] == protection[page] {
+			end++
+		}
+		memory := s.memory[uint64(page)*pageSize : uint64(end)*pageSize]
+		if err := syscall.Mprotect(memory, protection[page]); err != nil {
+			return err
+		}
+		page = end
+	}
+	s.protection = protection
+	return nil
+}
+
+// Applies the relocations in the SHT_RELA and SHT_RELR sections. The
+// IRELATIVE relocations are applied last, because their resolvers might
+// depend on the others.
+func (s *SharedObject) relocate(e *ELF, resolve SymbolResolver) error {
+	byteOrder := e.GetByteOrder()
+	indirect := []*Rela{}
+	for _, section := range e.Sections {
+		if section.Type == SHT_RELR {
+			if err := s.relocateRelative(section, byteOrder); err != nil {
+				return err
+			}
+			continue
+		}
+		if section.Type != SHT_RELA || section.Flags&SHF_ALLOC == 0 {
+			continue
+		}
+		relocations, err := section.GetRelocations()
+		if err != nil {
+			return err
+		}
+		for _, r := range relocations {
+			if r.Type == R_X86_64_IRELATIVE && e.Machine == EM_X86_64 || r.Type == R_AARCH64_IRELATIVE && e.Machine == EM_AARCH64 {
+				indirect = append(indirect, r)
+				continue
+			}
+			value, err := s.relocationValue(e.Machine, r, resolve)
+			if err != nil {
+				return err
+			}
+			if err := s.write(r.Offset, value, byteOrder); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range indirect {
+		value, err := callResolver(s.Base + uintptr(r.Addend))
+		if err != nil {
+			return err
+		}
+		if err := s.write(r.Offset, value, byteOrder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the value for a relocation that doesn't need to call a resolver.
+func (s *SharedObject) relocationValue(machine ELFMachine, r *Rela, resolve SymbolResolver) (uint64, error) {
+	switch {
+	case machine == EM_X86_64 && r.Type == R_X86_64_RELATIVE,
+		machine == EM_AARCH64 && r.Type == R_AARCH64_RELATIVE:
+		return uint64(s.Base) + uint64(r.Addend), nil
+	case machine == EM_X86_64 && (r.Type == R_X86_64_GLOB_DAT || r.Type == R_X86_64_JUMP_SLOT):
+		return s.symbolValue(r.Symbol, resolve)
+	case machine == EM_X86_64 && r.Type == R_X86_64_64,
+		machine == EM_AARCH64 && (r.Type == R_AARCH64_ABS64 || r.Type == R_AARCH64_GLOB_DAT || r.Type == R_AARCH64_JUMP_SLOT):
+		value, err := s.symbolValue(r.Symbol, resolve)
+		return value + uint64(r.Addend), err
+	case machine == EM_X86_64 && r.Type == R_X86_64_TPOFF64:
+		value, err := s.threadPointerOffset(r.Symbol, resolve)
+		return value + uint64(r.Addend), err
+	case machine == EM_X86_64 && r.Type >= R_X86_64_DTPMOD64 && r.Type <= R_X86_64_TPOFF64,
+		machine == EM_AARCH64 && r.Type >= R_AARCH64_TLS_DTPMOD && r.Type <= R_AARCH64_TLSDESC:
+		return 0, fmt.Errorf("Thread local storage is not supported")
+	}
+	return 0, fmt.Errorf("Unsupported relocation %d for %s", r.Type, machine)
+}
+
+// Returns the address of the symbol with the given index.
+func (s *SharedObject) symbolValue(index uint32, resolve SymbolResolver) (uint64, error) {
+	if int(index) >= len(s.symbols.Symbols) {
+		return 0, fmt.Errorf("Symbol %d is out of bounds", index)
+	}
+	symbol := s.symbols.Symbols[index]
+	if symbol.Shndx != SHN_UNDEF {
+		return s.definedSymbolValue(symbol)
+	}
+	if resolve != nil {
+		if address, ok := resolve(symbol.GetName()); ok {
+			return uint64(address), nil
+		}
+	}
+	if symbol.Binding == SB_WEAK {
+		return 0, nil
+	}
+	return 0, fmt.Errorf("Undefined symbol %s", symbol.GetName())
+}
+
+// Returns the offset of a thread local variable from the thread pointer.
+// Only the variables that other libraries define are supported.
+func (s *SharedObject) threadPointerOffset(index uint32, resolve SymbolResolver) (uint64, error) {
+	if int(index) >= len(s.symbols.Symbols) {
+		return 0, fmt.Errorf("Symbol %d is out of bounds", index)
+	}
+	symbol := s.symbols.Symbols[index]
+	if symbol.Shndx == SHN_UNDEF && resolve != nil {
+		if offset, ok := resolve(symbol.GetName()); ok {
+			return uint64(offset), nil
+		}
+	}
+	return 0, fmt.Errorf("Thread local storage is not supported for %s", symbol.GetName())
+}
+
+func (s *SharedObject) definedSymbolValue(symbol *Symbol) (uint64, error) {
+	address := uint64(s.Base) + uint64(symbol.Value)
+	if symbol.Type == STT_GNU_IFUNC {
+		return callResolver(uintptr(address))
+	}
+	return address, nil
+}
+
+// Calls the resolver of an indirect function, which returns the address of
+// the implementation that should be used.
+func callResolver(address uintptr) (uint64, error) {
+	result, _, err := lib.CallSysV(address, nil, nil)
+	return result, err
+}
+
+func (s *SharedObject) write(offset Elf64_Addr, value uint64, byteOrder binary.ByteOrder) error {
+	if err := s.checkWritable(uint64(offset)); err != nil {
+		return err
+	}
+	byteOrder.PutUint64(s.memory[offset:], value)
+	return nil
+}
+
+// Relocations can only be applied to the writable segments, e.g. to the
+// global offset table.
+func (s *SharedObject) checkWritable(offset uint64) error {
+	if offset+8 > uint64(len(s.memory)) {
+		return fmt.Errorf("Relocation at 0x%x is outside of the library", offset)
+	}
+	pageSize := uint64(syscall.Getpagesize())
+	for page := offset / pageSize; page <= (offset+7)/pageSize; page++ {
+		if s.protection[page]&syscall.PROT_WRITE == 0 {
+			return fmt.Errorf("Relocation at 0x%x is in a read-only segment", offset)
+		}
+	}
+	return nil
+}
+
+// Applies the relocations in a SHT_RELR section: an even entry is the
+// offset of a word that gets relocated, and an odd entry is a bitmap of
+// the next 63 words that get relocated as well.
+func (s *SharedObject) relocateRelative(section *Section, byteOrder binary.ByteOrder) error {
+	next := uint64(0)
+	for i := 0; i+8 <= len(section.Data); i += 8 {
+		entry := byteOrder.Uint64(section.Data[i:])
+		if entry&1 == 0 {
+			if err := s.relocateWord(entry, byteOrder); err != nil {
+				return err
+			}
+			next = entry + 8
+			continue
+		}
+		for bit := uint64(0); bit < 63; bit++ {
+			if entry&(2<<bit) == 0 {
+				continue
+			}
+			if err := s.relocateWord(next+bit*8, byteOrder); err != nil {
+				return err
+			}
+		}
+		next += 63 * 8
+	}
+	return nil
+}
+
+func (s *SharedObject) relocateWord(offset uint64, byteOrder binary.ByteOrder) error {
+	if err := s.checkWritable(offset); err != nil {
+		return err
+	}
+	value := byteOrder.Uint64(s.memory[offset:]) + uint64(s.Base)
+	byteOrder.PutUint64(s.memory[offset:], value)
+	return nil
+}
+
+// Returns the address of a function or variable that the library exports,
+// or the offset from the thread pointer of a thread local variable.
+func (s *SharedObject) Lookup(name string) (uintptr, error) {
+	symbol := s.symbols.GetSymbol(name)
+	if symbol == nil || symbol.Shndx == SHN_UNDEF || symbol.Binding == SB_LOCAL {
+		return 0, fmt.Errorf("%s doesn't define %s", s.Path, name)
+	}
+	if symbol.Type == STT_TLS {
+		if !s.hasTLS {
+			return 0, fmt.Errorf("Thread local storage is not supported for %s in %s", name, s.Path)
+		}
+		return s.tlsOffset + uintptr(symbol.Value), nil
+	}
+	address, err := s.definedSymbolValue(symbol)
+	return uintptr(address), err
+}
+
+func (s *SharedObject) resolve(name string) (uintptr, bool) {
+	address, err := s.Lookup(name)
+	return address, err == nil
+}
+
+// Unmaps the library. Its functions can't be called afterwards.
+func (s *SharedObject) Close() error {
+	if s.mapped {
+		return fmt.Errorf("%s has been mapped by the dynamic linker", s.Path)
+	}
+	if s.memory == nil {
+		return fmt.Errorf("%s has already been closed", s.Path)
+	}
+	err := syscall.Munmap(s.memory)
+	s.memory = nil
+	return err
+}
+
+// The directories that libraries are looked for in after the ones in
+// LD_LIBRARY_PATH.
+var LibraryPath = []string{
+	"/lib/x86_64-linux-gnu", "/usr/lib/x86_64-linux-gnu",
+	"/lib/aarch64-linux-gnu", "/usr/lib/aarch64-linux-gnu",
+	"/lib64", "/usr/lib64", "/lib", "/usr/lib", "/usr/local/lib",
+}
+
+// Returns the path of a library, which is name itself if it contains a
+// slash.
+func FindLibrary(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return name, nil
+	}
+	dirs := append(filepath.SplitList(os.Getenv("LD_LIBRARY_PATH")), LibraryPath...)
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("Library %s not found", name)
+}
+
+// Returns the copy of the library that the dynamic linker has mapped into
+// the process, or nil if there isn't one, e.g. because the process is
+// statically linked. The copies are found in /proc/self/maps.
+func mappedLibrary(name string) (*SharedObject, error) {
+	maps, err := ioutil.ReadFile("/proc/self/maps")
+	if err != nil {
+		return nil, nil
+	}
+	for _, line := range strings.Split(string(maps), "\n") {
+		// address perms offset dev inode path
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		path := strings.Join(fields[5:], " ")
+		offset, err := strconv.ParseUint(fields[2], 16, 64)
+		if err != nil || offset != 0 || (path != name && filepath.Base(path) != name) {
+			continue
+		}
+		start, err := strconv.ParseUint(strings.Split(fields[0], "-")[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		_, e, err := readSharedObject(path)
+		if err != nil {
+			return nil, err
+		}
+		symbols, err := dynamicSymbols(e)
+		if err != nil {
+			return nil, err
+		}
+		// The first segment starts at the base address
+		for _, ph := range e.ProgramHeaders {
+			if ph.Type == PT_LOAD {
+				start -= uint64(ph.SegmentVirtualAddress) &^ uint64(syscall.Getpagesize()-1)
+				break
+			}
+		}
+		so := &SharedObject{
+			Path:    path,
+			Base:    uintptr(start),
+			symbols: symbols,
+			mapped:  true,
+		}
+		so.findGlibcTLS()
+		return so, nil
+	}
+	return nil, nil
+}
+
+var (
+	loadedLibraries = map[string]*SharedObject{}
+	loadedMutex     sync.Mutex
+)
+
+// Loads the library with the given name (see FindLibrary), and the
+// libraries that it needs, unless they have been loaded already. The
+// symbols that a library doesn't define are looked up in the libraries
+// that it needs. The libraries stay loaded.
+//
+// Libraries that the dynamic linker has already mapped into the process
+// are used as they are. glibc's libc and dynamic linker can't be loaded
+// otherwise, but the libraries that need them, like libm, are loaded
+// against stand-ins for them (see glibcStandIn).
+func LoadLibrary(name string) (*SharedObject, error) {
+	loadedMutex.Lock()
+	defer loadedMutex.Unlock()
+	return loadLibrary(name, map[string]bool{})
+}
+
+func loadLibrary(name string, loading map[string]bool) (*SharedObject, error) {
+	if so, ok := loadedLibraries[name]; ok {
+		return so, nil
+	}
+	so, err := mappedLibrary(name)
+	if err != nil {
+		return nil, err
+	}
+	if so != nil {
+		loadedLibraries[name] = so
+		return so, nil
+	}
+	if loading[name] {
+		return nil, fmt.Errorf("Library %s depends on itself", name)
+	}
+	loading[name] = true
+	if isGlibc(name) {
+		// It only works after it has set up the process itself
+		return nil, fmt.Errorf("%s can't be loaded, because the process isn't linked against it", name)
+	}
+	path, err := FindLibrary(name)
+	if err != nil {
+		return nil, err
+	}
+	file, e, err := readSharedObject(path)
+	if err != nil {
+		return nil, err
+	}
+	needed, err := neededLibraries(e)
+	if err != nil {
+		return nil, err
+	}
+	dependencies := []SymbolResolver{}
+	for _, n := range needed {
+		var dependency SymbolResolver
+		so, err := loadLibrary(n, loading)
+		if err == nil {
+			dependency = so.resolve
+		} else if isGlibc(n) {
+			dependency, err = glibcStandIn(n)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load %s, which is needed by %s: %s", n, name, err.Error())
+		}
+		dependencies = append(dependencies, dependency)
+	}
+	resolve := func(symbol string) (uintptr, bool) {
+		for _, dependency := range dependencies {
+			if address, ok := dependency(symbol); ok {
+				return address, true
+			}
+		}
+		return 0, false
+	}
+	so, err = loadSharedObject(path, file, e, resolve)
+	if err != nil {
+		return nil, err
+	}
+	loadedLibraries[name] = so
+	return so, nil
+}
+
+// Returns the names in the DT_NEEDED entries.
+func neededLibraries(e *ELF) ([]string, error) {
+	dynamic := getSectionOfType(e, SHT_DYNAMIC)
+	if dynamic == nil {
+		return []string{}, nil
+	}
+	entries, err := dynamic.GetDynamicEntries()
+	if err != nil {
+		return nil, err
+	}
+	names := e.Sections[dynamic.Link].GetStringTable()
+	result := []string{}
+	for _, entry := range entries {
+		if entry.Tag == DT_NEEDED {
+			name, err := names.GetString(int(entry.Value))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
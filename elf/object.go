@@ -0,0 +1,189 @@
+package elf
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+// The sections of an Object that can be referred to.
+type ObjectSection uint8
+
+const (
+	TextSection ObjectSection = iota
+	ReadOnlyDataSection
+	DataSection
+	// The .got of an Executable, with an entry for each of its Imports. In
+	// an Object the entries are in the .data.rel.ro section.
+	GOTSection
+)
+
+// A function in the .text section of an Object, which gets exported as a
+// global symbol.
+type ObjectFunction struct {
+	Name   string
+	Offset uint64
+	Size   uint64
+}
+
+// A reference from the .text section to one of the sections of an Object.
+// The Addend includes the offset of the target in its section.
+type ObjectRelocation struct {
+	Type    RelocationType
+	Offset  uint64
+	Section ObjectSection
+	Addend  int64
+}
+
+// The contents of a relocatable object file (ET_REL), which can be linked
+// into other programs by the system linker (see CreateObjectFile).
+type Object struct {
+	Machine      ELFMachine
+	Text         []byte
+	ReadOnlyData []byte
+	Data         []byte
+	Functions    []*ObjectFunction
+	Relocations  []*ObjectRelocation
+	// The external functions that the code calls through their eight byte
+	// entries in the GOTSection, in the same order.
+	Imports []string
+}
+
+// Returns the ELF file for the object with the sections:
+//
+//	.text .rodata .data .symtab .strtab .rela.text .note.GNU-stack .shstrtab
+//
+// The symbol table starts with a local STT_SECTION symbol for each of the
+// first three sections, which the relocations refer to, followed by the
+// functions. If there are Imports their entries are in a .data.rel.ro
+// section, which gets a section symbol as well, and which the linker fills
+// in with the addresses of the undefined symbols for the imports (see
+// .rela.data.rel.ro).
+func (o *Object) ELF() (*ELF, error) {
+	e := NewELF()
+	e.ELFHeader = NewELFHeader()
+	e.Type = ET_REL
+	e.Machine = o.Machine
+
+	text := NewTextSection()
+	text.Data = o.Text
+	text.AddrAlign = 16
+	rodata := NewReadOnlyDataSection()
+	rodata.Data = o.ReadOnlyData
+	rodata.AddrAlign = 16
+	data := NewDataSection()
+	data.Data = o.Data
+	data.AddrAlign = 16
+	symtab := NewSection(".symtab", SHT_SYMTAB, SHF_NULL)
+	symtab.AddrAlign = 8
+	symtab.EntSize = SymbolSize64
+	strtab := NewSection(".strtab", SHT_STRTAB, SHF_NULL)
+	rela := NewSection(".rela.text", SHT_RELA, SHF_INFO_LINK)
+	rela.AddrAlign = 8
+	rela.EntSize = RelaSize64
+	// Marks the stack as non-executable
+	note := NewSection(".note.GNU-stack", SHT_PROGBITS, SHF_NULL)
+	e.Sections = []*Section{
+		NewNullSection(), text, rodata, data, symtab, strtab, rela, note,
+		NewSectionHeaderStringSection(),
+	}
+	var got, gotRela *Section
+	if len(o.Imports) > 0 {
+		got = NewSection(".data.rel.ro", SHT_PROGBITS, SHF_ALLOC|SHF_WRITE)
+		got.Data = make([]byte, 8*len(o.Imports))
+		got.AddrAlign = 8
+		gotRela = NewSection(".rela.data.rel.ro", SHT_RELA, SHF_INFO_LINK)
+		gotRela.AddrAlign = 8
+		gotRela.EntSize = RelaSize64
+		e.Sections = append(e.Sections[:len(e.Sections)-1], got, gotRela, e.Sections[len(e.Sections)-1])
+	}
+	sectionIndex := map[ObjectSection]uint16{
+		TextSection:         uint16(e.SectionIndex(".text")),
+		ReadOnlyDataSection: uint16(e.SectionIndex(".rodata")),
+		DataSection:         uint16(e.SectionIndex(".data")),
+	}
+	symtab.Link = uint32(e.SectionIndex(".strtab"))
+	rela.Link = uint32(e.SectionIndex(".symtab"))
+	rela.Info = uint32(sectionIndex[TextSection])
+
+	symbols := []*Symbol{
+		NewSymbol("", SB_LOCAL, STT_NOTYPE, SHN_UNDEF, 0, 0),
+		NewSymbol("", SB_LOCAL, STT_SECTION, sectionIndex[TextSection], 0, 0),
+		NewSymbol("", SB_LOCAL, STT_SECTION, sectionIndex[ReadOnlyDataSection], 0, 0),
+		NewSymbol("", SB_LOCAL, STT_SECTION, sectionIndex[DataSection], 0, 0),
+	}
+	if got != nil {
+		sectionIndex[GOTSection] = uint16(e.SectionIndex(got.Name))
+		symbols = append(symbols, NewSymbol("", SB_LOCAL, STT_SECTION, sectionIndex[GOTSection], 0, 0))
+		gotRela.Link = rela.Link
+		gotRela.Info = uint32(sectionIndex[GOTSection])
+	}
+	// The index of the first non-local symbol
+	symtab.Info = uint32(len(symbols))
+	for _, f := range o.Functions {
+		if f.Offset+f.Size > uint64(len(o.Text)) {
+			return nil, fmt.Errorf("Function %s is outside of the .text section", f.Name)
+		}
+		symbols = append(symbols, NewSymbol(f.Name, SB_GLOBAL, STT_FUNC, sectionIndex[TextSection], Elf64_Addr(f.Offset), f.Size))
+	}
+	if got != nil {
+		gotRela.Data = []byte{}
+		for j, name := range o.Imports {
+			entry := &Rela{
+				Offset: Elf64_Addr(8 * j),
+				Symbol: uint32(len(symbols)),
+				Type:   absoluteRelocation(o.Machine),
+			}
+			b, err := entry.Encode(e.ELFHeader)
+			if err != nil {
+				return nil, err
+			}
+			gotRela.Data = append(gotRela.Data, b...)
+			symbols = append(symbols, NewSymbol(name, SB_GLOBAL, STT_FUNC, SHN_UNDEF, 0, 0))
+		}
+	}
+	strings := NewStringTable([]byte{0})
+	symbolData, err := NewSymbolTable(symbols).Encode(e.ELFHeader, strings)
+	if err != nil {
+		return nil, err
+	}
+	symtab.Data = symbolData
+	strtab.Data = strings.Bytes()
+
+	rela.Data = []byte{}
+	for _, r := range o.Relocations {
+		if _, ok := sectionIndex[r.Section]; !ok {
+			return nil, fmt.Errorf("Relocation at 0x%x refers to the .got, but the object doesn't have imports", r.Offset)
+		}
+		entry := &Rela{
+			Offset: Elf64_Addr(r.Offset),
+			// The section symbols directly follow the null symbol
+			Symbol: uint32(r.Section) + 1,
+			Type:   r.Type,
+			Addend: r.Addend,
+		}
+		b, err := entry.Encode(e.ELFHeader)
+		if err != nil {
+			return nil, err
+		}
+		rela.Data = append(rela.Data, b...)
+	}
+	return e, nil
+}
+
+func (o *Object) Encode() ([]byte, error) {
+	e, err := o.ELF()
+	if err != nil {
+		return nil, err
+	}
+	return e.Encode()
+}
+
+// Writes the object to a relocatable object file, which can be linked with
+// e.g. `ld -r` or `cc main.c object.o`.
+func CreateObjectFile(o *Object, path string) error {
+	result, err := o.Encode()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, result, 0644)
+}
@@ -15,14 +15,16 @@ func _() {
 	_ = x[PT_NOTE-4]
 	_ = x[PT_SHLIB-5]
 	_ = x[PT_PHDR-6]
+	_ = x[PT_GNU_STACK-1685382481]
 	_ = x[PT_LOPROC-1879048192]
 	_ = x[PT_HIPROC-2147483647]
 }
 
 const (
 	_PHType_name_0 = "PT_NULLPT_LOADPT_DYNAMICPT_INTERPPT_NOTEPT_SHLIBPT_PHDR"
-	_PHType_name_1 = "PT_LOPROC"
-	_PHType_name_2 = "PT_HIPROC"
+	_PHType_name_1 = "PT_GNU_STACK"
+	_PHType_name_2 = "PT_LOPROC"
+	_PHType_name_3 = "PT_HIPROC"
 )
 
 var (
@@ -33,10 +35,12 @@ func (i PHType) String() string {
 	switch {
 	case i <= 6:
 		return _PHType_name_0[_PHType_index_0[i]:_PHType_index_0[i+1]]
-	case i == 1879048192:
+	case i == 1685382481:
 		return _PHType_name_1
-	case i == 2147483647:
+	case i == 1879048192:
 		return _PHType_name_2
+	case i == 2147483647:
+		return _PHType_name_3
 	default:
 		return "PHType(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
@@ -43,6 +43,9 @@ const (
 	// the memory image of the program.  If it is present, it must precede any
 	// loadable segment entry.
 	PT_PHDR PHType = 6
+	// The flags of this segment specify whether the stack should be
+	// executable. Without it, the stack is executable on some systems.
+	PT_GNU_STACK PHType = 0x6474e551
 	// Values in this inclusive range are reserved for processor-specific semantics.
 	PT_LOPROC PHType = 0x70000000
 	// Values in this inclusive range are reserved for processor-specific semantics.
@@ -217,7 +220,6 @@ type ProgramHeaderTable []*ProgramHeader
 
 func (p ProgramHeaderTable) Encode(header *ELFHeader) ([]byte, error) {
 	result := make([]byte, int(header.ProgramHeaderEntrySize)*len(p))
-	fmt.Println(len(result), header.ProgramHeaderEntrySize, len(p))
 	for i, ph := range p {
 		phBytes, err := ph.Encode(header)
 		if err != nil {
@@ -0,0 +1,134 @@
+package elf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// The relocation types are processor specific; the names are prefixed with
+// the machine that they're defined for.
+type RelocationType uint32
+
+const (
+	R_X86_64_NONE      RelocationType = 0
+	R_X86_64_64        RelocationType = 1  // S + A
+	R_X86_64_PC32      RelocationType = 2  // S + A - P
+	R_X86_64_PLT32     RelocationType = 4  // L + A - P
+	R_X86_64_GLOB_DAT  RelocationType = 6  // S
+	R_X86_64_JUMP_SLOT RelocationType = 7  // S
+	R_X86_64_RELATIVE  RelocationType = 8  // B + A
+	R_X86_64_DTPMOD64  RelocationType = 16 // The module ID of the symbol
+	R_X86_64_DTPOFF64  RelocationType = 17 // The offset of the symbol in its TLS block
+	R_X86_64_TPOFF64   RelocationType = 18 // The offset of the symbol from the thread pointer
+	R_X86_64_IRELATIVE RelocationType = 37 // The result of calling B + A
+
+	R_AARCH64_NONE          RelocationType = 0
+	R_AARCH64_ABS64         RelocationType = 257  // S + A
+	R_AARCH64_ADR_PREL_LO21 RelocationType = 274  // S + A - P
+	R_AARCH64_CALL26        RelocationType = 283  // S + A - P
+	R_AARCH64_GLOB_DAT      RelocationType = 1025 // S + A
+	R_AARCH64_JUMP_SLOT     RelocationType = 1026 // S + A
+	R_AARCH64_RELATIVE      RelocationType = 1027 // B + A
+	R_AARCH64_TLS_DTPMOD    RelocationType = 1028 // The module ID of the symbol
+	R_AARCH64_TLS_DTPREL    RelocationType = 1029 // The offset of the symbol in its TLS block
+	R_AARCH64_TLS_TPREL     RelocationType = 1030 // The offset of the symbol from the thread pointer
+	R_AARCH64_TLSDESC       RelocationType = 1031 // A TLS descriptor
+	R_AARCH64_IRELATIVE     RelocationType = 1032 // The result of calling B + A
+)
+
+// Returns the relocation type that patches the reference in the machine
+// code of the given machine.
+func GetRelocationType(machine ELFMachine, typ lib.RelocationType) (RelocationType, error) {
+	switch {
+	case machine == EM_X86_64 && typ == lib.R_PCRelative32:
+		return R_X86_64_PC32, nil
+	case machine == EM_AARCH64 && typ == lib.R_ADR21:
+		return R_AARCH64_ADR_PREL_LO21, nil
+	}
+	return 0, fmt.Errorf("Unsupported relocation %d for %s", typ, machine)
+}
+
+// Patches the reference at the start of code for a relocation of the given
+// type, where value is the already computed S + A - P.
+func applyRelocation(machine ELFMachine, typ RelocationType, byteOrder binary.ByteOrder, code []byte, value int64) error {
+	switch {
+	case machine == EM_X86_64 && typ == R_X86_64_PC32:
+		if value < -(1<<31) || value >= 1<<31 || len(code) < 4 {
+			return fmt.Errorf("Can't apply %d to %d bit relocation", value, 32)
+		}
+		byteOrder.PutUint32(code, uint32(int32(value)))
+		return nil
+	case machine == EM_AARCH64 && typ == R_AARCH64_ADR_PREL_LO21:
+		if value < -(1<<20) || value >= 1<<20 || len(code) < 4 {
+			return fmt.Errorf("Can't apply %d to %d bit relocation", value, 21)
+		}
+		// immlo is in bits 29-30 and immhi in bits 5-23 of the ADR instruction
+		instr := byteOrder.Uint32(code) &^ (0x3<<29 | 0x7ffff<<5)
+		instr |= uint32(value&0x3)<<29 | uint32((value>>2)&0x7ffff)<<5
+		byteOrder.PutUint32(code, instr)
+		return nil
+	}
+	return fmt.Errorf("Unsupported relocation %d for %s", typ, machine)
+}
+
+const RelaSize64 = 24
+
+// A relocation entry with an explicit addend, as found in SHT_RELA sections.
+type Rela struct {
+	// For a relocatable file, the offset from the beginning of the section
+	// that gets patched to the storage unit affected by the relocation.
+	Offset Elf64_Addr
+	// The index in the symbol table of the symbol that the relocation refers
+	// to.
+	Symbol uint32
+	Type   RelocationType
+	// A constant addend used to compute the value to be stored into the
+	// relocatable field.
+	Addend int64
+}
+
+func (r *Rela) Encode(header *ELFHeader) ([]byte, error) {
+	byteOrder := header.GetByteOrder()
+	buffer := bytes.NewBuffer([]byte{})
+	info := uint64(r.Symbol)<<32 | uint64(r.Type)
+	for _, field := range []interface{}{r.Offset, info, r.Addend} {
+		if err := binary.Write(buffer, byteOrder, field); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+func ParseRela(header *ELFHeader, r *bytes.Reader) (*Rela, error) {
+	result := &Rela{}
+	byteOrder := header.GetByteOrder()
+	info := uint64(0)
+	if err := binary.Read(r, byteOrder, &result.Offset); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, byteOrder, &info); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, byteOrder, &result.Addend); err != nil {
+		return nil, err
+	}
+	result.Symbol = uint32(info >> 32)
+	result.Type = RelocationType(info & 0xffffffff)
+	return result, nil
+}
+
+func (s *Section) GetRelocations() ([]*Rela, error) {
+	result := []*Rela{}
+	r := bytes.NewReader(s.Data)
+	for r.Len() > 0 {
+		rela, err := ParseRela(s.header, r)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rela)
+	}
+	return result, nil
+}
@@ -12,13 +12,13 @@ type Section struct {
 	Name      string
 	Type      SHType
 	Flags     SHFlags
-	Size      uint32
+	Size      uint64
 	Addr      Elf64_Addr
 	Offset    Elf64_Off
 	Link      uint32
 	Info      uint32
-	AddrAlign uint32
-	EntSize   uint32
+	AddrAlign uint64
+	EntSize   uint64
 	Data      []byte
 
 	header *ELFHeader
@@ -70,12 +70,17 @@ func (s *Section) GetSymbolTable(strTable *StringTable) (*SymbolTable, error) {
 	return ParseSymbolTable(s.header, strTable, bytes.NewReader(s.Data))
 }
 
+// The first entry in the section header table, which is reserved.
+func NewNullSection() *Section {
+	return NewSection("", SHT_NULL, SHF_NULL)
+}
+
 // This section holds uninitialized data that contribute to the program’s memory
 // image.  By definition, the system initializes the data with zeros when the
 // program begins to run.  The section occupies no file space, as indicated by
 // the section type, SHT_NOBITS
 func NewBSSSection() *Section {
-	return NewSection(".bss", SHT_NOBITS, SHF_ALLOC&SHF_WRITE)
+	return NewSection(".bss", SHT_NOBITS, SHF_ALLOC|SHF_WRITE)
 }
 
 // This section holds version control information.
@@ -85,7 +90,7 @@ func NewCommentSection() *Section {
 
 // These sections hold initialized data that contribute to the program’s memory image
 func NewDataSection() *Section {
-	return NewSection(".data", SHT_PROGBITS, SHF_ALLOC&SHF_WRITE)
+	return NewSection(".data", SHT_PROGBITS, SHF_ALLOC|SHF_WRITE)
 }
 
 // These sections hold read-only data that typically contribute to a
@@ -101,7 +106,7 @@ func NewSectionHeaderStringSection() *Section {
 
 // This section holds the "text", or executable instructions, of a program
 func NewTextSection() *Section {
-	return NewSection(".text", SHT_PROGBITS, SHF_ALLOC)
+	return NewSection(".text", SHT_PROGBITS, SHF_ALLOC|SHF_EXECINSTR)
 }
 
 func ParseSections(header *ELFHeader, shTable []*SectionHeader, r *bytes.Reader) ([]*Section, error) {
@@ -127,7 +132,7 @@ func ParseSections(header *ELFHeader, shTable []*SectionHeader, r *bytes.Reader)
 			return nil, err
 		}
 		data := []byte{}
-		if sectionHeader.Type != SHT_NULL {
+		if sectionHeader.Type != SHT_NULL && sectionHeader.Type != SHT_NOBITS {
 			_, err := r.Seek(int64(sectionHeader.Offset), io.SeekStart)
 			if err != nil {
 				return nil, err
@@ -67,6 +67,10 @@ const (
 	// save space.
 	SHT_DYNSYM SHType = 11
 	SHT_NUM    SHType = 12
+	// This section holds relative relocations in a compact format: an
+	// address, followed by bitmaps of the words after it that get relocated
+	// as well.
+	SHT_RELR SHType = 19
 	// Values in this inclusive range are reserved for processor-specific
 	// semantics.  If meanings are specified, the processorsupplement explains
 	// them.
@@ -96,7 +100,10 @@ const (
 	// attribute is off for those sections.
 	SHF_ALLOC SHFlags = 0x2
 	// The section contains executable machine instructions.
-	SHF_EXECINSTR      SHFlags = 0x4
+	SHF_EXECINSTR SHFlags = 0x4
+	// The Info member of the section header holds a section header table
+	// index, e.g. of the section that a relocation section applies to.
+	SHF_INFO_LINK      SHFlags = 0x40
 	SHF_RELA_LIVEPATCH SHFlags = 0x00100000
 	SHF_RO_AFTER_INIT  SHFlags = 0x00200000
 	// All bits included in this mask are reserved for processor-specific
@@ -126,7 +133,7 @@ type SectionHeader struct {
 	// This member gives the section’s size in bytes.  Unless the section type is
 	// SHT_NOBITS, the section occupiess h_size bytes in the file.
 	// A section of type SHT_NOBITS may have a non-zerosize, but it occupies no space in the file.
-	Size uint32
+	Size uint64
 	// This member holds a section header table index link, whoseinterpretation depends on the section type.
 	Link uint32
 	// This member holds extra information, whose interpretationdepends on the section type.
@@ -137,14 +144,16 @@ type SectionHeader struct {
 	// congruent to 0, modulo the value of sh_addralign.  Currently,
 	// only 0 and positive integral powersof two are allowed.  Values 0 and 1
 	// mean the section has noalignment constraints.
-	AddrAlign uint32
+	AddrAlign uint64
 	// Some sections hold a table of fixed-size entries, such as a symbol
 	// table.  For such a section, this member gives the size in bytes of each
 	// entry.  The member contains 0 if the section doesnot hold a table of
 	// fixed-size entries
-	EntSize uint32
+	EntSize uint64
 }
 
+const SectionHeaderSize64 uint16 = 64
+
 func ParseSectionHeader(header *ELFHeader, r *bytes.Reader) (*SectionHeader, error) {
 	result := &SectionHeader{}
 	byteOrder := header.GetByteOrder()
@@ -181,6 +190,21 @@ func ParseSectionHeader(header *ELFHeader, r *bytes.Reader) (*SectionHeader, err
 	return result, nil
 }
 
+func (s *SectionHeader) Encode(header *ELFHeader) ([]byte, error) {
+	byteOrder := header.GetByteOrder()
+	buffer := bytes.NewBuffer([]byte{})
+	fields := []interface{}{
+		s.Name, s.Type, s.Flags, s.Addr, s.Offset, s.Size,
+		s.Link, s.Info, s.AddrAlign, s.EntSize,
+	}
+	for _, field := range fields {
+		if err := binary.Write(buffer, byteOrder, field); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
 func (s *SectionHeader) String() string {
 	table := [][]string{
 		[]string{"Section Header:"},
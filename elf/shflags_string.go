@@ -12,6 +12,7 @@ func _() {
 	_ = x[SHF_WRITE-1]
 	_ = x[SHF_ALLOC-2]
 	_ = x[SHF_EXECINSTR-4]
+	_ = x[SHF_INFO_LINK-64]
 	_ = x[SHF_RELA_LIVEPATCH-1048576]
 	_ = x[SHF_RO_AFTER_INIT-2097152]
 	_ = x[SHF_MASKPROC-4026531840]
@@ -20,9 +21,10 @@ func _() {
 const (
 	_SHFlags_name_0 = "SHF_NULLSHF_WRITESHF_ALLOC"
 	_SHFlags_name_1 = "SHF_EXECINSTR"
-	_SHFlags_name_2 = "SHF_RELA_LIVEPATCH"
-	_SHFlags_name_3 = "SHF_RO_AFTER_INIT"
-	_SHFlags_name_4 = "SHF_MASKPROC"
+	_SHFlags_name_2 = "SHF_INFO_LINK"
+	_SHFlags_name_3 = "SHF_RELA_LIVEPATCH"
+	_SHFlags_name_4 = "SHF_RO_AFTER_INIT"
+	_SHFlags_name_5 = "SHF_MASKPROC"
 )
 
 var (
@@ -35,12 +37,14 @@ func (i SHFlags) String() string {
 		return _SHFlags_name_0[_SHFlags_index_0[i]:_SHFlags_index_0[i+1]]
 	case i == 4:
 		return _SHFlags_name_1
-	case i == 1048576:
+	case i == 64:
 		return _SHFlags_name_2
-	case i == 2097152:
+	case i == 1048576:
 		return _SHFlags_name_3
-	case i == 4026531840:
+	case i == 2097152:
 		return _SHFlags_name_4
+	case i == 4026531840:
+		return _SHFlags_name_5
 	default:
 		return "SHFlags(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
@@ -21,6 +21,7 @@ func _() {
 	_ = x[SHT_SHLIB-10]
 	_ = x[SHT_DYNSYM-11]
 	_ = x[SHT_NUM-12]
+	_ = x[SHT_RELR-19]
 	_ = x[SHT_LOPROC-1879048192]
 	_ = x[SHT_HIPROC-2147483647]
 	_ = x[SHT_LOUSER-2147483648]
@@ -29,27 +30,30 @@ func _() {
 
 const (
 	_SHType_name_0 = "SHT_NULLSHT_PROGBITSSHT_SYMTABSHT_STRTABSHT_RELASHT_HASHSHT_DYNAMICSHT_NOTESHT_NOBITSSHT_RELSHT_SHLIBSHT_DYNSYMSHT_NUM"
-	_SHType_name_1 = "SHT_LOPROC"
-	_SHType_name_2 = "SHT_HIPROCSHT_LOUSER"
-	_SHType_name_3 = "SHT_HIUSER"
+	_SHType_name_1 = "SHT_RELR"
+	_SHType_name_2 = "SHT_LOPROC"
+	_SHType_name_3 = "SHT_HIPROCSHT_LOUSER"
+	_SHType_name_4 = "SHT_HIUSER"
 )
 
 var (
 	_SHType_index_0 = [...]uint8{0, 8, 20, 30, 40, 48, 56, 67, 75, 85, 92, 101, 111, 118}
-	_SHType_index_2 = [...]uint8{0, 10, 20}
+	_SHType_index_3 = [...]uint8{0, 10, 20}
 )
 
 func (i SHType) String() string {
 	switch {
 	case i <= 12:
 		return _SHType_name_0[_SHType_index_0[i]:_SHType_index_0[i+1]]
-	case i == 1879048192:
+	case i == 19:
 		return _SHType_name_1
+	case i == 1879048192:
+		return _SHType_name_2
 	case 2147483647 <= i && i <= 2147483648:
 		i -= 2147483647
-		return _SHType_name_2[_SHType_index_2[i]:_SHType_index_2[i+1]]
+		return _SHType_name_3[_SHType_index_3[i]:_SHType_index_3[i+1]]
 	case i == 4294967295:
-		return _SHType_name_3
+		return _SHType_name_4
 	default:
 		return "SHType(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
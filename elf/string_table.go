@@ -15,6 +15,17 @@ func NewStringTable(data []byte) *StringTable {
 	return &StringTable{data}
 }
 
+// Appends the string to the table and returns its index.
+func (s *StringTable) Add(str string) uint32 {
+	ix := uint32(len(s.data))
+	s.data = append(append(s.data, []byte(str)...), 0)
+	return ix
+}
+
+func (s *StringTable) Bytes() []byte {
+	return s.data
+}
+
 func (s *StringTable) String() string {
 	result := []string{}
 	current := []byte{}
@@ -58,6 +58,14 @@ const (
 	// symbols for the file, if it is present.
 	STT_FILE SymbolType = 4
 
+	// The symbol is a thread local variable. Its value is the offset of the
+	// variable in the thread local storage of the object.
+	STT_TLS SymbolType = 6
+
+	// The symbol is associated with a function that returns the address of
+	// the implementation to use, e.g. depending on the features of the CPU.
+	STT_GNU_IFUNC SymbolType = 10
+
 	// Processor-specific
 	STT_LOPROC SymbolType = 13
 
@@ -96,6 +104,36 @@ type Symbol struct {
 	Size uint64
 }
 
+const SymbolSize64 = 24
+
+func NewSymbol(name string, binding SymbolBinding, typ SymbolType, shndx uint16, value Elf64_Addr, size uint64) *Symbol {
+	return &Symbol{
+		name:    name,
+		Binding: binding,
+		Type:    typ,
+		Shndx:   shndx,
+		Value:   value,
+		Size:    size,
+	}
+}
+
+func (s *Symbol) GetName() string {
+	return s.name
+}
+
+func (s *Symbol) Encode(header *ELFHeader) ([]byte, error) {
+	byteOrder := header.GetByteOrder()
+	buffer := bytes.NewBuffer([]byte{})
+	info := uint8(s.Binding)<<4 | uint8(s.Type)&0xf
+	fields := []interface{}{s.Name, info, s.Other, s.Shndx, s.Value, s.Size}
+	for _, field := range fields {
+		if err := binary.Write(buffer, byteOrder, field); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
 func ParseSymbol(header *ELFHeader, stringTable *StringTable, r *bytes.Reader) (*Symbol, error) {
 	result := &Symbol{}
 	byteOrder := header.GetByteOrder()
@@ -158,6 +196,31 @@ func (s *SymbolTable) GetSymbol(name string) *Symbol {
 	return s.lookup[name]
 }
 
+func NewSymbolTable(symbols []*Symbol) *SymbolTable {
+	lookup := map[string]*Symbol{}
+	for _, sym := range symbols {
+		lookup[sym.name] = sym
+	}
+	return &SymbolTable{symbols, lookup}
+}
+
+// Encodes the symbols, adding their names to the string table.
+func (s *SymbolTable) Encode(header *ELFHeader, stringTable *StringTable) ([]byte, error) {
+	result := []byte{}
+	for _, sym := range s.Symbols {
+		sym.Name = 0
+		if sym.name != "" {
+			sym.Name = stringTable.Add(sym.name)
+		}
+		b, err := sym.Encode(header)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, b...)
+	}
+	return result, nil
+}
+
 func ParseSymbolTable(header *ELFHeader, stringTable *StringTable, r *bytes.Reader) (*SymbolTable, error) {
 	result := []*Symbol{}
 	lookup := map[string]*Symbol{}
@@ -13,14 +13,18 @@ func _() {
 	_ = x[STT_FUNC-2]
 	_ = x[STT_SECTION-3]
 	_ = x[STT_FILE-4]
+	_ = x[STT_TLS-6]
+	_ = x[STT_GNU_IFUNC-10]
 	_ = x[STT_LOPROC-13]
 	_ = x[STT_HIPROC-15]
 }
 
 const (
 	_SymbolType_name_0 = "STT_NOTYPESTT_OBJECTSTT_FUNCSTT_SECTIONSTT_FILE"
-	_SymbolType_name_1 = "STT_LOPROC"
-	_SymbolType_name_2 = "STT_HIPROC"
+	_SymbolType_name_1 = "STT_TLS"
+	_SymbolType_name_2 = "STT_GNU_IFUNC"
+	_SymbolType_name_3 = "STT_LOPROC"
+	_SymbolType_name_4 = "STT_HIPROC"
 )
 
 var (
@@ -31,10 +35,14 @@ func (i SymbolType) String() string {
 	switch {
 	case i <= 4:
 		return _SymbolType_name_0[_SymbolType_index_0[i]:_SymbolType_index_0[i+1]]
-	case i == 13:
+	case i == 6:
 		return _SymbolType_name_1
-	case i == 15:
+	case i == 10:
 		return _SymbolType_name_2
+	case i == 13:
+		return _SymbolType_name_3
+	case i == 15:
+		return _SymbolType_name_4
 	default:
 		return "SymbolType(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
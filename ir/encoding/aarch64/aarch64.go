@@ -1,9 +1,11 @@
 package aarch64
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math"
 
-	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/elf"
 	"github.com/bspaans/jit-compiler/ir/expr"
 	. "github.com/bspaans/jit-compiler/ir/shared"
 	"github.com/bspaans/jit-compiler/ir/statements"
@@ -22,73 +24,179 @@ func (x *AArch64) EncodeStatement(stmt IR, ctx *IR_Context) ([]lib.Instruction,
 }
 
 func (x *AArch64) EncodeDataSection(stmts []IR, ctx *IR_Context) (*Segments, error) {
-	segments := NewSegments()
+	segments := &dataSection{Segments: NewSegments()}
 	for _, stmt := range stmts {
 		if err := encodeDataSection(stmt, ctx, segments); err != nil {
 			return nil, err
 		}
 	}
-	return segments, nil
+	// The allocator state comes last (see HeapStateSize)
+	segments.AddHeapState()
+	// The functions only add to the executable segment, so the addresses of
+	// the data that they refer to don't change anymore.
+	for _, f := range segments.functions {
+		if err := encode_IR_Function_for_DataSection(f.Function, ctx, segments.Segments, f.Declarations); err != nil {
+			return nil, err
+		}
+	}
+	return segments.Segments, nil
+}
+
+// The segments while the data section is being encoded. The functions are
+// encoded after all the other data has been added (see EncodeDataSection).
+type dataSection struct {
+	*Segments
+	// The functions and extern functions in the order they are declared in
+	declarations []*declaration
+	functions    []*pendingFunction
+}
+
+// A function, or extern function, that can be called from the functions that
+// are declared after it.
+type declaration struct {
+	Name      string
+	Signature Type
+	Address   *SegmentPointer
+}
+
+type pendingFunction struct {
+	Function *expr.IR_Function
+	// The functions that the function can call
+	Declarations []*declaration
+}
+
+func (d *dataSection) declare(name string, signature Type, address *SegmentPointer) {
+	d.declarations = append(d.declarations, &declaration{name, signature, address})
+}
+
+func (x *AArch64) EncodePrologue(stmts []IR, ctx *IR_Context) ([]lib.Instruction, error) {
+	return encodePrologue(ctx), nil
 }
 
 func encodeExpression(e IRExpression, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
 	switch v := e.(type) {
 	case *expr.IR_Add:
 		return encode_IR_Add(v, ctx, target)
+	case *expr.IR_AddressOf:
+		return encode_IR_AddressOf(v, ctx, target)
+	case *expr.IR_Alloc:
+		return encode_IR_Alloc(v, ctx, target)
 	case *expr.IR_And:
+		return encode_IR_And(v, ctx, target)
 	case *expr.IR_ArrayIndex:
+		return encode_IR_ArrayIndex(v, ctx, target)
+	case *expr.IR_BitwiseAnd:
+		return encode_IR_BitwiseAnd(v, ctx, target)
+	case *expr.IR_BitwiseNot:
+		return encode_IR_BitwiseNot(v, ctx, target)
+	case *expr.IR_BitwiseOr:
+		return encode_IR_BitwiseOr(v, ctx, target)
+	case *expr.IR_BitwiseXor:
+		return encode_IR_BitwiseXor(v, ctx, target)
 	case *expr.IR_Bool:
+		return encode_IR_Bool(v, ctx, target)
 	case *expr.IR_ByteArray:
+		return encode_IR_ByteArray(v, ctx, target)
 	case *expr.IR_Call:
+		return encode_IR_Call(v, ctx, target)
 	case *expr.IR_Cast:
+		return encode_IR_Cast(v, ctx, target)
+	case *expr.IR_Dereference:
+		return encode_IR_Dereference(v, ctx, target)
 	case *expr.IR_Div:
+		return encode_IR_Div(v, ctx, target)
 	case *expr.IR_Equals:
+		return encode_IR_Equals(v, ctx, target)
 	case *expr.IR_Float64:
+		return encode_IR_Float64(v, ctx, target)
 	case *expr.IR_Function:
+		return encode_IR_Function(v, ctx, target)
 	case *expr.IR_GT:
+		return encode_IR_GT(v, ctx, target)
 	case *expr.IR_GTE:
+		return encode_IR_GTE(v, ctx, target)
 	case *expr.IR_Int8:
+		return encode_IR_Int8(v, ctx, target)
 	case *expr.IR_Int16:
+		return encode_IR_Int16(v, ctx, target)
 	case *expr.IR_Int32:
+		return encode_IR_Int32(v, ctx, target)
 	case *expr.IR_Int64:
 		return encode_IR_Int64(v, ctx, target)
+	case *expr.IR_Len:
+		return encode_IR_Len(v, ctx, target)
 	case *expr.IR_LT:
+		return encode_IR_LT(v, ctx, target)
 	case *expr.IR_LTE:
+		return encode_IR_LTE(v, ctx, target)
+	case *expr.IR_Mod:
+		return encode_IR_Mod(v, ctx, target)
 	case *expr.IR_Mul:
+		return encode_IR_Mul(v, ctx, target)
 	case *expr.IR_Not:
+		return encode_IR_Not(v, ctx, target)
 	case *expr.IR_Or:
+		return encode_IR_Or(v, ctx, target)
+	case *expr.IR_ShiftLeft:
+		return encode_IR_ShiftLeft(v, ctx, target)
+	case *expr.IR_ShiftRight:
+		return encode_IR_ShiftRight(v, ctx, target)
+	case *expr.IR_Slice:
+		return encode_IR_Slice(v, ctx, target)
 	case *expr.IR_StaticArray:
+		return encode_IR_StaticArray(v, ctx, target)
 	case *expr.IR_Struct:
+		return encode_IR_Struct(v, ctx, target)
 	case *expr.IR_StructField:
+		return encode_IR_StructField(v, ctx, target)
 	case *expr.IR_Syscall:
+		return encode_IR_Syscall(v, ctx, target)
 	case *expr.IR_Sub:
 		return encode_IR_Sub(v, ctx, target)
 	case *expr.IR_Uint8:
+		return encode_IR_Uint8(v, ctx, target)
 	case *expr.IR_Uint16:
+		return encode_IR_Uint16(v, ctx, target)
 	case *expr.IR_Uint32:
+		return encode_IR_Uint32(v, ctx, target)
 	case *expr.IR_Uint64:
+		return encode_IR_Uint64(v, ctx, target)
 	case *expr.IR_Variable:
 		return encode_IR_Variable(v, ctx, target)
 	}
-	return nil, fmt.Errorf("Unsupported '%s' :: %s expression in x86_64 encoder", e.String(), e.Type().String())
+	return nil, fmt.Errorf("Unsupported '%s' expression in aarch64 encoder", e.String())
 }
 
 func encodeStatement(stmt IR, ctx *IR_Context) ([]lib.Instruction, error) {
+	if err := statements.CheckCalls(stmt, ctx); err != nil {
+		return nil, err
+	}
 	switch v := stmt.(type) {
-	case *statements.IR_Assignment:
-		return encode_IR_Assignment(v, ctx)
 	case *statements.IR_AndThen:
 		return encode_IR_AndThen(v, ctx)
 	case *statements.IR_ArrayAssignment:
+		return encode_IR_ArrayAssignment(v, ctx)
+	case *statements.IR_Assignment:
+		return encode_IR_Assignment(v, ctx)
+	case *statements.IR_Extern:
+		return encode_IR_Extern(v, ctx)
 	case *statements.IR_FunctionDef:
+		return encode_IR_FunctionDef(v, ctx)
 	case *statements.IR_If:
+		return encode_IR_If(v, ctx)
+	case *statements.IR_Nop:
+		return nil, nil
+	case *statements.IR_PointerAssignment:
+		return encode_IR_PointerAssignment(v, ctx)
 	case *statements.IR_Return:
+		return encode_IR_Return(v, ctx)
 	case *statements.IR_While:
+		return encode_IR_While(v, ctx)
 	}
-	return nil, fmt.Errorf("Unsupported '%s' statement in x86_64 encoder", stmt.String())
+	return nil, fmt.Errorf("Unsupported '%s' statement in aarch64 encoder", stmt.String())
 }
 
-func encodeDataSection(i IR, ctx *IR_Context, segments *Segments) error {
+func encodeDataSection(i IR, ctx *IR_Context, segments *dataSection) error {
 	switch v := i.(type) {
 	case *statements.IR_AndThen:
 		if err := encodeDataSection(v.Stmt1, ctx, segments); err != nil {
@@ -102,20 +210,44 @@ func encodeDataSection(i IR, ctx *IR_Context, segments *Segments) error {
 		return encodeExpressionForDataSection(v.Expr, ctx, segments)
 	case *statements.IR_Assignment:
 		return encodeExpressionForDataSection(v.Expr, ctx, segments)
+	case *statements.IR_Extern:
+		v.Address = segments.AddImport(v.Name, v.Library)
+		segments.declare(v.Name, v.Signature, v.Address)
+		return nil
 	case *statements.IR_FunctionDef:
+		// The address gets filled in when the function is encoded, and it
+		// is declared before its body so that it can call itself.
+		v.Expr.Address = &SegmentPointer{SegmentType: Executable}
+		segments.declare(v.Name, v.Expr.Signature, v.Expr.Address)
 		return encodeExpressionForDataSection(v.Expr, ctx, segments)
 	case *statements.IR_If:
-		// TODO
+		if err := encodeExpressionForDataSection(v.Condition, ctx, segments); err != nil {
+			return err
+		}
+		if err := encodeDataSection(v.Stmt1, ctx, segments); err != nil {
+			return err
+		}
+		return encodeDataSection(v.Stmt2, ctx, segments)
+	case *statements.IR_Nop:
+		return nil
+	case *statements.IR_PointerAssignment:
+		if err := encodeExpressionForDataSection(v.Pointer, ctx, segments); err != nil {
+			return err
+		}
+		return encodeExpressionForDataSection(v.Expr, ctx, segments)
 	case *statements.IR_Return:
-		// TODO
+		return encodeExpressionForDataSection(v.Expr, ctx, segments)
 	case *statements.IR_While:
+		if err := encodeExpressionForDataSection(v.Condition, ctx, segments); err != nil {
+			return err
+		}
+		return encodeDataSection(v.Stmt, ctx, segments)
 	default:
-		return fmt.Errorf("Unsupported '%s' statement in x86_64 data section encoder", i.String())
+		return fmt.Errorf("Unsupported '%s' statement in aarch64 data section encoder", i.String())
 	}
-	return nil
 }
 
-func encodeExpressionForDataSection(i IRExpression, ctx *IR_Context, segments *Segments) error {
+func encodeExpressionForDataSection(i IRExpression, ctx *IR_Context, segments *dataSection) error {
 	encodeOperators := func(op1, op2 IRExpression) error {
 		if err := encodeExpressionForDataSection(op1, ctx, segments); err != nil {
 			return err
@@ -126,12 +258,29 @@ func encodeExpressionForDataSection(i IRExpression, ctx *IR_Context, segments *S
 	case *expr.IR_ByteArray:
 		v.Address = segments.Add(ReadWrite, v.Value...)
 		return nil
+	case *expr.IR_Float64:
+		bytes := make([]uint8, 8)
+		binary.LittleEndian.PutUint64(bytes, math.Float64bits(v.Value))
+		v.Address = segments.Add(ReadOnly, bytes...)
+		return nil
 	case *expr.IR_Add:
 		return encodeOperators(v.Op1, v.Op2)
+	case *expr.IR_AddressOf:
+		return encodeExpressionForDataSection(v.Value, ctx, segments)
+	case *expr.IR_Alloc:
+		return encodeExpressionForDataSection(v.Count, ctx, segments)
 	case *expr.IR_And:
 		return encodeOperators(v.Op1, v.Op2)
 	case *expr.IR_ArrayIndex:
 		return encodeOperators(v.Array, v.Index)
+	case *expr.IR_BitwiseAnd:
+		return encodeOperators(v.Op1, v.Op2)
+	case *expr.IR_BitwiseNot:
+		return encodeExpressionForDataSection(v.Op1, ctx, segments)
+	case *expr.IR_BitwiseOr:
+		return encodeOperators(v.Op1, v.Op2)
+	case *expr.IR_BitwiseXor:
+		return encodeOperators(v.Op1, v.Op2)
 	case *expr.IR_Call:
 		for _, arg := range v.Args {
 			if err := encodeExpressionForDataSection(arg, ctx, segments); err != nil {
@@ -139,39 +288,64 @@ func encodeExpressionForDataSection(i IRExpression, ctx *IR_Context, segments *S
 			}
 		}
 		return nil
+	case *expr.IR_Cast:
+		return encodeExpressionForDataSection(v.Value, ctx, segments)
+	case *expr.IR_Dereference:
+		return encodeExpressionForDataSection(v.Pointer, ctx, segments)
 	case *expr.IR_Div:
 		return encodeOperators(v.Op1, v.Op2)
 	case *expr.IR_Equals:
 		return encodeOperators(v.Op1, v.Op2)
 	case *expr.IR_Function:
-		if err := encodeDataSection(v.Body, ctx, segments); err != nil {
+		inFunction := ctx.InFunction
+		ctx.InFunction = true
+		err := encodeDataSection(v.Body, ctx, segments)
+		ctx.InFunction = inFunction
+		if err != nil {
 			return err
 		}
-		// TODO: return encode_IR_Function_for_DataSection(v, ctx, segments)
+		segments.functions = append(segments.functions, &pendingFunction{v, segments.declarations})
 		return nil
 	case *expr.IR_GT:
 		return encodeOperators(v.Op1, v.Op2)
 	case *expr.IR_GTE:
 		return encodeOperators(v.Op1, v.Op2)
+	case *expr.IR_Len:
+		return encodeExpressionForDataSection(v.Value, ctx, segments)
 	case *expr.IR_LT:
 		return encodeOperators(v.Op1, v.Op2)
 	case *expr.IR_LTE:
 		return encodeOperators(v.Op1, v.Op2)
+	case *expr.IR_Mod:
+		return encodeOperators(v.Op1, v.Op2)
 	case *expr.IR_Mul:
 		return encodeOperators(v.Op1, v.Op2)
 	case *expr.IR_Not:
 		return encodeExpressionForDataSection(v.Op1, ctx, segments)
 	case *expr.IR_Or:
 		return encodeOperators(v.Op1, v.Op2)
-	case *expr.IR_StaticArray:
-		// TODO: return encode_IR_StaticArray_for_DataSection(v, segments)
+	case *expr.IR_ShiftLeft:
+		return encodeOperators(v.Op1, v.Op2)
+	case *expr.IR_ShiftRight:
+		return encodeOperators(v.Op1, v.Op2)
+	case *expr.IR_Slice:
+		if err := encodeOperators(v.Value, v.Low); err != nil {
+			return err
+		}
+		if v.High != nil {
+			return encodeExpressionForDataSection(v.High, ctx, segments)
+		}
 		return nil
+	case *expr.IR_StaticArray:
+		return encode_IR_StaticArray_for_DataSection(v, ctx, segments)
 	case *expr.IR_Struct:
-		// TODO: return encode_IR_Struct_for_DataSection(v, ctx, segments)
-		return nil
+		return encode_IR_Struct_for_DataSection(v, ctx, segments)
 	case *expr.IR_StructField:
 		return encodeExpressionForDataSection(v.Struct, ctx, segments)
 	case *expr.IR_Syscall:
+		if err := encodeExpressionForDataSection(v.Syscall, ctx, segments); err != nil {
+			return err
+		}
 		for _, arg := range v.Args {
 			if err := encodeExpressionForDataSection(arg, ctx, segments); err != nil {
 				return err
@@ -180,107 +354,35 @@ func encodeExpressionForDataSection(i IRExpression, ctx *IR_Context, segments *S
 		return nil
 	case *expr.IR_Sub:
 		return encodeOperators(v.Op1, v.Op2)
-	case *expr.IR_Bool, *expr.IR_Cast, *expr.IR_Variable, *expr.IR_Float64,
+	case *expr.IR_Bool, *expr.IR_Variable,
 		*expr.IR_Uint8, *expr.IR_Uint16, *expr.IR_Uint32, *expr.IR_Uint64,
 		*expr.IR_Int8, *expr.IR_Int16, *expr.IR_Int32, *expr.IR_Int64:
 		return nil
 	default:
-		return fmt.Errorf("Unsupported '%s' expr in x86_64 data section encoder", i.String())
+		return fmt.Errorf("Unsupported '%s' expr in aarch64 data section encoder", i.String())
 	}
-	return nil
 }
 
 func (x *AArch64) GetAllocator() Allocator {
 	return NewAArch64_Allocator()
 }
 
-type AArch64_Allocator struct {
-	Registers               []bool
-	RegistersAllocated      uint8
-	FloatRegisters          []bool
-	FloatRegistersAllocated uint8
-}
-
-func NewAArch64_Allocator() *AArch64_Allocator {
-	x := &AArch64_Allocator{
-		Registers:               make([]bool, 32),
-		RegistersAllocated:      0,
-		FloatRegisters:          make([]bool, 32), // TODO?
-		FloatRegistersAllocated: 0,
-	}
-	x.Registers[31] = true // stack pointer
-	x.RegistersAllocated = 1
-	return x
-}
-
-func (i *AArch64_Allocator) AllocateRegister(typ Type) lib.Operand {
-	if typ == TFloat64 {
-		return encoding.GetFloatingPointRegisterByIndex(i.allocateFloatRegister())
-	}
-	return encoding.Get64BitRegisterByIndex(i.allocateRegister()).ForOperandWidth(typ.Width())
-}
-
-func (i *AArch64_Allocator) DeallocateRegister(op lib.Operand) {
-	reg, ok := op.(*encoding.Register)
-	if !ok {
-		return
-	}
-	if reg.Size == lib.OWORD {
-		i.deallocateFloatRegister(reg.Register)
-		return
-	}
-	i.deallocateRegister(reg.Register)
-}
-
-func (i *AArch64_Allocator) allocateRegister() uint8 {
-	if i.RegistersAllocated >= 16 {
-		panic("Register allocation limit. Needs stack handling")
-	}
-	for j := 0; j < len(i.Registers); j++ {
-		if !i.Registers[j] {
-			i.Registers[j] = true
-			i.RegistersAllocated += 1
-			return uint8(j)
-		}
-	}
-	panic("Register allocation limit reached with incorrect allocation counter. Needs stack handling")
+func (x *AArch64) ELFMachine() elf.ELFMachine {
+	return elf.EM_AARCH64
 }
 
-func (i *AArch64_Allocator) deallocateRegister(reg uint8) {
-	i.Registers[reg] = false
-	i.RegistersAllocated -= 1
+// Returns a label pointing to the data in the segments, which can be loaded
+// with ADR.
+func dataAddress(ctx *IR_Context, pointer *SegmentPointer) *lib.Label {
+	address := ctx.Segments.GetAddress(pointer)
+	return lib.NewFixedLabel(fmt.Sprintf("data_0x%x", address), address)
 }
 
-func (i *AArch64_Allocator) allocateFloatRegister() uint8 {
-	if i.FloatRegistersAllocated >= 16 {
-		panic("FloatRegister allocation limit. Needs stack handling")
-	}
-	for j := 0; j < len(i.FloatRegisters); j++ {
-		if !i.FloatRegisters[j] {
-			i.FloatRegisters[j] = true
-			i.FloatRegistersAllocated += 1
-			return uint8(j)
-		}
-	}
-	panic("FloatRegister allocation limit reached with incorrect allocation counter. Needs stack handling")
-}
-
-func (i *AArch64_Allocator) deallocateFloatRegister(reg uint8) {
-	i.FloatRegisters[reg] = false
-	i.FloatRegistersAllocated -= 1
-}
-
-func (i *AArch64_Allocator) Copy() Allocator {
-	regs := make([]bool, 16)
-	floatRegs := make([]bool, 16)
-	for j := 0; j < 16; j++ {
-		regs[j] = i.Registers[j]
-		floatRegs[j] = i.FloatRegisters[j]
-	}
-	return &AArch64_Allocator{
-		Registers:               regs,
-		RegistersAllocated:      i.RegistersAllocated,
-		FloatRegisters:          floatRegs,
-		FloatRegistersAllocated: i.FloatRegistersAllocated,
+// Adds the instructions to the context and appends them to result.
+func addInstructions(ctx *IR_Context, result []lib.Instruction, instr ...lib.Instruction) []lib.Instruction {
+	for _, inst := range instr {
+		ctx.AddInstruction(inst)
+		result = append(result, inst)
 	}
+	return result
 }
@@ -1,5 +1,11 @@
 package aarch64
 
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
 /* The 64-bit ARM (AArch64) calling convention allocates the 31 general-purpose registers as:
 
     x31 (SP): Stack pointer or a zero register, depending on context.
@@ -19,5 +25,138 @@ The 32 floating-point registers are allocated as:
     v16 to v31: Local variables, caller saved.
 */
 
-type ABI_AArch64 struct {
+// The calling convention of the Procedure Call Standard for the Arm 64-bit
+// Architecture (AAPCS64).
+type ABI_AAPCS64 struct {
+}
+
+func NewABI_AAPCS64() *ABI_AAPCS64 {
+	return &ABI_AAPCS64{}
+}
+
+var (
+	intArgumentRegisters   = []*encoding.Register{encoding.X0, encoding.X1, encoding.X2, encoding.X3, encoding.X4, encoding.X5, encoding.X6, encoding.X7}
+	floatArgumentRegisters = []*encoding.Register{encoding.D0, encoding.D1, encoding.D2, encoding.D3, encoding.D4, encoding.D5, encoding.D6, encoding.D7}
+)
+
+// Returns where the arguments and the return value are passed. Integers and
+// pointers go in x0-x7, and floats in d0-d7, until the registers run out;
+// the rest are passed on the stack in eight byte slots. Structs of up to
+// four float64 fields are passed in consecutive d registers, other structs
+// of up to sixteen bytes in consecutive x registers, and larger structs are
+// passed as the address of a copy. A struct that doesn't fit in the
+// remaining registers goes on the stack, and no later argument of its kind
+// goes in a register. Large structs and slices are returned in the memory x8
+// points to.
+func (a *ABI_AAPCS64) ClassifyCall(args []Type, returnType Type) *CallLayout {
+	intTargets, floatTargets := intArgumentRegisters, floatArgumentRegisters
+	result := &CallLayout{
+		Args:   []*ValueLocation{},
+		Return: a.classifyReturn(returnType),
+	}
+	if result.Return.InMemory() {
+		result.ReturnPointer = encoding.X8
+	}
+	for _, arg := range args {
+		location := &ValueLocation{Size: 8}
+		var targets *[]*encoding.Register
+		count := 1
+		if IsFloat(arg) {
+			targets = &floatTargets
+		} else if str, ok := arg.(*TStruct); ok && isHomogeneousFloatAggregate(str) {
+			targets, count = &floatTargets, len(Scalars(str))
+			location.Size = 8 * count
+		} else if ok && Sizeof(str) <= 16 {
+			targets, count = &intTargets, (Sizeof(str)+7)/8
+			location.Size = 8 * count
+		} else {
+			targets = &intTargets
+			location.Indirect = ok
+		}
+		if count <= len(*targets) {
+			location.Registers = []lib.Operand{}
+			for _, reg := range (*targets)[:count] {
+				location.Registers = append(location.Registers, reg)
+			}
+			*targets = (*targets)[count:]
+		} else {
+			*targets = nil
+			location.StackOffset = result.StackSize
+			result.StackSize += location.Size
+		}
+		result.Args = append(result.Args, location)
+	}
+	// The stack pointer has to stay sixteen byte aligned.
+	result.StackSize = (result.StackSize + 15) &^ 15
+	return result
+}
+
+func (a *ABI_AAPCS64) classifyReturn(returnType Type) *ValueLocation {
+	if IsSlice(returnType) {
+		// Like a struct holding the header (see TSlice)
+		return &ValueLocation{Size: SliceHeaderSize}
+	}
+	str, ok := returnType.(*TStruct)
+	if !ok {
+		return &ValueLocation{Registers: []lib.Operand{ReturnRegister(returnType)}, Size: 8}
+	}
+	result := &ValueLocation{Registers: []lib.Operand{}, Size: (Sizeof(str) + 7) &^ 7}
+	if isHomogeneousFloatAggregate(str) {
+		for _, reg := range floatArgumentRegisters[:len(Scalars(str))] {
+			result.Registers = append(result.Registers, reg)
+		}
+	} else if Sizeof(str) <= 16 {
+		for _, reg := range intArgumentRegisters[:result.Size/8] {
+			result.Registers = append(result.Registers, reg)
+		}
+	} else {
+		result.Registers = nil
+	}
+	return result
+}
+
+func (a *ABI_AAPCS64) ReturnTypeToOperand(typ Type) lib.Operand {
+	return ReturnRegister(typ)
+}
+
+func (a *ABI_AAPCS64) IsCalleeSaved(reg lib.Operand) bool {
+	r, ok := reg.(*encoding.Register)
+	return ok && IsCalleeSaved(r)
+}
+
+// The main code is an ordinary function, which returns its result in x0.
+func (a *ABI_AAPCS64) ExecuteReturnOperand() lib.Operand {
+	return encoding.X0
+}
+
+// Structs of one to four float64 values, including those in nested structs
+// and arrays, are passed like that many floats.
+func isHomogeneousFloatAggregate(str *TStruct) bool {
+	scalars := Scalars(str)
+	if len(scalars) == 0 || len(scalars) > 4 {
+		return false
+	}
+	for _, field := range scalars {
+		if field.Type.Type() != T_Float64 {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the register in which a value of the given type is returned.
+func ReturnRegister(typ Type) *encoding.Register {
+	if typ.Type() == T_Float64 {
+		return encoding.D0
+	}
+	return encoding.X0.ForOperandWidth(typ.Width())
+}
+
+// Returns whether the register has to be preserved by the callee. Only the
+// bottom 64 bits of d8-d15 are preserved, which are the only bits in use.
+func IsCalleeSaved(reg *encoding.Register) bool {
+	if reg.Float {
+		return reg.Register >= 8 && reg.Register <= 15
+	}
+	return reg.Register >= 19 && reg.Register <= 28
 }
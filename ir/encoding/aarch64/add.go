@@ -8,6 +8,9 @@ import (
 )
 
 func encode_IR_Add(i *expr.IR_Add, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
-
-	return encode_Operator(i.Op1, i.Op2, aarch64.ADD, i.String(), ctx, target)
+	if IsPointer(i.Op1.ReturnType(ctx)) {
+		return encode_PointerOperator(i.Op1, i.Op2, aarch64.ADD, i.String(), ctx, target)
+	}
+	operator := numberOperator(i.Op1.ReturnType(ctx), aarch64.ADD, aarch64.FADD)
+	return encode_Operator(i.Op1, i.Op2, operator, i.String(), ctx, target)
 }
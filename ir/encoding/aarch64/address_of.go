@@ -0,0 +1,64 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_AddressOf(i *expr.IR_AddressOf, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	switch v := i.Value.(type) {
+	case *expr.IR_StructField:
+		_, offset, err := structField(v, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if offset > 4095 {
+			return nil, fmt.Errorf("Field '%s' is too far into %s", v.Field, v.Struct.String())
+		}
+		pointer, result, err := encodeOperand(v.Struct, ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer releaseOperand(ctx, v.Struct, pointer)
+		base, load := loadOperand(ctx, pointer, TUint64, 0)
+		result = lib.Instructions(result).Add(load)
+		dest := targetRegister(target, TUint64, 0)
+		result = addInstructions(ctx, result, aarch64.ADD(base, encoding.Uint64(offset), dest))
+		return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+	case *expr.IR_ArrayIndex:
+		array, result, err := encodeOperand(v.Array, ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer releaseOperand(ctx, v.Array, array)
+		index, instr, err := encodeOperand(v.Index, ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer releaseOperand(ctx, v.Index, index)
+		result = lib.Instructions(result).Add(instr)
+
+		indexReg, load1 := loadOperand(ctx, index, v.Index.ReturnType(ctx), 1)
+		base, load2 := arrayItems(ctx, v.Array.ReturnType(ctx), array, indexReg)
+		result = lib.Instructions(result).Add(load1).Add(load2)
+		dest := targetRegister(target, TUint64, 0)
+		shift := shiftForItemWidth(v.ReturnType(ctx).Width())
+		result = addInstructions(ctx, result,
+			aarch64.LSL(fullRegister(indexReg), encoding.Uint8(shift), encoding.X17),
+			aarch64.ADD(base, encoding.X17, dest),
+		)
+		return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+	case *expr.IR_Dereference:
+		return encodeExpression(v.Pointer, ctx, target)
+	}
+	if IsInline(i.Value.ReturnType(ctx)) {
+		// Structs and arrays with a size already evaluate to their address
+		return encodeExpression(i.Value, ctx, target)
+	}
+	return nil, fmt.Errorf("Can't take the address of %s", i.Value.String())
+}
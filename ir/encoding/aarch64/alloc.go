@@ -0,0 +1,94 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// The allocator hands out memory from the chunk that it mapped last, and
+// maps a new chunk when that runs out. The new chunk is big enough for the
+// allocation plus heapChunkSize bytes; the rest of the old one is lost.
+const heapChunkSize = 1 << 20
+
+// Encodes the allocator inline. The size is rounded up to a multiple of
+// sixteen bytes, so that every allocation is aligned. The allocator uses
+// x0-x5 and x8, which are only used to pass arguments, and keeps the address
+// of its state in x16; the kernel preserves all registers except x0.
+func encode_IR_Alloc(i *expr.IR_Alloc, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	countType := i.Count.ReturnType(ctx)
+	if !IsInteger(countType) || countType.Width() != lib.QUADWORD {
+		return nil, fmt.Errorf("Expecting a uint64 or int64 count in %s", i.String())
+	}
+	count, result, err := encodeOperand(i.Count, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, i.Count, count)
+	reg, load := loadOperand(ctx, count, TUint64, 0)
+	result = lib.Instructions(result).Add(load)
+	result = addInstructions(ctx, result, aarch64.MOV(reg, encoding.X1))
+	if size := uint64(Sizeof(i.ItemType)); size != 1 {
+		result = lib.Instructions(result).Add(loadImmediate(ctx, size, encoding.X2))
+		result = addInstructions(ctx, result, aarch64.MUL(encoding.X1, encoding.X2, encoding.X1))
+	}
+
+	result = addInstructions(ctx, result,
+		aarch64.ADD(encoding.X1, encoding.Uint64(15), encoding.X1),
+		aarch64.AND(encoding.X1, encoding.Uint64(0xfffffffffffffff0), encoding.X1),
+	)
+
+	fast := ctx.NewLabel("alloc_fast")
+	failed := ctx.NewLabel("alloc_failed")
+	done := ctx.NewLabel("alloc_done")
+	next := &encoding.DisplacedRegister{Register: encoding.X16, Displacement: 0}
+	end := &encoding.DisplacedRegister{Register: encoding.X16, Displacement: 8}
+
+	// x0 = next free byte, x3 = the end of the allocation
+	result = addInstructions(ctx, result,
+		aarch64.ADR(lib.NewFixedLabel("heap_state", HeapStateAddress()), encoding.X16),
+		aarch64.LDR(next, encoding.X0),
+		aarch64.LDR(end, encoding.X2),
+		aarch64.ADD(encoding.X0, encoding.X1, encoding.X3),
+		aarch64.CMP(encoding.X3, encoding.X2),
+		aarch64.B_cond(encoding.LS, fast),
+	)
+
+	// mmap(NULL, size + heapChunkSize, PROT_READ | PROT_WRITE,
+	//      MAP_PRIVATE | MAP_ANONYMOUS, -1, 0)
+	result = lib.Instructions(result).Add(loadImmediate(ctx, heapChunkSize, encoding.X17))
+	result = addInstructions(ctx, result, aarch64.ADD(encoding.X1, encoding.X17, encoding.X1))
+	result = lib.Instructions(result).Add(loadImmediate(ctx, 0, encoding.X0))
+	result = lib.Instructions(result).Add(loadImmediate(ctx, 3, encoding.X2))
+	result = lib.Instructions(result).Add(loadImmediate(ctx, 0x22, encoding.X3))
+	result = addInstructions(ctx, result, aarch64.MOVN(encoding.Uint64(0), encoding.Shift(0), encoding.X4))
+	result = lib.Instructions(result).Add(loadImmediate(ctx, 0, encoding.X5))
+	result = lib.Instructions(result).Add(loadImmediate(ctx, linuxSyscalls[uint64(expr.IR_Syscall_Linux_Mmap)], encoding.X8))
+	result = addInstructions(ctx, result,
+		aarch64.SVC(encoding.Uint64(0)),
+		// Errors are returned as -errno
+		aarch64.MOVN(encoding.Uint64(0xfff), encoding.Shift(0), encoding.X17),
+		aarch64.CMP(encoding.X0, encoding.X17),
+		aarch64.B_cond(encoding.HI, failed),
+		aarch64.ADD(encoding.X0, encoding.X1, encoding.X2),
+		aarch64.STR(encoding.X2, end),
+	)
+	result = lib.Instructions(result).Add(loadImmediate(ctx, heapChunkSize, encoding.X17))
+	result = addInstructions(ctx, result,
+		aarch64.SUB(encoding.X1, encoding.X17, encoding.X1),
+
+		lib.DefineLabel(fast),
+		aarch64.ADD(encoding.X0, encoding.X1, encoding.X3),
+		aarch64.STR(encoding.X3, next),
+		aarch64.B(done),
+
+		lib.DefineLabel(failed),
+	)
+	result = lib.Instructions(result).Add(loadImmediate(ctx, 0, encoding.X0))
+	result = addInstructions(ctx, result, lib.DefineLabel(done))
+	return lib.Instructions(result).Add(move(ctx, encoding.X0, target, TUint64)), nil
+}
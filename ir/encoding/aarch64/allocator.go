@@ -0,0 +1,196 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// The AArch64_Allocator hands out registers for variables and temporaries.
+//
+// Only the registers that aren't used by the calling convention are handed
+// out (see abi.go): x9-x15 and x19-x28 for integers and d8-d29 for floats.
+// The argument registers, x16-x17 and d30-d31 are kept free, so that values
+// can be moved into place for calls and loaded from and stored to the stack
+// without having to worry about clobbering anything.
+//
+// Variables are never released. If the registers run out, values are stored
+// in x29 relative slots in the StackFrame instead.
+type AArch64_Allocator struct {
+	Registers               []bool
+	RegistersAllocated      uint8
+	FloatRegisters          []bool
+	FloatRegistersAllocated uint8
+
+	// Shared between all the copies of this allocator.
+	Frame *StackFrame
+}
+
+// The order in which the registers are handed out. The caller-saved
+// registers come first, because the callee-saved ones have to be preserved
+// in the prologue.
+var (
+	allocatableRegisters      = []uint8{9, 10, 11, 12, 13, 14, 15, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28}
+	allocatableFloatRegisters = []uint8{16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 8, 9, 10, 11, 12, 13, 14, 15}
+)
+
+func NewAArch64_Allocator() *AArch64_Allocator {
+	return &AArch64_Allocator{
+		Registers:      make([]bool, 32),
+		FloatRegisters: make([]bool, 32),
+		Frame:          NewStackFrame(),
+	}
+}
+
+func (i *AArch64_Allocator) AllocateRegister(typ Type) lib.Operand {
+	if typ == TFloat64 {
+		if reg, ok := i.allocateFloatRegister(); ok {
+			return encoding.GetFloatingPointRegisterByIndex(reg)
+		}
+		return i.Frame.AllocateSlot()
+	}
+	if reg, ok := i.allocateRegister(); ok {
+		return encoding.Get64BitRegisterByIndex(reg).ForOperandWidth(typ.Width())
+	}
+	return i.Frame.AllocateSlot()
+}
+
+func (i *AArch64_Allocator) DeallocateRegister(op lib.Operand) {
+	reg, ok := op.(*encoding.Register)
+	if !ok {
+		return
+	}
+	if reg.Float {
+		if i.FloatRegisters[reg.Register] {
+			i.FloatRegisters[reg.Register] = false
+			i.FloatRegistersAllocated -= 1
+		}
+		return
+	}
+	if i.Registers[reg.Register] {
+		i.Registers[reg.Register] = false
+		i.RegistersAllocated -= 1
+	}
+}
+
+func (i *AArch64_Allocator) allocateRegister() (uint8, bool) {
+	for _, j := range allocatableRegisters {
+		if !i.Registers[j] {
+			i.Registers[j] = true
+			i.RegistersAllocated += 1
+			if IsCalleeSaved(encoding.Get64BitRegisterByIndex(j)) {
+				i.Frame.Save(encoding.Get64BitRegisterByIndex(j))
+			}
+			return j, true
+		}
+	}
+	return 0, false
+}
+
+func (i *AArch64_Allocator) allocateFloatRegister() (uint8, bool) {
+	for _, j := range allocatableFloatRegisters {
+		if !i.FloatRegisters[j] {
+			i.FloatRegisters[j] = true
+			i.FloatRegistersAllocated += 1
+			if IsCalleeSaved(encoding.GetFloatingPointRegisterByIndex(j)) {
+				i.Frame.Save(encoding.GetFloatingPointRegisterByIndex(j))
+			}
+			return j, true
+		}
+	}
+	return 0, false
+}
+
+// Returns the caller-saved registers that are currently allocated.
+func (i *AArch64_Allocator) CallerSavedInUse() []*encoding.Register {
+	result := []*encoding.Register{}
+	for _, j := range allocatableRegisters {
+		reg := encoding.Get64BitRegisterByIndex(j)
+		if i.Registers[j] && !IsCalleeSaved(reg) {
+			result = append(result, reg)
+		}
+	}
+	for _, j := range allocatableFloatRegisters {
+		reg := encoding.GetFloatingPointRegisterByIndex(j)
+		if i.FloatRegisters[j] && !IsCalleeSaved(reg) {
+			result = append(result, reg)
+		}
+	}
+	return result
+}
+
+func (i *AArch64_Allocator) Copy() Allocator {
+	regs := make([]bool, len(i.Registers))
+	floatRegs := make([]bool, len(i.FloatRegisters))
+	copy(regs, i.Registers)
+	copy(floatRegs, i.FloatRegisters)
+	return &AArch64_Allocator{
+		Registers:               regs,
+		RegistersAllocated:      i.RegistersAllocated,
+		FloatRegisters:          floatRegs,
+		FloatRegistersAllocated: i.FloatRegistersAllocated,
+		Frame:                   i.Frame,
+	}
+}
+
+// The part of the stack frame above the frame pointer that holds the values
+// that didn't fit in registers, followed by the callee-saved registers that
+// have to be preserved.
+type StackFrame struct {
+	Size  int
+	Saved []*encoding.Register
+	// The slot holding the address of the memory that the function that
+	// is being encoded returns its value in, if it does.
+	ReturnPointer lib.Operand
+	// The areas that hold the arrays and structs that are declared in the
+	// function (see GetArea).
+	Areas map[IRExpression]int
+}
+
+func NewStackFrame() *StackFrame {
+	return &StackFrame{
+		Saved: []*encoding.Register{},
+		Areas: map[IRExpression]int{},
+	}
+}
+
+// Reserves eight bytes and returns them as a memory operand.
+func (s *StackFrame) AllocateSlot() lib.Operand {
+	slot := &encoding.DisplacedRegister{Register: encoding.X29, Displacement: int32(s.Size)}
+	s.Size += 8
+	return slot
+}
+
+// Returns the offset of the area that holds the value of the expression,
+// reserving size bytes, rounded up to eightbytes, the first time.
+// Expressions can get encoded more than once, e.g. to determine their
+// length, and should end up in the same place.
+func (s *StackFrame) GetArea(e IRExpression, size int) int {
+	offset, found := s.Areas[e]
+	if !found {
+		offset = s.Size
+		s.Size += (size + 7) &^ 7
+		s.Areas[e] = offset
+	}
+	return offset
+}
+
+// Marks the callee-saved register as used, so that it gets preserved.
+func (s *StackFrame) Save(reg *encoding.Register) {
+	for _, r := range s.Saved {
+		if r == reg {
+			return
+		}
+	}
+	s.Saved = append(s.Saved, reg)
+}
+
+// Returns the slot in which the callee-saved register gets preserved.
+func (s *StackFrame) SaveSlot(ix int) lib.Operand {
+	return &encoding.DisplacedRegister{Register: encoding.X29, Displacement: int32(s.Size + ix*8)}
+}
+
+// The size of the frame, rounded up to keep the stack pointer 16 byte aligned.
+func (s *StackFrame) AlignedSize() int {
+	return (s.Size + len(s.Saved)*8 + 15) &^ 15
+}
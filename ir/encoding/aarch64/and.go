@@ -0,0 +1,13 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// The second operand is only evaluated if the first one is true (see
+// conditionalJump).
+func encode_IR_And(i *expr.IR_And, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	return encodeConditionValue(ctx, i, target)
+}
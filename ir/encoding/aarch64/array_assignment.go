@@ -0,0 +1,52 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_ArrayAssignment(i *statements.IR_ArrayAssignment, ctx *IR_Context) ([]lib.Instruction, error) {
+	returnType := i.Expr.ReturnType(ctx)
+	shift := shiftForItemWidth(returnType.Width())
+
+	array, found := ctx.VariableMap[i.Variable]
+	if !found {
+		return nil, fmt.Errorf("Unknown array '%s'", i.Variable)
+	}
+	index, result, err := encodeOperand(i.Index, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode array index in %s: %s", i.String(), err.Error())
+	}
+	defer releaseOperand(ctx, i.Index, index)
+	value, instr, err := encodeOperand(i.Expr, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode expr in %s: %s", i.String(), err.Error())
+	}
+	defer releaseOperand(ctx, i.Expr, value)
+	result = lib.Instructions(result).Add(instr)
+
+	indexReg, load1 := loadOperand(ctx, index, i.Index.ReturnType(ctx), 1)
+	base, load2 := arrayItems(ctx, ctx.VariableTypes[i.Variable], array, indexReg)
+	result = lib.Instructions(result).Add(load1).Add(load2)
+
+	var mem lib.Operand = &encoding.IndexedRegister{Register: base, Index: fullRegister(indexReg), Shift: shift}
+	if _, ok := value.(*encoding.Register); !ok && !IsFloat(returnType) {
+		// Both integer scratch registers might be in use, so the address
+		// is calculated first to free one up for the value.
+		instr := []lib.Instruction{
+			aarch64.LSL(fullRegister(indexReg), encoding.Uint8(shift), encoding.X17),
+			aarch64.ADD(base, encoding.X17, encoding.X16),
+		}
+		ctx.AddInstruction(instr...)
+		result = lib.Instructions(result).Add(instr)
+		mem = &encoding.DisplacedRegister{Register: encoding.X16, Displacement: 0}
+	}
+	reg, load := loadOperand(ctx, value, returnType, 1)
+	result = lib.Instructions(result).Add(load)
+	return lib.Instructions(result).Add(storeInMemory(ctx, reg, mem, returnType)), nil
+}
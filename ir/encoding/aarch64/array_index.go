@@ -0,0 +1,39 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_ArrayIndex(i *expr.IR_ArrayIndex, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	itemType := i.ReturnType(ctx)
+
+	array, result, err := encodeOperand(i.Array, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Array encoding issue: %s", err.Error())
+	}
+	defer releaseOperand(ctx, i.Array, array)
+	index, instr, err := encodeOperand(i.Index, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Array index encoding issue: %s", err.Error())
+	}
+	defer releaseOperand(ctx, i.Index, index)
+	result = lib.Instructions(result).Add(instr)
+
+	indexReg, load1 := loadOperand(ctx, index, i.Index.ReturnType(ctx), 1)
+	base, load2 := arrayItems(ctx, i.Array.ReturnType(ctx), array, indexReg)
+	result = lib.Instructions(result).Add(load1).Add(load2)
+
+	mem := &encoding.IndexedRegister{
+		Register: base,
+		Index:    fullRegister(indexReg),
+		Shift:    shiftForItemWidth(itemType.Width()),
+	}
+	dest := targetRegister(target, itemType, 0)
+	result = lib.Instructions(result).Add(loadFromMemory(ctx, mem, itemType, dest))
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
@@ -0,0 +1,38 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Flips the bits by xor-ing them with a register that has all of its bits
+// set, because that can't be encoded as a bitmask immediate.
+func encode_IR_BitwiseNot(i *expr.IR_BitwiseNot, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	returnType := i.Op1.ReturnType(ctx)
+	if !IsInteger(returnType) {
+		return nil, fmt.Errorf("Unsupported type %s in IR operation: %s", returnType, i.String())
+	}
+	loc, result, err := encodeOperand(i.Op1, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, i.Op1, loc)
+	reg, load := loadOperand(ctx, loc, returnType, 0)
+	result = lib.Instructions(result).Add(load)
+
+	ones := scratchRegister(returnType, 1)
+	dest := targetRegister(target, returnType, 0)
+	instr := []lib.Instruction{
+		aarch64.MOVN(encoding.Uint64(0), encoding.Shift(0), ones),
+		aarch64.EOR(reg, ones, dest),
+	}
+	ctx.AddInstruction(instr...)
+	result = append(result, instr...)
+	result = lib.Instructions(result).Add(normalize(ctx, dest, returnType))
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
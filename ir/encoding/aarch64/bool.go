@@ -0,0 +1,15 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Bool(i *expr.IR_Bool, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	value := 0
+	if i.Value {
+		value = 1
+	}
+	return encodeImmediate(ctx, uint64(value), TBool, target), nil
+}
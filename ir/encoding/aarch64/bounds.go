@@ -0,0 +1,65 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Traps with a breakpoint unless index < length, or index <= length if
+// inclusive is set. The values are compared as unsigned integers, so
+// negative indices are out of bounds as well. Nothing is checked when the
+// checks have been turned off (see IR_Context.NoBoundsChecks).
+func checkBounds(ctx *IR_Context, index *encoding.Register, length lib.Operand, inclusive bool) []lib.Instruction {
+	if ctx.NoBoundsChecks {
+		return nil
+	}
+	ok := ctx.NewLabel("in_bounds")
+	cond := encoding.CC_or_LO
+	if inclusive {
+		cond = encoding.LS
+	}
+	return addInstructions(ctx, nil,
+		aarch64.CMP(fullRegister(index), length),
+		aarch64.B_cond(cond, ok),
+		aarch64.BRK(encoding.Uint64(1)),
+		lib.DefineLabel(ok),
+	)
+}
+
+// Checks the index against the length of a slice or the size of an array
+// and returns the register with the address of its first item. The items of
+// slices are loaded into the scratch register x16, which is also used for
+// the checks, so the index shouldn't be in there. Arrays without a size
+// aren't checked.
+func arrayItems(ctx *IR_Context, typ Type, array lib.Operand, index *encoding.Register) (*encoding.Register, []lib.Instruction) {
+	result := []lib.Instruction{}
+	switch ty := typ.(type) {
+	case *TSlice:
+		if !ctx.NoBoundsChecks {
+			header, load := loadOperand(ctx, array, TUint64, 0)
+			result = lib.Instructions(result).Add(load)
+			length := &encoding.DisplacedRegister{Register: header, Displacement: SliceLengthOffset}
+			result = addInstructions(ctx, result, aarch64.LDR(length, encoding.X16))
+			result = lib.Instructions(result).Add(checkBounds(ctx, index, encoding.X16, false))
+		}
+	case *TArray:
+		if ty.Size > 4095 {
+			if !ctx.NoBoundsChecks {
+				result = lib.Instructions(result).Add(loadImmediate(ctx, uint64(ty.Size), encoding.X16))
+				result = lib.Instructions(result).Add(checkBounds(ctx, index, encoding.X16, false))
+			}
+		} else if ty.Size > 0 {
+			result = lib.Instructions(result).Add(checkBounds(ctx, index, encoding.Uint64(ty.Size), false))
+		}
+	}
+	base, load := loadOperand(ctx, array, TUint64, 0)
+	result = lib.Instructions(result).Add(load)
+	if IsSlice(typ) {
+		items := &encoding.DisplacedRegister{Register: base, Displacement: SliceItemsOffset}
+		result = addInstructions(ctx, result, aarch64.LDR(items, encoding.X16))
+		base = encoding.X16
+	}
+	return base, result
+}
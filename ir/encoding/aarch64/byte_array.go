@@ -0,0 +1,22 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_ByteArray(i *expr.IR_ByteArray, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	return encodeDataAddress(ctx, i.Address, target), nil
+}
+
+// Loads the address of the data into target using an ADR instruction, which
+// is relative to the instruction itself. The displacement gets filled in
+// when the instructions are resolved.
+func encodeDataAddress(ctx *IR_Context, pointer *SegmentPointer, target lib.Operand) []lib.Instruction {
+	reg := targetRegister(target, TUint64, 0)
+	adr := aarch64.ADR(dataAddress(ctx, pointer), reg)
+	ctx.AddInstruction(adr)
+	return append([]lib.Instruction{adr}, storeTarget(ctx, reg, target)...)
+}
@@ -0,0 +1,126 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Call(i *expr.IR_Call, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	signature, err := i.CheckCall(ctx)
+	if err != nil {
+		return nil, err
+	}
+	function, ok := ctx.VariableMap[i.Function]
+	if !ok || function == nil {
+		return nil, fmt.Errorf("Unknown function: %s", i.Function)
+	}
+
+	saved := ctx.Allocator.(*AArch64_Allocator).CallerSavedInUse()
+	result := lib.Instructions(saveRegisters(ctx, saved))
+
+	targets, err := argumentRegisters(ctx.ABI, signature.Args, signature.ReturnType)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s", err.Error(), i.String())
+	}
+	setup, err := encodeArguments(ctx, i.Args, targets)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s", err.Error(), i.String())
+	}
+	result = result.Add(setup)
+
+	if label, ok := function.(*lib.Label); ok && label.Address >= 0 {
+		// Functions in the executable segment, which starts at address 0,
+		// are called directly (see encode_IR_Function_for_DataSection)
+		result = addInstructions(ctx, result, aarch64.ADR(label, encoding.X16))
+	} else if label, ok := function.(*lib.Label); ok {
+		// External functions are called through the slot holding their address
+		result = addInstructions(ctx, result,
+			aarch64.ADR(label, encoding.X16),
+			aarch64.LDR(&encoding.DisplacedRegister{Register: encoding.X16}, encoding.X16))
+	} else {
+		result = result.Add(move(ctx, function, encoding.X16, TUint64))
+	}
+	// Slice headers are returned in an area in this stack frame
+	returnType := signature.ReturnType
+	offset := 0
+	if IsSlice(returnType) {
+		offset = ctx.Allocator.(*AArch64_Allocator).Frame.GetArea(i, SliceHeaderSize)
+		result = addInstructions(ctx, result, aarch64.ADD(encoding.X29, encoding.Uint64(offset), encoding.X8))
+	}
+	call := aarch64.BLR(encoding.X16)
+	ctx.AddInstruction(call)
+	result = append(result, call)
+
+	result = result.Add(restoreRegisters(ctx, saved))
+	if IsSlice(returnType) {
+		reg := targetRegister(target, TUint64, 0)
+		result = addInstructions(ctx, result, aarch64.ADD(encoding.X29, encoding.Uint64(offset), reg))
+		return result.Add(storeTarget(ctx, reg, target)), nil
+	}
+	return result.Add(move(ctx, ReturnRegister(returnType), target, returnType)), nil
+}
+
+// Evaluates the arguments and moves them into the argument registers.
+func encodeArguments(ctx *IR_Context, args []IRExpression, targets []*encoding.Register) ([]lib.Instruction, error) {
+	result := lib.Instructions{}
+	values := []lib.Operand{}
+	for _, arg := range args {
+		value, instr, err := encodeOperand(arg, ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer releaseOperand(ctx, arg, value)
+		values = append(values, value)
+		result = result.Add(instr)
+	}
+	for j, arg := range args {
+		result = result.Add(move(ctx, values[j], targets[j], arg.ReturnType(ctx)))
+	}
+	return result, nil
+}
+
+// Returns the register of each argument. Arguments that are passed on the
+// stack, and structs, aren't supported yet.
+func argumentRegisters(abi ABI, args []Type, returnType Type) ([]*encoding.Register, error) {
+	layout := abi.ClassifyCall(args, returnType)
+	result := []*encoding.Register{}
+	for j, location := range layout.Args {
+		if args[j].Type() == T_Struct {
+			return nil, fmt.Errorf("Struct arguments are not supported")
+		}
+		if location.InMemory() {
+			return nil, fmt.Errorf("Too many arguments")
+		}
+		result = append(result, location.Registers[0].(*encoding.Register))
+	}
+	if returnType.Type() == T_Struct {
+		return nil, fmt.Errorf("Struct return values are not supported")
+	}
+	return result, nil
+}
+
+// Pushes the registers on the stack, using sixteen bytes per register to
+// keep the stack pointer aligned.
+func saveRegisters(ctx *IR_Context, registers []*encoding.Register) []lib.Instruction {
+	result := []lib.Instruction{}
+	for _, reg := range registers {
+		result = append(result, aarch64.STR(reg, &encoding.PreIndexedRegister{Register: encoding.SP, Displacement: -16}))
+	}
+	ctx.AddInstruction(result...)
+	return result
+}
+
+// Pops the registers pushed by saveRegisters.
+func restoreRegisters(ctx *IR_Context, registers []*encoding.Register) []lib.Instruction {
+	result := []lib.Instruction{}
+	for j := len(registers) - 1; j >= 0; j-- {
+		result = append(result, aarch64.LDR(&encoding.PostIndexedRegister{Register: encoding.SP, Displacement: 16}, registers[j]))
+	}
+	ctx.AddInstruction(result...)
+	return result
+}
@@ -0,0 +1,63 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Cast(i *expr.IR_Cast, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	valueType := i.Value.ReturnType(ctx)
+	if valueType == nil {
+		return nil, fmt.Errorf("nil return type in %s", i.Value.String())
+	}
+	if valueType == i.CastToType {
+		return encodeExpression(i.Value, ctx, target)
+	}
+	// Pointers and uint64s only differ in their type
+	if (IsPointer(i.CastToType) || i.CastToType == TUint64) && (IsPointer(valueType) || valueType == TUint64) {
+		return encodeExpression(i.Value, ctx, target)
+	}
+	isUnsigned := valueType == TUint64 || valueType == TUint32 || valueType == TUint16 || valueType == TUint8
+
+	var convert func(src, dest lib.Operand) lib.Instruction
+	if i.CastToType == TUint64 {
+		if valueType == TUint32 || valueType == TUint16 || valueType == TUint8 {
+			// Narrower unsigned integers are already zero extended
+			return encodeExpression(i.Value, ctx, target)
+		} else if valueType == TFloat64 {
+			convert = aarch64.FCVTZS
+		}
+	} else if i.CastToType == TUint8 && isUnsigned {
+		convert = aarch64.UXTB
+	} else if i.CastToType == TUint16 && isUnsigned {
+		convert = aarch64.UXTH
+	} else if i.CastToType == TUint32 && isUnsigned {
+		convert = aarch64.MOV
+	} else if i.CastToType == TFloat64 && valueType == TUint64 {
+		convert = aarch64.SCVTF
+	}
+	if convert == nil {
+		return nil, fmt.Errorf("Unsupported cast operation %s -> (%s) in: %s", valueType.String(), i.CastToType.String(), i.String())
+	}
+
+	value, result, err := encodeOperand(i.Value, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, i.Value, value)
+	src, load := loadOperand(ctx, value, valueType, 0)
+	result = lib.Instructions(result).Add(load)
+	dest := targetRegister(target, i.CastToType, 1)
+	if !src.Float && !dest.Float {
+		// Truncating only looks at the lower 32 bits
+		src = src.ForOperandWidth(dest.Width())
+	}
+	instr := convert(src, dest)
+	ctx.AddInstruction(instr)
+	result = append(result, instr)
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
@@ -0,0 +1,81 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Evaluates both operands and sets the condition flags for op1 - op2.
+func compare(op1, op2 IRExpression, ctx *IR_Context) ([]lib.Instruction, error) {
+	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
+	if returnType1 != returnType2 {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in compare operation", returnType1, returnType2)
+	}
+	loc1, result, err := encodeOperand(op1, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, op1, loc1)
+	loc2, expr, err := encodeOperand(op2, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, op2, loc2)
+	result = lib.Instructions(result).Add(expr)
+
+	reg1, load1 := loadOperand(ctx, loc1, returnType1, 0)
+	reg2, load2 := loadOperand(ctx, loc2, returnType2, 1)
+	result = lib.Instructions(result).Add(load1).Add(load2)
+	cmp := aarch64.CMP(reg1, reg2)
+	if IsFloat(returnType1) {
+		cmp = aarch64.FCMP(reg1, reg2)
+	}
+	ctx.AddInstruction(cmp)
+	return append(result, cmp), nil
+}
+
+// Returns the operands of a comparison and the condition under which it
+// holds. The float conditions are false when either operand is NaN.
+func comparison(e IRExpression, ctx *IR_Context) (IRExpression, IRExpression, encoding.Condition, bool) {
+	switch c := e.(type) {
+	case *expr.IR_Equals:
+		return c.Op1, c.Op2, encoding.EQ, true
+	case *expr.IR_LT:
+		return c.Op1, c.Op2, orderCondition(c.Op1.ReturnType(ctx), encoding.MI, encoding.LT, encoding.CC_or_LO), true
+	case *expr.IR_LTE:
+		return c.Op1, c.Op2, orderCondition(c.Op1.ReturnType(ctx), encoding.LS, encoding.LE, encoding.LS), true
+	case *expr.IR_GT:
+		return c.Op1, c.Op2, orderCondition(c.Op1.ReturnType(ctx), encoding.GT, encoding.GT, encoding.HI), true
+	case *expr.IR_GTE:
+		return c.Op1, c.Op2, orderCondition(c.Op1.ReturnType(ctx), encoding.GE, encoding.GE, encoding.CS_or_HS), true
+	}
+	return nil, nil, encoding.AL, false
+}
+
+func orderCondition(typ Type, float, signed, unsigned encoding.Condition) encoding.Condition {
+	if IsFloat(typ) {
+		return float
+	} else if IsSignedInteger(typ) {
+		return signed
+	}
+	return unsigned
+}
+
+// Encodes the comparison into target as a bool.
+func encodeComparison(e IRExpression, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	op1, op2, cond, _ := comparison(e, ctx)
+	result, err := compare(op1, op2, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s", err.Error(), e.String())
+	}
+	dest := targetRegister(target, TBool, 0)
+	cset := aarch64.CSET(cond, dest)
+	ctx.AddInstruction(cset)
+	result = append(result, cset)
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
@@ -0,0 +1,102 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Jumps to the label if the condition is false. The operands of && and ||
+// are branched on one by one, so that the second operand is only evaluated
+// when it decides the outcome.
+func conditionalJump(ctx *IR_Context, condition IRExpression, label *lib.Label) ([]lib.Instruction, error) {
+	switch c := condition.(type) {
+	case *expr.IR_And:
+		if err := checkLogicalOperands(ctx, c.Op1, c.Op2, c.String()); err != nil {
+			return nil, err
+		}
+		result, err := conditionalJump(ctx, c.Op1, label)
+		if err != nil {
+			return nil, err
+		}
+		instr, err := conditionalJump(ctx, c.Op2, label)
+		if err != nil {
+			return nil, err
+		}
+		return lib.Instructions(result).Add(instr), nil
+	case *expr.IR_Or:
+		if err := checkLogicalOperands(ctx, c.Op1, c.Op2, c.String()); err != nil {
+			return nil, err
+		}
+		second := ctx.NewLabel("or")
+		end := ctx.NewLabel("end_or")
+		result, err := conditionalJump(ctx, c.Op1, second)
+		if err != nil {
+			return nil, err
+		}
+		result = addInstructions(ctx, result, aarch64.B(end), lib.DefineLabel(second))
+		instr, err := conditionalJump(ctx, c.Op2, label)
+		if err != nil {
+			return nil, err
+		}
+		return addInstructions(ctx, lib.Instructions(result).Add(instr), lib.DefineLabel(end)), nil
+	}
+
+	var result []lib.Instruction
+	var err error
+	var jump lib.Instruction
+
+	if op1, op2, cond, ok := comparison(condition, ctx); ok {
+		result, err = compare(op1, op2, ctx)
+		jump = aarch64.B_cond(cond.Invert(), label)
+	} else if not, ok := condition.(*expr.IR_Not); ok && isComparison(not.Op1, ctx) {
+		op1, op2, cond, _ := comparison(not.Op1, ctx)
+		result, err = compare(op1, op2, ctx)
+		jump = aarch64.B_cond(cond, label)
+	} else {
+		var value lib.Operand
+		value, result, err = encodeOperand(condition, ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer releaseOperand(ctx, condition, value)
+		reg, load := loadOperand(ctx, value, TBool, 0)
+		result = lib.Instructions(result).Add(load)
+		jump = aarch64.CBZ(reg, label)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ctx.AddInstruction(jump)
+	return append(result, jump), nil
+}
+
+// Evaluates the condition into target as a bool, by branching on it.
+func encodeConditionValue(ctx *IR_Context, condition IRExpression, target lib.Operand) ([]lib.Instruction, error) {
+	falseLabel := ctx.NewLabel("false")
+	end := ctx.NewLabel("end_condition")
+	result, err := conditionalJump(ctx, condition, falseLabel)
+	if err != nil {
+		return nil, err
+	}
+	result = lib.Instructions(result).Add(encodeImmediate(ctx, 1, TBool, target))
+	result = addInstructions(ctx, result, aarch64.B(end), lib.DefineLabel(falseLabel))
+	result = lib.Instructions(result).Add(encodeImmediate(ctx, 0, TBool, target))
+	return addInstructions(ctx, result, lib.DefineLabel(end)), nil
+}
+
+func checkLogicalOperands(ctx *IR_Context, op1, op2 IRExpression, repr string) error {
+	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
+	if returnType1 != TBool || returnType2 != TBool {
+		return fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
+	}
+	return nil
+}
+
+func isComparison(e IRExpression, ctx *IR_Context) bool {
+	_, _, _, ok := comparison(e, ctx)
+	return ok
+}
@@ -0,0 +1,28 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Dereference(i *expr.IR_Dereference, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	typ := i.ReturnType(ctx)
+	if IsInline(typ) {
+		// Structs and arrays evaluate to their address
+		return encodeExpression(i.Pointer, ctx, target)
+	}
+	pointer, result, err := encodeOperand(i.Pointer, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, i.Pointer, pointer)
+	base, load := loadOperand(ctx, pointer, TUint64, 0)
+	result = lib.Instructions(result).Add(load)
+
+	dest := targetRegister(target, typ, 0)
+	mem := &encoding.DisplacedRegister{Register: base, Displacement: 0}
+	result = lib.Instructions(result).Add(loadFromMemory(ctx, mem, typ, dest))
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
@@ -0,0 +1,16 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Div(i *expr.IR_Div, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	operator := numberOperator(i.Op1.ReturnType(ctx), aarch64.UDIV, aarch64.FDIV)
+	if IsSignedInteger(i.Op1.ReturnType(ctx)) {
+		operator = aarch64.SDIV
+	}
+	return encode_Operator(i.Op1, i.Op2, operator, i.String(), ctx, target)
+}
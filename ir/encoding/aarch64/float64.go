@@ -0,0 +1,33 @@
+package aarch64
+
+import (
+	"math"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Loads the bits of the float into a general purpose register, from the
+// read-only data if the float has been added to it, and moves them over with
+// FMOV.
+func encode_IR_Float64(i *expr.IR_Float64, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	tmp := scratchRegister(TUint64, 0)
+	var result []lib.Instruction
+	if i.Address != nil {
+		result = encodeDataAddress(ctx, i.Address, tmp)
+		ldr := aarch64.LDR(&encoding.DisplacedRegister{Register: tmp}, tmp)
+		ctx.AddInstruction(ldr)
+		result = append(result, ldr)
+	} else {
+		result = loadImmediate(ctx, math.Float64bits(i.Value), tmp)
+	}
+	if _, ok := target.(*encoding.Register); !ok {
+		return lib.Instructions(result).Add(storeTarget(ctx, tmp, target)), nil
+	}
+	fmov := aarch64.FMOV(tmp, target)
+	ctx.AddInstruction(fmov)
+	return append(result, fmov), nil
+}
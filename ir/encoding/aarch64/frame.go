@@ -0,0 +1,116 @@
+package aarch64
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Returns the instructions that set up the stack frame:
+//
+//	stp x29, x30, [sp, #-16]!
+//	sub sp, sp, #frame
+//	mov x29, sp
+//	str <callee-saved>, [x29, #offset]
+//
+// The size of the frame and the registers that have to be preserved are
+// only known after all the statements have been encoded, so the prologue
+// should be encoded last.
+func encodePrologue(ctx *IR_Context) []lib.Instruction {
+	allocator := ctx.Allocator.(*AArch64_Allocator)
+	result := []lib.Instruction{
+		aarch64.STP(encoding.X29, encoding.X30, &encoding.PreIndexedRegister{Register: encoding.SP, Displacement: -16}),
+		&frameAllocation{allocator.Frame, false},
+		aarch64.MOV(encoding.SP, encoding.X29),
+		&calleeSavedRegisters{allocator.Frame, false},
+	}
+	ctx.AddInstruction(result...)
+	return result
+}
+
+// Undoes the prologue, leaving the stack pointer where it was on entry.
+func encodeEpilogue(ctx *IR_Context) []lib.Instruction {
+	allocator := ctx.Allocator.(*AArch64_Allocator)
+	result := []lib.Instruction{
+		&calleeSavedRegisters{allocator.Frame, true},
+		&frameAllocation{allocator.Frame, true},
+		aarch64.LDP(&encoding.PostIndexedRegister{Register: encoding.SP, Displacement: 16}, encoding.X29, encoding.X30),
+	}
+	ctx.AddInstruction(result...)
+	return result
+}
+
+// Reserves the space for the stack frame, or releases it relative to the
+// frame pointer.
+type frameAllocation struct {
+	Frame   *StackFrame
+	Release bool
+}
+
+func (f *frameAllocation) instruction() (lib.Instruction, error) {
+	size := f.Frame.AlignedSize()
+	if size > 4095 {
+		return nil, fmt.Errorf("Stack frame of %d bytes is too large", size)
+	}
+	if f.Release {
+		return aarch64.ADD(encoding.X29, encoding.Uint64(size), encoding.SP), nil
+	}
+	return aarch64.SUB(encoding.SP, encoding.Uint64(size), encoding.SP), nil
+}
+
+func (f *frameAllocation) Encode() (lib.MachineCode, error) {
+	instr, err := f.instruction()
+	if err != nil {
+		return nil, err
+	}
+	return instr.Encode()
+}
+
+func (f *frameAllocation) String() string {
+	instr, err := f.instruction()
+	if err != nil {
+		return err.Error()
+	}
+	return instr.String()
+}
+
+// Stores or restores the callee-saved registers that have been allocated.
+type calleeSavedRegisters struct {
+	Frame   *StackFrame
+	Restore bool
+}
+
+func (c *calleeSavedRegisters) instructions() []lib.Instruction {
+	result := []lib.Instruction{}
+	for i, reg := range c.Frame.Saved {
+		if c.Restore {
+			result = append(result, aarch64.LDR(c.Frame.SaveSlot(i), reg))
+		} else {
+			result = append(result, aarch64.STR(reg, c.Frame.SaveSlot(i)))
+		}
+	}
+	return result
+}
+
+func (c *calleeSavedRegisters) Encode() (lib.MachineCode, error) {
+	return lib.Instructions(c.instructions()).Encode()
+}
+
+func (c *calleeSavedRegisters) String() string {
+	instr := c.instructions()
+	if len(instr) == 0 {
+		if c.Restore {
+			return "; no callee-saved registers to restore"
+		}
+		return "; no callee-saved registers to preserve"
+	}
+	lines := []string{}
+	for _, i := range instr {
+		lines = append(lines, i.String())
+	}
+	return strings.Join(lines, "\n")
+}
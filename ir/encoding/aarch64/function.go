@@ -0,0 +1,81 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Function(i *expr.IR_Function, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	// Load the address of the function into target (see encode_IR_ByteArray)
+	return encodeDataAddress(ctx, i.Address, target), nil
+}
+
+// Encodes the function, which can call the functions and extern functions
+// that are declared before it, and itself.
+func encode_IR_Function_for_DataSection(b *expr.IR_Function, ctx *IR_Context, segments *Segments, declarations []*declaration) error {
+	if b.Address == nil {
+		b.Address = &SegmentPointer{SegmentType: Executable}
+	}
+	b.Address.Offset = uint(len(segments.Segments[Executable].Data))
+
+	sources, err := argumentRegisters(ctx.ABI, b.Signature.Args, b.Signature.ReturnType)
+	if err != nil {
+		return err
+	}
+	ctx_ := ctx.Copy()
+	ctx_.Commit = false
+	ctx_.Allocator = NewAArch64_Allocator()
+	ctx_.Segments = segments
+	ctx_.VariableMap = map[string]lib.Operand{}
+	ctx_.VariableTypes = map[string]Type{}
+	assigned := statements.AssignedVariables(b.Body)
+	for _, d := range declarations {
+		if assigned[d.Name] {
+			// The variable hides the function
+			continue
+		}
+		ctx_.VariableTypes[d.Name] = d.Signature
+		ctx_.VariableMap[d.Name] = dataAddress(ctx_, d.Address)
+	}
+
+	// The arguments are moved out of x0-x7 and d0-d7, so that they don't get
+	// clobbered when the function makes calls itself.
+	instructions := lib.Instructions(encodePrologue(ctx_))
+	if IsSlice(b.Signature.ReturnType) {
+		// x8 gets clobbered by the calls that the function makes
+		frame := ctx_.Allocator.(*AArch64_Allocator).Frame
+		frame.ReturnPointer = frame.AllocateSlot()
+		instructions = addInstructions(ctx_, instructions, aarch64.STR(encoding.X8, frame.ReturnPointer))
+	}
+	for i, arg := range b.Signature.Args {
+		v := b.Signature.ArgNames[i]
+		reg := ctx_.AllocateRegister(arg)
+		ctx_.VariableMap[v] = reg
+		ctx_.VariableTypes[v] = arg
+		instructions = instructions.Add(move(ctx_, sources[i].ForOperandWidth(arg.Width()), reg, arg))
+	}
+	instr, err := encodeStatement(b.Body, ctx_)
+	if err != nil {
+		return err
+	}
+	instructions = instructions.Add(instr)
+	address := segments.GetAddress(b.Address)
+	if err := instructions.Resolve(address); err != nil {
+		return err
+	}
+	relocations, err := instructions.Relocations(address)
+	if err != nil {
+		return err
+	}
+	segments.Relocations = append(segments.Relocations, relocations...)
+	bytes, err := instructions.Encode()
+	if err != nil {
+		return err
+	}
+	b.Address.Size = segments.Add(Executable, bytes...).Size
+	return nil
+}
@@ -0,0 +1,18 @@
+package aarch64
+
+import (
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Allocates a new register and assigns it the address of the function.
+func encode_IR_FunctionDef(i *statements.IR_FunctionDef, ctx *IR_Context) ([]lib.Instruction, error) {
+	reg, found := ctx.VariableMap[i.Name]
+	if !found {
+		reg = ctx.AllocateRegister(TUint64)
+		ctx.VariableMap[i.Name] = reg
+	}
+	ctx.VariableTypes[i.Name] = i.Expr.ReturnType(ctx)
+	return encodeExpression(i.Expr, ctx, reg)
+}
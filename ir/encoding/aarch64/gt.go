@@ -0,0 +1,11 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_GT(i *expr.IR_GT, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	return encodeComparison(i, ctx, target)
+}
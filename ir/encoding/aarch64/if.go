@@ -0,0 +1,44 @@
+package aarch64
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_If(i *statements.IR_If, ctx *IR_Context) ([]lib.Instruction, error) {
+	if i.Condition.ReturnType(ctx) != TBool {
+		return nil, errors.New("Unsupported if IR condition")
+	}
+	elseLabel := ctx.NewLabel("else")
+	endLabel := ctx.NewLabel("end_if")
+
+	result, err := conditionalJump(ctx, i.Condition, elseLabel)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s", err.Error(), i.String())
+	}
+	s1, err := encodeStatement(i.Stmt1, ctx)
+	if err != nil {
+		return nil, err
+	}
+	result = lib.Instructions(result).Add(s1)
+	instr := []lib.Instruction{
+		aarch64.B(endLabel),
+		lib.DefineLabel(elseLabel),
+	}
+	ctx.AddInstruction(instr...)
+	result = append(result, instr...)
+
+	s2, err := encodeStatement(i.Stmt2, ctx)
+	if err != nil {
+		return nil, err
+	}
+	result = lib.Instructions(result).Add(s2)
+	definition := lib.DefineLabel(endLabel)
+	ctx.AddInstruction(definition)
+	return append(result, definition), nil
+}
@@ -0,0 +1,11 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Int8(i *expr.IR_Int8, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	return encodeImmediate(ctx, uint64(i.Value), TInt8, target), nil
+}
@@ -0,0 +1,32 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Len(i *expr.IR_Len, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	switch ty := i.Value.ReturnType(ctx).(type) {
+	case *TArray:
+		if ty.Size > 0 {
+			return encodeImmediate(ctx, uint64(ty.Size), TUint64, target), nil
+		}
+	case *TSlice:
+		header, result, err := encodeOperand(i.Value, ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer releaseOperand(ctx, i.Value, header)
+		base, load := loadOperand(ctx, header, TUint64, 0)
+		result = lib.Instructions(result).Add(load)
+		dest := targetRegister(target, TUint64, 0)
+		mem := &encoding.DisplacedRegister{Register: base, Displacement: SliceLengthOffset}
+		result = lib.Instructions(result).Add(loadFromMemory(ctx, mem, TUint64, dest))
+		return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+	}
+	return nil, fmt.Errorf("Can't take the length of %s", i.Value.String())
+}
@@ -0,0 +1,52 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Returns the shift that scales an index to the width of the items.
+func shiftForItemWidth(width lib.Size) uint8 {
+	switch width {
+	case lib.BYTE:
+		return 0
+	case lib.WORD:
+		return 1
+	case lib.DOUBLE:
+		return 2
+	}
+	return 3
+}
+
+// Loads a value of the given type from memory into dest.
+func loadFromMemory(ctx *IR_Context, mem lib.Operand, typ Type, dest *encoding.Register) []lib.Instruction {
+	var instr lib.Instruction
+	switch typ.Width() {
+	case lib.BYTE:
+		instr = aarch64.LDRB(mem, dest)
+	case lib.WORD:
+		instr = aarch64.LDRH(mem, dest)
+	default:
+		instr = aarch64.LDR(mem, dest)
+	}
+	ctx.AddInstruction(instr)
+	result := []lib.Instruction{instr}
+	return lib.Instructions(result).Add(normalize(ctx, dest, typ))
+}
+
+// Stores a value of the given type from src into memory.
+func storeInMemory(ctx *IR_Context, src *encoding.Register, mem lib.Operand, typ Type) []lib.Instruction {
+	var instr lib.Instruction
+	switch typ.Width() {
+	case lib.BYTE:
+		instr = aarch64.STRB(src, mem)
+	case lib.WORD:
+		instr = aarch64.STRH(src, mem)
+	default:
+		instr = aarch64.STR(src, mem)
+	}
+	ctx.AddInstruction(instr)
+	return []lib.Instruction{instr}
+}
@@ -0,0 +1,68 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// There's no remainder instruction, so it's computed as op1 - (op1 / op2) * op2.
+func encode_IR_Mod(i *expr.IR_Mod, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	returnType1, returnType2 := i.Op1.ReturnType(ctx), i.Op2.ReturnType(ctx)
+	if returnType1 != returnType2 || !IsInteger(returnType1) {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, i.String())
+	}
+	div := aarch64.UDIV
+	if IsSignedInteger(returnType1) {
+		div = aarch64.SDIV
+	}
+	loc1, result, err := encodeOperand(i.Op1, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, i.Op1, loc1)
+	loc2, expr, err := encodeOperand(i.Op2, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, i.Op2, loc2)
+	result = lib.Instructions(result).Add(expr)
+
+	reg1, load1 := loadOperand(ctx, loc1, returnType1, 0)
+	reg2, load2 := loadOperand(ctx, loc2, returnType1, 1)
+	result = lib.Instructions(result).Add(load1).Add(load2)
+
+	dest := targetRegister(target, returnType1, 0)
+	// The quotient needs a register that doesn't hold either operand.
+	var quotient *encoding.Register
+	for _, reg := range []*encoding.Register{dest, scratchRegister(returnType1, 0), scratchRegister(returnType1, 1)} {
+		if reg != reg1 && reg != reg2 {
+			quotient = reg
+			break
+		}
+	}
+	var instr []lib.Instruction
+	if quotient != nil {
+		instr = []lib.Instruction{
+			div(reg1, reg2, quotient),
+			aarch64.MSUB(quotient, reg2, reg1, dest),
+		}
+	} else {
+		// Both operands have been loaded into the scratch registers, so
+		// the first one can be loaded again after it's been overwritten.
+		instr = []lib.Instruction{
+			div(reg1, reg2, reg1),
+			aarch64.MUL(reg1, reg2, reg1),
+			aarch64.LDR(loc1, fullRegister(reg2)),
+			aarch64.SUB(reg2, reg1, dest),
+		}
+	}
+	ctx.AddInstruction(instr...)
+	result = append(result, instr...)
+	result = lib.Instructions(result).Add(normalize(ctx, dest, returnType1))
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
@@ -0,0 +1,13 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Mul(i *expr.IR_Mul, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	operator := numberOperator(i.Op1.ReturnType(ctx), aarch64.MUL, aarch64.FMUL)
+	return encode_Operator(i.Op1, i.Op2, operator, i.String(), ctx, target)
+}
@@ -0,0 +1,39 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Not(i *expr.IR_Not, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	var result []lib.Instruction
+	var err error
+	cond := encoding.EQ
+	if op1, op2, c, ok := comparison(i.Op1, ctx); ok {
+		// Negate the comparison instead of its result
+		result, err = compare(op1, op2, ctx)
+		cond = c.Invert()
+	} else {
+		var value lib.Operand
+		value, result, err = encodeOperand(i.Op1, ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer releaseOperand(ctx, i.Op1, value)
+		reg, load := loadOperand(ctx, value, TBool, 0)
+		cmp := aarch64.CMP(reg, encoding.Uint64(0))
+		ctx.AddInstruction(cmp)
+		result = append(lib.Instructions(result).Add(load), cmp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dest := targetRegister(target, TBool, 0)
+	cset := aarch64.CSET(cond, dest)
+	ctx.AddInstruction(cset)
+	result = append(result, cset)
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
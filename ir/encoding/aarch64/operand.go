@@ -0,0 +1,182 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Values live either in a register or in an eight byte slot in the stack
+// frame (see AArch64_Allocator). The functions below move values between the
+// two using the scratch registers x16-x17 and d30-d31, which the allocator
+// never hands out.
+//
+// The scratch registers are only valid within the encoding of a single
+// expression and should only be used after its subexpressions have been
+// encoded, because those are free to use them as well.
+
+var (
+	scratchRegisters      = []*encoding.Register{encoding.X16, encoding.X17}
+	floatScratchRegisters = []*encoding.Register{encoding.D30, encoding.D31}
+)
+
+func scratchRegister(typ Type, ix int) *encoding.Register {
+	if IsFloat(typ) {
+		return floatScratchRegisters[ix]
+	}
+	return scratchRegisters[ix].ForOperandWidth(typ.Width())
+}
+
+// Returns the register that holds all the bits of reg, which is the X
+// register for W registers.
+func fullRegister(reg *encoding.Register) *encoding.Register {
+	if reg.Float {
+		return reg
+	}
+	return reg.Get64BitRegister()
+}
+
+// Returns a register that holds the value of op, which is either op itself
+// or scratch register ix into which op gets loaded.
+func loadOperand(ctx *IR_Context, op lib.Operand, typ Type, ix int) (*encoding.Register, []lib.Instruction) {
+	if reg, ok := op.(*encoding.Register); ok {
+		return reg.ForOperandWidth(typ.Width()), nil
+	}
+	reg := scratchRegister(typ, ix)
+	instr := aarch64.LDR(op, fullRegister(reg))
+	ctx.AddInstruction(instr)
+	return reg, []lib.Instruction{instr}
+}
+
+// Returns the register to compute a value for target into, which is target
+// itself or scratch register ix. In the latter case the value should be
+// stored with storeTarget after it has been computed.
+func targetRegister(target lib.Operand, typ Type, ix int) *encoding.Register {
+	if reg, ok := target.(*encoding.Register); ok {
+		return reg.ForOperandWidth(typ.Width())
+	}
+	return scratchRegister(typ, ix)
+}
+
+// Stores reg in target if target isn't a register (see targetRegister).
+func storeTarget(ctx *IR_Context, reg *encoding.Register, target lib.Operand) []lib.Instruction {
+	if _, ok := target.(*encoding.Register); ok {
+		return nil
+	}
+	instr := aarch64.STR(fullRegister(reg), target)
+	ctx.AddInstruction(instr)
+	return []lib.Instruction{instr}
+}
+
+// Copies a value of the given type from src to target.
+func move(ctx *IR_Context, src, target lib.Operand, typ Type) []lib.Instruction {
+	if src == target {
+		return nil
+	}
+	result, reg := []lib.Instruction{}, (*encoding.Register)(nil)
+	if r, ok := src.(*encoding.Register); ok {
+		reg = r.ForOperandWidth(typ.Width())
+	} else if r, ok := target.(*encoding.Register); ok {
+		instr := aarch64.LDR(src, fullRegister(r))
+		ctx.AddInstruction(instr)
+		return []lib.Instruction{instr}
+	} else {
+		reg, result = loadOperand(ctx, src, typ, 0)
+	}
+	if r, ok := target.(*encoding.Register); ok {
+		r = r.ForOperandWidth(typ.Width())
+		if r == reg {
+			return result
+		}
+		var instr lib.Instruction
+		if reg.Float && r.Float {
+			instr = aarch64.FMOV(reg, r)
+		} else {
+			instr = aarch64.MOV(reg, r)
+		}
+		ctx.AddInstruction(instr)
+		return append(result, instr)
+	}
+	return append(result, storeTarget(ctx, reg, target)...)
+}
+
+// Evaluates the expression and returns the location of its value.
+// Variables are used in place, other expressions are encoded into a newly
+// allocated location that should be released with releaseOperand.
+func encodeOperand(e IRExpression, ctx *IR_Context) (lib.Operand, []lib.Instruction, error) {
+	if e.Type() == Variable {
+		variable := e.(*expr.IR_Variable).Value
+		op, ok := ctx.VariableMap[variable]
+		if !ok {
+			return nil, nil, fmt.Errorf("Unknown variable '%s'", variable)
+		}
+		return op, nil, nil
+	}
+	op := ctx.AllocateRegister(e.ReturnType(ctx))
+	result, err := encodeExpression(e, ctx, op)
+	if err != nil {
+		ctx.DeallocateRegister(op)
+		return nil, nil, err
+	}
+	return op, result, nil
+}
+
+func releaseOperand(ctx *IR_Context, e IRExpression, op lib.Operand) {
+	if e.Type() != Variable {
+		ctx.DeallocateRegister(op)
+	}
+}
+
+// Loads the immediate into the register, skipping the 16 bit chunks that
+// are zero.
+func loadImmediate(ctx *IR_Context, value uint64, reg *encoding.Register) []lib.Instruction {
+	chunks := 4
+	if reg.Width() != lib.QUADWORD {
+		chunks = 2
+	}
+	result := []lib.Instruction{aarch64.MOVZ(encoding.Uint64(value&0xffff), encoding.Shift(0), reg)}
+	for i := 1; i < chunks; i++ {
+		chunk := (value >> (16 * i)) & 0xffff
+		if chunk != 0 {
+			result = append(result, aarch64.MOVK(encoding.Uint64(chunk), encoding.Shift(16*i), reg))
+		}
+	}
+	ctx.AddInstruction(result...)
+	return result
+}
+
+// Encodes an integer constant into target. Values narrower than 64 bits are
+// kept sign or zero extended to 32 bits (see normalize).
+func encodeImmediate(ctx *IR_Context, value uint64, typ Type, target lib.Operand) []lib.Instruction {
+	reg := targetRegister(target, typ, 0)
+	if reg.Width() != lib.QUADWORD {
+		value = value & 0xffffffff
+	}
+	result := loadImmediate(ctx, value, reg)
+	return append(result, storeTarget(ctx, reg, target)...)
+}
+
+// Integers narrower than 32 bits are kept in W registers, zero extended if
+// they're unsigned and sign extended if they're signed. Arithmetic can
+// overflow into the upper bits, so this restores the invariant.
+func normalize(ctx *IR_Context, reg *encoding.Register, typ Type) []lib.Instruction {
+	var instr lib.Instruction
+	switch typ.Type() {
+	case T_Uint8:
+		instr = aarch64.UXTB(reg, reg)
+	case T_Uint16:
+		instr = aarch64.UXTH(reg, reg)
+	case T_Int8:
+		instr = aarch64.SXTB(reg, reg)
+	case T_Int16:
+		instr = aarch64.SXTH(reg, reg)
+	default:
+		return nil
+	}
+	ctx.AddInstruction(instr)
+	return []lib.Instruction{instr}
+}
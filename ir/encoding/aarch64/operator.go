@@ -2,41 +2,100 @@ package aarch64
 
 import (
 	"fmt"
+	"math/bits"
 
+	"github.com/bspaans/jit-compiler/asm/aarch64"
 	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
-	"github.com/bspaans/jit-compiler/ir/expr"
 	. "github.com/bspaans/jit-compiler/ir/shared"
 	"github.com/bspaans/jit-compiler/lib"
 )
 
 type op func(o1, o2, op3 lib.Operand) lib.Instruction
 
+// Evaluates both operands and computes target = operator(op1, op2).
 func encode_Operator(op1, op2 IRExpression, operator op, repr string, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
 	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
-	if returnType1 == returnType2 && IsNumber(returnType1) {
-		result, err := encodeExpression(op1, ctx, target)
-		if err != nil {
-			return nil, err
-		}
+	if returnType1 != returnType2 || !IsNumber(returnType1) {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
+	}
+	loc1, result, err := encodeOperand(op1, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, op1, loc1)
+	loc2, expr, err := encodeOperand(op2, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, op2, loc2)
+	result = lib.Instructions(result).Add(expr)
+
+	reg1, load1 := loadOperand(ctx, loc1, returnType1, 0)
+	reg2, load2 := loadOperand(ctx, loc2, returnType2, 1)
+	result = lib.Instructions(result).Add(load1).Add(load2)
+
+	dest := targetRegister(target, returnType1, 0)
+	instr := operator(reg1, reg2, dest)
+	ctx.AddInstruction(instr)
+	result = append(result, instr)
+	result = lib.Instructions(result).Add(normalize(ctx, dest, returnType1))
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
+
+// Like encode_Operator, but for operators that are only defined on integers.
+func encode_IntegerOperator(op1, op2 IRExpression, operator op, repr string, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
+	if !IsInteger(returnType1) {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
+	}
+	return encode_Operator(op1, op2, operator, repr, ctx, target)
+}
+
+// Computes target = operator(op1, op2) for a pointer op1 and a 64 bit integer
+// op2, which counts in values of the type that op1 points to.
+func encode_PointerOperator(op1, op2 IRExpression, operator op, repr string, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
+	if !IsInteger(returnType2) || returnType2.Width() != lib.QUADWORD {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
+	}
+	loc1, result, err := encodeOperand(op1, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, op1, loc1)
+	loc2, expr, err := encodeOperand(op2, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, op2, loc2)
+	result = lib.Instructions(result).Add(expr)
 
-		var reg lib.Operand
-		if op2.Type() == Variable {
-			variable := op2.(*expr.IR_Variable).Value
-			reg = ctx.VariableMap[variable]
-		} else {
-			reg = ctx.AllocateRegister(returnType2)
-			defer ctx.DeallocateRegister(reg.(*encoding.Register))
-
-			expr, err := encodeExpression(op2, ctx, reg)
-			if err != nil {
-				return nil, err
-			}
-			result = lib.Instructions(result).Add(expr)
+	// The offset is scaled before op1 is loaded, so that x16 is still free
+	reg2, load2 := loadOperand(ctx, loc2, TUint64, 1)
+	result = lib.Instructions(result).Add(load2)
+	if size := uint64(Sizeof(returnType1.(*TPointer).Target)); size&(size-1) == 0 {
+		if shift := bits.TrailingZeros64(size); shift > 0 {
+			result = addInstructions(ctx, result, aarch64.LSL(reg2, encoding.Uint8(shift), encoding.X17))
+			reg2 = encoding.X17
 		}
-		instr := operator(reg, reg, target)
-		ctx.AddInstruction(instr)
-		result = append(result, instr)
-		return result, nil
+	} else {
+		result = lib.Instructions(result).Add(loadImmediate(ctx, size, encoding.X16))
+		result = addInstructions(ctx, result, aarch64.MUL(reg2, encoding.X16, encoding.X17))
+		reg2 = encoding.X17
+	}
+	reg1, load1 := loadOperand(ctx, loc1, TUint64, 0)
+	result = lib.Instructions(result).Add(load1)
+
+	dest := targetRegister(target, TUint64, 0)
+	result = addInstructions(ctx, result, operator(reg1, reg2, dest))
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
+
+// Evaluates both bool operands and computes target = operator(op1, op2).
+// Picks the floating point variant of the operator for float64 operands.
+func numberOperator(typ Type, intOp, floatOp op) op {
+	if IsFloat(typ) {
+		return floatOp
 	}
-	return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
+	return intOp
 }
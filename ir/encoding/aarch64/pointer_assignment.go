@@ -0,0 +1,38 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_PointerAssignment(i *statements.IR_PointerAssignment, ctx *IR_Context) ([]lib.Instruction, error) {
+	targetType, err := i.TargetType(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pointer, result, err := encodeOperand(i.Pointer, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode pointer in %s: %s", i.String(), err.Error())
+	}
+	defer releaseOperand(ctx, i.Pointer, pointer)
+	value, instr, err := encodeOperand(i.Expr, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode expr in %s: %s", i.String(), err.Error())
+	}
+	defer releaseOperand(ctx, i.Expr, value)
+	result = lib.Instructions(result).Add(instr)
+
+	base, load1 := loadOperand(ctx, pointer, TUint64, 0)
+	reg, load2 := loadOperand(ctx, value, i.Expr.ReturnType(ctx), 1)
+	result = lib.Instructions(result).Add(load1).Add(load2)
+	if !reg.Float {
+		// Integers get truncated to the width of the target
+		reg = fullRegister(reg).ForOperandWidth(targetType.Width())
+	}
+	mem := &encoding.DisplacedRegister{Register: base, Displacement: 0}
+	return lib.Instructions(result).Add(storeInMemory(ctx, reg, mem, targetType)), nil
+}
@@ -0,0 +1,37 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Moves the value into x0 or d0 and returns to the caller. Narrow integers
+// are zero or sign extended to 32 bits (see normalize), which is what the
+// calling convention expects. Slice headers are copied to the memory that
+// the caller passed the address of in x8 instead.
+func encode_IR_Return(i *statements.IR_Return, ctx *IR_Context) ([]lib.Instruction, error) {
+	returnType := i.Expr.ReturnType(ctx)
+	value, result, err := encodeOperand(i.Expr, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, i.Expr, value)
+	result = lib.Instructions(result).Add(move(ctx, value, ReturnRegister(returnType), returnType))
+	if frame := ctx.Allocator.(*AArch64_Allocator).Frame; frame.ReturnPointer != nil {
+		header := ReturnRegister(returnType)
+		result = addInstructions(ctx, result, aarch64.LDR(frame.ReturnPointer, encoding.X16))
+		for j := 0; j < SliceHeaderSize; j += 8 {
+			result = addInstructions(ctx, result,
+				aarch64.LDR(&encoding.DisplacedRegister{Register: header, Displacement: int32(j)}, encoding.X17),
+				aarch64.STR(encoding.X17, &encoding.DisplacedRegister{Register: encoding.X16, Displacement: int32(j)}),
+			)
+		}
+	}
+	result = lib.Instructions(result).Add(encodeEpilogue(ctx))
+	ret := aarch64.RET()
+	ctx.AddInstruction(ret)
+	return append(result, ret), nil
+}
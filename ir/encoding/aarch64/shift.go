@@ -0,0 +1,40 @@
+package aarch64
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Shifts op1 by op2 into target. The shift count can have a different
+// integer type than op1; the register variants of the shift instructions use
+// it modulo the register size.
+func encode_Shift(op1, op2 IRExpression, shift op, repr string, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
+	if !IsInteger(returnType1) || !IsInteger(returnType2) {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
+	}
+	loc1, result, err := encodeOperand(op1, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, op1, loc1)
+	loc2, expr, err := encodeOperand(op2, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, op2, loc2)
+	result = lib.Instructions(result).Add(expr)
+
+	reg1, load1 := loadOperand(ctx, loc1, returnType1, 0)
+	reg2, load2 := loadOperand(ctx, loc2, returnType2, 1)
+	result = lib.Instructions(result).Add(load1).Add(load2)
+
+	dest := targetRegister(target, returnType1, 0)
+	instr := shift(reg1, reg2.ForOperandWidth(dest.Width()), dest)
+	ctx.AddInstruction(instr)
+	result = append(result, instr)
+	result = lib.Instructions(result).Add(normalize(ctx, dest, returnType1))
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
@@ -0,0 +1,15 @@
+package aarch64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_ShiftRight(i *expr.IR_ShiftRight, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	if IsSignedInteger(i.Op1.ReturnType(ctx)) {
+		return encode_Shift(i.Op1, i.Op2, aarch64.ASR, i.String(), ctx, target)
+	}
+	return encode_Shift(i.Op1, i.Op2, aarch64.LSR, i.String(), ctx, target)
+}
@@ -0,0 +1,96 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Encodes a slicing expression. The operands are evaluated and moved into
+// x0-x4: x0 holds the items, x1 the low bound, x2 the high bound, x3 the
+// capacity and x4 the address of the new header, which gets written to its
+// area in the stack frame (see TSlice). The bounds are checked against the
+// value: low <= high <= capacity.
+func encode_IR_Slice(i *expr.IR_Slice, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	valueType := i.Value.ReturnType(ctx)
+	if arr, ok := valueType.(*TArray); ok && arr.Size == 0 {
+		return nil, fmt.Errorf("Can't slice an array without a size in %s", i.String())
+	}
+	if i.High == nil && IsPointer(valueType) {
+		return nil, fmt.Errorf("Expecting an upper bound when slicing a pointer in %s", i.String())
+	}
+	for _, bound := range []IRExpression{i.Low, i.High} {
+		if bound == nil {
+			continue
+		}
+		if ty := bound.ReturnType(ctx); !IsInteger(ty) || ty.Width() != lib.QUADWORD {
+			return nil, fmt.Errorf("Expecting uint64 or int64 bounds in %s", i.String())
+		}
+	}
+
+	result := []lib.Instruction{}
+	operands := []IRExpression{i.Value, i.Low}
+	if i.High != nil {
+		operands = append(operands, i.High)
+	}
+	// The operands are only moved into place once they have all been
+	// evaluated, because calls clobber x0-x4.
+	ops := []lib.Operand{}
+	for _, e := range operands {
+		op, instr, err := encodeOperand(e, ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer releaseOperand(ctx, e, op)
+		result = lib.Instructions(result).Add(instr)
+		ops = append(ops, op)
+	}
+	items, low, high, capacity, headerReg := encoding.X0, encoding.X1, encoding.X2, encoding.X3, encoding.X4
+	for ix, op := range ops {
+		result = lib.Instructions(result).Add(move(ctx, op, []*encoding.Register{items, low, high}[ix], TUint64))
+	}
+	offset := ctx.Allocator.(*AArch64_Allocator).Frame.GetArea(i, SliceHeaderSize)
+	result = addInstructions(ctx, result, aarch64.ADD(encoding.X29, encoding.Uint64(offset), headerReg))
+
+	switch ty := valueType.(type) {
+	case *TSlice:
+		if i.High == nil {
+			result = addInstructions(ctx, result, aarch64.LDR(&encoding.DisplacedRegister{Register: items, Displacement: SliceLengthOffset}, high))
+		}
+		result = addInstructions(ctx, result,
+			aarch64.LDR(&encoding.DisplacedRegister{Register: items, Displacement: SliceCapacityOffset}, capacity),
+			aarch64.LDR(&encoding.DisplacedRegister{Register: items, Displacement: SliceItemsOffset}, items),
+		)
+	case *TArray:
+		if i.High == nil {
+			result = lib.Instructions(result).Add(loadImmediate(ctx, uint64(ty.Size), high))
+		}
+		result = lib.Instructions(result).Add(loadImmediate(ctx, uint64(ty.Size), capacity))
+	case *TPointer:
+		result = addInstructions(ctx, result, aarch64.MOV(high, capacity))
+	}
+	result = lib.Instructions(result).Add(checkBounds(ctx, low, high, true))
+	result = lib.Instructions(result).Add(checkBounds(ctx, high, capacity, true))
+
+	// The new slice starts at the low item, so the bound is subtracted
+	// from the length and the capacity.
+	shift := shiftForItemWidth(i.ReturnType(ctx).(*TSlice).ItemType.Width())
+	result = addInstructions(ctx, result,
+		aarch64.SUB(high, low, high),
+		aarch64.SUB(capacity, low, capacity),
+	)
+	if shift > 0 {
+		result = addInstructions(ctx, result, aarch64.LSL(low, encoding.Uint8(shift), low))
+	}
+	result = addInstructions(ctx, result,
+		aarch64.ADD(items, low, items),
+		aarch64.STR(items, &encoding.DisplacedRegister{Register: headerReg, Displacement: SliceItemsOffset}),
+		aarch64.STR(high, &encoding.DisplacedRegister{Register: headerReg, Displacement: SliceLengthOffset}),
+		aarch64.STR(capacity, &encoding.DisplacedRegister{Register: headerReg, Displacement: SliceCapacityOffset}),
+	)
+	return lib.Instructions(result).Add(move(ctx, headerReg, target, TUint64)), nil
+}
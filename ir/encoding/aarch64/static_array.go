@@ -0,0 +1,106 @@
+package aarch64
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_StaticArray(i *expr.IR_StaticArray, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	if i.Address == nil {
+		bytes, err := staticArrayBytes(i)
+		if err != nil {
+			return nil, err
+		}
+		return encodeFrameData(ctx, i, bytes, target), nil
+	}
+	// Load the address of the array into target (see encode_IR_ByteArray)
+	return encodeDataAddress(ctx, i.Address, target), nil
+}
+
+// Copies the data of an array or struct that's declared in a function into
+// the stack frame, and loads its address into target. The data is copied
+// eight bytes at a time, through the target register.
+func encodeFrameData(ctx *IR_Context, e IRExpression, bytes []uint8, target lib.Operand) []lib.Instruction {
+	offset := ctx.Allocator.(*AArch64_Allocator).Frame.GetArea(e, len(bytes))
+	reg := targetRegister(target, TUint64, 0)
+	result := []lib.Instruction{}
+	for j := 0; j < len(bytes); j += 8 {
+		eightbyte := make([]uint8, 8)
+		copy(eightbyte, bytes[j:])
+		result = append(result, loadImmediate(ctx, binary.LittleEndian.Uint64(eightbyte), reg)...)
+		result = addInstructions(ctx, result, aarch64.STR(reg, &encoding.DisplacedRegister{Register: encoding.X29, Displacement: int32(offset + j)}))
+	}
+	result = addInstructions(ctx, result, aarch64.ADD(encoding.X29, encoding.Uint64(offset), reg))
+	return append(result, storeTarget(ctx, reg, target)...)
+}
+
+func encode_IR_StaticArray_for_DataSection(b *expr.IR_StaticArray, ctx *IR_Context, segments *dataSection) error {
+	b.Address = nil
+	if ctx.InFunction {
+		// Every call of the function gets its own copy in its stack frame
+		// instead (see encodeFrameData).
+		return nil
+	}
+	bytes, err := staticArrayBytes(b)
+	if err != nil {
+		return err
+	}
+	b.Address = segments.Add(ReadWrite, bytes...)
+	return nil
+}
+
+// Returns the encoded values of the array.
+func staticArrayBytes(b *expr.IR_StaticArray) ([]uint8, error) {
+	result := []uint8{}
+	for _, v := range b.Value {
+		bytes := []uint8{}
+		if b.ElemType == TUint8 {
+			switch c := v.(type) {
+			case *expr.IR_Uint8:
+				bytes = []uint8{uint8(c.Value)}
+			case *expr.IR_Uint64:
+				bytes = []uint8{uint8(c.Value)}
+			default:
+				return nil, fmt.Errorf("Unsupport uint8 array type %s in %s", b.ElemType, b.String())
+			}
+		} else if b.ElemType == TUint16 {
+			switch c := v.(type) {
+			case *expr.IR_Uint16:
+				bytes = encoding.Uint16(c.Value).Encode()
+			case *expr.IR_Uint64:
+				bytes = encoding.Uint16(uint16(c.Value)).Encode()
+			default:
+				return nil, fmt.Errorf("Unsupport uint16 array type %s in %s", b.ElemType, b.String())
+			}
+		} else if b.ElemType == TUint32 {
+			switch c := v.(type) {
+			case *expr.IR_Uint32:
+				bytes = encoding.Uint32(c.Value).Encode()
+			case *expr.IR_Uint64:
+				bytes = encoding.Uint32(uint32(c.Value)).Encode()
+			default:
+				return nil, fmt.Errorf("Unsupport uint32 array type %s in %s", b.ElemType, b.String())
+			}
+		} else if b.ElemType == TUint64 {
+			ir := v.(*expr.IR_Uint64)
+			bytes = encoding.Uint64(ir.Value).Encode()
+		} else if b.ElemType == TFloat64 {
+			ir := v.(*expr.IR_Float64)
+			bytes = encoding.Uint64(math.Float64bits(ir.Value)).Encode()
+		} else if b.ElemType == TInt64 {
+			ir := v.(*expr.IR_Int64)
+			bytes = encoding.Uint64(ir.Value).Encode()
+		} else {
+			return nil, fmt.Errorf("Unsupported array type %s", v.Type().String())
+		}
+		result = append(result, bytes...)
+	}
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package aarch64
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Struct(i *expr.IR_Struct, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	if i.Address == nil {
+		bytes, err := structBytes(i)
+		if err != nil {
+			return nil, err
+		}
+		return encodeFrameData(ctx, i, bytes, target), nil
+	}
+	// Load the address of the struct into target (see encode_IR_ByteArray)
+	return encodeDataAddress(ctx, i.Address, target), nil
+}
+
+func encode_IR_Struct_for_DataSection(b *expr.IR_Struct, ctx *IR_Context, segments *dataSection) error {
+	b.Address = nil
+	if ctx.InFunction {
+		// Every call of the function gets its own copy in its stack frame
+		// instead (see encodeFrameData).
+		return nil
+	}
+	bytes, err := structBytes(b)
+	if err != nil {
+		return err
+	}
+	b.Address = segments.Add(ReadWrite, bytes...)
+	return nil
+}
+
+// Returns the encoded values of the struct, with the fields laid out like in
+// C (see TStruct.Layout).
+func structBytes(b *expr.IR_Struct) ([]uint8, error) {
+	str := b.StructType
+	if len(b.Values) != len(str.FieldTypes) {
+		return nil, fmt.Errorf("Expecting %d values in %s", len(str.FieldTypes), b.String())
+	}
+	layout := str.Layout()
+	result := make([]uint8, layout.Size)
+	for j, v := range b.Values {
+		bytes, err := literalBytes(str.FieldTypes[j], v)
+		if err != nil {
+			return nil, fmt.Errorf("%s in field '%s' of %s", err.Error(), str.Fields[j], b.String())
+		}
+		copy(result[layout.Offsets[j]:], bytes)
+	}
+	return result, nil
+}
+
+// Returns the encoded value of a literal of the given type, as it's stored in
+// a struct.
+func literalBytes(typ Type, v IRExpression) ([]uint8, error) {
+	var result []uint8
+	switch c := v.(type) {
+	case *expr.IR_Uint8:
+		result = []uint8{c.Value}
+	case *expr.IR_Uint16:
+		result = encoding.Uint16(c.Value).Encode()
+	case *expr.IR_Uint32:
+		result = encoding.Uint32(c.Value).Encode()
+	case *expr.IR_Uint64:
+		result = encoding.Uint64(c.Value).Encode()
+	case *expr.IR_Int8:
+		result = []uint8{uint8(c.Value)}
+	case *expr.IR_Int16:
+		result = encoding.Uint16(uint16(c.Value)).Encode()
+	case *expr.IR_Int32:
+		result = encoding.Uint32(uint32(c.Value)).Encode()
+	case *expr.IR_Int64:
+		result = encoding.Uint64(uint64(c.Value)).Encode()
+	case *expr.IR_Float64:
+		result = encoding.Uint64(math.Float64bits(c.Value)).Encode()
+	case *expr.IR_Bool:
+		result = []uint8{0}
+		if c.Value {
+			result[0] = 1
+		}
+	case *expr.IR_Struct:
+		bytes, err := structBytes(c)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := typ.(*TStruct); ok {
+			result = bytes
+		}
+	case *expr.IR_StaticArray:
+		// Arrays are stored inline; missing items are zero.
+		bytes, err := staticArrayBytes(c)
+		if err != nil {
+			return nil, err
+		}
+		if arr, ok := typ.(*TArray); ok && arr.ItemType == c.ElemType && len(bytes) <= Sizeof(typ) {
+			result = make([]uint8, Sizeof(typ))
+			copy(result, bytes)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported struct type %s", v.Type())
+	}
+	_, isFloat := v.(*expr.IR_Float64)
+	if len(result) != Sizeof(typ) || isFloat != (typ.Type() == T_Float64) {
+		return nil, fmt.Errorf("Expecting a value of type %s, got %s", typ, v.String())
+	}
+	return result, nil
+}
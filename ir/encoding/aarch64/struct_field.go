@@ -0,0 +1,55 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_StructField(i *expr.IR_StructField, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	fieldType, offset, err := structField(i, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pointer to the struct is loaded into base
+	pointer, result, err := encodeOperand(i.Struct, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOperand(ctx, i.Struct, pointer)
+	base, load := loadOperand(ctx, pointer, TUint64, 0)
+	result = lib.Instructions(result).Add(load)
+
+	dest := targetRegister(target, fieldType, 0)
+	if IsInline(fieldType) {
+		// Nested structs and arrays are stored inline and evaluate to
+		// their address.
+		if offset > 4095 {
+			return nil, fmt.Errorf("Field '%s' is too far into %s", i.Field, i.Struct.String())
+		}
+		result = addInstructions(ctx, result, aarch64.ADD(base, encoding.Uint64(offset), dest))
+	} else {
+		mem := &encoding.DisplacedRegister{Register: base, Displacement: int32(offset)}
+		result = lib.Instructions(result).Add(loadFromMemory(ctx, mem, fieldType, dest))
+	}
+	return lib.Instructions(result).Add(storeTarget(ctx, dest, target)), nil
+}
+
+// Returns the type and offset of the field. Fields of structs that are
+// pointed to are accessed through the pointer.
+func structField(i *expr.IR_StructField, ctx *IR_Context) (Type, int, error) {
+	str, ok := i.StructType(ctx)
+	if !ok {
+		return nil, 0, fmt.Errorf("Expecting struct, got %s", i.Struct.ReturnType(ctx))
+	}
+	j := str.FieldIndex(i.Field)
+	if j == -1 {
+		return nil, 0, fmt.Errorf("Unknown field '%s' in %s", i.Field, i.String())
+	}
+	return str.FieldTypes[j], str.Layout().Offsets[j], nil
+}
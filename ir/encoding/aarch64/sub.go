@@ -8,6 +8,9 @@ import (
 )
 
 func encode_IR_Sub(i *expr.IR_Sub, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
-
-	return encode_Operator(i.Op1, i.Op2, aarch64.SUB, i.String(), ctx, target)
+	if IsPointer(i.Op1.ReturnType(ctx)) {
+		return encode_PointerOperator(i.Op1, i.Op2, aarch64.SUB, i.String(), ctx, target)
+	}
+	operator := numberOperator(i.Op1.ReturnType(ctx), aarch64.SUB, aarch64.FSUB)
+	return encode_Operator(i.Op1, i.Op2, operator, i.String(), ctx, target)
 }
@@ -0,0 +1,66 @@
+package aarch64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	"github.com/bspaans/jit-compiler/asm/aarch64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// The IR uses the x86-64 syscall numbers (see expr.IR_Syscall_Linux), which
+// differ on aarch64. Linux on aarch64 doesn't have open(2), only openat(2).
+var linuxSyscalls = map[uint64]uint64{
+	uint64(expr.IR_Syscall_Linux_Read):  63,
+	uint64(expr.IR_Syscall_Linux_Write): 64,
+	uint64(expr.IR_Syscall_Linux_Close): 57,
+	uint64(expr.IR_Syscall_Linux_Mmap):  222,
+	uint64(expr.IR_Syscall_Linux_Exit):  93,
+}
+
+// Arguments are passed in x0-x5 and the syscall number in x8. The kernel
+// preserves all the other registers, so nothing needs saving.
+func encode_IR_Syscall(i *expr.IR_Syscall, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	syscall := i.Syscall
+	if nr, ok := syscall.(*expr.IR_Uint64); ok {
+		translated, ok := linuxSyscalls[nr.Value]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported syscall %d in %s", nr.Value, i.String())
+		}
+		syscall = expr.NewIR_Uint64(translated)
+	}
+	registers := []*encoding.Register{encoding.X0, encoding.X1, encoding.X2, encoding.X3, encoding.X4, encoding.X5}
+	if len(i.Args) > len(registers) {
+		return nil, fmt.Errorf("Too many arguments in %s", i.String())
+	}
+	for _, arg := range i.Args {
+		if IsFloat(arg.ReturnType(ctx)) {
+			return nil, fmt.Errorf("Unsupported float argument in %s", i.String())
+		}
+	}
+	result, err := encodeArguments(ctx, i.Args, registers[:len(i.Args)])
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s", err.Error(), i.String())
+	}
+	instr, err := encodeExpression(syscall, ctx, encoding.X8)
+	if err != nil {
+		return nil, err
+	}
+	result = lib.Instructions(result).Add(instr)
+	svc := aarch64.SVC(encoding.Uint64(0))
+	ctx.AddInstruction(svc)
+	result = append(result, svc)
+	return lib.Instructions(result).Add(move(ctx, encoding.X0, target, TUint64)), nil
+}
+
+// The entry point of executables calls the code and passes the value that it
+// returns in x0 on to exit(2), because there's nothing to return to.
+func (x *AArch64) EncodeEntry(main *lib.Label) []lib.Instruction {
+	return []lib.Instruction{
+		aarch64.BL(main),
+		aarch64.MOVZ(encoding.Uint64(linuxSyscalls[uint64(expr.IR_Syscall_Linux_Exit)]), encoding.Shift(0), encoding.X8),
+		aarch64.SVC(encoding.Uint64(0)),
+	}
+}
@@ -1,11 +1,17 @@
 package aarch64
 
 import (
+	"fmt"
+
 	"github.com/bspaans/jit-compiler/ir/expr"
 	. "github.com/bspaans/jit-compiler/ir/shared"
 	"github.com/bspaans/jit-compiler/lib"
 )
 
-func encode_IR_Variable(v *expr.IR_Variable, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
-	return nil, nil
+func encode_IR_Variable(i *expr.IR_Variable, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	reg, ok := ctx.VariableMap[i.Value]
+	if !ok || reg == nil {
+		return nil, fmt.Errorf("Unknown variable '%s'", i.Value)
+	}
+	return move(ctx, reg, target, i.ReturnType(ctx)), nil
 }
@@ -0,0 +1,39 @@
+package aarch64
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/aarch64"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_While(i *statements.IR_While, ctx *IR_Context) ([]lib.Instruction, error) {
+	if i.Condition.ReturnType(ctx) != TBool {
+		return nil, errors.New("Unsupported while IR condition")
+	}
+	beginning := ctx.NewLabel("while")
+	end := ctx.NewLabel("end_while")
+
+	result := []lib.Instruction{lib.DefineLabel(beginning)}
+	ctx.AddInstruction(result...)
+
+	jmp, err := conditionalJump(ctx, i.Condition, end)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s", err.Error(), i.String())
+	}
+	result = lib.Instructions(result).Add(jmp)
+	s1, err := encodeStatement(i.Stmt, ctx)
+	if err != nil {
+		return nil, err
+	}
+	result = lib.Instructions(result).Add(s1)
+	instr := []lib.Instruction{
+		aarch64.B(beginning),
+		lib.DefineLabel(end),
+	}
+	ctx.AddInstruction(instr...)
+	return append(result, instr...), nil
+}
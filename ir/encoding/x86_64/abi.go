@@ -22,25 +22,124 @@ type ABI_AMDSystemV struct {
 
 func NewABI_AMDSystemV() *ABI_AMDSystemV {
 	return &ABI_AMDSystemV{
-		intTargets:   []*encoding.Register{encoding.Rdi, encoding.Rsi, encoding.Rdx, encoding.Rcx, encoding.R10, encoding.R8, encoding.R9},
-		floatTargets: []*encoding.Register{encoding.Xmm0, encoding.Xmm1, encoding.Xmm2, encoding.Xmm3, encoding.Xmm4, encoding.Xmm5},
+		intTargets:   []*encoding.Register{encoding.Rdi, encoding.Rsi, encoding.Rdx, encoding.Rcx, encoding.R8, encoding.R9},
+		floatTargets: []*encoding.Register{encoding.Xmm0, encoding.Xmm1, encoding.Xmm2, encoding.Xmm3, encoding.Xmm4, encoding.Xmm5, encoding.Xmm6, encoding.Xmm7},
 	}
 }
-func (a *ABI_AMDSystemV) GetRegistersForArgs(args []Type) []*encoding.Register {
-	intRegisterIx := 0
-	floatRegisterIx := 0
-	result := []*encoding.Register{}
 
-	var reg *encoding.Register
+// The classes of the eightbytes that arguments and return values consist of.
+type ArgumentClass int
+
+const (
+	// Passed in the general purpose registers.
+	INTEGER ArgumentClass = iota
+	// Passed in the xmm registers.
+	SSE
+	// Passed in memory.
+	MEMORY
+)
+
+// Returns the classes of the eightbytes of a value of the given type.
+// Structs that are larger than two eightbytes (or empty) are passed in
+// MEMORY; in smaller structs an eightbyte is SSE if it only holds float64
+// fields.
+func Classify(typ Type) []ArgumentClass {
+	str, ok := typ.(*TStruct)
+	if !ok {
+		if typ.Type() == T_Float64 {
+			return []ArgumentClass{SSE}
+		}
+		return []ArgumentClass{INTEGER}
+	}
+	size := Sizeof(str)
+	if size == 0 || size > 16 {
+		return []ArgumentClass{MEMORY}
+	}
+	// Fields are aligned (see TStruct.Layout), so none of them straddles two
+	// eightbytes.
+	result := make([]ArgumentClass, (size+7)/8)
+	for i := range result {
+		result[i] = SSE
+	}
+	for _, field := range Scalars(str) {
+		if field.Type.Type() != T_Float64 {
+			result[field.Offset/8] = INTEGER
+		}
+	}
+	return result
+}
+
+// Returns where the arguments and the return value are passed. Arguments
+// are passed in the registers for the classes of their eightbytes, until the
+// registers run out; the rest are passed on the stack, with the first
+// argument at the lowest address. A return value that is passed in MEMORY
+// takes up the first integer register for its address.
+func (a *ABI_AMDSystemV) ClassifyCall(args []Type, returnType Type) *CallLayout {
+	intTargets, floatTargets := a.intTargets, a.floatTargets
+	result := &CallLayout{
+		Args:   []*ValueLocation{},
+		Return: a.classifyReturn(returnType),
+	}
+	if result.Return.InMemory() {
+		result.ReturnPointer = intTargets[0]
+		intTargets = intTargets[1:]
+	}
 	for _, arg := range args {
-		if arg.Type() == T_Float64 {
-			reg = a.floatTargets[floatRegisterIx]
-			floatRegisterIx += 1
+		classes := Classify(arg)
+		location := &ValueLocation{Size: 8 * len(classes)}
+		ints, floats := 0, 0
+		for _, class := range classes {
+			if class == INTEGER {
+				ints += 1
+			} else if class == SSE {
+				floats += 1
+			}
+		}
+		if classes[0] == MEMORY || ints > len(intTargets) || floats > len(floatTargets) {
+			if classes[0] == MEMORY {
+				location.Size = (Sizeof(arg) + 7) &^ 7
+			}
+			location.StackOffset = result.StackSize
+			result.StackSize += location.Size
+		} else {
+			location.Registers = []lib.Operand{}
+			for _, class := range classes {
+				if class == INTEGER {
+					location.Registers = append(location.Registers, intTargets[0])
+					intTargets = intTargets[1:]
+				} else {
+					location.Registers = append(location.Registers, floatTargets[0])
+					floatTargets = floatTargets[1:]
+				}
+			}
+		}
+		result.Args = append(result.Args, location)
+	}
+	return result
+}
+
+// Integer eightbytes are returned in rax and rdx, and SSE eightbytes in xmm0
+// and xmm1. Slices are returned like a struct holding their header, so that
+// the header ends up in the stack frame of the caller (see TSlice).
+func (a *ABI_AMDSystemV) classifyReturn(returnType Type) *ValueLocation {
+	if IsSlice(returnType) {
+		return &ValueLocation{Size: SliceHeaderSize}
+	}
+	classes := Classify(returnType)
+	if classes[0] == MEMORY {
+		return &ValueLocation{Size: (Sizeof(returnType) + 7) &^ 7}
+	}
+	intTargets := []*encoding.Register{encoding.Rax, encoding.Rdx}
+	floatTargets := []*encoding.Register{encoding.Xmm0, encoding.Xmm1}
+	result := &ValueLocation{Registers: []lib.Operand{}, Size: 8 * len(classes)}
+	for _, class := range classes {
+		if class == INTEGER {
+			result.Registers = append(result.Registers, intTargets[0])
+			intTargets = intTargets[1:]
 		} else {
-			reg = a.intTargets[intRegisterIx]
-			intRegisterIx += 1
+			result.Registers = append(result.Registers, floatTargets[0])
+			floatTargets = floatTargets[1:]
 		}
-		result = append(result, reg)
 	}
 	return result
 }
@@ -52,108 +151,270 @@ func (a *ABI_AMDSystemV) ReturnTypeToOperand(arg Type) lib.Operand {
 	return encoding.Rax
 }
 
-// returns instructions and clobbered registers
-func PreserveRegisters(ctx *IR_Context, argTypes []Type, returnType Type) (lib.Instructions, map[lib.Operand]lib.Operand, []lib.Operand) {
-	clobbered := []lib.Operand{}
-	result := []lib.Instruction{}
-	mapping := map[lib.Operand]lib.Operand{}
+func (a *ABI_AMDSystemV) IsCalleeSaved(reg lib.Operand) bool {
+	r, ok := reg.(*encoding.Register)
+	return ok && IsCalleeSaved(r)
+}
 
-	// push the return register; TODO: check if in use?
-	returnOp := ctx.ABI.ReturnTypeToOperand(returnType)
-	push := x86_64.PUSH(returnOp)
-	result = append(result, push)
-	clobbered = append(clobbered, returnOp)
-	ctx.AddInstruction(push)
+// The main code is called like a Go function without arguments (see
+// lib.MachineCode.Execute), which expects the result on the stack.
+func (a *ABI_AMDSystemV) ExecuteReturnOperand() lib.Operand {
+	return &encoding.DisplacedRegister{Register: encoding.Rsp, Displacement: 8}
+}
 
-	allocator := ctx.Allocator.(*X86_64_Allocator)
-	// Push registers that are already in use
-	regs := ctx.ABI.GetRegistersForArgs(argTypes)
-	var inUse bool
-	for i, arg := range argTypes {
-		reg := regs[i]
-		if arg.Type() == T_Float64 {
-			inUse = allocator.FloatRegisters[reg.Register]
-		} else {
-			inUse = allocator.Registers[reg.Register]
+// Returns whether the register has to be preserved by the callee: rbx, rbp
+// and r12-r15. The xmm registers are all caller-saved.
+func IsCalleeSaved(reg *encoding.Register) bool {
+	if reg.Size == lib.OWORD {
+		return false
+	}
+	switch reg.Register {
+	case 3, 5, 12, 13, 14, 15:
+		return true
+	}
+	return false
+}
+
+func argumentTypes(ctx *IR_Context, args []IRExpression) ([]Type, error) {
+	result := make([]Type, len(args))
+	for i, arg := range args {
+		result[i] = arg.ReturnType(ctx)
+		if result[i] == nil {
+			return nil, fmt.Errorf("Unknown type for value: %s", arg)
 		}
-		if inUse {
-			result = append(result, x86_64.PUSH(reg))
-			ctx.AddInstruction(x86_64.PUSH(reg))
+	}
+	return result, nil
+}
+
+// Pushes the registers that are in use, so that the call can overwrite them.
+// Returns the instructions, the mapping from the pushed registers to their
+// location on the stack, and the pushed registers.
+func PreserveRegisters(ctx *IR_Context, regs []*encoding.Register) (lib.Instructions, map[lib.Operand]lib.Operand, []lib.Operand) {
+	allocator := ctx.Allocator.(*X86_64_Allocator)
+	clobbered := []lib.Operand{}
+	for _, reg := range regs {
+		if allocator.inUse(reg) && !containsOperand(clobbered, reg) {
 			clobbered = append(clobbered, reg)
 		}
 	}
-	// Build the register -> location on the stack mapping
-	mappedClobbered := 1 // set to 1, to account for the return op TODO
-	for i, arg := range argTypes {
-		reg := regs[i]
-		if arg.Type() == T_Float64 {
-			inUse = allocator.FloatRegisters[reg.Register]
-		} else {
-			inUse = allocator.Registers[reg.Register]
-		}
-		if inUse {
-			offset := (len(clobbered) - mappedClobbered) * int(arg.Width())
-			mapping[reg] = &encoding.DisplacedRegister{encoding.Rsp, uint8(offset)}
-			mappedClobbered += 1
-		}
+	result := []lib.Instruction{}
+	mapping := map[lib.Operand]lib.Operand{}
+	for i, reg := range clobbered {
+		push := pushRegister(reg.(*encoding.Register))
+		ctx.AddInstruction(push...)
+		result = append(result, push...)
+		mapping[reg] = displaced(encoding.Rsp, (len(clobbered)-1-i)*8)
 	}
+	allocator.Frame.Pushed += len(clobbered)
 	return result, mapping, clobbered
 }
 
-func ABI_Call_Setup(ctx *IR_Context, args []IRExpression, returnType Type) (lib.Instructions, map[lib.Operand]lib.Operand, []lib.Operand, error) {
-	argTypes := make([]Type, len(args))
-	for i, arg := range args {
-		argTypes[i] = arg.ReturnType(ctx)
-		if argTypes[i] == nil {
-			return nil, nil, nil, fmt.Errorf("Unknown type for value: %s", arg)
-		}
+// Moves the arguments to where the layout says they're passed. The space for
+// the arguments that are passed on the stack should already be reserved.
+// Variables in the pushed registers are read from their location in mapping,
+// because the registers get overwritten. Structs are passed by copying their
+// eightbytes; if the return value is passed in memory, space for it is
+// reserved in the stack frame.
+func ABI_Call_Setup(ctx *IR_Context, args []IRExpression, layout *CallLayout, mapping map[lib.Operand]lib.Operand) (lib.Instructions, error) {
+	// TODO: this should probably move to the "encode" package
+	if ctx.Architecture == nil {
+		return nil, fmt.Errorf("Missing Architecture in IR_Context")
+	}
+	result := lib.Instructions{}
+	emit := func(instr ...lib.Instruction) {
+		ctx.AddInstruction(instr...)
+		result = append(result, instr...)
 	}
-	result, mapping, clobbered := PreserveRegisters(ctx, argTypes, returnType)
-	regs := ctx.ABI.GetRegistersForArgs(argTypes)
 
 	ctx_ := ctx.Copy()
-	allocator := ctx_.Allocator.(*X86_64_Allocator)
-	for _, reg := range regs {
-		if reg.Size == lib.OWORD {
-			allocator.FloatRegisters[reg.Register] = true
-			allocator.FloatRegistersAllocated += 1
-		} else {
-			if !allocator.Registers[reg.Register] {
-				allocator.Registers[reg.Register] = true
-				allocator.RegistersAllocated += 1
-			}
+	for variable, location := range ctx_.VariableMap {
+		if reg, ok := location.(*encoding.Register); ok && reg.Size != lib.OWORD {
+			location = reg.Get64BitRegister()
+		}
+		if moved, found := mapping[location]; found {
+			ctx_.VariableMap[variable] = moved
 		}
 	}
 
-	for i, arg := range args {
-		// TODO: this should probably move to the "encode" package
-		if ctx.Architecture == nil {
-			return nil, nil, nil, fmt.Errorf("Missing Architecture in IR_Context")
+	// The arguments that are passed on the stack go first, so that the
+	// temporaries they need can't overwrite arguments in registers. After
+	// that the argument registers are reserved in the copy of the context,
+	// so that they don't get used for temporaries.
+	for _, inMemory := range []bool{true, false} {
+		if !inMemory {
+			allocator := ctx_.Allocator.(*X86_64_Allocator)
+			if layout.ReturnPointer != nil {
+				allocator.reserveRegister(layout.ReturnPointer.(*encoding.Register))
+			}
+			for _, location := range layout.Args {
+				for _, reg := range location.Registers {
+					allocator.reserveRegister(reg.(*encoding.Register))
+				}
+			}
 		}
-		instr, err := ctx.Architecture.EncodeExpression(arg, ctx_, regs[i])
-		if err != nil {
-			return nil, nil, nil, err
+		for i, arg := range args {
+			location := layout.Args[i]
+			if location.InMemory() != inMemory {
+				continue
+			}
+			argType := arg.ReturnType(ctx)
+			narrow := argType.Type() != T_Float64 && argType.Width() != lib.QUADWORD
+			if !location.InMemory() && argType.Type() != T_Struct {
+				reg := location.Registers[0].(*encoding.Register)
+				if narrow {
+					reg = reg.ForOperandWidth(argType.Width())
+				}
+				instr, err := ctx.Architecture.EncodeExpression(arg, ctx_, reg)
+				if err != nil {
+					return nil, err
+				}
+				ctx.AddInstruction(instr...)
+				result = result.Add(instr)
+				if narrow {
+					emit(extendRegister(reg, argType))
+				}
+				continue
+			}
+			if argType.Type() == T_Struct {
+				// The struct evaluates to its address
+				argType = TUint64
+			}
+			tmpReg := ctx_.AllocateRegister(argType)
+			instr, err := ctx.Architecture.EncodeExpression(arg, ctx_, tmpReg)
+			if err != nil {
+				return nil, err
+			}
+			ctx.AddInstruction(instr...)
+			result = result.Add(instr)
+			if str, ok := arg.ReturnType(ctx).(*TStruct); ok {
+				emit(copyStruct(ctx_, tmpReg.(*encoding.Register), location, Sizeof(str))...)
+			} else if narrow {
+				reg := tmpReg.(*encoding.Register)
+				emit(extendRegister(reg, argType), spill(reg.Get64BitRegister(), displaced(encoding.Rsp, location.StackOffset)))
+			} else {
+				emit(spill(tmpReg.(*encoding.Register), displaced(encoding.Rsp, location.StackOffset)))
+			}
+			ctx_.DeallocateRegister(tmpReg)
 		}
-		ctx.AddInstruction(instr...)
-		result = result.Add(instr)
 	}
-	for variable, location := range ctx_.VariableMap {
-		if newLocation, found := mapping[location]; found {
-			ctx_.VariableMap[variable] = newLocation
-		}
+
+	if layout.ReturnPointer != nil {
+		offset := ctx.Allocator.(*X86_64_Allocator).Frame.AllocateArea(layout.Return.Size)
+		emit(x86_64.LEA(frameSlot(offset, lib.QUADWORD), layout.ReturnPointer))
 	}
+	return result, nil
+}
 
-	return result, mapping, clobbered, nil
+// Extends the integer or bool in the lower part of the register to the
+// whole register. Values that are narrower than 64 bits are passed and
+// returned that way, like C compilers do.
+func extendRegister(reg *encoding.Register, typ Type) lib.Instruction {
+	if IsSignedInteger(typ) {
+		return x86_64.MOVSX(reg, reg.Get64BitRegister())
+	}
+	if reg.Width() == lib.DOUBLE {
+		// Writing to a 32 bit register clears the upper half
+		return x86_64.MOV(reg, reg)
+	}
+	return x86_64.MOVZX(reg, reg.Get64BitRegister())
+}
 
+// Copies the eightbytes of the struct at the address in source to the
+// registers or the stack, depending on where it's passed.
+func copyStruct(ctx *IR_Context, source *encoding.Register, location *ValueLocation, size int) []lib.Instruction {
+	result := []lib.Instruction{}
+	if !location.InMemory() {
+		for j, reg := range location.Registers {
+			result = append(result, reload(displaced(source, j*8), reg.(*encoding.Register)))
+		}
+		return result
+	}
+	tmpReg := ctx.AllocateRegister(TUint64)
+	defer ctx.DeallocateRegister(tmpReg)
+	for j := 0; j < size; j += 8 {
+		result = append(result,
+			x86_64.MOV(displaced(source, j), tmpReg),
+			x86_64.MOV(tmpReg, displaced(encoding.Rsp, location.StackOffset+j)),
+		)
+	}
+	return result
 }
 
 func RestoreRegisters(ctx *IR_Context, clobbered []lib.Operand) lib.Instructions {
 	// Pop in reverse order
 	result := []lib.Instruction{}
 	for j := len(clobbered) - 1; j >= 0; j-- {
-		reg := clobbered[j]
-		result = append(result, x86_64.POP(reg))
-		ctx.AddInstruction(x86_64.POP(reg))
+		pop := popRegister(clobbered[j].(*encoding.Register))
+		ctx.AddInstruction(pop...)
+		result = append(result, pop...)
 	}
+	ctx.Allocator.(*X86_64_Allocator).Frame.Pushed -= len(clobbered)
 	return result
 }
+
+// There are no push and pop instructions for the xmm registers, so those are
+// moved onto the stack instead.
+func pushRegister(reg *encoding.Register) []lib.Instruction {
+	if reg.Size == lib.OWORD {
+		return []lib.Instruction{
+			x86_64.SUB(encoding.Uint32(8), encoding.Rsp),
+			x86_64.MOV(reg, &encoding.DisplacedRegister{Register: encoding.Rsp, Displacement: 0}),
+		}
+	}
+	return []lib.Instruction{x86_64.PUSH(reg)}
+}
+
+func popRegister(reg *encoding.Register) []lib.Instruction {
+	if reg.Size == lib.OWORD {
+		return []lib.Instruction{
+			x86_64.MOV(&encoding.DisplacedRegister{Register: encoding.Rsp, Displacement: 0}, reg),
+			x86_64.ADD(encoding.Uint32(8), encoding.Rsp),
+		}
+	}
+	return []lib.Instruction{x86_64.POP(reg)}
+}
+
+// Returns where the pushed registers and the variables that live on top of
+// the stack end up, after the stack pointer moved down by the size of the
+// pushed registers and another offset bytes.
+func stackMapping(ctx *IR_Context, mapping map[lib.Operand]lib.Operand, pushed []lib.Operand, offset int) map[lib.Operand]lib.Operand {
+	result := map[lib.Operand]lib.Operand{}
+	for _, location := range ctx.VariableMap {
+		if stackOffset, ok := stackOperandOffset(location); ok {
+			result[location] = displaced(encoding.Rsp, stackOffset+len(pushed)*8+offset)
+		}
+	}
+	for reg, location := range mapping {
+		stackOffset, _ := stackOperandOffset(location)
+		result[reg] = displaced(encoding.Rsp, stackOffset+offset)
+	}
+	return result
+}
+
+// Returns the offset of an operand relative to the stack pointer.
+func stackOperandOffset(op lib.Operand) (int, bool) {
+	switch v := op.(type) {
+	case *encoding.DisplacedRegister:
+		return int(int8(v.Displacement)), v.Register == encoding.Rsp
+	case *encoding.DisplacedRegister32:
+		return int(v.Displacement), v.Register == encoding.Rsp
+	}
+	return 0, false
+}
+
+// Returns the memory operand at offset bytes from the address in base.
+func displaced(base *encoding.Register, offset int) lib.Operand {
+	if offset >= -128 && offset < 128 {
+		return &encoding.DisplacedRegister{Register: base, Displacement: uint8(offset)}
+	}
+	return &encoding.DisplacedRegister32{Register: base, Displacement: encoding.Int32(offset)}
+}
+
+func containsOperand(ops []lib.Operand, op lib.Operand) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
@@ -8,5 +8,8 @@ import (
 )
 
 func encode_IR_Add(i *expr.IR_Add, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	if IsPointer(i.Op1.ReturnType(ctx)) {
+		return encode_PointerOperator(i.Op1, i.Op2, x86_64.ADD, i.String(), ctx, target)
+	}
 	return encode_Operator(i.Op1, i.Op2, x86_64.ADD, i.String(), ctx, target)
 }
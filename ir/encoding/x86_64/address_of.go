@@ -0,0 +1,63 @@
+package x86_64
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_AddressOf(i *expr.IR_AddressOf, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	ctx.AddInstruction("address_of " + encoding.Comment(i.String()))
+	reg := target.(*encoding.Register).Get64BitRegister()
+	switch v := i.Value.(type) {
+	case *expr.IR_StructField:
+		_, offset, err := structField(v, ctx)
+		if err != nil {
+			return nil, err
+		}
+		result, err := encodeExpression(v.Struct, ctx, reg)
+		if err != nil {
+			return nil, err
+		}
+		lea := x86_64.LEA(displaced(reg, offset), reg)
+		ctx.AddInstruction(lea)
+		return append(result, lea), nil
+	case *expr.IR_ArrayIndex:
+		itemWidth := v.ReturnType(ctx).Width()
+		result, err := encodeExpression(v.Array, ctx, reg)
+		if err != nil {
+			return nil, err
+		}
+		indexReg := ctx.AllocateRegister(TUint64)
+		defer ctx.DeallocateRegister(indexReg)
+		index, err := encodeExpression(v.Index, ctx, indexReg)
+		if err != nil {
+			return nil, err
+		}
+		result = lib.Instructions(result).Add(index)
+		arrayType := v.Array.ReturnType(ctx)
+		result = lib.Instructions(result).Add(checkIndex(ctx, arrayType, reg, indexReg.(*encoding.Register)))
+		if IsSlice(arrayType) {
+			result = lib.Instructions(result).Add(sliceItems(ctx, reg, reg))
+		}
+		instr := []lib.Instruction{}
+		if shift := bits.TrailingZeros(uint(itemWidth)); shift > 0 {
+			instr = append(instr, x86_64.SHL(encoding.Uint8(shift), indexReg))
+		}
+		instr = append(instr, x86_64.ADD(indexReg, reg))
+		ctx.AddInstruction(instr...)
+		return lib.Instructions(result).Add(instr), nil
+	case *expr.IR_Dereference:
+		return encodeExpression(v.Pointer, ctx, reg)
+	}
+	if IsInline(i.Value.ReturnType(ctx)) {
+		// Structs and arrays with a size already evaluate to their address
+		return encodeExpression(i.Value, ctx, reg)
+	}
+	return nil, fmt.Errorf("Can't take the address of %s", i.Value.String())
+}
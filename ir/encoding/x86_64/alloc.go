@@ -0,0 +1,115 @@
+package x86_64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// The allocator hands out memory from the chunk that it mapped last, and
+// maps a new chunk when that runs out. The new chunk is big enough for the
+// allocation plus heapChunkSize bytes; the rest of the old one is lost.
+const heapChunkSize = 1 << 20
+
+// Returns a RIP relative operand pointing to the allocator state (see
+// HeapStateSize).
+func heapState() *encoding.RIPRelative {
+	return &encoding.RIPRelative{Label: lib.NewFixedLabel("heap_state", HeapStateAddress())}
+}
+
+// Encodes the allocator inline. The size is rounded up to a multiple of
+// sixteen bytes, so that every allocation is aligned.
+func encode_IR_Alloc(i *expr.IR_Alloc, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	ctx.AddInstruction("alloc " + encoding.Comment(i.String()))
+	countType := i.Count.ReturnType(ctx)
+	if !IsInteger(countType) || countType.Width() != lib.QUADWORD {
+		return nil, fmt.Errorf("Expecting a uint64 or int64 count in %s", i.String())
+	}
+	count := ctx.AllocateRegister(TUint64)
+	defer ctx.DeallocateRegister(count)
+	result, err := encodeExpression(i.Count, ctx, count)
+	if err != nil {
+		return nil, err
+	}
+
+	// The syscall overwrites rcx and r11, and the others are used for its
+	// arguments.
+	regs := []*encoding.Register{
+		encoding.Rax, encoding.Rcx, encoding.Rdx, encoding.Rsi, encoding.Rdi,
+		encoding.R8, encoding.R9, encoding.R10, encoding.R11,
+	}
+	preserve, _, clobbered := PreserveRegisters(ctx, regs)
+	result = lib.Instructions(result).Add(preserve)
+
+	fast := ctx.NewLabel("alloc_fast")
+	failed := ctx.NewLabel("alloc_failed")
+	done := ctx.NewLabel("alloc_done")
+	instr := []lib.Instruction{}
+	if count != encoding.Rsi {
+		instr = append(instr, x86_64.MOV(count, encoding.Rsi))
+	}
+	if size := Sizeof(i.ItemType); size != 1 {
+		instr = append(instr,
+			x86_64.MOV_immediate(uint64(size), encoding.Rax),
+			x86_64.IMUL2(encoding.Rax, encoding.Rsi),
+		)
+	}
+	instr = append(instr,
+		x86_64.ADD(encoding.Uint32(15), encoding.Rsi),
+		// 0xf0 gets sign extended to ~15
+		x86_64.AND(encoding.Uint8(0xf0), encoding.Rsi),
+
+		// rax = next free byte, rdx = the end of the allocation
+		x86_64.LEA(heapState(), encoding.Rdi),
+		x86_64.MOV(displaced(encoding.Rdi, 0), encoding.Rax),
+		x86_64.MOV(encoding.Rax, encoding.Rdx),
+		x86_64.ADD(encoding.Rsi, encoding.Rdx),
+		x86_64.CMP(displaced(encoding.Rdi, 8), encoding.Rdx),
+		x86_64.JBE(fast),
+
+		// mmap(NULL, size + heapChunkSize, PROT_READ | PROT_WRITE,
+		//      MAP_PRIVATE | MAP_ANONYMOUS, -1, 0)
+		x86_64.ADD(encoding.Uint32(heapChunkSize), encoding.Rsi),
+		x86_64.MOV_immediate(0, encoding.Rdi),
+		x86_64.MOV_immediate(3, encoding.Rdx),
+		x86_64.MOV_immediate(0x22, encoding.R10),
+		x86_64.MOV_immediate(0xffffffffffffffff, encoding.R8),
+		x86_64.MOV_immediate(0, encoding.R9),
+		x86_64.MOV_immediate(uint64(expr.IR_Syscall_Linux_Mmap), encoding.Rax),
+		x86_64.SYSCALL(),
+		// Errors are returned as -errno
+		x86_64.CMP_immediate(0xfffff000, encoding.Rax),
+		x86_64.JA(failed),
+		x86_64.LEA(heapState(), encoding.Rdi),
+		x86_64.MOV(encoding.Rax, encoding.Rdx),
+		x86_64.ADD(encoding.Rsi, encoding.Rdx),
+		x86_64.MOV(encoding.Rdx, displaced(encoding.Rdi, 8)),
+		x86_64.SUB(encoding.Uint32(heapChunkSize), encoding.Rsi),
+
+		lib.DefineLabel(fast),
+		x86_64.MOV(encoding.Rax, encoding.Rdx),
+		x86_64.ADD(encoding.Rsi, encoding.Rdx),
+		x86_64.MOV(encoding.Rdx, displaced(encoding.Rdi, 0)),
+		x86_64.JMP(done),
+
+		lib.DefineLabel(failed),
+		x86_64.MOV_immediate(0, encoding.Rax),
+		lib.DefineLabel(done),
+	)
+	ctx.AddInstruction(instr...)
+	result = lib.Instructions(result).Add(instr)
+
+	tmpTarget := ctx.AllocateRegister(TUint64)
+	defer ctx.DeallocateRegister(tmpTarget)
+	mov := x86_64.MOV(encoding.Rax, tmpTarget)
+	ctx.AddInstruction(mov)
+	result = append(result, mov)
+	result = lib.Instructions(result).Add(RestoreRegisters(ctx, clobbered))
+	mov = x86_64.MOV(tmpTarget, target)
+	ctx.AddInstruction(mov)
+	return append(result, mov), nil
+}
@@ -0,0 +1,503 @@
+package x86_64
+
+import (
+	"sort"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// The X86_64_Allocator hands out registers for variables and temporaries.
+//
+// Variables are allocated with a linear scan over their LiveRanges: before a
+// statement gets encoded the registers of variables that are no longer live
+// are released, spilled variables that are read by the statement are
+// reloaded, and if there aren't enough free registers left for the
+// statement's temporaries, the variables that stay live the longest are
+// spilled to rbp relative slots in the StackFrame.
+//
+// If, despite all that, the registers run out, temporaries are handed out as
+// stack slots instead.
+type X86_64_Allocator struct {
+	Registers               []bool
+	RegistersAllocated      uint8
+	FloatRegisters          []bool
+	FloatRegistersAllocated uint8
+
+	// The live ranges of the function that is being encoded. If nil,
+	// variables are never released or spilled.
+	LiveRanges *LiveRanges
+	// Shared between all the copies of this allocator.
+	Frame *StackFrame
+}
+
+func NewX86_64_Allocator() *X86_64_Allocator {
+	x := &X86_64_Allocator{
+		Registers:               make([]bool, 16),
+		RegistersAllocated:      0,
+		FloatRegisters:          make([]bool, 8), // the assembler doesn't support xmm8-15 yet
+		FloatRegistersAllocated: 0,
+		Frame:                   NewStackFrame(),
+	}
+	// Always allocate the stack and frame pointer so that they don't
+	// get overwritten.
+	x.Registers[4] = true // stack pointer
+	x.Registers[5] = true // frame pointer
+	x.RegistersAllocated = 2
+	return x
+}
+
+func (i *X86_64_Allocator) AllocateRegister(typ Type) lib.Operand {
+	if typ == TFloat64 {
+		if reg, ok := i.allocateFloatRegister(); ok {
+			return encoding.GetFloatingPointRegisterByIndex(reg)
+		}
+		return i.Frame.AllocateSlot(lib.QUADWORD)
+	}
+	if reg, ok := i.allocateRegister(); ok {
+		return encoding.Get64BitRegisterByIndex(reg).ForOperandWidth(typ.Width())
+	}
+	return i.Frame.AllocateSlot(typ.Width())
+}
+
+func (i *X86_64_Allocator) DeallocateRegister(op lib.Operand) {
+	reg, ok := op.(*encoding.Register)
+	if !ok {
+		return
+	}
+	if reg.Size == lib.OWORD {
+		i.deallocateFloatRegister(reg.Register)
+		return
+	}
+	i.deallocateRegister(reg.Register)
+}
+
+func (i *X86_64_Allocator) allocateRegister() (uint8, bool) {
+	for j := 0; j < len(i.Registers); j++ {
+		if !i.Registers[j] {
+			i.Registers[j] = true
+			i.RegistersAllocated += 1
+			if reg := encoding.Get64BitRegisterByIndex(uint8(j)); IsCalleeSaved(reg) {
+				i.Frame.Save(reg)
+			}
+			return uint8(j), true
+		}
+	}
+	return 0, false
+}
+
+func (i *X86_64_Allocator) deallocateRegister(reg uint8) {
+	if i.Registers[reg] {
+		i.Registers[reg] = false
+		i.RegistersAllocated -= 1
+	}
+}
+
+func (i *X86_64_Allocator) allocateFloatRegister() (uint8, bool) {
+	for j := 0; j < len(i.FloatRegisters); j++ {
+		if !i.FloatRegisters[j] {
+			i.FloatRegisters[j] = true
+			i.FloatRegistersAllocated += 1
+			return uint8(j), true
+		}
+	}
+	return 0, false
+}
+
+func (i *X86_64_Allocator) deallocateFloatRegister(reg uint8) {
+	if i.FloatRegisters[reg] {
+		i.FloatRegisters[reg] = false
+		i.FloatRegistersAllocated -= 1
+	}
+}
+
+// Marks the register as allocated, without it being handed out.
+func (i *X86_64_Allocator) reserveRegister(reg *encoding.Register) {
+	if i.inUse(reg) {
+		return
+	}
+	if reg.Size == lib.OWORD {
+		i.FloatRegisters[reg.Register] = true
+		i.FloatRegistersAllocated += 1
+		return
+	}
+	i.Registers[reg.Register] = true
+	i.RegistersAllocated += 1
+}
+
+func (i *X86_64_Allocator) inUse(reg *encoding.Register) bool {
+	if reg.Size == lib.OWORD {
+		return i.FloatRegisters[reg.Register]
+	}
+	return i.Registers[reg.Register]
+}
+
+func (i *X86_64_Allocator) freeRegisters(float bool) int {
+	if float {
+		return len(i.FloatRegisters) - int(i.FloatRegistersAllocated)
+	}
+	return len(i.Registers) - int(i.RegistersAllocated)
+}
+
+func (i *X86_64_Allocator) Copy() Allocator {
+	regs := make([]bool, len(i.Registers))
+	floatRegs := make([]bool, len(i.FloatRegisters))
+	copy(regs, i.Registers)
+	copy(floatRegs, i.FloatRegisters)
+	return &X86_64_Allocator{
+		Registers:               regs,
+		RegistersAllocated:      i.RegistersAllocated,
+		FloatRegisters:          floatRegs,
+		FloatRegistersAllocated: i.FloatRegistersAllocated,
+		LiveRanges:              i.LiveRanges,
+		Frame:                   i.Frame,
+	}
+}
+
+// The part of the stack frame below the frame pointer that holds spilled
+// variables and temporaries, followed by the callee-saved registers that
+// have to be preserved.
+type StackFrame struct {
+	Size  int
+	Slots map[string]lib.Operand
+	Saved []*encoding.Register
+	// The number of eightbytes that are currently pushed on top of the
+	// frame, which is used to keep the stack aligned at call sites.
+	Pushed int
+	// Where the function that is being encoded returns its value, and, if
+	// that's in memory, the slot holding the address of that memory.
+	Return        *ValueLocation
+	ReturnPointer lib.Operand
+	// The areas that hold the arrays and structs that are declared in the
+	// function (see GetArea).
+	Areas map[IRExpression]int
+}
+
+func NewStackFrame() *StackFrame {
+	return &StackFrame{
+		Slots: map[string]lib.Operand{},
+		Saved: []*encoding.Register{},
+		Areas: map[IRExpression]int{},
+	}
+}
+
+// Returns the slot in which the variable gets stored when it's spilled.
+func (s *StackFrame) GetSlot(variable string) lib.Operand {
+	slot, found := s.Slots[variable]
+	if !found {
+		slot = s.AllocateSlot(lib.QUADWORD)
+		s.Slots[variable] = slot
+	}
+	return slot
+}
+
+// Reserves eight bytes and returns them as an operand of the given width.
+func (s *StackFrame) AllocateSlot(width lib.Size) lib.Operand {
+	s.Size += 8
+	return frameSlot(s.Size, width)
+}
+
+// Reserves size bytes, rounded up to eightbytes, and returns the offset of
+// the area below the frame pointer. The area starts at the lowest address.
+func (s *StackFrame) AllocateArea(size int) int {
+	s.Size += (size + 7) &^ 7
+	return s.Size
+}
+
+// Returns the offset of the area that holds the value of the expression,
+// reserving size bytes the first time. Expressions can get encoded more than
+// once, e.g. to determine their length, and should end up in the same place.
+func (s *StackFrame) GetArea(e IRExpression, size int) int {
+	offset, found := s.Areas[e]
+	if !found {
+		offset = s.AllocateArea(size)
+		s.Areas[e] = offset
+	}
+	return offset
+}
+
+// Marks the callee-saved register as used, so that it gets preserved.
+func (s *StackFrame) Save(reg *encoding.Register) {
+	for _, r := range s.Saved {
+		if r == reg {
+			return
+		}
+	}
+	s.Saved = append(s.Saved, reg)
+}
+
+// Returns the slot in which the callee-saved register gets preserved.
+func (s *StackFrame) SaveSlot(ix int) lib.Operand {
+	return frameSlot(s.Size+(ix+1)*8, lib.QUADWORD)
+}
+
+// The size of the frame, rounded up to keep the stack pointer 16 byte aligned.
+func (s *StackFrame) AlignedSize() int {
+	return (s.Size + len(s.Saved)*8 + 15) &^ 15
+}
+
+func frameSlot(offset int, width lib.Size) lib.Operand {
+	return displaced(encoding.Rbp.ForOperandWidth(width), -offset)
+}
+
+func spill(reg *encoding.Register, slot lib.Operand) lib.Instruction {
+	if reg.Size == lib.OWORD {
+		return x86_64.MOV(reg, slot)
+	}
+	return x86_64.MOV(reg.Get64BitRegister(), slot)
+}
+
+func reload(slot lib.Operand, reg *encoding.Register) lib.Instruction {
+	if reg.Size == lib.OWORD {
+		return x86_64.MOV(slot, reg)
+	}
+	return x86_64.MOV(slot, reg.Get64BitRegister())
+}
+
+func sortedVariables(variables map[string]lib.Operand) []string {
+	result := make([]string, 0, len(variables))
+	for v := range variables {
+		result = append(result, v)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func containsVariable(variables []string, variable string) bool {
+	for _, v := range variables {
+		if v == variable {
+			return true
+		}
+	}
+	return false
+}
+
+// Spills the register variable of the given class that is live the longest,
+// unless it's in keep. Returns false if there's nothing left to spill.
+func spillVariable(ctx *IR_Context, allocator *X86_64_Allocator, float bool, keep []string) (lib.Instruction, bool) {
+	victim, victimEnd := "", -1
+	var victimReg *encoding.Register
+	for _, v := range sortedVariables(ctx.VariableMap) {
+		reg, ok := ctx.VariableMap[v].(*encoding.Register)
+		if !ok || (reg.Size == lib.OWORD) != float || containsVariable(keep, v) {
+			continue
+		}
+		if end := allocator.LiveRanges.End(v); end > victimEnd {
+			victim, victimEnd, victimReg = v, end, reg
+		}
+	}
+	if victimReg == nil {
+		return nil, false
+	}
+	slot := allocator.Frame.GetSlot(victim)
+	allocator.DeallocateRegister(victimReg)
+	ctx.VariableMap[victim] = slot
+	return spill(victimReg, slot), true
+}
+
+// Returns the number of registers the statement needs for temporaries and
+// newly assigned variables.
+func statementRegisterNeed(stmt IR) int {
+	switch v := stmt.(type) {
+	case *statements.IR_ArrayAssignment:
+		// The items of slices are loaded into a register of their own
+		return expressionRegisterNeed(v.Index) + expressionRegisterNeed(v.Expr) + 2
+	case *statements.IR_Assignment:
+		return expressionRegisterNeed(v.Expr) + 1
+	case *statements.IR_FunctionDef:
+		return 1
+	case *statements.IR_If:
+		return expressionRegisterNeed(v.Condition) + 2
+	case *statements.IR_PointerAssignment:
+		return expressionRegisterNeed(v.Pointer) + expressionRegisterNeed(v.Expr) + 1
+	case *statements.IR_Return:
+		return expressionRegisterNeed(v.Expr) + 2
+	case *statements.IR_While:
+		return expressionRegisterNeed(v.Condition) + 1
+	}
+	return 0
+}
+
+// Performs the linear scan step for the statement: releases the registers of
+// variables whose live range has ended, reloads the spilled variables that are
+// used by the statement, and spills variables until there are enough free
+// registers left to encode it.
+func allocateForStatement(stmt IR, ctx *IR_Context) ([]lib.Instruction, error) {
+	allocator, ok := ctx.Allocator.(*X86_64_Allocator)
+	if !ok || allocator.LiveRanges == nil {
+		return nil, nil
+	}
+	position, found := allocator.LiveRanges.Positions[stmt]
+	if !found {
+		return nil, nil
+	}
+	uses := allocator.LiveRanges.Uses[stmt]
+	result := []lib.Instruction{}
+
+	// Expire old variables
+	for _, v := range sortedVariables(ctx.VariableMap) {
+		if !allocator.LiveRanges.IsLiveAfter(v, position-1) {
+			allocator.DeallocateRegister(ctx.VariableMap[v])
+			delete(ctx.VariableMap, v)
+		}
+	}
+
+	// Reload the spilled variables used by this statement
+	for _, v := range uses {
+		slot, spilled := allocator.Frame.Slots[v]
+		typ, known := ctx.VariableTypes[v]
+		if !spilled || !known || ctx.VariableMap[v] != slot {
+			continue
+		}
+		float := typ == TFloat64
+		for allocator.freeRegisters(float) == 0 {
+			instr, ok := spillVariable(ctx, allocator, float, uses)
+			if !ok {
+				break
+			}
+			result = append(result, instr)
+		}
+		reg, ok := allocator.AllocateRegister(typ).(*encoding.Register)
+		if !ok {
+			continue
+		}
+		ctx.VariableMap[v] = reg
+		result = append(result, reload(slot, reg))
+	}
+
+	// Make room for the temporaries
+	need := statementRegisterNeed(stmt)
+	for _, float := range []bool{false, true} {
+		for allocator.freeRegisters(float) < need {
+			instr, ok := spillVariable(ctx, allocator, float, uses)
+			if !ok {
+				break
+			}
+			result = append(result, instr)
+		}
+	}
+	for _, instr := range result {
+		ctx.AddInstruction(instr)
+	}
+	return result, nil
+}
+
+// A record of where the variables were at the start of a branch.
+type allocationSnapshot struct {
+	variables map[string]lib.Operand
+	allocator *X86_64_Allocator
+	// The variables that were assigned in the branches and that are
+	// still live afterwards.
+	introduced map[string]lib.Operand
+}
+
+func snapshotAllocation(ctx *IR_Context) *allocationSnapshot {
+	allocator, ok := ctx.Allocator.(*X86_64_Allocator)
+	if !ok || allocator.LiveRanges == nil {
+		return nil
+	}
+	variables := map[string]lib.Operand{}
+	for v, op := range ctx.VariableMap {
+		variables[v] = op
+	}
+	return &allocationSnapshot{
+		variables:  variables,
+		allocator:  allocator.Copy().(*X86_64_Allocator),
+		introduced: map[string]lib.Operand{},
+	}
+}
+
+// Moves the variables back to where they were when the snapshot was taken,
+// so that the code following a branch doesn't have to care about the path
+// that was taken. Variables that were introduced in the branch, and that are
+// still live after position, are left in their stack slots; see
+// mergeIntroduced.
+func restoreAllocation(ctx *IR_Context, snapshot *allocationSnapshot, position int) []lib.Instruction {
+	allocator, ok := ctx.Allocator.(*X86_64_Allocator)
+	if !ok || snapshot == nil {
+		return nil
+	}
+	result := []lib.Instruction{}
+
+	// Store everything that's not in its original register
+	for _, v := range sortedVariables(ctx.VariableMap) {
+		op := ctx.VariableMap[v]
+		if _, found := snapshot.variables[v]; !found && allocator.LiveRanges.IsLiveAfter(v, position) {
+			snapshot.introduced[v] = allocator.Frame.GetSlot(v)
+		}
+		reg, ok := op.(*encoding.Register)
+		if !ok || snapshot.variables[v] == reg {
+			continue
+		}
+		if allocator.LiveRanges.IsLiveAfter(v, position) {
+			result = append(result, spill(reg, allocator.Frame.GetSlot(v)))
+		}
+	}
+	// And load it back into the original registers
+	for _, v := range sortedVariables(snapshot.variables) {
+		reg, ok := snapshot.variables[v].(*encoding.Register)
+		if !ok || ctx.VariableMap[v] == reg {
+			continue
+		}
+		slot, spilled := allocator.Frame.Slots[v]
+		if spilled && allocator.LiveRanges.IsLiveAfter(v, position) {
+			result = append(result, reload(slot, reg))
+		}
+	}
+
+	ctx.VariableMap = map[string]lib.Operand{}
+	for v, op := range snapshot.variables {
+		ctx.VariableMap[v] = op
+	}
+	restored := snapshot.allocator.Copy().(*X86_64_Allocator)
+	allocator.Registers = restored.Registers
+	allocator.RegistersAllocated = restored.RegistersAllocated
+	allocator.FloatRegisters = restored.FloatRegisters
+	allocator.FloatRegistersAllocated = restored.FloatRegistersAllocated
+	for _, instr := range result {
+		ctx.AddInstruction(instr)
+	}
+	return result
+}
+
+// Makes the variables that were introduced in the branches available to the
+// statements that follow. This should be called after all the branches have
+// been encoded, so that they all start out with the same allocation.
+func mergeIntroduced(ctx *IR_Context, snapshot *allocationSnapshot) {
+	if snapshot == nil {
+		return
+	}
+	for v, slot := range snapshot.introduced {
+		ctx.VariableMap[v] = slot
+	}
+}
+
+// Encodes a branch of an if statement or the body of a loop, and moves the
+// variables back to where they were in the snapshot afterwards.
+func encodeBranch(stmt IR, ctx *IR_Context, snapshot *allocationSnapshot, position int) ([]lib.Instruction, error) {
+	result, err := encodeStatement(stmt, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, restoreAllocation(ctx, snapshot, position)...), nil
+}
+
+// Returns the position at which the control flow continues after the statement.
+func statementEnd(stmt IR, ctx *IR_Context) int {
+	if allocator, ok := ctx.Allocator.(*X86_64_Allocator); ok && allocator.LiveRanges != nil {
+		return allocator.LiveRanges.Ends[stmt]
+	}
+	return 0
+}
+
+// Returns the position of the statement, or of the condition of an if or while
+// statement.
+func statementPosition(stmt IR, ctx *IR_Context) int {
+	if allocator, ok := ctx.Allocator.(*X86_64_Allocator); ok && allocator.LiveRanges != nil {
+		return allocator.LiveRanges.Positions[stmt]
+	}
+	return 0
+}
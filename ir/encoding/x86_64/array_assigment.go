@@ -37,6 +37,14 @@ func encode_IR_ArrayAssignment(i *statements.IR_ArrayAssignment, ctx *IR_Context
 	}
 	result = lib.Instructions(result).Add(exprInstr)
 
+	arrayType := ctx.VariableTypes[i.Variable]
+	result = lib.Instructions(result).Add(checkIndex(ctx, arrayType, reg.(*encoding.Register), indexReg.(*encoding.Register)))
+	if IsSlice(arrayType) {
+		items := ctx.AllocateRegister(TUint64)
+		defer ctx.DeallocateRegister(items)
+		result = lib.Instructions(result).Add(sliceItems(ctx, reg.(*encoding.Register), items.(*encoding.Register)))
+		reg = items
+	}
 	target := &encoding.SIBRegister{reg.(*encoding.Register), indexReg.(*encoding.Register), encoding.ScaleForItemWidth(itemWidth)}
 	mov := x86_64.MOV(exprReg.(*encoding.Register).ForOperandWidth(itemWidth), target)
 	ctx.AddInstruction(mov)
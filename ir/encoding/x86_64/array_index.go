@@ -13,6 +13,7 @@ import (
 func encode_IR_ArrayIndex(i *expr.IR_ArrayIndex, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
 	ctx.AddInstruction("array_index " + encoding.Comment(i.String()))
 
+	arrayType := i.Array.ReturnType(ctx)
 	itemWidth := i.ReturnType(ctx).Width()
 
 	var arrayReg, indexReg lib.Operand
@@ -33,8 +34,9 @@ func encode_IR_ArrayIndex(i *expr.IR_ArrayIndex, ctx *IR_Context, target lib.Ope
 		return nil, fmt.Errorf("Array encoding issue: %s", err.Error())
 	}
 
-	// Specialise for integers
-	if i.Index.Type() == Uint64 {
+	// Specialise for integers. The first item of an array with a size is
+	// always in bounds.
+	if i.Index.Type() == Uint64 && !IsSlice(arrayType) {
 		op := i.Index.(*expr.IR_Uint64)
 		if op.Value == 0 {
 			mov := x86_64.MOV(&encoding.IndirectRegister{
@@ -71,6 +73,13 @@ func encode_IR_ArrayIndex(i *expr.IR_ArrayIndex, ctx *IR_Context, target lib.Ope
 		return nil, fmt.Errorf("Array index encoding issue: %s", err.Error())
 	}
 	result = lib.Instructions(result).Add(index)
+	result = lib.Instructions(result).Add(checkIndex(ctx, arrayType, arrayReg.(*encoding.Register), indexReg.(*encoding.Register)))
+	if IsSlice(arrayType) {
+		items := ctx.AllocateRegister(TUint64)
+		defer ctx.DeallocateRegister(items)
+		result = lib.Instructions(result).Add(sliceItems(ctx, arrayReg.(*encoding.Register), items.(*encoding.Register)))
+		arrayReg = items
+	}
 	mov := x86_64.MOV(&encoding.SIBRegister{
 		arrayReg.(*encoding.Register),
 		indexReg.(*encoding.Register),
@@ -0,0 +1,23 @@
+package x86_64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_BitwiseNot(i *expr.IR_BitwiseNot, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	if returnType := i.Op1.ReturnType(ctx); !IsInteger(returnType) {
+		return nil, fmt.Errorf("Unsupported type %s in IR operation: %s", returnType, i.String())
+	}
+	result, err := encodeExpression(i.Op1, ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	not := x86_64.NOT(target)
+	ctx.AddInstruction(not)
+	return append(result, not), nil
+}
@@ -0,0 +1,53 @@
+package x86_64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Traps with an invalid opcode exception unless index < length, or index <=
+// length if inclusive is set. The values are compared as unsigned integers,
+// so negative indices are out of bounds as well. Nothing is checked when the
+// checks have been turned off (see IR_Context.NoBoundsChecks).
+func checkBounds(ctx *IR_Context, index *encoding.Register, length lib.Operand, inclusive bool) []lib.Instruction {
+	if ctx.NoBoundsChecks {
+		return nil
+	}
+	ok := ctx.NewLabel("in_bounds")
+	jump := x86_64.JB(ok)
+	if inclusive {
+		jump = x86_64.JBE(ok)
+	}
+	result := []lib.Instruction{
+		x86_64.CMP(length, index.Get64BitRegister()),
+		jump,
+		x86_64.UD2(),
+		lib.DefineLabel(ok),
+	}
+	ctx.AddInstruction(result...)
+	return result
+}
+
+// Checks the index against the length of a slice or the size of an array,
+// whose value is in reg. Arrays without a size aren't checked.
+func checkIndex(ctx *IR_Context, typ Type, reg, index *encoding.Register) []lib.Instruction {
+	switch ty := typ.(type) {
+	case *TSlice:
+		return checkBounds(ctx, index, displaced(reg, SliceLengthOffset), false)
+	case *TArray:
+		if ty.Size > 0 {
+			return checkBounds(ctx, index, encoding.Uint32(uint32(ty.Size)), false)
+		}
+	}
+	return nil
+}
+
+// Loads the address of the first item of the slice whose header is in
+// header into dest.
+func sliceItems(ctx *IR_Context, header, dest *encoding.Register) []lib.Instruction {
+	mov := x86_64.MOV(displaced(header, SliceItemsOffset), dest)
+	ctx.AddInstruction(mov)
+	return []lib.Instruction{mov}
+}
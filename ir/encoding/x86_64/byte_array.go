@@ -2,19 +2,17 @@ package x86_64
 
 import (
 	"github.com/bspaans/jit-compiler/asm/x86_64"
-	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
 	"github.com/bspaans/jit-compiler/ir/expr"
 	. "github.com/bspaans/jit-compiler/ir/shared"
 	"github.com/bspaans/jit-compiler/lib"
 )
 
 func encode_IR_ByteArray(i *expr.IR_ByteArray, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
-	// Calculate the displacement between RIP (the instruction pointer,
-	// pointing to the *next* instruction) and the address of our byte array,
-	// and load the resulting address into target using a LEA instruction.
-	ownLength := uint(7)
-	diff := uint(ctx.InstructionPointer+ownLength) - uint(ctx.Segments.GetAddress(i.Address))
-	result := []lib.Instruction{x86_64.LEA(&encoding.RIPRelative{encoding.Int32(int32(-diff))}, target)}
+	// Load the address of our byte array relative to RIP (the instruction
+	// pointer, pointing to the *next* instruction) into target using a LEA
+	// instruction. The displacement gets filled in when the instructions are
+	// resolved.
+	result := []lib.Instruction{x86_64.LEA(dataAddress(ctx, i.Address), target)}
 	ctx.AddInstruction(result...)
 	return result, nil
 }
@@ -11,35 +11,91 @@ import (
 )
 
 func encode_IR_Call(i *expr.IR_Call, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
-	result, mapping, clobbered, err := ABI_Call_Setup(ctx, i.Args, ctx.VariableTypes[i.Function].(*TFunction).ReturnType)
+	frame := ctx.Allocator.(*X86_64_Allocator).Frame
+	signature, err := i.CheckCall(ctx)
 	if err != nil {
 		return nil, err
 	}
-
 	function := ctx.VariableMap[i.Function]
 	if function == nil {
-		return nil, fmt.Errorf("Unknown function:" + i.Function)
+		return nil, fmt.Errorf("Unknown function: %s", i.Function)
+	}
+	returnType := signature.ReturnType
+	layout := ctx.ABI.ClassifyCall(signature.Args, returnType)
+
+	result, mapping, clobbered := PreserveRegisters(ctx, callerSavedRegisters)
+
+	// The stack pointer is 16 byte aligned after the prologue, but it has
+	// to be aligned at the call as well, so the pushed registers and the
+	// arguments on the stack are padded to an even number of eightbytes.
+	area := layout.StackSize
+	if (frame.Pushed+area/8)%2 == 1 {
+		area += 8
+	}
+	if area > 0 {
+		sub := x86_64.SUB(encoding.Uint32(uint32(area)), encoding.Rsp)
+		ctx.AddInstruction(sub)
+		result = append(result, sub)
+	}
+	frame.Pushed += area / 8
+	mapping = stackMapping(ctx, mapping, clobbered, area)
+
+	setup, err := ABI_Call_Setup(ctx, i.Args, layout, mapping)
+	if err != nil {
+		return nil, err
 	}
+	result = result.Add(setup)
 
 	// Use a different address for function if its location has been clobbered
 	if movedTarget, found := mapping[function]; found {
 		function = movedTarget
 	}
+	call := []lib.Instruction{x86_64.CALL(function)}
+	if area > 0 {
+		call = append(call, x86_64.ADD(encoding.Uint32(uint32(area)), encoding.Rsp))
+	}
+	frame.Pushed -= area / 8
+	ctx.AddInstruction(call...)
+	result = append(result, call...)
 
-	call := x86_64.CALL(function)
-	tmpReg := ctx.AllocateRegister(TUint64)
+	// Structs and slices are returned as the address of a copy in the stack
+	// frame
+	tmpType := returnType
+	if returnType.Type() == T_Struct {
+		tmpType = TUint64
+	}
+	tmpReg := ctx.AllocateRegister(tmpType)
 	defer ctx.DeallocateRegister(tmpReg)
-	mov := x86_64.MOV(encoding.Rax, tmpReg)
-	ctx.AddInstruction(call)
-	ctx.AddInstruction(mov)
-	result = append(result, call)
-	result = append(result, mov)
+	returned := []lib.Instruction{}
+	if layout.Return.InMemory() {
+		returned = append(returned, x86_64.MOV(encoding.Rax, tmpReg))
+	} else if returnType.Type() == T_Struct {
+		offset := frame.AllocateArea(layout.Return.Size)
+		for j, reg := range layout.Return.Registers {
+			returned = append(returned, spill(reg.(*encoding.Register), frameSlot(offset-j*8, lib.QUADWORD)))
+		}
+		returned = append(returned, x86_64.LEA(frameSlot(offset, lib.QUADWORD), tmpReg))
+	} else {
+		// Only the lower part of the register holds a narrower value
+		reg := layout.Return.Registers[0].(*encoding.Register)
+		returned = append(returned, x86_64.MOV(reg.ForOperandWidth(tmpReg.Width()), tmpReg))
+	}
+	ctx.AddInstruction(returned...)
+	result = append(result, returned...)
 
 	restore := RestoreRegisters(ctx, clobbered)
 	result = result.Add(restore)
 
-	mov = x86_64.MOV(tmpReg, target)
+	mov := x86_64.MOV(tmpReg, target)
 	ctx.AddInstruction(mov)
 	result = append(result, mov)
 	return result, nil
 }
+
+// The registers that the called functions are free to overwrite.
+var callerSavedRegisters = []*encoding.Register{
+	encoding.Rax, encoding.Rcx, encoding.Rdx, encoding.Rsi, encoding.Rdi,
+	encoding.R8, encoding.R9, encoding.R10, encoding.R11,
+	encoding.Xmm0, encoding.Xmm1, encoding.Xmm2, encoding.Xmm3,
+	encoding.Xmm4, encoding.Xmm5, encoding.Xmm6, encoding.Xmm7,
+}
@@ -17,6 +17,10 @@ func encode_IR_Cast(i *expr.IR_Cast, ctx *IR_Context, target lib.Operand) ([]lib
 	if valueType == nil {
 		return nil, fmt.Errorf("nil return type in %s", i.Value.String())
 	}
+	// Pointers and uint64s only differ in their type
+	if (IsPointer(i.CastToType) || i.CastToType == TUint64) && (IsPointer(valueType) || valueType == TUint64) {
+		return encodeExpression(i.Value, ctx, target)
+	}
 	// TODO: use movsx and movzx
 	if i.CastToType == TUint64 {
 		if valueType == TUint64 {
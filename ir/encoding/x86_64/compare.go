@@ -10,52 +10,175 @@ import (
 	"github.com/bspaans/jit-compiler/lib"
 )
 
-func compare(op1, op2 IRExpression, ctx *IR_Context) ([]lib.Instruction, error) {
+type comparison int
+
+const (
+	equals comparison = iota
+	lessThan
+	lessThanOrEqual
+	greaterThan
+	greaterThanOrEqual
+)
+
+type orderOpcode func(lib.Operand) lib.Instruction
+
+// The instructions that set a byte, jump if the comparison holds, and jump
+// if it doesn't.
+type conditionCode struct {
+	set, jump, jumpNot orderOpcode
+}
+
+var signedConditionCodes = map[comparison]conditionCode{
+	equals:             {x86_64.SETE, x86_64.JE, x86_64.JNE},
+	lessThan:           {x86_64.SETL, x86_64.JL, x86_64.JNL},
+	lessThanOrEqual:    {x86_64.SETLE, x86_64.JLE, x86_64.JNLE},
+	greaterThan:        {x86_64.SETG, x86_64.JG, x86_64.JNG},
+	greaterThanOrEqual: {x86_64.SETGE, x86_64.JGE, x86_64.JNGE},
+}
+
+// Also used for float64s, which set the flags like unsigned integers.
+var unsignedConditionCodes = map[comparison]conditionCode{
+	equals:             {x86_64.SETE, x86_64.JE, x86_64.JNE},
+	lessThan:           {x86_64.SETB, x86_64.JB, x86_64.JNB},
+	lessThanOrEqual:    {x86_64.SETBE, x86_64.JBE, x86_64.JNBE},
+	greaterThan:        {x86_64.SETA, x86_64.JA, x86_64.JNA},
+	greaterThanOrEqual: {x86_64.SETAE, x86_64.JAE, x86_64.JNAE},
+}
+
+func conditionCodes(typ Type, cmp comparison) conditionCode {
+	if IsSignedInteger(typ) {
+		return signedConditionCodes[cmp]
+	}
+	return unsignedConditionCodes[cmp]
+}
+
+// Returns the operands and the kind of a comparison expression.
+func comparisonOperands(e IRExpression) (IRExpression, IRExpression, comparison, bool) {
+	switch c := e.(type) {
+	case *expr.IR_Equals:
+		return c.Op1, c.Op2, equals, true
+	case *expr.IR_LT:
+		return c.Op1, c.Op2, lessThan, true
+	case *expr.IR_LTE:
+		return c.Op1, c.Op2, lessThanOrEqual, true
+	case *expr.IR_GT:
+		return c.Op1, c.Op2, greaterThan, true
+	case *expr.IR_GTE:
+		return c.Op1, c.Op2, greaterThanOrEqual, true
+	}
+	return nil, nil, equals, false
+}
+
+func compareOperand(op IRExpression, ctx *IR_Context, typ Type) ([]lib.Instruction, lib.Operand, func(), error) {
+	if op.Type() == Variable {
+		variable := op.(*expr.IR_Variable).Value
+		reg := ctx.VariableMap[variable]
+		if _, isRegister := reg.(*encoding.Register); isRegister || typ != TFloat64 {
+			return nil, reg, func() {}, nil
+		}
+	}
+	reg := ctx.AllocateRegister(typ)
+	instr, err := encodeExpression(op, ctx, reg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return instr, reg, func() { ctx.DeallocateRegister(reg) }, nil
+}
+
+// Sets the flags by comparing op1 with op2. Returns the comparison that the
+// flags have to be tested for.
+//
+// float64s are compared with COMISD, or UCOMISD for ==, which set ZF, PF and
+// CF when either operand is NaN. Only the "above" conditions are false in
+// that case, so < and <= are turned into > and >= by swapping the operands,
+// and == has to check PF as well (see setCondition and jumpCondition).
+func compare(op1, op2 IRExpression, ctx *IR_Context, cmp comparison) ([]lib.Instruction, comparison, error) {
 
-	result := []lib.Instruction{}
 	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
 	if returnType1 != returnType2 {
-		return nil, fmt.Errorf("Unsupported types (%s, %s) in compare operation", returnType1, returnType2)
+		return nil, cmp, fmt.Errorf("Unsupported types (%s, %s) in compare operation", returnType1, returnType2)
 	}
 
-	var reg1, reg2 lib.Operand
+	result, reg1, free1, err := compareOperand(op1, ctx, returnType1)
+	if err != nil {
+		return nil, cmp, err
+	}
+	defer free1()
+	instr, reg2, free2, err := compareOperand(op2, ctx, returnType1)
+	if err != nil {
+		return nil, cmp, err
+	}
+	defer free2()
+	result = lib.Instructions(result).Add(instr)
 
-	if op1.Type() == Variable {
-		variable := op1.(*expr.IR_Variable).Value
-		reg1 = ctx.VariableMap[variable]
-	} else {
-		reg1 = ctx.AllocateRegister(returnType1)
-		defer ctx.DeallocateRegister(reg1.(*encoding.Register))
-		expr1, err := encodeExpression(op1, ctx, reg1)
-		if err != nil {
-			return nil, err
+	var cmpInstr lib.Instruction
+	if returnType1 == TFloat64 {
+		switch cmp {
+		case lessThan:
+			reg1, reg2, cmp = reg2, reg1, greaterThan
+		case lessThanOrEqual:
+			reg1, reg2, cmp = reg2, reg1, greaterThanOrEqual
 		}
-		result = lib.Instructions(result).Add(expr1)
+		if cmp == equals {
+			cmpInstr = x86_64.UCOMISD(reg2, reg1)
+		} else {
+			cmpInstr = x86_64.COMISD(reg2, reg1)
+		}
+	} else {
+		cmpInstr = x86_64.CMP(reg2, reg1)
 	}
+	result = append(result, cmpInstr)
+	ctx.AddInstruction(cmpInstr)
+	return result, cmp, nil
+}
 
-	if op2.Type() == Variable {
-		variable := op2.(*expr.IR_Variable).Value
-		reg2 = ctx.VariableMap[variable]
-	} else {
-		reg2 = ctx.AllocateRegister(returnType1)
-		defer ctx.DeallocateRegister(reg2.(*encoding.Register))
-		expr2, err := encodeExpression(op2, ctx, reg2)
-		if err != nil {
-			return nil, err
-		}
-		result = lib.Instructions(result).Add(expr2)
+// Sets reg8 to 1 if the flags hold the comparison and to 0 otherwise.
+func setCondition(ctx *IR_Context, typ Type, cmp comparison, reg8 lib.Operand) []lib.Instruction {
+	result := []lib.Instruction{conditionCodes(typ, cmp).set(reg8)}
+	if typ == TFloat64 && cmp == equals {
+		parity := ctx.AllocateRegister(TUint64)
+		defer ctx.DeallocateRegister(parity)
+		parity8 := parity.(*encoding.Register).Get8BitRegister()
+		result = append(result,
+			x86_64.SETNP(parity8),
+			x86_64.AND(parity8, reg8),
+		)
 	}
-	cmp := x86_64.CMP(reg2, reg1)
-	result = append(result, cmp)
-	ctx.AddInstruction(cmp)
-	return result, nil
+	ctx.AddInstruction(result...)
+	return result
 }
 
-type orderOpcode func(lib.Operand) lib.Instruction
+// Jumps to the label if the flags hold the comparison and jumpIf is true,
+// or if they don't and jumpIf is false.
+func jumpCondition(ctx *IR_Context, typ Type, cmp comparison, label *lib.Label, jumpIf bool) []lib.Instruction {
+	codes := conditionCodes(typ, cmp)
+	var result []lib.Instruction
+	if typ == TFloat64 && cmp == equals {
+		if jumpIf {
+			unordered := ctx.NewLabel("unordered")
+			result = []lib.Instruction{
+				x86_64.JP(unordered),
+				x86_64.JE(label),
+				lib.DefineLabel(unordered),
+			}
+		} else {
+			result = []lib.Instruction{
+				x86_64.JP(label),
+				x86_64.JNE(label),
+			}
+		}
+	} else if jumpIf {
+		result = []lib.Instruction{codes.jump(label)}
+	} else {
+		result = []lib.Instruction{codes.jumpNot(label)}
+	}
+	ctx.AddInstruction(result...)
+	return result
+}
 
-func order(op1, op2 IRExpression, ctx *IR_Context, target lib.Operand, includeSETE bool, repr string, unsignedOp, signedOp orderOpcode) ([]lib.Instruction, error) {
+func order(op1, op2 IRExpression, ctx *IR_Context, target lib.Operand, includeSETE bool, repr string, cmp comparison) ([]lib.Instruction, error) {
 
-	result, err := compare(op1, op2, ctx)
+	result, cmp, err := compare(op1, op2, ctx, cmp)
 	if err != nil {
 		return nil, fmt.Errorf("%s in %s", err.Error(), repr)
 	}
@@ -63,14 +186,9 @@ func order(op1, op2 IRExpression, ctx *IR_Context, target lib.Operand, includeSE
 		tmpReg := ctx.AllocateRegister(TUint64)
 		defer ctx.DeallocateRegister(tmpReg)
 		reg8 := tmpReg.(*encoding.Register).Get8BitRegister()
-		sete := unsignedOp(reg8)
-		if IsSignedInteger(op1.ReturnType(ctx)) {
-			sete = signedOp(reg8)
-		}
+		result = append(result, setCondition(ctx, op1.ReturnType(ctx), cmp, reg8)...)
 		mov := x86_64.MOV(tmpReg.(*encoding.Register).ForOperandWidth(target.Width()), target)
-		result = append(result, sete)
 		result = append(result, mov)
-		ctx.AddInstruction(sete)
 		ctx.AddInstruction(mov)
 	}
 	return result, nil
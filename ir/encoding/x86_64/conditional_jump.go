@@ -4,106 +4,125 @@ import (
 	"fmt"
 
 	"github.com/bspaans/jit-compiler/asm/x86_64"
-	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
 	"github.com/bspaans/jit-compiler/ir/expr"
 	. "github.com/bspaans/jit-compiler/ir/shared"
 	"github.com/bspaans/jit-compiler/lib"
 )
 
-func conditionalJump(ctx *IR_Context, condition IRExpression, stmtLen int) ([]lib.Instruction, error) {
-
-	jmpSize := 2
-	reg := ctx.AllocateRegister(TBool)
-	defer ctx.DeallocateRegister(reg)
+// Jumps to the label if the condition is false. The operands of && and ||
+// are branched on one by one, so that the second operand is only evaluated
+// when it decides the outcome.
+func conditionalJump(ctx *IR_Context, condition IRExpression, label *lib.Label) ([]lib.Instruction, error) {
+	return branch(ctx, condition, label, false)
+}
 
-	var result []lib.Instruction
-	var instr []lib.Instruction
-	var err error
-	switch condition.(type) {
-	case *expr.IR_Equals:
-		c := condition.(*expr.IR_Equals)
-		result, err = encode_IR_Equals(c, ctx, reg, false)
-		instr = []lib.Instruction{
-			x86_64.JNE(encoding.Uint8(stmtLen + jmpSize)),
-		}
-	case *expr.IR_LT:
-		c := condition.(*expr.IR_LT)
-		result, err = encode_IR_LT(c, ctx, reg, false)
-		if IsSignedInteger(c.Op1.ReturnType(ctx)) {
-			instr = []lib.Instruction{
-				x86_64.JNL(encoding.Uint8(stmtLen + jmpSize)),
-			}
-		} else {
-			instr = []lib.Instruction{
-				x86_64.JNB(encoding.Uint8(stmtLen + jmpSize)),
-			}
-		}
-	case *expr.IR_LTE:
-		c := condition.(*expr.IR_LTE)
-		result, err = encode_IR_LTE(c, ctx, reg, false)
-		if IsSignedInteger(c.Op1.ReturnType(ctx)) {
-			instr = []lib.Instruction{
-				x86_64.JNLE(encoding.Uint8(stmtLen + jmpSize)),
-			}
-		} else {
-			instr = []lib.Instruction{
-				x86_64.JNBE(encoding.Uint8(stmtLen + jmpSize)),
-			}
-		}
-	case *expr.IR_GT:
-		c := condition.(*expr.IR_GT)
-		result, err = encode_IR_GT(c, ctx, reg, false)
-		if IsSignedInteger(c.Op1.ReturnType(ctx)) {
-			instr = []lib.Instruction{
-				x86_64.JNG(encoding.Uint8(stmtLen + jmpSize)),
-			}
-		} else {
-			instr = []lib.Instruction{
-				x86_64.JNA(encoding.Uint8(stmtLen + jmpSize)),
-			}
-		}
-	case *expr.IR_GTE:
-		c := condition.(*expr.IR_GTE)
-		result, err = encode_IR_GTE(c, ctx, reg, false)
-		if IsSignedInteger(c.Op1.ReturnType(ctx)) {
-			instr = []lib.Instruction{
-				x86_64.JNGE(encoding.Uint8(stmtLen + jmpSize)),
-			}
-		} else {
-			instr = []lib.Instruction{
-				x86_64.JNAE(encoding.Uint8(stmtLen + jmpSize)),
-			}
-		}
-	case *expr.IR_Not:
-		result, err = encode_IR_Not(condition.(*expr.IR_Not), ctx, reg, false)
-		instr = []lib.Instruction{
-			x86_64.JE(encoding.Uint8(stmtLen + jmpSize)),
-		}
+// Jumps to the label if the condition evaluates to jumpIf.
+func branch(ctx *IR_Context, condition IRExpression, label *lib.Label, jumpIf bool) ([]lib.Instruction, error) {
+	switch c := condition.(type) {
 	case *expr.IR_And:
-		result, err = encode_IR_And(condition.(*expr.IR_And), ctx, reg)
-		instr = []lib.Instruction{
-			x86_64.JE(encoding.Uint8(stmtLen + jmpSize)),
+		if err := checkLogicalOperands(ctx, c.Op1, c.Op2, "&&", c.String()); err != nil {
+			return nil, err
 		}
+		return branchLogical(ctx, c.Op1, c.Op2, label, jumpIf, false)
 	case *expr.IR_Or:
-		result, err = encode_IR_Or(condition.(*expr.IR_Or), ctx, reg)
-		instr = []lib.Instruction{
-			x86_64.JE(encoding.Uint8(stmtLen + jmpSize)),
+		if err := checkLogicalOperands(ctx, c.Op1, c.Op2, "||", c.String()); err != nil {
+			return nil, err
 		}
-	case *expr.IR_Bool, *expr.IR_Variable:
-		result, err = encodeExpression(condition, ctx, reg)
-		instr = []lib.Instruction{
-			x86_64.CMP_immediate(1, reg),
-			x86_64.JNE(encoding.Uint8(stmtLen + jmpSize)),
+		return branchLogical(ctx, c.Op1, c.Op2, label, jumpIf, true)
+	case *expr.IR_Not:
+		return branch(ctx, c.Op1, label, !jumpIf)
+	}
+
+	if op1, op2, cmp, ok := comparisonOperands(condition); ok {
+		result, cmp, err := compare(op1, op2, ctx, cmp)
+		if err != nil {
+			return nil, fmt.Errorf("%s in %s", err.Error(), condition.String())
 		}
-	default:
+		return append(result, jumpCondition(ctx, op1.ReturnType(ctx), cmp, label, jumpIf)...), nil
+	}
+
+	if condition.ReturnType(ctx) != TBool {
 		return nil, fmt.Errorf("Unsupported condition %s (type: %v)", condition.String(), condition.Type())
 	}
+	reg := ctx.AllocateRegister(TBool)
+	defer ctx.DeallocateRegister(reg)
+	result, err := encodeExpression(condition, ctx, reg)
+	if err != nil {
+		return nil, err
+	}
+	instr := []lib.Instruction{x86_64.CMP_immediate(1, reg)}
+	if jumpIf {
+		instr = append(instr, x86_64.JE(label))
+	} else {
+		instr = append(instr, x86_64.JNE(label))
+	}
+	ctx.AddInstruction(instr...)
+	return append(result, instr...), nil
+}
+
+// Branches on op1 && op2, or on op1 || op2 if isOr is true. If the first
+// operand already decides the outcome, the second one is skipped.
+func branchLogical(ctx *IR_Context, op1, op2 IRExpression, label *lib.Label, jumpIf, isOr bool) ([]lib.Instruction, error) {
+	if jumpIf == isOr {
+		// Either operand decides the outcome on its own
+		result, err := branch(ctx, op1, label, jumpIf)
+		if err != nil {
+			return nil, err
+		}
+		instr, err := branch(ctx, op2, label, jumpIf)
+		if err != nil {
+			return nil, err
+		}
+		return append(result, instr...), nil
+	}
+	skip := ctx.NewLabel("skip")
+	result, err := branch(ctx, op1, skip, isOr)
+	if err != nil {
+		return nil, err
+	}
+	instr, err := branch(ctx, op2, label, jumpIf)
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, instr...)
+	definition := lib.DefineLabel(skip)
+	ctx.AddInstruction(definition)
+	return append(result, definition), nil
+}
+
+// Evaluates the condition into target as a bool, by branching on it.
+func encodeConditionValue(ctx *IR_Context, condition IRExpression, target lib.Operand) ([]lib.Instruction, error) {
+	falseLabel := ctx.NewLabel("false")
+	end := ctx.NewLabel("end_condition")
+	result, err := conditionalJump(ctx, condition, falseLabel)
+	if err != nil {
+		return nil, err
+	}
+	instr, err := encodeExpression(expr.NewIR_Bool(true), ctx, target)
 	if err != nil {
 		return nil, err
 	}
-	for _, inst := range instr {
-		ctx.AddInstruction(inst)
-		result = append(result, inst)
+	result = append(result, instr...)
+	instr = []lib.Instruction{
+		x86_64.JMP(end),
+		lib.DefineLabel(falseLabel),
+	}
+	ctx.AddInstruction(instr...)
+	result = append(result, instr...)
+	instr, err = encodeExpression(expr.NewIR_Bool(false), ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, instr...)
+	definition := lib.DefineLabel(end)
+	ctx.AddInstruction(definition)
+	return append(result, definition), nil
+}
+
+func checkLogicalOperands(ctx *IR_Context, op1, op2 IRExpression, operator, repr string) error {
+	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
+	if returnType1 != TBool || returnType2 != TBool {
+		return fmt.Errorf("Unsupported types (%s, %s) in %s IR operation: %s", returnType1, returnType2, operator, repr)
 	}
-	return result, nil
+	return nil
 }
@@ -0,0 +1,24 @@
+package x86_64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Dereference(i *expr.IR_Dereference, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	ctx.AddInstruction("dereference " + encoding.Comment(i.String()))
+	typ := i.ReturnType(ctx)
+	if IsInline(typ) {
+		// Structs and arrays evaluate to their address
+		return encodeExpression(i.Pointer, ctx, target)
+	}
+	tmpReg := ctx.AllocateRegister(TUint64)
+	defer ctx.DeallocateRegister(tmpReg)
+	result, err := encodeExpression(i.Pointer, ctx, tmpReg)
+	if err != nil {
+		return nil, err
+	}
+	return lib.Instructions(result).Add(loadFromMemory(ctx, tmpReg.(*encoding.Register), 0, typ, target)), nil
+}
@@ -0,0 +1,20 @@
+package x86_64
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Maps the function to the slot that holds its address, so that it gets
+// called indirectly through the slot (see encode_IR_Call).
+func encode_IR_Extern(i *statements.IR_Extern, ctx *IR_Context) ([]lib.Instruction, error) {
+	if ctx.Segments == nil || i.Address == nil {
+		return nil, fmt.Errorf("Extern declarations are only supported at the top level: %s", i.String())
+	}
+	ctx.VariableTypes[i.Name] = i.Signature
+	ctx.VariableMap[i.Name] = dataAddress(ctx, i.Address)
+	return nil, nil
+}
@@ -8,12 +8,18 @@ import (
 	"github.com/bspaans/jit-compiler/lib"
 )
 
+// Loads the bits of the float into a general purpose register, from the
+// read-only data if the float has been added to it, and moves them to target.
 func encode_IR_Float64(i *expr.IR_Float64, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
 	tmp := ctx.AllocateRegister(TUint64)
 	defer ctx.DeallocateRegister(tmp)
 
+	var value lib.Operand = encoding.Float64(i.Value)
+	if i.Address != nil {
+		value = dataAddress(ctx, i.Address)
+	}
 	result := []lib.Instruction{
-		x86_64.MOV(encoding.Float64(i.Value), tmp),
+		x86_64.MOV(value, tmp),
 		x86_64.MOV(tmp, target),
 	}
 	ctx.AddInstruction(result...)
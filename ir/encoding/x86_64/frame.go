@@ -0,0 +1,104 @@
+package x86_64
+
+import (
+	"strings"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Sets up the live ranges for the statements and returns the instructions
+// that set up the stack frame:
+//
+//	push %rbp
+//	mov %rsp, %rbp
+//	and $-16, %rsp
+//	sub $frame, %rsp
+//	mov <callee-saved>, offset(%rbp)
+//
+// The stack pointer is aligned explicitly, because not every caller (e.g.
+// Go) keeps it aligned. The size of the frame and the registers that have
+// to be preserved are only known after all the statements have been
+// encoded, so the prologue should be encoded last.
+func encodePrologue(stmts []IR, args []string, ctx *IR_Context) []lib.Instruction {
+	allocator := ctx.Allocator.(*X86_64_Allocator)
+	allocator.LiveRanges = NewLiveRanges(stmts, args)
+	result := []lib.Instruction{
+		x86_64.PUSH(encoding.Rbp),
+		x86_64.MOV(encoding.Rsp, encoding.Rbp),
+		x86_64.AND(encoding.Uint8(0xf0), encoding.Rsp),
+		&frameAllocation{allocator.Frame},
+		&calleeSavedRegisters{allocator.Frame, false},
+	}
+	ctx.AddInstruction(result...)
+	return result
+}
+
+// Undoes the prologue, leaving the stack pointer where it was on entry.
+func encodeEpilogue(ctx *IR_Context) []lib.Instruction {
+	allocator := ctx.Allocator.(*X86_64_Allocator)
+	result := []lib.Instruction{
+		&calleeSavedRegisters{allocator.Frame, true},
+		x86_64.MOV(encoding.Rbp, encoding.Rsp),
+		x86_64.POP(encoding.Rbp),
+	}
+	ctx.AddInstruction(result...)
+	return result
+}
+
+// Reserves the space for the stack frame. Always uses a 32 bit immediate so
+// that the length of the instruction doesn't change when the frame grows.
+type frameAllocation struct {
+	Frame *StackFrame
+}
+
+func (f *frameAllocation) instruction() lib.Instruction {
+	return x86_64.SUB(encoding.Uint32(f.Frame.AlignedSize()), encoding.Rsp)
+}
+
+func (f *frameAllocation) Encode() (lib.MachineCode, error) {
+	return f.instruction().Encode()
+}
+
+func (f *frameAllocation) String() string {
+	return f.instruction().String()
+}
+
+// Stores or restores the callee-saved registers that have been allocated.
+type calleeSavedRegisters struct {
+	Frame   *StackFrame
+	Restore bool
+}
+
+func (c *calleeSavedRegisters) instructions() []lib.Instruction {
+	result := []lib.Instruction{}
+	for i, reg := range c.Frame.Saved {
+		if c.Restore {
+			result = append(result, x86_64.MOV(c.Frame.SaveSlot(i), reg))
+		} else {
+			result = append(result, x86_64.MOV(reg, c.Frame.SaveSlot(i)))
+		}
+	}
+	return result
+}
+
+func (c *calleeSavedRegisters) Encode() (lib.MachineCode, error) {
+	return lib.Instructions(c.instructions()).Encode()
+}
+
+func (c *calleeSavedRegisters) String() string {
+	instr := c.instructions()
+	if len(instr) == 0 {
+		if c.Restore {
+			return "; no callee-saved registers to restore"
+		}
+		return "; no callee-saved registers to preserve"
+	}
+	lines := []string{}
+	for _, i := range instr {
+		lines = append(lines, i.String())
+	}
+	return strings.Join(lines, "\n")
+}
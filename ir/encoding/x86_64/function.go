@@ -7,54 +7,137 @@ import (
 	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
 	"github.com/bspaans/jit-compiler/ir/expr"
 	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
 	"github.com/bspaans/jit-compiler/lib"
 )
 
 func encode_IR_Function(i *expr.IR_Function, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
-	ownLength := uint(7)
-	diff := uint(ctx.InstructionPointer+ownLength) - uint(ctx.Segments.GetAddress(i.Address))
-	result := []lib.Instruction{x86_64.LEA(&encoding.RIPRelative{encoding.Int32(int32(-diff))}, target)}
+	// Load the address of the function into target (see encode_IR_ByteArray)
+	result := []lib.Instruction{x86_64.LEA(dataAddress(ctx, i.Address), target)}
 	ctx.AddInstruction(result...)
 	return result, nil
 }
 
-func encode_IR_Function_for_DataSection(b *expr.IR_Function, ctx *IR_Context, segments *Segments) error {
+// Encodes the function with its own stack frame, so that it can be called
+// from other code that follows the calling convention; the callee-saved
+// registers it uses are preserved by the prologue and epilogue.
+//
+// Arguments that are passed on the stack are read from the caller's frame,
+// and structs that are passed in registers are stored in the function's own
+// frame, so that struct arguments are addresses like any other struct.
+//
+// The function can call the functions and extern functions that are declared
+// before it, and itself.
+func encode_IR_Function_for_DataSection(b *expr.IR_Function, ctx *IR_Context, segments *Segments, declarations []*declaration) error {
+	if b.Address == nil {
+		b.Address = &SegmentPointer{SegmentType: Executable}
+	}
+	b.Address.Offset = uint(len(segments.Segments[Executable].Data))
 
-	// TODO: restore rbx, rbp, r12-r15
-	targets := []*encoding.Register{encoding.Rdi, encoding.Rsi, encoding.Rdx, encoding.R10, encoding.R8, encoding.R9}
-	returnTarget := encoding.Rax
-	registers := make([]bool, 16)
-	registers[returnTarget.Register] = true
-	variableMap := map[string]lib.Operand{}
-	variableTypes := map[string]Type{}
-	for i, arg := range b.Signature.Args {
-		if arg.Type() == T_Float64 {
-			return fmt.Errorf("Float arguments not supported")
+	layout := ctx.ABI.ClassifyCall(b.Signature.Args, b.Signature.ReturnType)
+	returnTarget := ctx.ABI.ReturnTypeToOperand(b.Signature.ReturnType)
+	allocator := NewX86_64_Allocator()
+	allocator.reserveRegister(encoding.Rax)
+	allocator.Frame.Return = layout.Return
+	if layout.ReturnPointer != nil {
+		allocator.reserveRegister(layout.ReturnPointer.(*encoding.Register))
+	}
+	for _, location := range layout.Args {
+		for _, reg := range location.Registers {
+			allocator.reserveRegister(reg.(*encoding.Register))
 		}
-		v := b.Signature.ArgNames[i]
-		registers[targets[i].Register] = true
-		variableMap[v] = targets[i]
-		variableTypes[v] = arg
 	}
 
 	ctx_ := ctx.Copy()
 	ctx_.PushReturnOperand(returnTarget)
 	ctx_.Commit = false
-	ctx_.Allocator.(*X86_64_Allocator).Registers = registers
-	ctx_.Allocator.(*X86_64_Allocator).RegistersAllocated = uint8(len(b.Signature.Args) + 1)
-	ctx_.VariableMap = variableMap
-	ctx_.VariableTypes = variableTypes
+	ctx_.Allocator = allocator
+	ctx_.Segments = segments
+	ctx_.VariableMap = map[string]lib.Operand{}
+	ctx_.VariableTypes = map[string]Type{}
+	assigned := statements.AssignedVariables(b.Body)
+	for _, d := range declarations {
+		if assigned[d.Name] {
+			// The variable hides the function
+			continue
+		}
+		ctx_.VariableTypes[d.Name] = d.Signature
+		if d.Address.SegmentType == Executable {
+			// Functions are called directly (see encode_IR_Call)
+			address := segments.GetAddress(d.Address)
+			ctx_.VariableMap[d.Name] = lib.NewFixedLabel(fmt.Sprintf("function_0x%x", address), address)
+		} else {
+			ctx_.VariableMap[d.Name] = dataAddress(ctx_, d.Address)
+		}
+	}
+	prologue := encodePrologue([]IR{b.Body}, b.Signature.ArgNames, ctx_)
+
+	args := []lib.Instruction{}
+	if layout.ReturnPointer != nil {
+		allocator.Frame.ReturnPointer = allocator.Frame.AllocateSlot(lib.QUADWORD)
+		args = append(args, x86_64.MOV(layout.ReturnPointer, allocator.Frame.ReturnPointer))
+	}
+	for i, arg := range b.Signature.Args {
+		v := b.Signature.ArgNames[i]
+		location := layout.Args[i]
+		ctx_.VariableTypes[v] = arg
+		if arg.Type() != T_Struct {
+			if location.InMemory() {
+				// Returned by GetSlot when the variable gets spilled
+				allocator.Frame.Slots[v] = displaced(encoding.Rbp, 16+location.StackOffset)
+				ctx_.VariableMap[v] = allocator.Frame.Slots[v]
+			} else if arg.Type() == T_Float64 {
+				ctx_.VariableMap[v] = location.Registers[0]
+			} else {
+				ctx_.VariableMap[v] = location.Registers[0].(*encoding.Register).ForOperandWidth(arg.Width())
+			}
+			continue
+		}
+		reg := allocator.AllocateRegister(TUint64)
+		if location.InMemory() {
+			args = append(args, x86_64.LEA(displaced(encoding.Rbp, 16+location.StackOffset), reg))
+		} else {
+			offset := allocator.Frame.AllocateArea(location.Size)
+			for j, eightbyte := range location.Registers {
+				args = append(args, spill(eightbyte.(*encoding.Register), frameSlot(offset-j*8, lib.QUADWORD)))
+			}
+			args = append(args, x86_64.LEA(frameSlot(offset, lib.QUADWORD), reg))
+		}
+		ctx_.VariableMap[v] = reg
+	}
+	ctx_.AddInstruction(args...)
+
+	// The registers that only held eightbytes of structs, or the return
+	// pointer, are free now.
+	if layout.ReturnPointer != nil {
+		allocator.DeallocateRegister(layout.ReturnPointer)
+	}
+	for i, location := range layout.Args {
+		if b.Signature.Args[i].Type() == T_Struct {
+			for _, reg := range location.Registers {
+				allocator.DeallocateRegister(reg)
+			}
+		}
+	}
+
 	instr, err := encodeStatement(b.Body, ctx_)
 	if err != nil {
 		return err
 	}
-	for _, i := range instr {
-		fmt.Println(i)
+	instructions := lib.Instructions(prologue).Add(args).Add(instr)
+	address := segments.GetAddress(b.Address)
+	if err := instructions.Resolve(address); err != nil {
+		return err
+	}
+	relocations, err := instructions.Relocations(address)
+	if err != nil {
+		return err
 	}
-	bytes, err := lib.Instructions(instr).Encode()
+	segments.Relocations = append(segments.Relocations, relocations...)
+	bytes, err := instructions.Encode()
 	if err != nil {
 		return err
 	}
-	b.Address = segments.Add(Executable, bytes...)
+	b.Address.Size = segments.Add(Executable, bytes...).Size
 	return nil
 }
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/bspaans/jit-compiler/asm/x86_64"
-	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
 	. "github.com/bspaans/jit-compiler/ir/shared"
 	"github.com/bspaans/jit-compiler/ir/statements"
 	"github.com/bspaans/jit-compiler/lib"
@@ -18,38 +17,42 @@ func encode_IR_If(i *statements.IR_If, ctx *IR_Context) ([]lib.Instruction, erro
 	reg := ctx.AllocateRegister(TBool)
 	defer ctx.DeallocateRegister(reg)
 
-	// Get the lengths of the true and false branches
-	stmt1Len, err := IR_Length(i.Stmt1, ctx)
-	if err != nil {
-		return nil, err
-	}
-	stmt2Len, err := IR_Length(i.Stmt2, ctx)
-	if err != nil {
-		return nil, err
-	}
+	// Both branches should leave the variables where they found them
+	snapshot := snapshotAllocation(ctx)
+	end := statementEnd(i, ctx)
 
-	result, err := conditionalJump(ctx, i.Condition, stmt1Len)
+	elseLabel := ctx.NewLabel("else")
+	endLabel := ctx.NewLabel("end_if")
+
+	result, err := conditionalJump(ctx, i.Condition, elseLabel)
 	if err != nil {
 		return nil, fmt.Errorf("%s in %s", err.Error(), i.String())
 	}
 
-	s1, err := encodeStatement(i.Stmt1, ctx)
+	s1, err := encodeBranch(i.Stmt1, ctx, snapshot, end)
 	if err != nil {
 		return nil, err
 	}
 	for _, instr := range s1 {
 		result = append(result, instr)
 	}
-	jmp := x86_64.JMP(encoding.Uint8(stmt2Len))
-	ctx.AddInstruction(jmp)
-	result = append(result, jmp)
+	instr := []lib.Instruction{
+		x86_64.JMP(endLabel),
+		lib.DefineLabel(elseLabel),
+	}
+	ctx.AddInstruction(instr...)
+	result = append(result, instr...)
 
-	s2, err := encodeStatement(i.Stmt2, ctx)
+	s2, err := encodeBranch(i.Stmt2, ctx, snapshot, end)
 	if err != nil {
 		return nil, err
 	}
 	for _, instr := range s2 {
 		result = append(result, instr)
 	}
+	definition := lib.DefineLabel(endLabel)
+	ctx.AddInstruction(definition)
+	result = append(result, definition)
+	mergeIntroduced(ctx, snapshot)
 	return result, nil
 }
@@ -0,0 +1,29 @@
+package x86_64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Len(i *expr.IR_Len, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	ctx.AddInstruction("len " + encoding.Comment(i.String()))
+	switch ty := i.Value.ReturnType(ctx).(type) {
+	case *TArray:
+		if ty.Size > 0 {
+			return encodeExpression(expr.NewIR_Uint64(uint64(ty.Size)), ctx, target)
+		}
+	case *TSlice:
+		tmpReg := ctx.AllocateRegister(TUint64)
+		defer ctx.DeallocateRegister(tmpReg)
+		result, err := encodeExpression(i.Value, ctx, tmpReg)
+		if err != nil {
+			return nil, err
+		}
+		return lib.Instructions(result).Add(loadFromMemory(ctx, tmpReg.(*encoding.Register), SliceLengthOffset, TUint64, target)), nil
+	}
+	return nil, fmt.Errorf("Can't take the length of %s", i.Value.String())
+}
@@ -0,0 +1,311 @@
+package x86_64
+
+import (
+	"sort"
+
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+)
+
+// A live range is the interval of statement positions during which a variable
+// holds a value that might still be read.
+type LiveRange struct {
+	Start int
+	End   int
+}
+
+// LiveRanges numbers the statements in a function body in the order in which
+// they get encoded and records, for every variable, the first and last
+// position at which it is referenced. Loops extend the ranges of every
+// variable they reference to the whole loop, because the value needs to
+// survive the jump back to the condition.
+type LiveRanges struct {
+	Ranges map[string]*LiveRange
+	// The position of simple statements, and of the condition of if and while
+	// statements.
+	Positions map[IR]int
+	// The last position inside a (compound) statement.
+	Ends map[IR]int
+	// The variables that are read or written by the statement at that
+	// position, not including nested statements.
+	Uses map[IR][]string
+
+	position int
+}
+
+// Computes the live ranges for a function body. The arguments are considered
+// to be defined at position 0.
+func NewLiveRanges(stmts []IR, args []string) *LiveRanges {
+	l := &LiveRanges{
+		Ranges:    map[string]*LiveRange{},
+		Positions: map[IR]int{},
+		Ends:      map[IR]int{},
+		Uses:      map[IR][]string{},
+	}
+	for _, arg := range args {
+		l.reference(arg)
+	}
+	for _, stmt := range stmts {
+		l.addStatement(stmt)
+	}
+	return l
+}
+
+// Returns true if the variable might still be read after the given position.
+// Variables without a known live range are always live.
+func (l *LiveRanges) IsLiveAfter(variable string, position int) bool {
+	r, found := l.Ranges[variable]
+	return !found || r.End > position
+}
+
+// Returns the last position at which the variable is live. Variables without
+// a known live range never end.
+func (l *LiveRanges) End(variable string) int {
+	r, found := l.Ranges[variable]
+	if !found {
+		return int(^uint(0) >> 1)
+	}
+	return r.End
+}
+
+func (l *LiveRanges) reference(variable string) {
+	r, found := l.Ranges[variable]
+	if !found {
+		l.Ranges[variable] = &LiveRange{l.position, l.position}
+		return
+	}
+	if l.position < r.Start {
+		r.Start = l.position
+	}
+	if l.position > r.End {
+		r.End = l.position
+	}
+}
+
+func (l *LiveRanges) addPosition(stmt IR, variables []string) {
+	l.position++
+	l.Positions[stmt] = l.position
+	seen := map[string]bool{}
+	uses := []string{}
+	for _, v := range variables {
+		if !seen[v] {
+			seen[v] = true
+			uses = append(uses, v)
+			l.reference(v)
+		}
+	}
+	sort.Strings(uses)
+	l.Uses[stmt] = uses
+}
+
+func (l *LiveRanges) addStatement(stmt IR) {
+	switch v := stmt.(type) {
+	case *statements.IR_AndThen:
+		l.addStatement(v.Stmt1)
+		l.addStatement(v.Stmt2)
+	case *statements.IR_ArrayAssignment:
+		variables := append([]string{v.Variable}, expressionVariables(v.Index)...)
+		l.addPosition(stmt, append(variables, expressionVariables(v.Expr)...))
+	case *statements.IR_Assignment:
+		l.addPosition(stmt, append([]string{v.Variable}, expressionVariables(v.Expr)...))
+	case *statements.IR_FunctionDef:
+		l.addPosition(stmt, []string{v.Name})
+	case *statements.IR_If:
+		l.addPosition(stmt, expressionVariables(v.Condition))
+		l.addStatement(v.Stmt1)
+		l.addStatement(v.Stmt2)
+	case *statements.IR_PointerAssignment:
+		l.addPosition(stmt, append(expressionVariables(v.Pointer), expressionVariables(v.Expr)...))
+	case *statements.IR_Return:
+		l.addPosition(stmt, expressionVariables(v.Expr))
+	case *statements.IR_While:
+		l.addPosition(stmt, expressionVariables(v.Condition))
+		start := l.position
+		l.addStatement(v.Stmt)
+		for _, r := range l.Ranges {
+			if r.Start <= l.position && r.End >= start {
+				if start < r.Start {
+					r.Start = start
+				}
+				if l.position > r.End {
+					r.End = l.position
+				}
+			}
+		}
+	}
+	l.Ends[stmt] = l.position
+}
+
+// Returns the variables that are referenced in an expression. Function
+// literals are not descended into, because their bodies get their own
+// variable scope.
+func expressionVariables(e IRExpression) []string {
+	operators := func(ops ...IRExpression) []string {
+		result := []string{}
+		for _, op := range ops {
+			result = append(result, expressionVariables(op)...)
+		}
+		return result
+	}
+	switch v := e.(type) {
+	case *expr.IR_Variable:
+		return []string{v.Value}
+	case *expr.IR_Add:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_AddressOf:
+		return operators(v.Value)
+	case *expr.IR_Alloc:
+		return operators(v.Count)
+	case *expr.IR_And:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_ArrayIndex:
+		return operators(v.Array, v.Index)
+	case *expr.IR_BitwiseAnd:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_BitwiseNot:
+		return operators(v.Op1)
+	case *expr.IR_BitwiseOr:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_BitwiseXor:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_Call:
+		return append([]string{v.Function}, operators(v.Args...)...)
+	case *expr.IR_Cast:
+		return operators(v.Value)
+	case *expr.IR_Dereference:
+		return operators(v.Pointer)
+	case *expr.IR_Div:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_Equals:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_GT:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_GTE:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_Len:
+		return operators(v.Value)
+	case *expr.IR_LT:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_LTE:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_Mod:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_Mul:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_Not:
+		return operators(v.Op1)
+	case *expr.IR_Or:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_ShiftLeft:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_ShiftRight:
+		return operators(v.Op1, v.Op2)
+	case *expr.IR_Slice:
+		if v.High == nil {
+			return operators(v.Value, v.Low)
+		}
+		return operators(v.Value, v.Low, v.High)
+	case *expr.IR_StaticArray:
+		return operators(v.Value...)
+	case *expr.IR_Struct:
+		return operators(v.Values...)
+	case *expr.IR_StructField:
+		return operators(v.Struct)
+	case *expr.IR_Syscall:
+		return append(operators(v.Syscall), operators(v.Args...)...)
+	case *expr.IR_Sub:
+		return operators(v.Op1, v.Op2)
+	}
+	return []string{}
+}
+
+// Estimates the number of scratch registers needed to encode an expression,
+// by counting the temporaries that are live at the same time.
+func expressionRegisterNeed(e IRExpression) int {
+	binary := func(op1, op2 IRExpression) int {
+		n1, n2 := expressionRegisterNeed(op1), expressionRegisterNeed(op2)+1
+		if n1 > n2 {
+			return n1 + 1
+		}
+		return n2 + 1
+	}
+	arguments := func(args []IRExpression) int {
+		result := 0
+		for _, arg := range args {
+			if n := expressionRegisterNeed(arg); n > result {
+				result = n
+			}
+		}
+		return result + 2
+	}
+	switch v := e.(type) {
+	case *expr.IR_Add:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_AddressOf:
+		return expressionRegisterNeed(v.Value) + 1
+	case *expr.IR_Alloc:
+		return expressionRegisterNeed(v.Count) + 2
+	case *expr.IR_And:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_ArrayIndex:
+		// The items of slices are loaded into a register of their own
+		return binary(v.Array, v.Index) + 1
+	case *expr.IR_BitwiseAnd:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_BitwiseNot:
+		return expressionRegisterNeed(v.Op1)
+	case *expr.IR_BitwiseOr:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_BitwiseXor:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_Call:
+		return arguments(v.Args)
+	case *expr.IR_Cast:
+		return expressionRegisterNeed(v.Value) + 1
+	case *expr.IR_Dereference:
+		return expressionRegisterNeed(v.Pointer) + 1
+	case *expr.IR_Div:
+		return binary(v.Op1, v.Op2) + 2
+	case *expr.IR_Equals:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_GT:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_GTE:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_Len:
+		return expressionRegisterNeed(v.Value) + 1
+	case *expr.IR_LT:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_LTE:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_Mod:
+		return binary(v.Op1, v.Op2) + 2
+	case *expr.IR_Mul:
+		return binary(v.Op1, v.Op2) + 2
+	case *expr.IR_Not:
+		return expressionRegisterNeed(v.Op1) + 1
+	case *expr.IR_Or:
+		return binary(v.Op1, v.Op2)
+	case *expr.IR_ShiftLeft:
+		return binary(v.Op1, v.Op2) + 2
+	case *expr.IR_ShiftRight:
+		return binary(v.Op1, v.Op2) + 2
+	case *expr.IR_Slice:
+		// The header, the items, the bounds and the capacity
+		need := expressionRegisterNeed(v.Value)
+		for _, bound := range []IRExpression{v.Low, v.High} {
+			if bound != nil && expressionRegisterNeed(bound) > need {
+				need = expressionRegisterNeed(bound)
+			}
+		}
+		return need + 5
+	case *expr.IR_StructField:
+		return expressionRegisterNeed(v.Struct) + 1
+	case *expr.IR_Syscall:
+		return arguments(append([]IRExpression{v.Syscall}, v.Args...))
+	case *expr.IR_Sub:
+		return binary(v.Op1, v.Op2)
+	}
+	return 1
+}
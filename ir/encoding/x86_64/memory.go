@@ -0,0 +1,34 @@
+package x86_64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Loads a value of the given type from offset bytes past the address in
+// base into target.
+func loadFromMemory(ctx *IR_Context, base *encoding.Register, offset int, typ Type, target lib.Operand) []lib.Instruction {
+	result := []lib.Instruction{}
+	width := typ.Width()
+	if width < lib.QUADWORD {
+		// Move 0 into target register if going from a wider to narrower register
+		result = append(result, x86_64.MOV(encoding.Uint64(0), target.(*encoding.Register).Get64BitRegister()))
+	}
+	// The width of the base register determines the width of the load
+	// (see encode_IR_ArrayIndex)
+	mem := displaced(base.ForOperandWidth(width), offset)
+	result = append(result, x86_64.MOV(mem, target.(*encoding.Register).ForOperandWidth(width)))
+	ctx.AddInstruction(result...)
+	return result
+}
+
+// Stores the value of the given type in src at offset bytes past the
+// address in base. Integers are truncated to the width of the type.
+func storeInMemory(ctx *IR_Context, src *encoding.Register, base *encoding.Register, offset int, typ Type) []lib.Instruction {
+	width := typ.Width()
+	mov := x86_64.MOV(src.ForOperandWidth(width), displaced(base.ForOperandWidth(width), offset))
+	ctx.AddInstruction(mov)
+	return []lib.Instruction{mov}
+}
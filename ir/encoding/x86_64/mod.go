@@ -0,0 +1,19 @@
+package x86_64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_Mod(i *expr.IR_Mod, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	ctx.AddInstruction("operator " + encoding.Comment(i.String()))
+	returnType1, returnType2 := i.Op1.ReturnType(ctx), i.Op2.ReturnType(ctx)
+	if returnType1 != returnType2 || !IsInteger(returnType1) {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in %% IR operation: %s", returnType1, returnType2, i.String())
+	}
+	return encode_IntegerDivision(i.Op1, i.Op2, true, ctx, target)
+}
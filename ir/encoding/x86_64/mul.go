@@ -24,7 +24,8 @@ func encode_IR_Mul(i *expr.IR_Mul, ctx *IR_Context, target lib.Operand) ([]lib.I
 		allocator := ctx.Allocator.(*X86_64_Allocator)
 		raxInUse := allocator.Registers[0]
 
-		shouldPreserveRdx := (returnType1.Width() != lib.BYTE) && allocator.Registers[2]
+		// The target doesn't need to be preserved, because it gets overwritten
+		shouldPreserveRdx := (returnType1.Width() != lib.BYTE) && allocator.Registers[2] && target.(*encoding.Register).Register != 2
 		shouldPreserveRax := target.(*encoding.Register).Register != 0 && raxInUse
 		var tmpRdx, tmpRax lib.Operand
 
@@ -106,6 +106,11 @@ func encode_IR_Not(i *expr.IR_Not, ctx *IR_Context, target lib.Operand, includeS
 			result = append(result, r)
 		}
 		reg1 = target
+		if !includeSETE {
+			cmp := x86_64.CMP_immediate(1, reg1)
+			result = append(result, cmp)
+			ctx.AddInstruction(cmp)
+		}
 	case *expr.IR_Or:
 		var err error
 		var eq []lib.Instruction
@@ -117,6 +122,11 @@ func encode_IR_Not(i *expr.IR_Not, ctx *IR_Context, target lib.Operand, includeS
 			result = append(result, r)
 		}
 		reg1 = target
+		if !includeSETE {
+			cmp := x86_64.CMP_immediate(1, reg1)
+			result = append(result, cmp)
+			ctx.AddInstruction(cmp)
+		}
 	default:
 		return nil, fmt.Errorf("Unsupported ! operation: %s", i.Op1.Type())
 	}
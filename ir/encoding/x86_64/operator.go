@@ -3,6 +3,7 @@ package x86_64
 import (
 	"fmt"
 
+	"github.com/bspaans/jit-compiler/asm/x86_64"
 	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
 	"github.com/bspaans/jit-compiler/ir/expr"
 	. "github.com/bspaans/jit-compiler/ir/shared"
@@ -41,3 +42,45 @@ func encode_Operator(op1, op2 IRExpression, operator op, repr string, ctx *IR_Co
 	}
 	return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
 }
+
+// Like encode_Operator, but for operators that are only defined on integers.
+func encode_IntegerOperator(op1, op2 IRExpression, operator op, repr string, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
+	if !IsInteger(returnType1) {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
+	}
+	return encode_Operator(op1, op2, operator, repr, ctx, target)
+}
+
+// Adds an integer number of items to, or subtracts it from, the pointer in
+// op1, so the integer is scaled by the size of the items first.
+func encode_PointerOperator(op1, op2 IRExpression, operator op, repr string, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	ctx.AddInstruction("pointer_operator " + encoding.Comment(repr))
+	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
+	if !IsInteger(returnType2) || returnType2.Width() != lib.QUADWORD {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
+	}
+	result, err := encodeExpression(op1, ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	reg := ctx.AllocateRegister(TUint64)
+	defer ctx.DeallocateRegister(reg)
+	expr, err := encodeExpression(op2, ctx, reg)
+	if err != nil {
+		return nil, err
+	}
+	result = lib.Instructions(result).Add(expr)
+	instr := []lib.Instruction{}
+	if size := Sizeof(returnType1.(*TPointer).Target); size != 1 {
+		tmpReg := ctx.AllocateRegister(TUint64)
+		defer ctx.DeallocateRegister(tmpReg)
+		instr = append(instr,
+			x86_64.MOV_immediate(uint64(size), tmpReg),
+			x86_64.IMUL2(tmpReg, reg),
+		)
+	}
+	instr = append(instr, operator(reg, target))
+	ctx.AddInstruction(instr...)
+	return append(result, instr...), nil
+}
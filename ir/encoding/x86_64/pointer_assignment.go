@@ -0,0 +1,35 @@
+package x86_64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_PointerAssignment(i *statements.IR_PointerAssignment, ctx *IR_Context) ([]lib.Instruction, error) {
+	ctx.AddInstruction("pointer_assignment " + encoding.Comment(i.String()))
+	targetType, err := i.TargetType(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pointerReg := ctx.AllocateRegister(TUint64)
+	defer ctx.DeallocateRegister(pointerReg)
+	exprReg := ctx.AllocateRegister(i.Expr.ReturnType(ctx))
+	defer ctx.DeallocateRegister(exprReg)
+
+	result, err := encodeExpression(i.Pointer, ctx, pointerReg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode pointer in %s: %s", i.String(), err.Error())
+	}
+	exprInstr, err := encodeExpression(i.Expr, ctx, exprReg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode expr in %s: %s", i.String(), err.Error())
+	}
+	result = lib.Instructions(result).Add(exprInstr)
+	store := storeInMemory(ctx, exprReg.(*encoding.Register), pointerReg.(*encoding.Register), 0, targetType)
+	return lib.Instructions(result).Add(store), nil
+}
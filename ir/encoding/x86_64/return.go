@@ -29,7 +29,12 @@ func encode_IR_Return(i *statements.IR_Return, ctx *IR_Context) ([]lib.Instructi
 		}
 		result = result_
 	}
-	if reg.Width() != lib.QUADWORD {
+	frame := ctx.Allocator.(*X86_64_Allocator).Frame
+	if returnType := i.Expr.ReturnType(ctx); frame.Return != nil && (returnType.Type() == T_Struct || IsSlice(returnType)) {
+		return append(result, encodeStructReturn(ctx, reg, frame)...), nil
+	}
+	float := i.Expr.ReturnType(ctx).Type() == T_Float64
+	if !float && reg.Width() != lib.QUADWORD {
 		cast := ctx.AllocateRegister(TUint64)
 		defer ctx.DeallocateRegister(cast)
 
@@ -51,14 +56,76 @@ func encode_IR_Return(i *statements.IR_Return, ctx *IR_Context) ([]lib.Instructi
 		}
 		reg = cast
 	}
-	target := ctx.PeekReturn()
-	instr := []lib.Instruction{
-		x86_64.MOV(reg.(*encoding.Register).Get64BitRegister(), target),
-		x86_64.RETURN(),
+	// The value might be in a callee-saved register that gets restored by
+	// the epilogue, so it's moved into rax (or xmm0) first.
+	returnReg := encoding.Rax
+	if float {
+		returnReg = encoding.Xmm0
+	} else {
+		reg = reg.(*encoding.Register).Get64BitRegister()
+	}
+	if reg != returnReg {
+		mov := x86_64.MOV(reg, returnReg)
+		ctx.AddInstruction(mov)
+		result = append(result, mov)
+	}
+	result = append(result, encodeEpilogue(ctx)...)
+	instr := []lib.Instruction{}
+	if target := ctx.PeekReturn(); target != returnReg {
+		instr = append(instr, x86_64.MOV(returnReg, target))
 	}
+	instr = append(instr, x86_64.RETURN())
 	for _, inst := range instr {
 		ctx.AddInstruction(inst)
 		result = append(result, inst)
 	}
 	return result, nil
 }
+
+// Returns the struct, or slice header, at the address in reg the way the
+// calling convention expects it: either loaded into the return registers,
+// or copied to the memory that the caller passed the address of, in which
+// case that address is returned.
+func encodeStructReturn(ctx *IR_Context, reg lib.Operand, frame *StackFrame) []lib.Instruction {
+	result := []lib.Instruction{}
+	ptr, ok := reg.(*encoding.Register)
+	if !ok || (frame.Return.InMemory() && ptr.Register == encoding.Rax.Register) {
+		// rax is needed for the address of the copy; r10 isn't used for
+		// any return value.
+		result = append(result, x86_64.MOV(reg, encoding.R10))
+		ptr = encoding.R10
+	}
+	ptr = ptr.Get64BitRegister()
+	if frame.Return.InMemory() {
+		scratch := encoding.R11
+		if ptr == encoding.R11 {
+			scratch = encoding.R10
+		}
+		result = append(result, x86_64.MOV(frame.ReturnPointer, encoding.Rax))
+		for j := 0; j < frame.Return.Size; j += 8 {
+			result = append(result,
+				x86_64.MOV(displaced(ptr, j), scratch),
+				x86_64.MOV(scratch, displaced(encoding.Rax, j)),
+			)
+		}
+	} else {
+		// The eightbyte that goes into the register holding the address
+		// is loaded last.
+		last := -1
+		for j, eightbyte := range frame.Return.Registers {
+			if eightbyte == ptr {
+				last = j
+				continue
+			}
+			result = append(result, reload(displaced(ptr, j*8), eightbyte.(*encoding.Register)))
+		}
+		if last >= 0 {
+			result = append(result, x86_64.MOV(displaced(ptr, last*8), ptr))
+		}
+	}
+	ctx.AddInstruction(result...)
+	result = append(result, encodeEpilogue(ctx)...)
+	ret := x86_64.RETURN()
+	ctx.AddInstruction(ret)
+	return append(result, ret)
+}
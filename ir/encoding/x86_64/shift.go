@@ -0,0 +1,121 @@
+package x86_64
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Returns the value of a constant shift count. The shift instructions only
+// use the lower 5 or 6 bits of the count, so it's fine to truncate it.
+func shiftCount(e IRExpression) (uint8, bool) {
+	switch c := e.(type) {
+	case *expr.IR_Uint8:
+		return c.Value, true
+	case *expr.IR_Uint16:
+		return uint8(c.Value), true
+	case *expr.IR_Uint32:
+		return uint8(c.Value), true
+	case *expr.IR_Uint64:
+		return uint8(c.Value), true
+	case *expr.IR_Int8:
+		return uint8(c.Value), true
+	case *expr.IR_Int16:
+		return uint8(c.Value), true
+	case *expr.IR_Int32:
+		return uint8(c.Value), true
+	case *expr.IR_Int64:
+		return uint8(c.Value), true
+	}
+	return 0, false
+}
+
+// Shifts op1 by op2 into target. Constant shift counts are encoded as an
+// immediate, other counts have to be in %cl, so %rcx gets preserved if it's
+// in use.
+func encode_Shift(op1, op2 IRExpression, shiftImmediate op, shiftCL func(lib.Operand) lib.Instruction, repr string, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	ctx.AddInstruction("operator " + encoding.Comment(repr))
+	returnType1, returnType2 := op1.ReturnType(ctx), op2.ReturnType(ctx)
+	if !IsInteger(returnType1) || !IsInteger(returnType2) {
+		return nil, fmt.Errorf("Unsupported types (%s, %s) in IR operation: %s", returnType1, returnType2, repr)
+	}
+	if count, ok := shiftCount(op2); ok {
+		result, err := encodeExpression(op1, ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		instr := shiftImmediate(encoding.Uint8(count), target)
+		ctx.AddInstruction(instr)
+		return append(result, instr), nil
+	}
+
+	allocator := ctx.Allocator.(*X86_64_Allocator)
+	targetReg, targetIsRegister := target.(*encoding.Register)
+	targetIsRcx := targetIsRegister && targetReg.Register == 1
+
+	result := lib.Instructions{}
+	ctxCopy := ctx
+
+	// Preserve the %rcx register. If it's the target it doesn't have to be
+	// restored, but the operands may still read the old value.
+	var tmpRcx lib.Operand
+	if allocator.Registers[1] {
+		tmpRcx = ctx.AllocateRegister(TUint64)
+		defer ctx.DeallocateRegister(tmpRcx)
+		preserveRcx := x86_64.MOV(encoding.Rcx, tmpRcx)
+		result = append(result, preserveRcx)
+		ctx.AddInstruction(preserveRcx)
+		// Replace variables in the variablemap that point to rcx with the new register
+		ctxCopy = ctxCopy.Copy()
+		for v, vTarget := range ctxCopy.VariableMap {
+			if r, ok := vTarget.(*encoding.Register); ok && r.Register == 1 {
+				ctxCopy.VariableMap[v] = tmpRcx
+			}
+		}
+	} else {
+		ctxCopy = ctxCopy.Copy()
+	}
+	// Make sure we don't allocate %rcx
+	ctxCopy.Allocator.(*X86_64_Allocator).reserveRegister(encoding.Rcx)
+
+	// The count is encoded first, so that the target can't overwrite a
+	// variable that it reads.
+	cl := encoding.Rcx.ForOperandWidth(returnType2.Width())
+	count, err := encodeExpression(op2, ctxCopy, cl)
+	if err != nil {
+		return nil, err
+	}
+	result = result.Add(count)
+
+	value := target
+	if targetIsRcx {
+		value = ctxCopy.AllocateRegister(returnType1)
+		defer ctxCopy.DeallocateRegister(value)
+	}
+	instr, err := encodeExpression(op1, ctxCopy, value)
+	if err != nil {
+		return nil, err
+	}
+	result = result.Add(instr)
+
+	shift := shiftCL(value)
+	ctx.AddInstruction(shift)
+	result = append(result, shift)
+
+	if value != target {
+		mov := x86_64.MOV(value, target)
+		ctx.AddInstruction(mov)
+		result = append(result, mov)
+	}
+	// Restore %rcx
+	if tmpRcx != nil && !targetIsRcx {
+		restore := x86_64.MOV(tmpRcx, encoding.Rcx)
+		ctx.AddInstruction(restore)
+		result = append(result, restore)
+	}
+	return result, nil
+}
@@ -0,0 +1,15 @@
+package x86_64
+
+import (
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+func encode_IR_ShiftRight(i *expr.IR_ShiftRight, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	if IsSignedInteger(i.Op1.ReturnType(ctx)) {
+		return encode_Shift(i.Op1, i.Op2, x86_64.SAR, x86_64.SAR_CL, i.String(), ctx, target)
+	}
+	return encode_Shift(i.Op1, i.Op2, x86_64.SHR, x86_64.SHR_CL, i.String(), ctx, target)
+}
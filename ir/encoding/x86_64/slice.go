@@ -0,0 +1,103 @@
+package x86_64
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/bspaans/jit-compiler/asm/x86_64"
+	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// Encodes a slicing expression. The bounds are checked against the value,
+// low <= high <= capacity, after which the header of the new slice gets
+// written to its area in the stack frame (see TSlice).
+func encode_IR_Slice(i *expr.IR_Slice, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
+	ctx.AddInstruction("slice " + encoding.Comment(i.String()))
+	valueType := i.Value.ReturnType(ctx)
+	if i.High == nil && IsPointer(valueType) {
+		return nil, fmt.Errorf("Expecting an upper bound when slicing a pointer in %s", i.String())
+	}
+	if arr, ok := valueType.(*TArray); ok && arr.Size == 0 {
+		return nil, fmt.Errorf("Can't slice an array without a size in %s", i.String())
+	}
+	for _, bound := range []IRExpression{i.Low, i.High} {
+		if bound == nil {
+			continue
+		}
+		if ty := bound.ReturnType(ctx); !IsInteger(ty) || ty.Width() != lib.QUADWORD {
+			return nil, fmt.Errorf("Expecting uint64 or int64 bounds in %s", i.String())
+		}
+	}
+
+	items := ctx.AllocateRegister(TUint64).(*encoding.Register)
+	defer ctx.DeallocateRegister(items)
+	result, err := encodeExpression(i.Value, ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	low := ctx.AllocateRegister(TUint64).(*encoding.Register)
+	defer ctx.DeallocateRegister(low)
+	instr, err := encodeExpression(i.Low, ctx, low)
+	if err != nil {
+		return nil, err
+	}
+	result = lib.Instructions(result).Add(instr)
+	high := ctx.AllocateRegister(TUint64).(*encoding.Register)
+	defer ctx.DeallocateRegister(high)
+	if i.High != nil {
+		instr, err := encodeExpression(i.High, ctx, high)
+		if err != nil {
+			return nil, err
+		}
+		result = lib.Instructions(result).Add(instr)
+	}
+
+	capacity := ctx.AllocateRegister(TUint64).(*encoding.Register)
+	defer ctx.DeallocateRegister(capacity)
+	instr = []lib.Instruction{}
+	switch ty := valueType.(type) {
+	case *TSlice:
+		if i.High == nil {
+			instr = append(instr, x86_64.MOV(displaced(items, SliceLengthOffset), high))
+		}
+		instr = append(instr,
+			x86_64.MOV(displaced(items, SliceCapacityOffset), capacity),
+			x86_64.MOV(displaced(items, SliceItemsOffset), items),
+		)
+	case *TArray:
+		if i.High == nil {
+			instr = append(instr, x86_64.MOV_immediate(uint64(ty.Size), high))
+		}
+		instr = append(instr, x86_64.MOV_immediate(uint64(ty.Size), capacity))
+	case *TPointer:
+		instr = append(instr, x86_64.MOV(high, capacity))
+	}
+	ctx.AddInstruction(instr...)
+	result = lib.Instructions(result).Add(instr)
+	result = lib.Instructions(result).Add(checkBounds(ctx, low, high, true))
+	result = lib.Instructions(result).Add(checkBounds(ctx, high, capacity, true))
+
+	// The new slice starts at the low item, so the bound is subtracted
+	// from the length and the capacity.
+	instr = []lib.Instruction{
+		x86_64.SUB(low, high),
+		x86_64.SUB(low, capacity),
+	}
+	itemWidth := i.ReturnType(ctx).(*TSlice).ItemType.Width()
+	if shift := bits.TrailingZeros(uint(itemWidth)); shift > 0 {
+		instr = append(instr, x86_64.SHL(encoding.Uint8(shift), low))
+	}
+	offset := ctx.Allocator.(*X86_64_Allocator).Frame.GetArea(i, SliceHeaderSize)
+	instr = append(instr,
+		x86_64.ADD(low, items),
+		x86_64.MOV(items, frameSlot(offset-SliceItemsOffset, lib.QUADWORD)),
+		x86_64.MOV(high, frameSlot(offset-SliceLengthOffset, lib.QUADWORD)),
+		x86_64.MOV(capacity, frameSlot(offset-SliceCapacityOffset, lib.QUADWORD)),
+		x86_64.LEA(frameSlot(offset, lib.QUADWORD), target),
+	)
+	ctx.AddInstruction(instr...)
+	return lib.Instructions(result).Add(instr), nil
+}
@@ -1,6 +1,7 @@
 package x86_64
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"github.com/bspaans/jit-compiler/asm/x86_64"
@@ -11,19 +12,57 @@ import (
 )
 
 func encode_IR_StaticArray(i *expr.IR_StaticArray, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
-	// Calculate the displacement between RIP (the instruction pointer,
-	// pointing to the *next* instruction) and the address of our byte array,
-	// and load the resulting address into target using a LEA instruction.
-	ownLength := uint(7)
-	// TODO: instead of address + 2; use datasectionOffset + len(ctx.ReadonlySegment) + address; in JIT mode
-	diff := uint(ctx.InstructionPointer+ownLength) - uint(ctx.Segments.GetAddress(i.Address))
-	result := []lib.Instruction{x86_64.LEA(&encoding.RIPRelative{encoding.Int32(int32(-diff))}, target)}
+	if i.Address == nil {
+		bytes, err := staticArrayBytes(i)
+		if err != nil {
+			return nil, err
+		}
+		return encodeFrameData(ctx, i, bytes, target), nil
+	}
+	// Load the address of the array into target (see encode_IR_ByteArray)
+	result := []lib.Instruction{x86_64.LEA(dataAddress(ctx, i.Address), target)}
 	ctx.AddInstruction(result...)
 	return result, nil
 }
 
-func encode_IR_StaticArray_for_DataSection(b *expr.IR_StaticArray, segments *Segments) error {
+// Copies the data of an array or struct that's declared in a function into
+// the stack frame, and loads its address into target. The data is copied
+// eight bytes at a time, through target.
+func encodeFrameData(ctx *IR_Context, e IRExpression, bytes []uint8, target lib.Operand) []lib.Instruction {
+	offset := ctx.Allocator.(*X86_64_Allocator).Frame.GetArea(e, len(bytes))
+	reg := target.(*encoding.Register).Get64BitRegister()
+	result := []lib.Instruction{}
+	for j := 0; j < len(bytes); j += 8 {
+		eightbyte := make([]uint8, 8)
+		copy(eightbyte, bytes[j:])
+		result = append(result,
+			x86_64.MOV_immediate(binary.LittleEndian.Uint64(eightbyte), reg),
+			x86_64.MOV(reg, frameSlot(offset-j, lib.QUADWORD)),
+		)
+	}
+	result = append(result, x86_64.LEA(frameSlot(offset, lib.QUADWORD), target))
+	ctx.AddInstruction(result...)
+	return result
+}
+
+func encode_IR_StaticArray_for_DataSection(b *expr.IR_StaticArray, ctx *IR_Context, segments *dataSection) error {
 	b.Address = nil
+	if ctx.InFunction {
+		// Every call of the function gets its own copy in its stack frame
+		// instead (see encodeFrameData).
+		return nil
+	}
+	bytes, err := staticArrayBytes(b)
+	if err != nil {
+		return err
+	}
+	b.Address = segments.Add(ReadWrite, bytes...)
+	return nil
+}
+
+// Returns the encoded values of the array.
+func staticArrayBytes(b *expr.IR_StaticArray) ([]uint8, error) {
+	result := []uint8{}
 	for _, v := range b.Value {
 		bytes := []uint8{}
 		if b.ElemType == TUint8 {
@@ -33,7 +72,7 @@ func encode_IR_StaticArray_for_DataSection(b *expr.IR_StaticArray, segments *Seg
 			case *expr.IR_Uint64:
 				bytes = []uint8{uint8(c.Value)}
 			default:
-				return fmt.Errorf("Unsupport uint8 array type %s in %s", b.ElemType, b.String())
+				return nil, fmt.Errorf("Unsupport uint8 array type %s in %s", b.ElemType, b.String())
 			}
 		} else if b.ElemType == TUint16 {
 			switch c := v.(type) {
@@ -42,7 +81,7 @@ func encode_IR_StaticArray_for_DataSection(b *expr.IR_StaticArray, segments *Seg
 			case *expr.IR_Uint64:
 				bytes = encoding.Uint16(uint16(c.Value)).Encode()
 			default:
-				return fmt.Errorf("Unsupport uint16 array type %s in %s", b.ElemType, b.String())
+				return nil, fmt.Errorf("Unsupport uint16 array type %s in %s", b.ElemType, b.String())
 			}
 		} else if b.ElemType == TUint32 {
 			switch c := v.(type) {
@@ -51,7 +90,7 @@ func encode_IR_StaticArray_for_DataSection(b *expr.IR_StaticArray, segments *Seg
 			case *expr.IR_Uint64:
 				bytes = encoding.Uint32(uint32(c.Value)).Encode()
 			default:
-				return fmt.Errorf("Unsupport uint32 array type %s in %s", b.ElemType, b.String())
+				return nil, fmt.Errorf("Unsupport uint32 array type %s in %s", b.ElemType, b.String())
 			}
 		} else if b.ElemType == TUint64 {
 			ir := v.(*expr.IR_Uint64)
@@ -63,12 +102,9 @@ func encode_IR_StaticArray_for_DataSection(b *expr.IR_StaticArray, segments *Seg
 			ir := v.(*expr.IR_Int64)
 			bytes = encoding.Uint64(ir.Value).Encode()
 		} else {
-			return fmt.Errorf("Unsupported array type %s", v.Type().String())
-		}
-		addr := segments.Add(ReadWrite, bytes...)
-		if b.Address == nil {
-			b.Address = addr
+			return nil, fmt.Errorf("Unsupported array type %s", v.Type().String())
 		}
+		result = append(result, bytes...)
 	}
-	return nil
+	return result, nil
 }
@@ -15,26 +15,33 @@ func encode_IR_StructField(i *expr.IR_StructField, ctx *IR_Context, target lib.O
 	tmpReg := ctx.AllocateRegister(TUint64)
 	defer ctx.DeallocateRegister(tmpReg)
 	result, err := encodeExpression(i.Struct, ctx, tmpReg)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldType, offset, err := structField(i, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if IsInline(fieldType) {
+		// Nested structs and arrays are stored inline and evaluate to
+		// their address.
+		lea := x86_64.LEA(displaced(tmpReg.(*encoding.Register), offset), target)
+		ctx.AddInstruction(lea)
+		return append(result, lea), nil
+	}
+	return lib.Instructions(result).Add(loadFromMemory(ctx, tmpReg.(*encoding.Register), offset, fieldType, target)), nil
+}
 
-	// Calculate the offset for our field
-	structType := i.Struct.ReturnType(ctx)
-	str, ok := structType.(*TStruct)
+// Returns the type of the field and its offset from the start of the struct.
+func structField(i *expr.IR_StructField, ctx *IR_Context) (Type, int, error) {
+	str, ok := i.StructType(ctx)
 	if !ok {
-		return nil, fmt.Errorf("Expecting struct, got %s", structType)
+		return nil, 0, fmt.Errorf("Expecting struct, got %s", i.Struct.ReturnType(ctx))
 	}
-	offset := 0
-	for j, f := range str.Fields {
-		if f == i.Field {
-			break
-		}
-		offset += int(str.FieldTypes[j].Width())
+	j := str.FieldIndex(i.Field)
+	if j == -1 {
+		return nil, 0, fmt.Errorf("Unknown field '%s' in %s", i.Field, i.String())
 	}
-	// Add offset and load value at address into target
-	add := x86_64.ADD(encoding.Uint32(uint32(offset)), tmpReg)
-	mov := x86_64.MOV(&encoding.IndirectRegister{tmpReg.(*encoding.Register)}, target)
-	ctx.AddInstruction(add)
-	ctx.AddInstruction(mov)
-	result = append(result, add)
-	result = append(result, mov)
-	return result, err
+	return str.FieldTypes[j], str.Layout().Offsets[j], nil
 }
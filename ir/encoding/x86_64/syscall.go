@@ -1,6 +1,8 @@
 package x86_64
 
 import (
+	"fmt"
+
 	"github.com/bspaans/jit-compiler/asm/x86_64"
 	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
 	"github.com/bspaans/jit-compiler/ir/expr"
@@ -9,11 +11,35 @@ import (
 )
 
 func encode_IR_Syscall(i *expr.IR_Syscall, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error) {
-
-	result, _, clobbered, err := ABI_Call_Setup(ctx, i.Args, TUint64)
+	argTypes, err := argumentTypes(ctx, i.Args)
+	if err != nil {
+		return nil, err
+	}
+	layout := ctx.ABI.ClassifyCall(argTypes, TUint64)
+	if layout.StackSize > 0 {
+		return nil, fmt.Errorf("Too many arguments for syscall: %d", len(i.Args))
+	}
+	// The kernel takes the fourth argument in r10 instead of rcx
+	for _, location := range layout.Args {
+		for j, reg := range location.Registers {
+			if reg == encoding.Rcx {
+				location.Registers[j] = encoding.R10
+			}
+		}
+	}
+	// The syscall instruction itself overwrites rcx and r11
+	regs := []*encoding.Register{encoding.Rax, encoding.Rcx, encoding.R11}
+	for _, location := range layout.Args {
+		for _, reg := range location.Registers {
+			regs = append(regs, reg.(*encoding.Register))
+		}
+	}
+	result, mapping, clobbered := PreserveRegisters(ctx, regs)
+	setup, err := ABI_Call_Setup(ctx, i.Args, layout, stackMapping(ctx, mapping, clobbered, 0))
 	if err != nil {
 		return nil, err
 	}
+	result = result.Add(setup)
 	instr, err := encodeExpression(i.Syscall, ctx, encoding.Rax)
 	if err != nil {
 		return nil, err
@@ -39,3 +65,14 @@ func encode_IR_Syscall(i *expr.IR_Syscall, ctx *IR_Context, target lib.Operand)
 	result = append(result, mov)
 	return result, nil
 }
+
+// The entry point of executables calls the code and passes the value that it
+// returns in rax on to exit(2), because there's nothing to return to.
+func (x *X86_64) EncodeEntry(main *lib.Label) []lib.Instruction {
+	return []lib.Instruction{
+		x86_64.CALL(main),
+		x86_64.MOV(encoding.Rax, encoding.Rdi),
+		x86_64.MOV_immediate(uint64(expr.IR_Syscall_Linux_Exit), encoding.Rax),
+		x86_64.SYSCALL(),
+	}
+}
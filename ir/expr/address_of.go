@@ -0,0 +1,39 @@
+package expr
+
+import (
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Evaluates to the address of a value in memory: the field of a struct, the
+// item of an array or the target of a pointer. Structs and arrays with a
+// size already evaluate to their address (see IsInline), so taking their
+// address gives a pointer to them.
+type IR_AddressOf struct {
+	*BaseIRExpression
+	Value IRExpression
+}
+
+func NewIR_AddressOf(value IRExpression) *IR_AddressOf {
+	return &IR_AddressOf{
+		BaseIRExpression: NewBaseIRExpression(AddressOf),
+		Value:            value,
+	}
+}
+
+func (i *IR_AddressOf) ReturnType(ctx *IR_Context) Type {
+	return &TPointer{Target: i.Value.ReturnType(ctx)}
+}
+
+func (i *IR_AddressOf) String() string {
+	return "&" + i.Value.String()
+}
+
+func (b *IR_AddressOf) AddToDataSection(ctx *IR_Context) error {
+	return b.Value.AddToDataSection(ctx)
+}
+
+// The value itself is never evaluated, so only its operands get rewritten.
+func (b *IR_AddressOf) SSA_Transform(ctx *SSA_Context) (SSA_Rewrites, IRExpression) {
+	rewrites, expr := b.Value.SSA_Transform(ctx)
+	return rewrites, NewIR_AddressOf(expr)
+}
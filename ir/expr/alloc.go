@@ -0,0 +1,49 @@
+package expr
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Allocates zeroed memory for Count values of the ItemType and evaluates to
+// a pointer to the first one, or to 0 if the memory couldn't be mapped. The
+// allocator hands out memory from chunks that it maps with mmap(2), and the
+// memory is never freed.
+type IR_Alloc struct {
+	*BaseIRExpression
+	ItemType Type
+	Count    IRExpression
+	pointer  *TPointer
+}
+
+func NewIR_Alloc(itemType Type, count IRExpression) *IR_Alloc {
+	return &IR_Alloc{
+		BaseIRExpression: NewBaseIRExpression(Alloc),
+		ItemType:         itemType,
+		Count:            count,
+		pointer:          &TPointer{Target: itemType},
+	}
+}
+
+func (i *IR_Alloc) ReturnType(ctx *IR_Context) Type {
+	return i.pointer
+}
+
+func (i *IR_Alloc) String() string {
+	return fmt.Sprintf("alloc(%s, %s)", i.ItemType.String(), i.Count.String())
+}
+
+func (b *IR_Alloc) AddToDataSection(ctx *IR_Context) error {
+	return b.Count.AddToDataSection(ctx)
+}
+
+func (b *IR_Alloc) SSA_Transform(ctx *SSA_Context) (SSA_Rewrites, IRExpression) {
+	if IsLiteralOrVariable(b.Count) {
+		return nil, b
+	}
+	rewrites, expr := b.Count.SSA_Transform(ctx)
+	v := ctx.GenerateVariable()
+	rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+	return rewrites, NewIR_Alloc(b.ItemType, NewIR_Variable(v))
+}
@@ -26,6 +26,9 @@ func (i *IR_ArrayIndex) ReturnType(ctx *IR_Context) Type {
 		fmt.Println(i)
 		panic("Type is nil")
 	}
+	if slice, ok := ty.(*TSlice); ok {
+		return slice.ItemType
+	}
 	if ty.Type() != T_Array {
 		panic("Not an array")
 	}
@@ -0,0 +1,37 @@
+package expr
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+type IR_BitwiseNot struct {
+	*BaseIRExpression
+	Op1 IRExpression
+}
+
+func NewIR_BitwiseNot(op1 IRExpression) *IR_BitwiseNot {
+	return &IR_BitwiseNot{
+		BaseIRExpression: NewBaseIRExpression(BitwiseNot),
+		Op1:              op1,
+	}
+}
+
+func (i *IR_BitwiseNot) ReturnType(ctx *IR_Context) Type {
+	return i.Op1.ReturnType(ctx)
+}
+
+func (i *IR_BitwiseNot) String() string {
+	return fmt.Sprintf("^(%s)", i.Op1.String())
+}
+
+func (b *IR_BitwiseNot) SSA_Transform(ctx *SSA_Context) (SSA_Rewrites, IRExpression) {
+	if IsLiteralOrVariable(b.Op1) {
+		return nil, b
+	}
+	rewrites, expr := b.Op1.SSA_Transform(ctx)
+	v := ctx.GenerateVariable()
+	rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+	return rewrites, NewIR_BitwiseNot(NewIR_Variable(v))
+}
@@ -21,15 +21,70 @@ func NewIR_Call(function string, args []IRExpression) *IR_Call {
 	}
 }
 
+// Returns nil if the function is unknown, which the encoders report as an
+// error (see CheckCall).
 func (i *IR_Call) ReturnType(ctx *IR_Context) Type {
-	signature := ctx.VariableTypes[i.Function]
-	if signature == nil {
-		panic("Unknown function: " + i.Function)
+	signature, ok := ctx.VariableTypes[i.Function].(*TFunction)
+	if !ok {
+		return nil
 	}
-	if _, ok := signature.(*TFunction); !ok {
-		panic("Expected function, got: " + signature.String())
+	return signature.ReturnType
+}
+
+// Returns the signature of the called function, or an error if there is no
+// such function or if the arguments don't match its parameters. Arrays that
+// are passed to a slice parameter are replaced by a slice of the whole array,
+// like a[:] in Go, because slices are passed as the address of a header.
+func (i *IR_Call) CheckCall(ctx *IR_Context) (*TFunction, error) {
+	typ, ok := ctx.VariableTypes[i.Function]
+	if !ok {
+		return nil, fmt.Errorf("Unknown function: %s", i.Function)
+	}
+	signature, ok := typ.(*TFunction)
+	if !ok {
+		return nil, fmt.Errorf("Expected function, got %s: %s", typ.String(), i.Function)
+	}
+	if len(i.Args) != len(signature.Args) {
+		return nil, fmt.Errorf("Expecting %d arguments, got %d in %s", len(signature.Args), len(i.Args), i.String())
+	}
+	for j, arg := range i.Args {
+		argType := arg.ReturnType(ctx)
+		if argType == nil {
+			return nil, fmt.Errorf("Unknown type for value %s in %s", arg.String(), i.String())
+		}
+		if !acceptsArgument(signature.Args[j], arg, argType) {
+			return nil, fmt.Errorf("Can't pass %s as %s in %s", argType.String(), signature.Args[j].String(), i.String())
+		}
+		if IsSlice(signature.Args[j]) && argType.Type() == T_Array {
+			i.Args[j] = NewIR_Slice(arg, nil, nil)
+		}
+	}
+	return signature, nil
+}
+
+// Integers aren't converted implicitly, so their types have to be the same,
+// except for integer literals like 2 that fit in the parameter. Arrays of any
+// size can be passed to an array parameter without one, and arrays with a size
+// to a slice parameter with the same item type.
+func acceptsArgument(param Type, arg IRExpression, argType Type) bool {
+	if literal, ok := arg.(*IR_Int64); ok && IsInteger(param) {
+		bits := uint(param.Width()) * 8
+		if IsSignedInteger(param) {
+			return bits == 64 || (literal.Value >= -(1<<(bits-1)) && literal.Value < 1<<(bits-1))
+		}
+		return literal.Value >= 0 && (bits == 64 || literal.Value < 1<<bits)
+	}
+	if array, ok := param.(*TArray); ok && array.Size == 0 {
+		if argArray, ok := argType.(*TArray); ok {
+			return array.ItemType.String() == argArray.ItemType.String()
+		}
+	}
+	if slice, ok := param.(*TSlice); ok {
+		if argArray, ok := argType.(*TArray); ok && argArray.Size > 0 {
+			return slice.ItemType.String() == argArray.ItemType.String()
+		}
 	}
-	return signature.(*TFunction).ReturnType
+	return param.Type() == argType.Type() && param.String() == argType.String()
 }
 
 func (i *IR_Call) String() string {
@@ -0,0 +1,52 @@
+package expr
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Loads the value that the pointer points to. Structs and arrays with a
+// size evaluate to their address, so dereferencing a pointer to one of
+// those doesn't load anything.
+type IR_Dereference struct {
+	*BaseIRExpression
+	Pointer IRExpression
+}
+
+func NewIR_Dereference(pointer IRExpression) *IR_Dereference {
+	return &IR_Dereference{
+		BaseIRExpression: NewBaseIRExpression(Dereference),
+		Pointer:          pointer,
+	}
+}
+
+func (i *IR_Dereference) ReturnType(ctx *IR_Context) Type {
+	ty := i.Pointer.ReturnType(ctx)
+	if ty == nil {
+		return nil
+	}
+	pointer, ok := ty.(*TPointer)
+	if !ok {
+		panic(fmt.Sprintf("Not a pointer: %s", i.Pointer.String()))
+	}
+	return pointer.Target
+}
+
+func (i *IR_Dereference) String() string {
+	return fmt.Sprintf("*(%s)", i.Pointer.String())
+}
+
+func (b *IR_Dereference) AddToDataSection(ctx *IR_Context) error {
+	return b.Pointer.AddToDataSection(ctx)
+}
+
+func (b *IR_Dereference) SSA_Transform(ctx *SSA_Context) (SSA_Rewrites, IRExpression) {
+	if IsLiteralOrVariable(b.Pointer) {
+		return nil, b
+	}
+	rewrites, expr := b.Pointer.SSA_Transform(ctx)
+	v := ctx.GenerateVariable()
+	rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+	return rewrites, NewIR_Dereference(NewIR_Variable(v))
+}
@@ -9,6 +9,10 @@ import (
 type IR_Float64 struct {
 	*BaseIRExpression
 	Value float64
+
+	// Set during EncodeDataSection. The value is loaded from the read-only
+	// data when it has an address.
+	Address *SegmentPointer
 }
 
 func NewIR_Float64(v float64) *IR_Float64 {
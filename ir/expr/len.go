@@ -0,0 +1,42 @@
+package expr
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Evaluates to the length of a slice, or to the size of an array.
+type IR_Len struct {
+	*BaseIRExpression
+	Value IRExpression
+}
+
+func NewIR_Len(value IRExpression) *IR_Len {
+	return &IR_Len{
+		BaseIRExpression: NewBaseIRExpression(Len),
+		Value:            value,
+	}
+}
+
+func (i *IR_Len) ReturnType(ctx *IR_Context) Type {
+	return TUint64
+}
+
+func (i *IR_Len) String() string {
+	return fmt.Sprintf("len(%s)", i.Value.String())
+}
+
+func (b *IR_Len) AddToDataSection(ctx *IR_Context) error {
+	return b.Value.AddToDataSection(ctx)
+}
+
+func (b *IR_Len) SSA_Transform(ctx *SSA_Context) (SSA_Rewrites, IRExpression) {
+	if IsLiteralOrVariable(b.Value) {
+		return nil, b
+	}
+	rewrites, expr := b.Value.SSA_Transform(ctx)
+	v := ctx.GenerateVariable()
+	rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+	return rewrites, NewIR_Len(NewIR_Variable(v))
+}
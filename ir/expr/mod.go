@@ -0,0 +1,59 @@
+package expr
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// The remainder of the integer division Op1 / Op2, which has the sign of
+// Op1.
+type IR_Mod struct {
+	*BaseIRExpression
+	Op1 IRExpression
+	Op2 IRExpression
+}
+
+func NewIR_Mod(op1, op2 IRExpression) *IR_Mod {
+	return &IR_Mod{
+		BaseIRExpression: NewBaseIRExpression(Mod),
+		Op1:              op1,
+		Op2:              op2,
+	}
+}
+
+func (i *IR_Mod) ReturnType(ctx *IR_Context) Type {
+	return i.Op1.ReturnType(ctx)
+}
+
+func (i *IR_Mod) String() string {
+	return fmt.Sprintf("%s %% %s", i.Op1.String(), i.Op2.String())
+}
+
+func (b *IR_Mod) SSA_Transform(ctx *SSA_Context) (SSA_Rewrites, IRExpression) {
+	if IsLiteralOrVariable(b.Op1) {
+		if IsLiteralOrVariable(b.Op2) {
+			return nil, b
+		} else {
+			rewrites, expr := b.Op2.SSA_Transform(ctx)
+			v := ctx.GenerateVariable()
+			rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+			return rewrites, NewIR_Mod(b.Op1, NewIR_Variable(v))
+		}
+	} else {
+		rewrites, expr := b.Op1.SSA_Transform(ctx)
+		v := ctx.GenerateVariable()
+		rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+		if IsLiteralOrVariable(b.Op2) {
+			return rewrites, NewIR_Mod(NewIR_Variable(v), b.Op2)
+		} else {
+			rewrites2, expr2 := b.Op2.SSA_Transform(ctx)
+			for _, rw := range rewrites2 {
+				rewrites = append(rewrites, rw)
+			}
+			v2 := ctx.GenerateVariable()
+			rewrites = append(rewrites, NewSSA_Rewrite(v2, expr2))
+			return rewrites, NewIR_Mod(NewIR_Variable(v), NewIR_Variable(v2))
+		}
+	}
+}
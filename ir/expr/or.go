@@ -28,32 +28,14 @@ func (i *IR_Or) String() string {
 	return fmt.Sprintf("%s || %s", i.Op1.String(), i.Op2.String())
 }
 
+// The second operand is only evaluated when the first one doesn't decide
+// the result, so it can't be hoisted out of the expression.
 func (b *IR_Or) SSA_Transform(ctx *SSA_Context) (SSA_Rewrites, IRExpression) {
 	if IsLiteralOrVariable(b.Op1) {
-		if IsLiteralOrVariable(b.Op2) {
-			return nil, b
-		} else {
-			rewrites, expr := b.Op2.SSA_Transform(ctx)
-			v := ctx.GenerateVariable()
-			rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
-			return rewrites, NewIR_Or(b.Op1, NewIR_Variable(v))
-		}
-	} else {
-		rewrites, expr := b.Op1.SSA_Transform(ctx)
-		v := ctx.GenerateVariable()
-		rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
-		if IsLiteralOrVariable(b.Op2) {
-			return rewrites, NewIR_Or(NewIR_Variable(v), b.Op2)
-		} else {
-			rewrites2, expr2 := b.Op2.SSA_Transform(ctx)
-			for _, rw := range rewrites2 {
-				rewrites = append(rewrites, rw)
-			}
-			v2 := ctx.GenerateVariable()
-			rewrites = append(rewrites, NewSSA_Rewrite(v2, expr2))
-			return rewrites, NewIR_Or(NewIR_Variable(v), NewIR_Variable(v2))
-		}
-
+		return nil, b
 	}
-	return nil, b
+	rewrites, expr := b.Op1.SSA_Transform(ctx)
+	v := ctx.GenerateVariable()
+	rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+	return rewrites, NewIR_Or(NewIR_Variable(v), b.Op2)
 }
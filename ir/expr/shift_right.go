@@ -0,0 +1,60 @@
+package expr
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Shifts Op1 right by Op2, which can be any integer type. The shift is
+// arithmetic for signed integers and logical for unsigned ones. The shift
+// count is taken modulo 32, or 64 for 64 bit values (see IR_ShiftLeft).
+type IR_ShiftRight struct {
+	*BaseIRExpression
+	Op1 IRExpression
+	Op2 IRExpression
+}
+
+func NewIR_ShiftRight(op1, op2 IRExpression) *IR_ShiftRight {
+	return &IR_ShiftRight{
+		BaseIRExpression: NewBaseIRExpression(ShiftRight),
+		Op1:              op1,
+		Op2:              op2,
+	}
+}
+
+func (i *IR_ShiftRight) ReturnType(ctx *IR_Context) Type {
+	return i.Op1.ReturnType(ctx)
+}
+
+func (i *IR_ShiftRight) String() string {
+	return fmt.Sprintf("%s >> %s", i.Op1.String(), i.Op2.String())
+}
+
+func (b *IR_ShiftRight) SSA_Transform(ctx *SSA_Context) (SSA_Rewrites, IRExpression) {
+	if IsLiteralOrVariable(b.Op1) {
+		if IsLiteralOrVariable(b.Op2) {
+			return nil, b
+		} else {
+			rewrites, expr := b.Op2.SSA_Transform(ctx)
+			v := ctx.GenerateVariable()
+			rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+			return rewrites, NewIR_ShiftRight(b.Op1, NewIR_Variable(v))
+		}
+	} else {
+		rewrites, expr := b.Op1.SSA_Transform(ctx)
+		v := ctx.GenerateVariable()
+		rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+		if IsLiteralOrVariable(b.Op2) {
+			return rewrites, NewIR_ShiftRight(NewIR_Variable(v), b.Op2)
+		} else {
+			rewrites2, expr2 := b.Op2.SSA_Transform(ctx)
+			for _, rw := range rewrites2 {
+				rewrites = append(rewrites, rw)
+			}
+			v2 := ctx.GenerateVariable()
+			rewrites = append(rewrites, NewSSA_Rewrite(v2, expr2))
+			return rewrites, NewIR_ShiftRight(NewIR_Variable(v), NewIR_Variable(v2))
+		}
+	}
+}
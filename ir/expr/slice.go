@@ -0,0 +1,81 @@
+package expr
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Slices an array, a slice or a pointer: Value[Low:High]. The result shares
+// the items of the value and has a new header (see TSlice), with the length
+// High - Low and the capacity that's left after Low. High defaults to the
+// length of the value, so it can be nil, except when slicing a pointer.
+type IR_Slice struct {
+	*BaseIRExpression
+	Value IRExpression
+	Low   IRExpression
+	High  IRExpression
+}
+
+func NewIR_Slice(value, low, high IRExpression) *IR_Slice {
+	if low == nil {
+		low = NewIR_Uint64(0)
+	}
+	return &IR_Slice{
+		BaseIRExpression: NewBaseIRExpression(Slice),
+		Value:            value,
+		Low:              low,
+		High:             high,
+	}
+}
+
+func (i *IR_Slice) ReturnType(ctx *IR_Context) Type {
+	switch ty := i.Value.ReturnType(ctx).(type) {
+	case *TArray:
+		return &TSlice{ItemType: ty.ItemType}
+	case *TSlice:
+		return ty
+	case *TPointer:
+		return &TSlice{ItemType: ty.Target}
+	}
+	panic(fmt.Sprintf("Can't slice %s", i.Value.String()))
+}
+
+func (i *IR_Slice) String() string {
+	if i.High == nil {
+		return fmt.Sprintf("%s[%s:]", i.Value.String(), i.Low.String())
+	}
+	return fmt.Sprintf("%s[%s:%s]", i.Value.String(), i.Low.String(), i.High.String())
+}
+
+func (b *IR_Slice) AddToDataSection(ctx *IR_Context) error {
+	if err := b.Value.AddToDataSection(ctx); err != nil {
+		return err
+	}
+	if err := b.Low.AddToDataSection(ctx); err != nil {
+		return err
+	}
+	if b.High != nil {
+		return b.High.AddToDataSection(ctx)
+	}
+	return nil
+}
+
+func (b *IR_Slice) SSA_Transform(ctx *SSA_Context) (SSA_Rewrites, IRExpression) {
+	rewrites := SSA_Rewrites{}
+	operand := func(e IRExpression) IRExpression {
+		if e == nil || IsLiteralOrVariable(e) {
+			return e
+		}
+		rw, expr := e.SSA_Transform(ctx)
+		v := ctx.GenerateVariable()
+		rewrites = append(rewrites, rw...)
+		rewrites = append(rewrites, NewSSA_Rewrite(v, expr))
+		return NewIR_Variable(v)
+	}
+	value, low, high := operand(b.Value), operand(b.Low), operand(b.High)
+	if len(rewrites) == 0 {
+		return nil, b
+	}
+	return rewrites, NewIR_Slice(value, low, high)
+}
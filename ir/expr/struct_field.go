@@ -19,17 +19,26 @@ func NewIR_StructField(structExpr IRExpression, field string) *IR_StructField {
 }
 
 func (i *IR_StructField) ReturnType(ctx *IR_Context) Type {
-	structType := i.Struct.ReturnType(ctx)
-	if str, ok := structType.(*TStruct); !ok {
+	str, ok := i.StructType(ctx)
+	if !ok {
 		panic("Not a struct")
-	} else {
-		for j, f := range str.Fields {
-			if f == i.Field {
-				return str.FieldTypes[j]
-			}
-		}
 	}
-	panic("Field not found")
+	j := str.FieldIndex(i.Field)
+	if j == -1 {
+		panic("Field not found")
+	}
+	return str.FieldTypes[j]
+}
+
+// Returns the type of the struct. Fields can be accessed through pointers
+// to structs as well, which evaluate to the same address as the struct.
+func (i *IR_StructField) StructType(ctx *IR_Context) (*TStruct, bool) {
+	ty := i.Struct.ReturnType(ctx)
+	if pointer, ok := ty.(*TPointer); ok {
+		ty = pointer.Target
+	}
+	str, ok := ty.(*TStruct)
+	return str, ok
 }
 
 func (i *IR_StructField) String() string {
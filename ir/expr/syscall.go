@@ -66,6 +66,8 @@ const (
 	IR_Syscall_Linux_Write IR_Syscall_Linux = 1
 	IR_Syscall_Linux_Open  IR_Syscall_Linux = 2
 	IR_Syscall_Linux_Close IR_Syscall_Linux = 3
+	IR_Syscall_Linux_Mmap  IR_Syscall_Linux = 9
+	IR_Syscall_Linux_Exit  IR_Syscall_Linux = 60
 )
 
 func NewIR_LinuxWrite(fid IRExpression, b []uint8, size int) IRExpression {
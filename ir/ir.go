@@ -1,31 +1,152 @@
 package ir
 
 import (
+	"encoding/binary"
 	"fmt"
 
-	"github.com/bspaans/jit-compiler/asm/x86_64"
-	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
 	"github.com/bspaans/jit-compiler/elf"
 	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/ssa"
+	"github.com/bspaans/jit-compiler/ir/statements"
 	"github.com/bspaans/jit-compiler/lib"
 )
 
-func Compile(targetArchitecture Architecture, abi ABI, stmts []IR, debug bool) (lib.MachineCode, error) {
+func Compile(targetArchitecture Architecture, abi ABI, stmts []IR, debug bool) (*lib.Program, error) {
 	ctx := NewIRContext(targetArchitecture, abi)
-	return CompileWithContext(stmts, debug, ctx)
+	code, err := CompileWithContext(stmts, debug, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newProgram(code, ctx.Segments)
+}
+
+// Returns the program for the code, with the data of the segments. The
+// functions in the executable segment precede the entry point.
+func newProgram(code lib.MachineCode, segments *Segments) (*lib.Program, error) {
+	data, err := loadImports(segments)
+	if err != nil {
+		return nil, err
+	}
+	return &lib.Program{
+		Code:         code,
+		Data:         data,
+		Entry:        len(segments.Segments[Executable].Data),
+		ReadOnlyData: segments.Segments[ReadOnly].Data,
+		Relocations:  segments.ReadOnlyRelocations(),
+	}, nil
 }
 
+// Compiles the statements into an executable. The functions and the main
+// code are placed in the .text section, followed by the entry point, which
+// exits with the value that the main code returns. The data is placed in the
+// .rodata, .data and .bss sections (see elf.Executable). The executable is
+// dynamically linked against the libraries of the extern functions, if there
+// are any.
 func CompileToBinary(targetArchitecture Architecture, abi ABI, stmts []IR, debug bool, path string) error {
 	ctx := NewIRContext(targetArchitecture, abi)
-	ctx.ReturnOperandStack = []lib.Operand{encoding.Rax}
+	ctx.ReturnOperandStack = []lib.Operand{abi.ReturnTypeToOperand(TUint64)}
 	code, err := CompileWithContext(stmts, debug, ctx)
 	if err != nil {
 		return err
 	}
-	return elf.CreateTinyBinary(code, path)
+	segments := ctx.Segments
+	// The main code starts after the functions
+	main := lib.NewFixedLabel("main", len(segments.Segments[Executable].Data))
+	entry := lib.Instructions(targetArchitecture.EncodeEntry(main))
+	if err := entry.Resolve(len(code)); err != nil {
+		return err
+	}
+	entryCode, err := entry.Encode()
+	if err != nil {
+		return err
+	}
+	code = append(code, entryCode...)
+	machine := targetArchitecture.ELFMachine()
+	relocations, err := objectRelocations(machine, segments, 0, len(code))
+	if err != nil {
+		return err
+	}
+	// The zeroes at the end of the data don't have to be stored in the file.
+	data := segments.Segments[ReadWrite].Data
+	initialised := len(data)
+	for initialised > 0 && data[initialised-1] == 0 {
+		initialised--
+	}
+	executable := &elf.Executable{
+		Machine:      machine,
+		Text:         code,
+		ReadOnlyData: segments.Segments[ReadOnly].Data,
+		Data:         data[:initialised],
+		BSSSize:      uint64(len(data) - initialised),
+		Entry:        uint64(len(code) - len(entryCode)),
+		Relocations:  relocations,
+		Imports:      []string{},
+		Libraries:    []string{},
+	}
+	for _, i := range segments.Imports {
+		executable.Imports = append(executable.Imports, i.Name)
+		if !containsString(executable.Libraries, i.Library) {
+			executable.Libraries = append(executable.Libraries, i.Library)
+		}
+	}
+	return elf.CreateExecutable(executable, path)
+}
+
+// Returns the data of the segments, with the addresses of the extern
+// functions filled in. Their libraries get loaded into the process (see
+// elf.LoadLibrary).
+func loadImports(segments *Segments) ([]uint8, error) {
+	data := segments.Data()
+	for _, i := range segments.Imports {
+		library, err := elf.LoadLibrary(i.Library)
+		if err != nil {
+			return nil, err
+		}
+		address, err := library.Lookup(i.Name)
+		if err != nil {
+			return nil, err
+		}
+		// The Imports segment comes first
+		binary.LittleEndian.PutUint64(data[i.Address.Offset:], uint64(address))
+	}
+	return data, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 func CompileWithContext(stmts []IR, debug bool, ctx *IR_Context) (lib.MachineCode, error) {
+	stmts, err := transformSSA(stmts)
+	if err != nil {
+		return nil, err
+	}
+	return encode(stmts, debug, ctx)
+}
+
+// Converts the statements into SSA form, optimises them and converts them
+// back out again before they are encoded (see ssa.Transform).
+func transformSSA(stmts []IR) ([]IR, error) {
+	if len(stmts) == 0 {
+		return stmts, nil
+	}
+	stmt := stmts[0]
+	for _, s := range stmts[1:] {
+		stmt = statements.NewIR_AndThen(stmt, s)
+	}
+	result, err := ssa.Transform(stmt, ssa.ConstantPropagation)
+	if err != nil {
+		return nil, err
+	}
+	return []IR{result}, nil
+}
+
+func encode(stmts []IR, debug bool, ctx *IR_Context) (lib.MachineCode, error) {
 	result := []uint8{}
 	segments, err := ctx.Architecture.EncodeDataSection(stmts, ctx)
 	if err != nil {
@@ -36,54 +157,59 @@ func CompileWithContext(stmts []IR, debug bool, ctx *IR_Context) (lib.MachineCod
 	}
 	// TODO: do this properly
 	ctx.Segments = segments
-	dataSection := segments.Encode()
+	// The functions come first; the entry point is right after them.
+	result = append(result, segments.Encode()...)
+	ctx.InstructionPointer = uint(len(result))
 
-	ctx.InstructionPointer += uint(len(dataSection))
 	if debug {
 		fmt.Println("_start:")
 	}
-	if len(dataSection) > 0 {
-		// TODO make Architecture dependent
-		jmp := x86_64.JMP(encoding.Uint8(len(dataSection)))
-		if debug {
-			fmt.Printf("0x%x: %s\n", 0, jmp.String())
-		}
-		result_, err := jmp.Encode()
-		if err != nil {
-			return nil, err
-		}
-		result = result_
-		if debug {
-			fmt.Println(lib.MachineCode(result_))
-		}
-		result = append(result, dataSection...)
-	} else {
-		ctx.InstructionPointer = 0
+	prologue, err := ctx.Architecture.EncodePrologue(stmts, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding prologue: %s", err.Error())
 	}
-	address := uint(2 + len(dataSection))
-	for _, stmt := range stmts {
-		code, err := ctx.Architecture.EncodeStatement(stmt, ctx)
+	instructions := lib.Instructions(prologue)
+	code := make([][]lib.Instruction, len(stmts))
+	for j, stmt := range stmts {
+		code[j], err = ctx.Architecture.EncodeStatement(stmt, ctx)
 		if err != nil {
 			return nil, fmt.Errorf("Error encoding %s: %s", stmt, err.Error())
 		}
+		instructions = instructions.Add(code[j])
+	}
+	// The labels can only be resolved, and the prologue only be encoded,
+	// once all the statements have been encoded.
+	if err := instructions.Resolve(len(result)); err != nil {
+		return nil, err
+	}
+	relocations, err := instructions.Relocations(len(result))
+	if err != nil {
+		return nil, err
+	}
+	segments.Relocations = append(segments.Relocations, relocations...)
+	encodeInstructions := func(name string, instr []lib.Instruction) error {
 		if debug {
-			fmt.Println("\n:: " + stmt.String() + "\n")
+			fmt.Println("\n:: " + name + "\n")
 		}
-		for _, i := range code {
+		for _, i := range instr {
 			b, err := i.Encode()
 			if err != nil {
-				return nil, fmt.Errorf("Failed to encode %s: %s\n%s", stmt, err.Error(), lib.Instructions(code).String())
+				return fmt.Errorf("Failed to encode %s: %s\n%s", name, err.Error(), lib.Instructions(instr).String())
 			}
 			if debug {
-				fmt.Printf("0x%x-0x%x 0x%x: %s\n", address, address+uint(len(b)), ctx.InstructionPointer, i.String())
-			}
-			address += uint(len(b))
-			if debug {
+				fmt.Printf("0x%x-0x%x: %s\n", len(result), len(result)+len(b), i.String())
 				fmt.Println(lib.MachineCode(b))
 			}
-			for _, code := range b {
-				result = append(result, code)
-			}
+			result = append(result, b...)
+		}
+		return nil
+	}
+	if err := encodeInstructions("prologue", prologue); err != nil {
+		return nil, err
+	}
+	for j, stmt := range stmts {
+		if err := encodeInstructions(stmt.String(), code[j]); err != nil {
+			return nil, err
 		}
 	}
 	if debug {
@@ -1,12 +1,27 @@
 package ir
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
 	"testing"
 
+	"github.com/bspaans/jit-compiler/asm/x86_64/opcodes"
+	"github.com/bspaans/jit-compiler/elf"
+	"github.com/bspaans/jit-compiler/ir/encoding/aarch64"
 	"github.com/bspaans/jit-compiler/ir/encoding/x86_64"
 	. "github.com/bspaans/jit-compiler/ir/expr"
 	. "github.com/bspaans/jit-compiler/ir/shared"
 	. "github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
 )
 
 var TargetArch = &x86_64.X86_64{}
@@ -206,6 +221,16 @@ func Test_ParseExecute_Happy(t *testing.T) {
 		`b = true && false; if !b { f = 53 } else { f = 100 }`,
 		`b = false && true; if !b { f = 53 } else { f = 100 }`,
 		`b = false && false; if !b { f = 53 } else { f = 100 }`,
+		// The second operand would divide by zero
+		`i = 0; if (i != 0) && ((100 / i) == 50) { f = 100 } else { f = 53 }`,
+		`i = 2; if (i != 0) && ((100 / i) == 50) { f = 53 } else { f = 100 }`,
+		`i = 0; if (i == 0) || ((100 / i) == 50) { f = 53 } else { f = 100 }`,
+		`i = 2; if (i == 0) || ((100 / i) == 50) { f = 53 } else { f = 100 }`,
+		`i = 0; if !((i != 0) && ((100 / i) == 50)) { f = 53 } else { f = 100 }`,
+		`i = 0; b = (i != 0) && ((100 / i) == 50); if !b { f = 53 } else { f = 100 }`,
+		`i = 0; b = (i == 0) || ((100 / i) == 50); if b { f = 53 } else { f = 100 }`,
+		`i = 0; b = (i == 1) || ((i != 0) && ((100 / i) == 50)); if b { f = 100 } else { f = 53 }`,
+		`i = 0; f = 51; while (i == 0) || ((100 / i) != 1) { i = i + 50; f = f + 1 }`,
 		`b = 10 > 9; if b { f = 53 } else { f = 100 }`,
 		`b = 10 >= 9; if b { f = 53 } else { f = 100 }`,
 		`b = 10 < 9; if !b { f = 53 } else { f = 100 }`,
@@ -264,10 +289,70 @@ func Test_ParseExecute_Happy(t *testing.T) {
 		`b = struct{Field int64}{53}; f = b.Field`,
 		`b = struct{Field int64
 		            Field2 int64}{51, 53}; f = b.Field2`,
+		`b = struct{A uint8; B uint64; C uint16}{3, 40, 10}; f = (uint64(b.A) + b.B) + uint64(b.C)`,
+		`b = struct{A uint8; P struct{X uint32; Y float64}}{3, struct{X uint32; Y float64}{25, 12.5}}; f = (uint64(b.P.Y * 2.0) + uint64(b.P.X)) + uint64(b.A)`,
+		`b = struct{A bool; D [3]uint16; C uint64}{true, []uint16{10, 43}, 0}; d = b.D; if b.A { f = uint64(d[0]) + uint64(d[1]) } else { f = uint64(100) }`,
 
 		// functions
 		`b = func(i uint64) uint64 { return i - uint64(2) }; f = b(55)`,
 		`func b(i uint64) uint64 { return i - uint64(2)}; f = b(55)`,
+		// Negated comparisons on arguments, which can't be folded
+		`func b(i int64, j int64) int64 { if !(i < j) { return 100 } else { return 53 } }; f = b(1, 3)`,
+		`func b(i int64, j int64) int64 { if !(i >= j) { return 53 } else { return 100 } }; f = b(1, 3)`,
+		`func b(i float64, j float64) int64 { if !(i > j) { return 53 } else { return 100 } }; f = b(1.5, 3.5)`,
+		`func b(i float64) int64 { if (i * 2.0) <= 3.0 { return 53 } else { return 100 } }; f = b(1.5)`,
+		// Arrays and structs that are declared in a function are local to
+		// each call
+		`func b(i int64) int64 { a = []int64{1, 2, 3}; a[0] = a[0] + i; return a[0] }; c = b(5); d = b(46); f = c + d`,
+		`func b(i uint64) uint64 { a = []uint8{1, 2, 3, 4, 5, 6, 7, 8, 9}; a[8] = a[8] + a[8]; return uint64(a[8]) + i }; c = b(uint64(0)); f = b(uint64(35))`,
+		`func b(i int64) int64 { s = struct{X int64
+		            Y int64}{3, 50}; return (s.X * i) + s.Y }; f = b(1)`,
+
+		// bitwise operators, shifts and remainders
+		`f = (60 | 5) - 8`,
+		`f = 255 & 53`,
+		`f = (uint64(1) << uint64(5)) + uint64(21)`,
+		`f = (212 >> 2) ^ 0`,
+		`f = -1 ^ -54`,
+		`f = ^(-54)`,
+		`f = 153 % 100`,
+		`f = (-53 % 100) * -1`,
+		`func b(i uint64, j uint64) uint64 { return (i | j) ^ (i << j) }; f = b(uint64(19), uint64(1))`,
+		`func b(i int64, j uint64) int64 { return i >> j }; f = b(-212, uint64(2)) * -1`,
+		`func b(i uint64, j uint64) uint64 { return i % j }; f = b(uint64(253), uint64(200))`,
+		`func b(i int64, j int64) int64 { return i % j }; f = b(-53, 100) * -1`,
+		// The shift count and the divisor are arguments that live in %rcx and %rdx
+		`func b(i uint64, j uint64, k uint64, l uint64) uint64 { return ((i << l) + k) + (j % k) }; f = b(uint64(3), uint64(9), uint64(4), uint64(4))`,
+		`func b(i int64, j int64, k int64, l int64) int64 { m = i >> l; n = i % k; return ((m + n) + j) + l }; f = b(400, -1, 7, 3)`,
+
+		// pointers
+		`p = alloc(int64); *p = 53; f = *p`,
+		`p = alloc(uint16, 4); *(p + 3) = uint16(53); *p = uint16(1); f = uint64(*(p + 3))`,
+		`p = alloc(uint64, 4); q = p + 3; *q = 54; q = q - 1; f = *(p + 3) - uint64(1)`,
+		`a = []int64{1, 2, 3}; p = &a[1]; *p = 51; f = a[1] + a[1] - 49`,
+		`b = struct{A int64; B int64}{1, 2}; p = &b.B; *p = 52; f = b.A + b.B`,
+		`p = alloc(struct{A uint8; B uint64; C [3]uint8}); p.B = uint64(50); d = p.C; d[2] = uint8(3); e = p.C; f = p.B + uint64(e[2])`,
+		`p = alloc(struct{A uint8; B int64}); q = &p.B; *q = 53; f = (*p).B`,
+		`a = alloc(uint8, 100); b = alloc(uint8); f = (uint64(b) - uint64(a)) - uint64(59)`,
+		`func b(p *int64) int64 { *p = *p + 50; return 0 }; p = alloc(int64); *p = 3; c = b(p); f = *p`,
+		// Linked data stores its pointers as uint64s
+		`h = uint64(0); i = 1; while i < 11 { n = alloc(struct{V int64; N uint64}); n.V = i; n.N = h; h = uint64(n); i = i + 1 }; f = -2; while h != uint64(0) { n = (*struct{V int64; N uint64})(h); f = f + n.V; h = n.N }`,
+		// Allocations that don't fit in the mapped memory map more
+		`i = 0; while i < 300 { p = alloc(uint8, 10000); i = i + 1 }; *p = 53; q = alloc(uint64, 200000); *(q + 199999) = 1; f = uint64(*p) * *(q + 199999)`,
+		// slices
+		`a = []uint64{50, 51, 52, 53}; s = a[1:3]; f = s[1] + len(s) - uint64(1)`,
+		`a = []uint64{50, 51, 52, 53}; s = a[:]; f = s[3] + len(s) - uint64(4)`,
+		`a = []uint64{50, 51, 52, 53}; s = a[2:]; t = s[1:]; f = t[0]`,
+		`a = []uint64{50, 51, 52, 53}; s = a[0:1]; s = s[0:4]; f = s[3]`,
+		`a = []uint8{1, 2, 3, 4}; s = a[1:]; s[2] = uint8(53); f = uint64(a[3])`,
+		`a = []int64{1, 2, 3, 4}; s = a[1:]; p = &s[1]; *p = 53; f = a[2]`,
+		`a = []uint64{50, 51, 52, 53}; f = len(a) + uint64(49)`,
+		`p = alloc(uint64, 10); s = p[0:10]; s[9] = 43; f = s[9] + len(s)`,
+		`func sum(s []uint64) uint64 { r = uint64(0); i = uint64(0); while i < len(s) { r = r + s[i]; i = i + uint64(1) }; return r }; a = []uint64{20, 30, 3}; f = sum(a[:])`,
+		`func tail(s []uint64) []uint64 { return s[1:] }; a = []uint64{50, 51, 52, 53}; s = tail(tail(a[:])); f = s[1]`,
+		`func mk(n uint64) []uint64 { p = alloc(uint64, n); return p[0:n] }; s = mk(uint64(6)); s[5] = 47; f = s[5] + len(s)`,
+		`func tail(s []uint64) []uint64 { return s[1:] }; a = []uint64{50, 51, 52, 53}; s = tail(a[:]); t = tail(tail(s)); f = s[2] + (t[0] - uint64(53))`,
+		`a = []uint64{1, 2, 3}; f = uint64(0); i = 0; while i != 1000000 { s = a[1:]; f = s[1]; i = i + 1 }; f = f + uint64(50)`,
 	}
 	for _, ir := range units {
 		i, err := ParseIR(ir + "; return f")
@@ -392,6 +477,259 @@ func Test_Execute_Result(t *testing.T) {
 	}
 }
 
+// Assigns 0..n-1 to the variables <prefix>0..<prefix>n-1, using more
+// registers than there are available.
+func manyVariables(prefix string, n int, suffix string) string {
+	result := []string{}
+	for i := 0; i < n; i++ {
+		result = append(result, fmt.Sprintf("%s%d = %d%s", prefix, i, i, suffix))
+	}
+	return strings.Join(result, "; ")
+}
+
+// Adds the variables <prefix>0..<prefix>n-1 to f.
+func sumVariables(prefix string, n int) string {
+	result := []string{}
+	for i := 0; i < n; i++ {
+		result = append(result, fmt.Sprintf("f = f + %s%d", prefix, i))
+	}
+	return strings.Join(result, "; ")
+}
+
+func Test_Execute_Register_Pressure(t *testing.T) {
+	units := []string{
+		manyVariables("a", 20, "") + "; f = 0; " + sumVariables("a", 20) + "; f = f - 137",
+		manyVariables("a", 40, "") + "; f = 0; " + sumVariables("a", 40) + "; f = f - 727",
+		manyVariables("g", 20, ".0") + "; h = 0.0; " + strings.Replace(sumVariables("g", 20), "f", "h", -1) + "; f = uint64(h - 137.0)",
+		manyVariables("a", 20, "") + "; i = 0; while i != 53 { i = i + 1 }; f = i; " + sumVariables("a", 20) + "; f = f - 190",
+		manyVariables("a", 20, "") + "; i = 0; while i != 53 { i = i + a1 }; f = i; " + sumVariables("a", 20) + "; f = f - 190",
+		manyVariables("a", 20, "") + "; if a3 == 3 { f = a19 + 34 } else { f = 100 }; " + sumVariables("a", 20) + "; f = f - 190",
+		manyVariables("a", 20, "") + "; if a3 != 3 { f = 100 } else { f = a19 + 34 }; " + sumVariables("a", 20) + "; f = f - 190",
+		manyVariables("a", 20, "") + "; if a3 == 3 { b = a18 + 35 } else { b = 100 }; f = b; " + sumVariables("a", 20) + "; f = f - 190",
+	}
+	for _, ir := range units {
+		i, err := ParseIR(ir + "; return f")
+		if err != nil {
+			t.Fatal(err, "in", ir)
+		}
+		b, err := Compile(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err, "in", ir)
+		}
+		value := b.Execute(false)
+		if value != int(53) {
+			t.Fatal("Expecting 53 got", value, "in", ir, "\n", b)
+		}
+	}
+}
+
+func Test_Execute_Long_Branches(t *testing.T) {
+	// Bodies that are too long for a rel8 jump. They depend on the
+	// arguments, so that they can't be folded away.
+	body := strings.Repeat("j = j + x; ", 40)
+	units := []struct {
+		Body     string
+		Expected int64
+	}{
+		{"i = 0; j = 0; while i != y { " + body + "i = i + x }; r = j", 2120},
+		{"j = 0; if x == 1 { " + body + "r = j + y } else { r = 100 }", 93},
+		{"j = 0; if x != 1 { r = 100 } else { " + body + "r = j + y }", 93},
+		{"j = 0; if x != 1 { " + body + "r = j } else { r = y }", 53},
+		{"r = 13; i = 0; while i != 40 { if i != y { j = 0; " + body + "r = r + j } else { r = 0 }; i = i + x }", 1613},
+	}
+	for _, u := range units {
+		ir := "func f(x int64, y int64) int64 { " + u.Body + "; return r }"
+		i, err := ParseIR(ir)
+		if err != nil {
+			t.Fatal(err, "in", ir)
+		}
+		module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err, "in", ir)
+		}
+		f, _ := module.Lookup("f")
+		if !hasLongJump(t, module.Code[f.offset:]) {
+			t.Fatal("Expecting a rel32 jump in", ir)
+		}
+		value, err := f.Call(int64(1), int64(53))
+		if err != nil {
+			t.Fatal(err, "in", ir)
+		}
+		if value != u.Expected {
+			t.Fatal("Expecting", u.Expected, "got", value, "in", ir)
+		}
+		module.Close()
+	}
+}
+
+// Decodes the code, which can't contain any data, and returns whether it
+// has a jump with a rel32 operand; the rel8 jumps are two bytes long.
+func hasLongJump(t *testing.T, code lib.MachineCode) bool {
+	for len(code) > 0 {
+		instr, length, err := opcodes.DecodeInstruction(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(instr.String(), "j") && length > 2 {
+			return true
+		}
+		code = code[length:]
+	}
+	return false
+}
+
+func Test_Execute_No_Bounds_Checks(t *testing.T) {
+	// s[2] is out of range, but still within the array
+	i, err := ParseIR(`a = []uint64{50, 51, 53, 54}; s = a[0:2]; f = s[2]; return f`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := NewIRContext(TargetArch, TargetABI)
+	withChecks, err := CompileWithContext([]IR{i}, false, checked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewIRContext(TargetArch, TargetABI)
+	ctx.NoBoundsChecks = true
+	code, err := CompileWithContext([]IR{i}, false, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) >= len(withChecks) {
+		t.Fatal("Expecting less code without bounds checks, got", len(code), "and", len(withChecks))
+	}
+	b, err := newProgram(code, ctx.Segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := b.Execute(false)
+	if value != int(53) {
+		t.Fatal("Expecting 53 got", value, "\n", b)
+	}
+}
+
+// The aarch64 code can't be executed here, so this only checks that the
+// programs compile.
+func Test_Compile_AArch64(t *testing.T) {
+	units := []string{
+		`f = 53`,
+		`f = 3 + (100 / 2)`,
+		`h = 25; f = (2 * h) + 3`,
+		`f = -53 / -1`,
+		`f = uint8(51) + uint8(2)`,
+		`f = uint64(53.343)`,
+		`g = 26.5; h = 2.0; f = uint64(g * h)`,
+		`b = func(i uint64) uint64 { return i - uint64(2) }; f = b(55)`,
+		`func b(i uint64, j float64) uint64 { return i - uint64(j)}; f = b(55, 2.0)`,
+		`f = 0; while f != 53 { f = f + 1 }`,
+		`if 1 != 1 { f = 100 } else { f = 53 }`,
+		`f = 1; if !(f < 2) { f = 100 } else { f = 53 }`,
+		`i = 0; if (i != 0) && ((100 / i) == 50) { f = 100 } else { f = 53 }`,
+		`i = 0; b = (i == 0) || ((100 / i) == 50); if b { f = 53 } else { f = 100 }`,
+		`func b(i uint64, j uint64) uint64 { return ((i & j) | (i ^ j)) >> j }; f = b(uint64(106), uint64(1))`,
+		`func b(i int64, j uint64) int64 { return ((^i) << j) % 7 }; f = b(3, uint64(1))`,
+		`a = []uint8{50, 51, 52, 53}; f = uint64(a[3])`,
+		`a = []uint64{50, 51, 52, 53}; a[1] = 53; f = a[1]`,
+		`func b(i uint64) uint64 { a = []uint8{1, 2, 3, 4, 5, 6, 7, 8, 9}; a[i] = a[8]; return uint64(a[i]) }; f = b(uint64(2)) + uint64(44)`,
+		`p = alloc(uint64, 4); *(p + 3) = 53; f = *(p + 3)`,
+		`p = alloc(struct{A uint8; B int64}); q = &p.B; *q = 53; f = p.B`,
+		`p = alloc(struct{A uint8; B int64; C uint64}); f = uint64(3); i = 0; while i < 5 { r = alloc(uint64, 3); *(r + 2) = 10; p = (*struct{A uint8; B int64; C uint64})(r); f = f + p.C; i = i + 1 }`,
+		`a = []uint64{50, 51, 52, 53}; s = a[1:3]; f = s[1] + len(s) - uint64(1)`,
+		`a = []uint8{1, 2, 3, 4}; s = a[1:]; s[2] = uint8(53); p = &s[0]; f = uint64(a[3])`,
+		`func tail(s []uint64) []uint64 { return s[1:] }; a = []uint64{50, 51, 52, 53}; s = tail(a[:]); f = s[2]`,
+		`func tail(s []uint64) []uint64 { return s[1:] }; a = []uint64{50, 51, 52, 53}; s = tail(a[:]); t = tail(tail(s)); f = s[2] + (t[0] - uint64(53))`,
+		manyVariables("a", 40, "") + "; f = 0; " + sumVariables("a", 40) + "; f = f - 727",
+		manyVariables("g", 40, ".0") + "; h = 0.0; " + strings.Replace(sumVariables("g", 40), "f", "h", -1) + "; f = uint64(h - 727.0)",
+		manyVariables("a", 20, "") + "; i = 0; while i != 53 { i = i + a1 }; f = i; " + sumVariables("a", 20) + "; f = f - 190",
+	}
+	for _, ir := range units {
+		i, err := ParseIR(ir + "; return f")
+		if err != nil {
+			t.Fatal(err, "in", ir)
+		}
+		if _, err := Compile(&aarch64.AArch64{}, aarch64.NewABI_AAPCS64(), []IR{i}, false); err != nil {
+			t.Fatal(err, "in", ir)
+		}
+	}
+	syscall := []IR{
+		NewIR_Assignment("f", NewIR_LinuxWrite(NewIR_Uint64(1), []uint8("hello world\n"), 12)),
+		NewIR_Return(NewIR_Variable("f")),
+	}
+	if _, err := Compile(&aarch64.AArch64{}, aarch64.NewABI_AAPCS64(), syscall, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ClassifyCall_AAPCS64(t *testing.T) {
+	abi := aarch64.NewABI_AAPCS64()
+	registers := func(location *ValueLocation) string {
+		names := []string{}
+		for _, reg := range location.Registers {
+			names = append(names, reg.String())
+		}
+		return strings.Join(names, ",")
+	}
+	pair := &TStruct{Fields: []string{"x", "y"}, FieldTypes: []Type{TFloat64, TFloat64}}
+	mixed := &TStruct{Fields: []string{"x", "y"}, FieldTypes: []Type{TUint64, TFloat64}}
+	big := &TStruct{Fields: []string{"a", "b", "c"}, FieldTypes: []Type{TUint64, TUint64, TUint64}}
+
+	args := []Type{TUint64, TFloat64, pair, mixed, big, TInt32, TUint64, TUint64, TUint64, TUint64}
+	layout := abi.ClassifyCall(args, big)
+	expected := []string{"x0", "d0", "d1,d2", "x1,x2", "x3", "x4", "x5", "x6", "x7", ""}
+	for j, location := range layout.Args {
+		if registers(location) != expected[j] {
+			t.Fatal("Expecting", expected[j], "got", registers(location), "for argument", j)
+		}
+	}
+	if !layout.Args[4].Indirect || layout.Args[3].Indirect {
+		t.Fatal("Expecting only structs larger than sixteen bytes to be passed indirectly")
+	}
+	if layout.Args[9].StackOffset != 0 || layout.StackSize != 16 {
+		t.Fatal("Expecting the last argument on the stack, got", layout.Args[9].StackOffset, layout.StackSize)
+	}
+	if layout.ReturnPointer == nil || layout.ReturnPointer.String() != "x8" || !layout.Return.InMemory() {
+		t.Fatal("Expecting the struct to be returned through x8")
+	}
+	if layout := abi.ClassifyCall(nil, &TSlice{ItemType: TUint64}); layout.ReturnPointer == nil || layout.Return.Size != SliceHeaderSize {
+		t.Fatal("Expecting the slice header to be returned through x8")
+	}
+	if r := registers(abi.ClassifyCall(nil, pair).Return); r != "d0,d1" {
+		t.Fatal("Expecting d0,d1 got", r)
+	}
+	if r := registers(abi.ClassifyCall(nil, mixed).Return); r != "x0,x1" {
+		t.Fatal("Expecting x0,x1 got", r)
+	}
+	nested := &TStruct{Fields: []string{"p", "z"}, FieldTypes: []Type{pair, TFloat64}}
+	if r := registers(abi.ClassifyCall(nil, nested).Return); r != "d0,d1,d2" {
+		t.Fatal("Expecting d0,d1,d2 got", r)
+	}
+	if abi.IsCalleeSaved(abi.ReturnTypeToOperand(TUint64)) || abi.IsCalleeSaved(abi.ReturnTypeToOperand(TFloat64)) {
+		t.Fatal("Expecting the return registers to be caller-saved")
+	}
+}
+
+func Test_Struct_Layout(t *testing.T) {
+	inner := &TStruct{Fields: []string{"x", "y"}, FieldTypes: []Type{TUint16, TUint64}}
+	str := &TStruct{
+		Fields:     []string{"a", "b", "c", "n", "tail"},
+		FieldTypes: []Type{TUint8, TInt32, TFloat64, inner, &TArray{ItemType: TUint8, Size: 3}},
+	}
+	layout := str.Layout()
+	if !reflect.DeepEqual(layout.Offsets, []int{0, 4, 8, 16, 32}) {
+		t.Fatal("Expecting offsets [0 4 8 16 32] got", layout.Offsets)
+	}
+	if layout.Size != 40 || layout.Align != 8 {
+		t.Fatal("Expecting size 40 and alignment 8 got", layout.Size, layout.Align)
+	}
+	packed := &TStruct{Fields: []string{"a", "b", "c"}, FieldTypes: []Type{TUint8, TUint16, TBool}}
+	if layout := packed.Layout(); layout.Size != 6 || layout.Align != 2 {
+		t.Fatal("Expecting size 6 and alignment 2 got", layout.Size, layout.Align)
+	}
+	if classes := x86_64.Classify(&TStruct{Fields: []string{"a", "b", "c"}, FieldTypes: []Type{TUint8, TUint32, TFloat64}}); !reflect.DeepEqual(classes, []x86_64.ArgumentClass{x86_64.INTEGER, x86_64.SSE}) {
+		t.Fatal("Expecting INTEGER, SSE got", classes)
+	}
+}
+
 func Test_IR_Length(t *testing.T) {
 
 	ctx := NewIRContext(TargetArch, TargetABI)
@@ -418,3 +756,1170 @@ func Test_IR_Length_does_not_affect_instruction_pointer(t *testing.T) {
 		t.Fatal("InstructionPointer changed")
 	}
 }
+
+func Test_Module_Lookup(t *testing.T) {
+	i, err := ParseIR(`func add(a uint64, b uint64) uint64 { return a + b }
+	func sub(a int64, b int64) int64 { return a - b }
+	func sum(a uint64, b uint64, c uint64, d uint64, e uint64) uint64 { return a + b + c + d + e }
+	func second(a []uint64) uint64 { return a[1] }
+	func third(a [3]uint64) uint64 { return a[2] }
+	func last(s []uint64) uint64 { return s[len(s) - uint64(1)] }
+	func sum8(a int64, b int64, c int64, d int64, e int64, f int64, g int64, h int64) int64 { r = a - b; r = r - c; r = r - d; r = r - e; r = r - f; r = r - g; r = r - h; return r }
+	func sum10(a float64, b float64, c float64, d float64, e float64, f float64, g float64, h float64, i float64, j float64) float64 { r = a - b; r = r - c; r = r - d; r = r - e; r = r - f; r = r - g; r = r - h; r = r - i; r = r - j; return r }
+	func pointsum(p struct {x uint64; y float64}) uint64 { return p.x + uint64(p.y) }
+	func idpoint(p struct {x uint64; y float64}) struct {x uint64; y float64} { return p }
+	func idbig(pad int64, s struct {a uint64; b uint64; c uint64}) struct {a uint64; b uint64; c uint64} { t = s; return t }
+	func dec(a int8, b int8) int8 { return a - b }
+	f = func(a uint8, b uint8) uint8 { return a + b }
+	return 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+
+	units := []struct {
+		Name     string
+		Args     []interface{}
+		Expected interface{}
+	}{
+		{"add", []interface{}{uint64(50), uint64(3)}, uint64(53)},
+		{"sub", []interface{}{int64(3), int64(56)}, int64(-53)},
+		{"sum", []interface{}{uint64(1), uint64(2), uint64(10), uint64(20), uint64(20)}, uint64(53)},
+		{"second", []interface{}{[]uint64{52, 53, 54}}, uint64(53)},
+		{"last", []interface{}{[]uint64{51, 52, 53}}, uint64(53)},
+		{"third", []interface{}{[]uint64{51, 52, 53}}, uint64(53)},
+		{"sum8", []interface{}{int64(89), int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(15)}, int64(53)},
+		{"sum10", []interface{}{98.0, 1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 9.0}, 53.0},
+		{"pointsum", []interface{}{struct {
+			X uint64
+			Y float64
+		}{40, 13.5}}, uint64(53)},
+		{"idpoint", []interface{}{struct {
+			X uint64
+			Y float64
+		}{40, 13.5}}, struct {
+			X uint64
+			Y float64
+		}{40, 13.5}},
+		{"idbig", []interface{}{int64(400), struct{ A, B, C uint64 }{1, 2, 53}}, struct{ A, B, C uint64 }{1, 2, 53}},
+		{"dec", []interface{}{int8(-100), int8(100)}, int8(56)},
+		{"f", []interface{}{uint8(255), uint8(54)}, uint8(53)},
+	}
+	for _, u := range units {
+		f, err := module.Lookup(u.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := f.Call(u.Args...)
+		if err != nil {
+			t.Fatal(err, "in", u.Name)
+		}
+		if result != u.Expected {
+			t.Fatal("Expecting", u.Expected, "got", result, "in", u.Name)
+		}
+	}
+
+	var add func(uint64, uint64) uint64
+	f, _ := module.Lookup("add")
+	if err := f.Bind(&add); err != nil {
+		t.Fatal(err)
+	}
+	if add(50, 3) != 53 {
+		t.Fatal("Expecting 53 got", add(50, 3))
+	}
+	var wrong func(int64, int64) int64
+	if err := f.Bind(&wrong); err == nil {
+		t.Fatal("Expecting an error when binding to the wrong func type")
+	}
+	if _, err := f.Call(uint64(1)); err == nil {
+		t.Fatal("Expecting an error for a missing argument")
+	}
+	if _, err := f.Call(1, 2); err == nil {
+		t.Fatal("Expecting an error for arguments of the wrong type")
+	}
+	if _, err := module.Lookup("unknown"); err == nil {
+		t.Fatal("Expecting an error for an unknown function")
+	}
+	third, _ := module.Lookup("third")
+	for _, arg := range [][]uint64{nil, {51, 52}} {
+		if _, err := third.Call(arg); err == nil {
+			t.Fatal("Expecting an error for an array with", len(arg), "items")
+		}
+	}
+}
+
+func Test_Module_Close(t *testing.T) {
+	modules := []*Module{}
+	for j := 0; j < 200; j++ {
+		i, err := ParseIR(fmt.Sprintf("func f(a uint64) uint64 { return a + uint64(%d) }; return 0", j))
+		if err != nil {
+			t.Fatal(err)
+		}
+		module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		modules = append(modules, module)
+	}
+	// Free every other module and check that the rest still works
+	for j := 0; j < len(modules); j += 2 {
+		if err := modules[j].Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for j, module := range modules {
+		f, err := module.Lookup("f")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := f.Call(uint64(53))
+		if j%2 == 0 {
+			if err == nil {
+				t.Fatal("Expecting an error when calling a function in a closed module")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != uint64(53+j) {
+			t.Fatal("Expecting", 53+j, "got", result)
+		}
+		if err := module.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_Execute_Function_Calls(t *testing.T) {
+	cases := []struct {
+		IR       string
+		Expected int
+	}{
+		{`func g(x uint64) uint64 { return x + uint64(3) }
+		func s(n uint64) uint64 { r = g(n); return r }
+		return s(uint64(50))`, 53},
+		{`func fib(n uint64) uint64 { if n < uint64(2) { return n } else { a = fib(n - uint64(1)); b = fib(n - uint64(2)); return a + b } }
+		return fib(uint64(10))`, 55},
+		{`func g(x uint64) uint64 { return x + uint64(3) }
+		func s(g uint64) uint64 { return g }
+		return s(uint64(53))`, 53},
+	}
+	for _, c := range cases {
+		i, err := ParseIR(c.IR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		program, err := Compile(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err, "in", c.IR)
+		}
+		if result := program.Execute(false); result != c.Expected {
+			t.Fatal("Expecting", c.Expected, "got", result, "in", c.IR)
+		}
+	}
+	for _, ir := range []string{
+		`func s(n uint64) uint64 { r = h(n); return r }; return s(uint64(50))`,
+		`return h(uint64(50))`,
+	} {
+		i, err := ParseIR(ir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Compile(TargetArch, TargetABI, []IR{i}, false); err == nil {
+			t.Fatal("Expecting an error for the unknown function in", ir)
+		}
+	}
+}
+
+func Test_Compile_Call_Arguments(t *testing.T) {
+	for _, ir := range []string{
+		`func h(x float64) float64 { return x }; a = h(2); return 0`,
+		`func h(x uint64, y uint64) uint64 { return x + y }; return h(uint64(1))`,
+		`func h(x uint64) uint64 { return x }; return h(uint64(1), uint64(2))`,
+		`func h(x uint8) uint8 { return x }; a = h(uint64(300)); return 0`,
+		`func h(x uint8) uint8 { return x }; a = h(300); return 0`,
+		`func h(x uint8) uint8 { return x }; func g(y uint64) uint8 { a = h(y); return a }; return 0`,
+		`func h(x int64) int64 { return x }; a = []int64{1, 2}; b = h(a); return 0`,
+		`func h(x []int64) uint64 { return len(x) }; a = []uint8{1, 2}; b = h(a); return 0`,
+	} {
+		i, err := ParseIR(ir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Compile(TargetArch, TargetABI, []IR{i}, false); err == nil {
+			t.Fatal("Expecting an error for the arguments in", ir)
+		}
+	}
+	i, err := ParseIR(`func h(x uint8, y int8) uint8 { z = int8(0); if y < z { return x + uint8(50) } else { return x } }; a = h(3, -1); return uint64(a)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Compile(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := program.Execute(false); result != 53 {
+		t.Fatal("Expecting 53 got", result)
+	}
+}
+
+// Arrays get a slice header when they're passed to a slice parameter.
+func Test_Execute_Stdlib_Write(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	i, err := ParseIR(Stdlib + `
+	func f(fid uint64) int64 {
+		s = []uint8{104, 105, 10}
+		return Write(fid, s, uint64(3))
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+	f, _ := module.Lookup("f")
+	result, err := f.Call(uint64(w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != int64(3) {
+		t.Fatal("Expecting 3 got", result)
+	}
+	buf := make([]byte, 3)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hi\n" {
+		t.Fatalf("Expecting %q got %q", "hi\n", buf)
+	}
+}
+
+func Test_Execute_Float_Args(t *testing.T) {
+	i, err := ParseIR(`func mix(a uint64, x float64, b uint64, y float64) float64 { return (x * y) + float64(a - b) }
+	func half(x float64) float64 { return x / 2.0 }
+	func trunc(x float64) uint64 { return uint64(x) }
+	return 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+	units := []struct {
+		Name     string
+		Args     []interface{}
+		Expected interface{}
+	}{
+		{"mix", []interface{}{uint64(60), 1.5, uint64(10), 2.0}, 53.0},
+		{"half", []interface{}{107.0}, 53.5},
+		{"trunc", []interface{}{53.9}, uint64(53)},
+	}
+	for _, u := range units {
+		f, err := module.Lookup(u.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := f.Call(u.Args...)
+		if err != nil {
+			t.Fatal(err, "in", u.Name)
+		}
+		if result != u.Expected {
+			t.Fatal("Expecting", u.Expected, "got", result, "in", u.Name)
+		}
+	}
+
+	// Calls from the main code, with float variables that are live across
+	// the call
+	programs := []string{
+		"y = half(107.0); return uint64(y)",
+		"a = 2.5; y = half(101.0); z = y + a; return uint64(z)",
+		"a = 1.0; y = half(a + 105.0); z = (y - a) + 1.0; return uint64(z)",
+		"c = 0.5; y = mix(uint64(60), 6.0, uint64(10), c); return uint64(y)",
+	}
+	for _, program := range programs {
+		i, err := ParseIR(`func mix(a uint64, x float64, b uint64, y float64) float64 { return (x * y) + float64(a - b) }
+		func half(x float64) float64 { return x / 2.0 }; ` + program)
+		if err != nil {
+			t.Fatal(err, "in", program)
+		}
+		b, err := Compile(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err, "in", program)
+		}
+		if value := b.Execute(false); value != 53 {
+			t.Fatal("Expecting 53 got", value, "in", program, "\n", b)
+		}
+	}
+}
+
+// Every comparison with NaN is false, except for !=.
+func Test_Execute_Float_Comparisons(t *testing.T) {
+	operators := []struct {
+		Name     string
+		Operator string
+		Go       func(a, b float64) bool
+	}{
+		{"lt", "<", func(a, b float64) bool { return a < b }},
+		{"lte", "<=", func(a, b float64) bool { return a <= b }},
+		{"gt", ">", func(a, b float64) bool { return a > b }},
+		{"gte", ">=", func(a, b float64) bool { return a >= b }},
+		{"eq", "==", func(a, b float64) bool { return a == b }},
+		{"ne", "!=", func(a, b float64) bool { return a != b }},
+	}
+	source := []string{}
+	for _, op := range operators {
+		// As a branch condition, negated, and as a value
+		source = append(source,
+			fmt.Sprintf("func %s(a float64, b float64) uint64 { if a %s b { return uint64(1) } else { return uint64(0) } }", op.Name, op.Operator),
+			fmt.Sprintf("func n%s(a float64, b float64) uint64 { if !(a %s b) { return uint64(0) } else { return uint64(1) } }", op.Name, op.Operator),
+			fmt.Sprintf("func v%s(a float64, b float64) uint64 { c = a %s b; if c { return uint64(1) } else { return uint64(0) } }", op.Name, op.Operator),
+		)
+	}
+	i, err := ParseIR(strings.Join(source, "\n") + "\nreturn 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+	nan := math.NaN()
+	pairs := [][2]float64{
+		{1.5, 2.5}, {2.5, 1.5}, {2.5, 2.5}, {-1.0, 1.0}, {math.Copysign(0, -1), 0},
+		{nan, 1.0}, {1.0, nan}, {nan, nan}, {math.Inf(-1), 1.0}, {math.Inf(1), math.Inf(1)},
+	}
+	for _, op := range operators {
+		for _, prefix := range []string{"", "n", "v"} {
+			f, err := module.Lookup(prefix + op.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, pair := range pairs {
+				expected := uint64(0)
+				if op.Go(pair[0], pair[1]) {
+					expected = 1
+				}
+				result, err := f.Call(pair[0], pair[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result != expected {
+					t.Fatal("Expecting", expected, "got", result, "in", prefix+op.Name, pair)
+				}
+			}
+		}
+	}
+}
+
+func Test_Execute_Integer_Operators(t *testing.T) {
+	operators := []struct {
+		Name     string
+		Operator string
+	}{
+		{"and", "&"}, {"or", "|"}, {"xor", "^"}, {"shl", "<<"}, {"shr", ">>"}, {"mod", "%"},
+	}
+	types := []struct {
+		Name   string
+		Go     reflect.Type
+		Bits   uint
+		Signed bool
+	}{
+		{"uint8", reflect.TypeOf(uint8(0)), 8, false},
+		{"int8", reflect.TypeOf(int8(0)), 8, true},
+		{"uint16", reflect.TypeOf(uint16(0)), 16, false},
+		{"int32", reflect.TypeOf(int32(0)), 32, true},
+		{"uint64", reflect.TypeOf(uint64(0)), 64, false},
+		{"int64", reflect.TypeOf(int64(0)), 64, true},
+	}
+	source := []string{}
+	for _, typ := range types {
+		for _, op := range operators {
+			source = append(source, fmt.Sprintf("func %s%s(a %s, b %s) %s { return a %s b }",
+				op.Name, typ.Name, typ.Name, typ.Name, typ.Name, op.Operator))
+		}
+		source = append(source, fmt.Sprintf("func not%s(a %s, b %s) %s { return ^a }",
+			typ.Name, typ.Name, typ.Name, typ.Name))
+	}
+	i, err := ParseIR(strings.Join(source, "\n") + "\nreturn 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+
+	pairs := [][2]uint64{
+		{53, 7}, {0xf0f0, 0xff}, {1, 63}, {0xffffffffffffffff, 3}, {0x8000000000000081, 9},
+		{100, 33}, {0xfffffffffffffff9, 2}, {12345678901, 0xfffffffffffffffd},
+	}
+	for _, typ := range types {
+		// The shift counts are taken modulo 32, or 64 for 64 bit values
+		countMask := uint64(31)
+		if typ.Bits == 64 {
+			countMask = 63
+		}
+		truncate := func(v uint64) uint64 {
+			return v << (64 - typ.Bits) >> (64 - typ.Bits)
+		}
+		signed := func(v uint64) int64 {
+			return int64(v<<(64-typ.Bits)) >> (64 - typ.Bits)
+		}
+		expected := map[string]func(a, b uint64) uint64{
+			"and": func(a, b uint64) uint64 { return a & b },
+			"or":  func(a, b uint64) uint64 { return a | b },
+			"xor": func(a, b uint64) uint64 { return a ^ b },
+			"not": func(a, b uint64) uint64 { return ^a },
+			"shl": func(a, b uint64) uint64 { return a << (b & countMask) },
+			"shr": func(a, b uint64) uint64 {
+				if typ.Signed {
+					return uint64(signed(a) >> (b & countMask))
+				}
+				return a >> (b & countMask)
+			},
+			"mod": func(a, b uint64) uint64 {
+				if typ.Signed {
+					return uint64(signed(a) % signed(b))
+				}
+				return a % b
+			},
+		}
+		for name, goOp := range expected {
+			f, err := module.Lookup(name + typ.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, pair := range pairs {
+				a, b := truncate(pair[0]), truncate(pair[1])
+				if name == "mod" && (b == 0 || (typ.Signed && signed(b) == -1)) {
+					continue
+				}
+				args := []interface{}{
+					reflect.ValueOf(a).Convert(typ.Go).Interface(),
+					reflect.ValueOf(b).Convert(typ.Go).Interface(),
+				}
+				result, err := f.Call(args...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				value := truncate(reflect.ValueOf(result).Convert(reflect.TypeOf(uint64(0))).Uint())
+				if value != truncate(goOp(a, b)) {
+					t.Fatalf("Expecting 0x%x got 0x%x in %s%s(0x%x, 0x%x)", truncate(goOp(a, b)), value, name, typ.Name, a, b)
+				}
+			}
+		}
+	}
+}
+
+func Test_Execute_Linked_List(t *testing.T) {
+	node := "struct {value int64; next uint64}"
+	i, err := ParseIR(`func push(head uint64, value int64) *` + node + ` {
+		n = alloc(` + node + `)
+		n.value = value
+		n.next = head
+		return n
+	}
+	func sum(n *` + node + `) int64 {
+		s = 0
+		while uint64(n) != uint64(0) {
+			s = s + n.value
+			n = (*` + node + `)(n.next)
+		}
+		return s
+	}
+	return 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+	var push func(uint64, int64) uintptr
+	var sum func(uintptr) int64
+	for name, fn := range map[string]interface{}{"push": &push, "sum": &sum} {
+		f, err := module.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Bind(fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The allocator state is kept between calls
+	head, previous := uintptr(0), uintptr(0)
+	for j := int64(1); j <= 100; j++ {
+		head = push(uint64(head), j)
+		if head == 0 || head%16 != 0 || (previous != 0 && head-previous != 16) {
+			t.Fatalf("Unexpected node address 0x%x after 0x%x", head, previous)
+		}
+		previous = head
+	}
+	if s := sum(head); s != 5050 {
+		t.Fatal("Expecting 5050 got", s)
+	}
+}
+
+// Float constants are loaded from the read-only data, which is relocated
+// into pages of its own that can't be written to.
+func Test_Execute_ReadOnly_Data(t *testing.T) {
+	i, err := ParseIR(`func f(x float64) float64 { return x * 2.25 }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+	readOnly := module.block.ReadOnlyData
+	if len(readOnly) != 8 || binary.LittleEndian.Uint64(readOnly) != math.Float64bits(2.25) {
+		t.Fatal("Expecting 2.25 in the read-only data, got", readOnly)
+	}
+	f, _ := module.Lookup("f")
+	result, err := f.Call(1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != 3.375 {
+		t.Fatal("Expecting 3.375 got", result)
+	}
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expecting a fault when writing to the read-only data")
+		}
+	}()
+	readOnly[0] = 0
+}
+
+func Test_Execute_Large_Executable_Segment(t *testing.T) {
+	// The functions are placed in front of the entry point, which used to
+	// be reached with a jump that only had an 8 bit displacement.
+	source := []string{}
+	for f := 0; f < 3; f++ {
+		body := []string{"a = x + 1"}
+		for j := 0; j < 40; j++ {
+			body = append(body, fmt.Sprintf("a = a + (x * %d)", j))
+		}
+		source = append(source, fmt.Sprintf("func f%d(x int64) int64 { %s ; return a }", f, strings.Join(body, " ; ")))
+	}
+	source = append(source, "b = f0(1)", "c = f2(2)", "d = b + c", "e = 0 + d", "return e")
+	i, err := ParseIR(strings.Join(source, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Compile(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program.Entry < 256 {
+		t.Fatal("Expecting the functions to take up more than 256 bytes, got", program.Entry)
+	}
+	if result := program.Execute(false); result != 2345 {
+		t.Fatal("Expecting 2345 got", result)
+	}
+}
+
+func Test_Execute_Extern(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil || runtime.GOARCH != "amd64" {
+		t.Skip("Needs a C compiler and amd64")
+	}
+	dir := t.TempDir()
+	source := "long triple(long x) { return x * 3; } double scale(long n, double x) { return n * x; }"
+	if err := ioutil.WriteFile(filepath.Join(dir, "triple.c"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	library := filepath.Join(dir, "libtriple.so")
+	if output, err := exec.Command(cc, "-shared", "-fPIC", "-nostdlib", "-o", library, filepath.Join(dir, "triple.c")).CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err.Error(), output)
+	}
+	i, err := ParseIR(fmt.Sprintf(`extern %q func triple(x int64) int64
+	a = 11
+	b = triple(a + 3)
+	return b`, library))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := Compile(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := program.Execute(false); result != 42 {
+		t.Fatal("Expecting 42 got", result)
+	}
+	i, err = ParseIR(fmt.Sprintf(`extern %q func scale(n int64, x float64) float64
+	a = 0.25
+	b = scale(4, 13.0)
+	c = b + a
+	return uint64(c)`, library))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err = Compile(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := program.Execute(false); result != 52 {
+		t.Fatal("Expecting 52 got", result)
+	}
+	i, err = ParseIR(fmt.Sprintf(`extern %q func triple(x int64) int64
+	func f(x int64) int64 { return triple(x) + 2 }
+	b = f(17)
+	return b`, library))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err = Compile(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := program.Execute(false); result != 53 {
+		t.Fatal("Expecting 53 got", result)
+	}
+	if _, err := elf.FindLibrary("libm.so.6"); err == nil {
+		i, err = ParseIR(`extern "libm.so.6" func cos(x float64) float64
+		a = cos(0.0) + 52.0
+		return uint64(a)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		program, err = Compile(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := program.Execute(false); result != 53 {
+			t.Fatal("Expecting 53 got", result)
+		}
+		i, err = ParseIR(`extern "libm.so.6" func cos(x float64) float64
+		func f(x float64) float64 { return cos(x) + 52.0 }
+		a = f(0.0)
+		return uint64(a)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		program, err = Compile(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := program.Execute(false); result != 53 {
+			t.Fatal("Expecting 53 got", result)
+		}
+	}
+	i, err = ParseIR(`extern func triple(x int64) int64; b = triple(6)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Compile(TargetArch, TargetABI, []IR{i}, false); err == nil {
+		t.Fatal("Expecting an error for a function that libc doesn't define")
+	}
+}
+
+// Links the object with a C program that calls its functions, and checks the
+// exit status of the program.
+func Test_CompileToObject_Extern(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil || runtime.GOARCH != "amd64" {
+		t.Skip("Needs a C compiler and amd64")
+	}
+	if _, err := elf.FindLibrary("libm.so.6"); err != nil {
+		t.Skip("Needs libm")
+	}
+	i, err := ParseIR(`extern "libm.so.6" func cos(x float64) float64
+	func g(x float64) float64 { return cos(x) + 50.0 }
+	func f(x float64) float64 { a = g(x); return a + 2.0 }
+	return 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	object := filepath.Join(dir, "f.o")
+	if err := CompileToObject(TargetArch, TargetABI, []IR{i}, false, object); err != nil {
+		t.Fatal(err)
+	}
+	source := "double f(double); int main() { return (int)f(0.0); }"
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.c"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "main")
+	if output, err := exec.Command(cc, "-o", binary, filepath.Join(dir, "main.c"), object, "-lm").CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err.Error(), output)
+	}
+	err = exec.Command(binary).Run()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 53 {
+		t.Fatal("Expecting exit status 53, got", err)
+	}
+}
+
+// Runs the executables and checks their exit status, which is the value that
+// the main code returns.
+func Test_CompileToBinary(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("Needs linux and amd64")
+	}
+	units := []struct {
+		IR       string
+		Expected int
+	}{
+		{`return 53`, 53},
+		{`func g(x uint64) uint64 { return x + uint64(3) }
+		a = []uint64{10, 20}
+		c = a[1]
+		b = g(c)
+		return b + uint64(30)`, 53},
+		{`func h(x float64) float64 { return x * 2.0 }
+		a = h(26.5)
+		b = uint64(a)
+		if b == uint64(53) { return uint64(7) } else { return uint64(3) }`, 7},
+	}
+	if _, err := elf.FindLibrary("libm.so.6"); err == nil {
+		units = append(units, struct {
+			IR       string
+			Expected int
+		}{`extern "libm.so.6" func cos(x float64) float64
+		a = cos(0.0)
+		return uint64(a) + uint64(52)`, 53})
+	}
+	dir := t.TempDir()
+	for j, u := range units {
+		i, err := ParseIR(u.IR)
+		if err != nil {
+			t.Fatal(err, "in", u.IR)
+		}
+		binary := filepath.Join(dir, fmt.Sprintf("test%d", j))
+		if err := CompileToBinary(TargetArch, TargetABI, []IR{i}, false, binary); err != nil {
+			t.Fatal(err, "in", u.IR)
+		}
+		err = exec.Command(binary).Run()
+		if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != u.Expected {
+			t.Fatal("Expecting exit status", u.Expected, "got", err, "in", u.IR)
+		}
+	}
+}
+
+// Links the object with a C program that calls its functions. The main code
+// isn't part of the object, so its return doesn't matter.
+func Test_CompileToObject(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil || runtime.GOARCH != "amd64" {
+		t.Skip("Needs a C compiler and amd64")
+	}
+	i, err := ParseIR(`func fib(n uint64) uint64 { if n < uint64(2) { return n } else { return fib(n - uint64(1)) + fib(n - uint64(2)) } }
+	func f(x float64) uint64 { b = uint64(x * 0.5); return uint64(40) + b + fib(uint64(4)) }
+	return 100`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	object := filepath.Join(dir, "f.o")
+	if err := CompileToObject(TargetArch, TargetABI, []IR{i}, false, object); err != nil {
+		t.Fatal(err)
+	}
+	source := "unsigned long f(double); int main() { return (int)f(20.0); }"
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.c"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "main")
+	if output, err := exec.Command(cc, "-o", binary, filepath.Join(dir, "main.c"), object).CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err.Error(), output)
+	}
+	err = exec.Command(binary).Run()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 53 {
+		t.Fatal("Expecting exit status 53, got", err)
+	}
+}
+
+// check calls the function in its first argument with the second argument,
+// and returns -1 if the function didn't preserve the callee-saved registers.
+// aligned returns its argument, or -1 if the stack wasn't 16 byte aligned at
+// the call.
+const frameChecks = `
+	.text
+	.globl check
+	.globl aligned
+check:
+	push %rbx
+	push %rbp
+	push %r12
+	push %r13
+	push %r14
+	push %r15
+	sub $8, %rsp
+	mov $1, %rbx
+	mov $2, %rbp
+	mov $3, %r12
+	mov $4, %r13
+	mov $5, %r14
+	mov $6, %r15
+	mov %rdi, %rax
+	mov %rsi, %rdi
+	call *%rax
+	cmp $1, %rbx
+	jne 1f
+	cmp $2, %rbp
+	jne 1f
+	cmp $3, %r12
+	jne 1f
+	cmp $4, %r13
+	jne 1f
+	cmp $5, %r14
+	jne 1f
+	cmp $6, %r15
+	je 2f
+1:	mov $-1, %rax
+2:	add $8, %rsp
+	pop %r15
+	pop %r14
+	pop %r13
+	pop %r12
+	pop %rbp
+	pop %rbx
+	ret
+aligned:
+	lea 8(%rsp), %rax
+	test $15, %rax
+	jnz 1f
+	mov %rdi, %rax
+	ret
+1:	mov $-1, %rax
+	ret
+`
+
+func Test_Execute_Stack_Frames(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil || runtime.GOARCH != "amd64" {
+		t.Skip("Needs a C compiler and amd64")
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "frame.S"), []byte(frameChecks), 0644); err != nil {
+		t.Fatal(err)
+	}
+	library := filepath.Join(dir, "libframe.so")
+	if output, err := exec.Command(cc, "-shared", "-nostdlib", "-o", library, filepath.Join(dir, "frame.S")).CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err.Error(), output)
+	}
+	so, err := elf.LoadLibrary(library)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, err := so.Lookup("check")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A function that needs more registers than the caller-saved ones
+	variables := []string{}
+	for j := 0; j < 12; j++ {
+		variables = append(variables, fmt.Sprintf("b%d = a + uint64(%d)", j, j))
+	}
+	i, err := ParseIR("func f(a uint64) uint64 { " + strings.Join(variables, "; ") + "; f = uint64(0); " + sumVariables("b", 12) + "; return f }; return 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+	f, err := module.Lookup("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _, err := lib.CallSysV(check, []uint64{uint64(module.block.Address()) + uint64(f.offset), 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != 78 {
+		t.Fatal("Expecting 78 got", int64(result))
+	}
+
+	// Calls with an odd and an even number of registers pushed
+	units := []string{
+		"b = aligned(53); return b",
+		"a = 50; b = aligned(a + 3); return b",
+		"a = 50; c = 3; b = aligned(a); d = b + c; return d",
+		"a = 50; c = 2; e = 1; b = aligned(a); d = b + c + e; return d",
+	}
+	for _, ir := range units {
+		i, err := ParseIR(fmt.Sprintf("extern %q func aligned(x int64) int64; ", library) + ir)
+		if err != nil {
+			t.Fatal(err, "in", ir)
+		}
+		program, err := Compile(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err, "in", ir)
+		}
+		if result := program.Execute(false); result != 53 {
+			t.Fatal("Expecting 53 got", result, "in", ir, "\n", program)
+		}
+	}
+}
+
+// Functions that take their arguments in every way the calling convention
+// passes them, and functions that call a function pointer that way.
+const callLayouts = `
+struct point { unsigned long x; double y; };
+struct big { unsigned long a, b, c; };
+struct small { unsigned char a; unsigned int b; double c; };
+struct mixed { unsigned char a; unsigned int b; double c; struct { unsigned short x; unsigned long y; } n; unsigned char tail[3]; };
+
+long sum8(long a, long b, long c, long d, long e, long f, long g, long h) {
+	return ((((((a * 2 + b) * 2 + c) * 2 + d) * 2 + e) * 2 + f) * 2 + g) * 2 + h;
+}
+double sum10(double a, double b, double c, double d, double e, double f, double g, double h, double i, double j) {
+	return ((((((((a * 2 + b) * 2 + c) * 2 + d) * 2 + e) * 2 + f) * 2 + g) * 2 + h) * 2 + i) * 2 + j;
+}
+unsigned long pointsum(struct point p) { return p.x + (unsigned long)p.y; }
+unsigned long bigsum(long pad, struct big b) { return pad + b.a * 100 + b.b * 10 + b.c; }
+struct point makepoint(unsigned long x) { struct point p = {x, 2.0 * x}; return p; }
+struct big makebig(unsigned long x) { struct big b = {x, x + 1, x + 2}; return b; }
+unsigned long smallsum(struct small s) { return s.a + s.b * 10 + (unsigned long)s.c * 100; }
+struct small makesmall(unsigned long x) { struct small s = {x, x + 1, 2.0 * x}; return s; }
+unsigned long mixedsum(struct mixed m) {
+	return m.a + m.b * 10 + (unsigned long)m.c * 100 + m.n.x * 1000 + m.n.y * 10000 + m.tail[2] * 100000;
+}
+int add32(int a, int b) { return a + b; }
+unsigned char add8(unsigned char a, unsigned char b) { return a + b; }
+_Bool positive(long a) { return a > 0; }
+long pick(_Bool c, long a, long b) { return c ? a : b; }
+long narrow8(long a, long b, long c, long d, long e, int f, unsigned char g, int h) {
+	return a + b + c + d + e + f + g + h;
+}
+double scale(unsigned char a, double x, int b, double y) { return a * x + b * y; }
+
+long call8(long (*f)(long, long, long, long, long, long, long, long)) {
+	return f(1, 2, 3, 4, 5, 6, 7, 8);
+}
+double call10(double (*f)(double, double, double, double, double, double, double, double, double, double)) {
+	return f(1, 2, 3, 4, 5, 6, 7, 8, 9, 10);
+}
+unsigned long callpoint(struct point (*f)(struct point)) {
+	struct point p = {40, 13.5};
+	struct point q = f(p);
+	return q.x + (unsigned long)q.y;
+}
+unsigned long callbig(struct big (*f)(long, struct big)) {
+	struct big b = {1, 2, 3};
+	struct big c = f(400, b);
+	return c.a * 100 + c.b * 10 + c.c;
+}
+unsigned long callsmall(struct small (*f)(struct small)) {
+	struct small s = {3, 4, 5.0};
+	struct small q = f(s);
+	return q.a + q.b * 10 + (unsigned long)q.c * 100;
+}
+long call32(int (*f)(int, int)) { return f(-50, 103); }
+long callpositive(_Bool (*f)(long)) { return f(3) * 50 + f(-3) * 7 + 3; }
+long callnarrow8(int (*f)(long, long, long, long, long, int, unsigned char, int)) {
+	return f(1, 2, 3, 4, 5, -6, 250, 59);
+}
+long callscale(double (*f)(unsigned char, double, int, double)) { return f(2, 1.5, -10, -5.0); }
+`
+
+func Test_Execute_Call_Layouts(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil || runtime.GOARCH != "amd64" {
+		t.Skip("Needs a C compiler and amd64")
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "layouts.c"), []byte(callLayouts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	library := filepath.Join(dir, "liblayouts.so")
+	if output, err := exec.Command(cc, "-shared", "-fPIC", "-nostdlib", "-fno-builtin", "-O1", "-o", library, filepath.Join(dir, "layouts.c")).CombinedOutput(); err != nil {
+		t.Fatalf("%s: %s", err.Error(), output)
+	}
+	point := "struct {x uint64; y float64}"
+	big := "struct {a uint64; b uint64; c uint64}"
+	// Padded like in C, with the small struct passed in a general purpose
+	// and an xmm register.
+	small := "struct {a uint8; b uint32; c float64}"
+	mixed := "struct {a uint8; b uint32; c float64; n struct {x uint16; y uint64}; tail [3]uint8}"
+	externs := fmt.Sprintf(`extern %[1]q func sum8(a int64, b int64, c int64, d int64, e int64, f int64, g int64, h int64) int64
+	extern %[1]q func sum10(a float64, b float64, c float64, d float64, e float64, f float64, g float64, h float64, i float64, j float64) float64
+	extern %[1]q func pointsum(p %[2]s) uint64
+	extern %[1]q func bigsum(pad int64, b %[3]s) uint64
+	extern %[1]q func makepoint(x uint64) %[2]s
+	extern %[1]q func makebig(x uint64) %[3]s
+	extern %[1]q func smallsum(s %[4]s) uint64
+	extern %[1]q func makesmall(x uint64) %[4]s
+	extern %[1]q func mixedsum(m %[5]s) uint64
+	extern %[1]q func add32(a int32, b int32) int32
+	extern %[1]q func add8(a uint8, b uint8) uint8
+	extern %[1]q func positive(a int64) bool
+	extern %[1]q func pick(c bool, a int64, b int64) int64
+	extern %[1]q func narrow8(a int64, b int64, c int64, d int64, e int64, f int32, g uint8, h int32) int64
+	extern %[1]q func scale(a uint8, x float64, b int32, y float64) float64
+	`, library, point, big, small, mixed)
+
+	// Calls from the main code into C
+	units := []struct {
+		IR       string
+		Expected int
+	}{
+		{"b = sum8(1, 2, 3, 4, 5, 6, 7, 8); return b", 502},
+		{"a = 3; c = 5; b = sum8(1, 2, a, 4, c, 6, 7, 8); d = b + (a + c); return d", 510},
+		{"x = sum10(1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 9.0, 10.0); return uint64(x)", 2036},
+		{"p = " + point + "{40, 13.5}; b = pointsum(p); return b", 53},
+		{"s = " + big + "{1, 2, 3}; b = bigsum(400, s); return b", 523},
+		{"p = makepoint(uint64(20)); b = p.x + uint64(p.y); return b", 60},
+		{"s = makebig(uint64(5)); x = s.a; y = s.b; z = s.c; b = (x * uint64(100)) + ((y * uint64(10)) + z); return b", 567},
+		{"s = makebig(uint64(1)); t = makebig(uint64(5)); x = s.a; b = (x * uint64(100)) + t.c; return b", 107},
+		{"s = " + small + "{3, 4, 5.0}; b = smallsum(s); return b", 543},
+		{"s = makesmall(uint64(7)); b = (uint64(s.a) + (uint64(s.b) * uint64(10))) + (uint64(s.c) * uint64(100)); return b", 1487},
+		{"m = " + mixed + "{1, 2, 3.0, struct {x uint16; y uint64}{4, 5}, []uint8{0, 0, 6}}; b = mixedsum(m); return b", 654321},
+		{"b = add32(int32(-50), int32(103)); c = int32(53); if b == c { d = 53 } else { d = 1 }; return d", 53},
+		{"b = add8(uint8(250), uint8(59)); return uint64(b)", 53},
+		{"p = positive(3); if p { b = 50 } else { b = 0 }; q = positive(-3); if q { c = 0 } else { c = 3 }; d = b + c; return d", 53},
+		{"b = pick(true, 53, 1); c = pick(false, 1, 2); d = b * c; return d", 106},
+		{"b = narrow8(1, 2, 3, 4, 5, int32(-6), uint8(250), int32(-206)); return b", 53},
+		{"x = scale(uint8(2), 1.5, int32(-10), -5.0); return uint64(x)", 53},
+	}
+	for _, u := range units {
+		i, err := ParseIR(externs + u.IR)
+		if err != nil {
+			t.Fatal(err, "in", u.IR)
+		}
+		program, err := Compile(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err, "in", u.IR)
+		}
+		if result := program.Execute(false); result != u.Expected {
+			t.Fatal("Expecting", u.Expected, "got", result, "in", u.IR, "\n", program)
+		}
+	}
+
+	// Calls from C into JIT compiled functions
+	names := "abcdefghij"
+	ints, floats, horner, fhorner := []string{}, []string{}, "r = a", "r = a"
+	for j := 0; j < len(names); j++ {
+		if j < 8 {
+			ints = append(ints, names[j:j+1]+" int64")
+		}
+		floats = append(floats, names[j:j+1]+" float64")
+		if j > 0 && j < 8 {
+			horner += fmt.Sprintf("; r = (r * 2) + %c", names[j])
+		}
+		if j > 0 {
+			fhorner += fmt.Sprintf("; r = (r * 2.0) + %c", names[j])
+		}
+	}
+	functions := []string{
+		fmt.Sprintf("func f8(%s) int64 { %s; return r }\n", strings.Join(ints, ", "), horner),
+		fmt.Sprintf("func f10(%s) float64 { %s; return r }\n", strings.Join(floats, ", "), fhorner),
+		fmt.Sprintf("func idpoint(p %s) %[1]s { return p }\n", point),
+		fmt.Sprintf("func idbig(pad int64, s %s) %[1]s { t = s; return t }\n", big),
+		fmt.Sprintf("func idsmall(s %s) %[1]s { return s }\n", small),
+		"func j32(a int32, b int32) int32 { r = a + b; return r }\n",
+		"func jpositive(a int64) bool { return a > 0 }\n",
+		"func jnarrow8(a int64, b int64, c int64, d int64, e int64, f int32, g uint8, h int32) int32 { k = uint8(250); if g == k { r = f + h } else { r = f }; return r }\n",
+		"func jscale(a uint8, x float64, b int32, y float64) float64 { r = float64(uint64(a)) * x; k = int32(-10); if b == k { r = r - (10.0 * y) } else { r = 0.0 }; return r }\n",
+	}
+	i, err := ParseIR(strings.Join(functions, "") + "return 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := CompileModule(TargetArch, TargetABI, []IR{i}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+	so, err := elf.LoadLibrary(library)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbacks := []struct {
+		Driver   string
+		Function string
+		Expected uint64
+	}{
+		{"call8", "f8", 502},
+		{"callpoint", "idpoint", 53},
+		{"callbig", "idbig", 123},
+		{"callsmall", "idsmall", 543},
+		{"call32", "j32", 53},
+		{"callpositive", "jpositive", 53},
+		{"callnarrow8", "jnarrow8", 53},
+		{"callscale", "jscale", 53},
+	}
+	for _, c := range callbacks {
+		driver, err := so.Lookup(c.Driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := module.Lookup(c.Function)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, _, err := lib.CallSysV(driver, []uint64{uint64(module.block.Address()) + uint64(f.offset)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != c.Expected {
+			t.Fatal("Expecting", c.Expected, "got", int64(result), "in", c.Driver)
+		}
+	}
+	driver, err := so.Lookup("call10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := module.Lookup("f10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, result, err := lib.CallSysV(driver, []uint64{uint64(module.block.Address()) + uint64(f.offset)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != 2036 {
+		t.Fatal("Expecting 2036 got", result)
+	}
+
+	// Calls between JIT compiled functions
+	units = []struct {
+		IR       string
+		Expected int
+	}{
+		{functions[0] + "b = f8(1, 2, 3, 4, 5, 6, 7, 8); return b", 502},
+		{functions[0] + "a = 7; c = 1; b = f8(c, 2, 3, 4, 5, 6, a, 8); d = b + (a + c); return d", 510},
+		{functions[1] + "x = f10(1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0, 9.0, 10.0); return uint64(x)", 2036},
+		{functions[2] + "p = idpoint(" + point + "{40, 13.5}); b = p.x + uint64(p.y); return b", 53},
+		{functions[3] + "s = idbig(400, " + big + "{1, 2, 3}); x = s.a; y = s.b; z = s.c; b = (x * uint64(100)) + ((y * uint64(10)) + z); return b", 123},
+		{functions[5] + "b = j32(int32(-50), int32(103)); c = int32(53); if b == c { d = 53 } else { d = 1 }; return d", 53},
+		{functions[6] + "p = jpositive(3); if p { b = 50 } else { b = 0 }; q = jpositive(-3); if q { c = 0 } else { c = 3 }; d = b + c; return d", 53},
+		{functions[7] + "b = jnarrow8(1, 2, 3, 4, 5, int32(-6), uint8(250), int32(59)); c = int32(53); if b == c { d = 53 } else { d = 1 }; return d", 53},
+		{functions[8] + "x = jscale(uint8(2), 1.5, int32(-10), -5.0); return uint64(x)", 53},
+	}
+	for _, u := range units {
+		i, err := ParseIR(u.IR)
+		if err != nil {
+			t.Fatal(err, "in", u.IR)
+		}
+		program, err := Compile(TargetArch, TargetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err, "in", u.IR)
+		}
+		if result := program.Execute(false); result != u.Expected {
+			t.Fatal("Expecting", u.Expected, "got", result, "in", u.IR, "\n", program)
+		}
+	}
+}
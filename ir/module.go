@@ -0,0 +1,449 @@
+package ir
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+	"github.com/bspaans/jit-compiler/lib"
+)
+
+// A Module is a compiled program that has been loaded into a code cache. The
+// functions that it defines can be called from Go (see Module.Lookup).
+type Module struct {
+	Code      lib.MachineCode
+	functions map[string]*CompiledFunction
+	block     *lib.CodeBlock
+	abi       ABI
+}
+
+// Compiles the statements and adds the machine code to the DefaultCodeCache.
+func CompileModule(targetArchitecture Architecture, abi ABI, stmts []IR, debug bool) (*Module, error) {
+	ctx := NewIRContext(targetArchitecture, abi)
+	stmts, err := transformSSA(stmts)
+	if err != nil {
+		return nil, err
+	}
+	code, err := encode(stmts, debug, ctx)
+	if err != nil {
+		return nil, err
+	}
+	program, err := newProgram(code, ctx.Segments)
+	if err != nil {
+		return nil, err
+	}
+	block, err := lib.DefaultCodeCache.Add(program)
+	if err != nil {
+		return nil, err
+	}
+	module := &Module{
+		Code:      code,
+		functions: map[string]*CompiledFunction{},
+		block:     block,
+		abi:       abi,
+	}
+	for _, stmt := range stmts {
+		walkFunctions(stmt, func(name string, f *expr.IR_Function) {
+			module.addFunction(name, f, ctx.Segments)
+		})
+	}
+	return module, nil
+}
+
+// Calls fn for the functions that are defined by the statement, either with
+// a function definition or by assigning a function to a variable.
+func walkFunctions(stmt IR, fn func(name string, f *expr.IR_Function)) {
+	switch v := stmt.(type) {
+	case *statements.IR_AndThen:
+		walkFunctions(v.Stmt1, fn)
+		walkFunctions(v.Stmt2, fn)
+	case *statements.IR_FunctionDef:
+		fn(v.Name, v.Expr)
+	case *statements.IR_Assignment:
+		if f, ok := v.Expr.(*expr.IR_Function); ok {
+			fn(v.Variable, f)
+		}
+	}
+}
+
+func (m *Module) addFunction(name string, f *expr.IR_Function, segments *Segments) {
+	if f.Address == nil {
+		return
+	}
+	m.functions[name] = &CompiledFunction{
+		Name:      name,
+		Signature: f.Signature,
+		module:    m,
+		offset:    segments.GetAddress(f.Address),
+	}
+}
+
+// Returns the function with the given name.
+func (m *Module) Lookup(name string) (*CompiledFunction, error) {
+	f, found := m.functions[name]
+	if !found {
+		return nil, fmt.Errorf("Unknown function '%s'", name)
+	}
+	return f, nil
+}
+
+// Removes the code from the code cache. The functions in the module can't be
+// called afterwards.
+func (m *Module) Close() error {
+	if m.block == nil {
+		return nil
+	}
+	err := m.block.Free()
+	m.block = nil
+	return err
+}
+
+// A compiled function in a Module. It's called following the System V AMD64
+// calling convention (see lib.CallSysV).
+type CompiledFunction struct {
+	Name      string
+	Signature *TFunction
+	module    *Module
+	offset    int
+}
+
+// Calls the function. The arguments need to have the Go types that
+// correspond to the types in the signature (see GoType); arrays are passed
+// as slices that have at least as many items as the array, of which only
+// the pointer to the first element is passed on, and slices get a header
+// that points into the Go slice (see TSlice). The arguments are passed
+// where the ABI of the module puts them, so structs are passed by value and
+// arguments that don't fit in the registers on the stack, up to
+// lib.MaxStackArguments eightbytes. The result has the Go type
+// that corresponds to the return type.
+func (f *CompiledFunction) Call(args ...interface{}) (interface{}, error) {
+	values := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		values[i] = reflect.ValueOf(arg)
+	}
+	result, err := f.call(values)
+	if err != nil {
+		return nil, err
+	}
+	return result.Interface(), nil
+}
+
+// Sets fn, which must be a pointer to a func variable with the Go types that
+// correspond to the signature, to a func that calls this function, e.g.
+//
+//	var add func(uint64, uint64) uint64
+//	err := f.Bind(&add)
+//
+// The bound func panics if the function can't be called, e.g. because the
+// module has been closed.
+func (f *CompiledFunction) Bind(fn interface{}) error {
+	ptr := reflect.ValueOf(fn)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Func {
+		return fmt.Errorf("Expecting a pointer to a func, got %T", fn)
+	}
+	fnType := ptr.Elem().Type()
+	expected, err := f.goType()
+	if err != nil {
+		return err
+	}
+	if fnType != expected {
+		return fmt.Errorf("Expecting a func of type %s for %s, got %s", expected, f.Name, fnType)
+	}
+	ptr.Elem().Set(reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		result, err := f.call(args)
+		if err != nil {
+			panic(err)
+		}
+		return []reflect.Value{result}
+	}))
+	return nil
+}
+
+func (f *CompiledFunction) goType() (reflect.Type, error) {
+	args := make([]reflect.Type, len(f.Signature.Args))
+	for i, arg := range f.Signature.Args {
+		ty, err := GoType(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = ty
+	}
+	returnType, err := GoType(f.Signature.ReturnType)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.FuncOf(args, []reflect.Type{returnType}, false), nil
+}
+
+func (f *CompiledFunction) call(args []reflect.Value) (reflect.Value, error) {
+	if f.module.block == nil {
+		return reflect.Value{}, fmt.Errorf("Can't call %s: the module has been closed", f.Name)
+	}
+	if len(args) != len(f.Signature.Args) {
+		return reflect.Value{}, fmt.Errorf("Expecting %d arguments for %s, got %d", len(f.Signature.Args), f.Name, len(args))
+	}
+	returnType, err := GoType(f.Signature.ReturnType)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if returnType.Kind() == reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("Unsupported return type %s in %s", f.Signature.ReturnType, f.Name)
+	}
+	layout := f.module.abi.ClassifyCall(f.Signature.Args, f.Signature.ReturnType)
+	ints, floats := []uint64{}, []float64{}
+	stack := make([]uint64, layout.StackSize/8)
+	// A return value that is passed in memory is written here
+	var returned []uint64
+	if layout.ReturnPointer != nil {
+		returned = make([]uint64, layout.Return.Size/8)
+		ints = append(ints, uint64(reflect.ValueOf(returned).Pointer()))
+	}
+	headers := [][]uint64{}
+	for i, arg := range args {
+		ty, err := GoType(f.Signature.Args[i])
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if !arg.IsValid() || arg.Type() != ty {
+			return reflect.Value{}, fmt.Errorf("Expecting argument %d of %s to be a %s, got %s", i+1, f.Name, ty, arg)
+		}
+		// Only the pointer to an array is passed, so the function would read
+		// past the end of a shorter slice
+		if array, ok := f.Signature.Args[i].(*TArray); ok && arg.Len() < array.Size {
+			return reflect.Value{}, fmt.Errorf("Expecting argument %d of %s to have at least %d items, got %d", i+1, f.Name, array.Size, arg.Len())
+		}
+		var words []uint64
+		if str, ok := f.Signature.Args[i].(*TStruct); ok {
+			words = toEightbytes(str, arg)
+		} else if IsSlice(f.Signature.Args[i]) {
+			header := []uint64{uint64(arg.Pointer()), uint64(arg.Len()), uint64(arg.Cap())}
+			headers = append(headers, header)
+			words = []uint64{uint64(reflect.ValueOf(header).Pointer())}
+		} else {
+			words = []uint64{scalarBits(arg)}
+		}
+		location := layout.Args[i]
+		if location.InMemory() {
+			copy(stack[location.StackOffset/8:], words)
+			continue
+		}
+		for j, reg := range location.Registers {
+			if reg.Width() == lib.OWORD {
+				floats = append(floats, math.Float64frombits(words[j]))
+			} else {
+				ints = append(ints, words[j])
+			}
+		}
+	}
+	address := f.module.block.Address() + uintptr(f.offset)
+	registers, err := lib.CallSysVStack(address, ints, floats, stack)
+	// The slices are only referenced by their address during the call
+	runtime.KeepAlive(args)
+	runtime.KeepAlive(headers)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	// Otherwise it's gathered from the registers that the layout says
+	words := returned
+	if words == nil {
+		intResults, floatResults := registers.Ints[:], registers.Floats[:]
+		for _, reg := range layout.Return.Registers {
+			if reg.Width() == lib.OWORD {
+				words = append(words, math.Float64bits(floatResults[0]))
+				floatResults = floatResults[1:]
+			} else {
+				words = append(words, intResults[0])
+				intResults = intResults[1:]
+			}
+		}
+	}
+	result := reflect.New(returnType).Elem()
+	if str, ok := f.Signature.ReturnType.(*TStruct); ok {
+		fromEightbytes(str, words, result)
+	} else {
+		setScalar(result, words[0])
+	}
+	return result, nil
+}
+
+// Returns the Go type that corresponds to the IR type: the integer types,
+// float64 and bool map onto their Go equivalents, pointers onto uintptr,
+// arrays and slices onto slices and structs onto Go structs with the same
+// layout, in which the first letters of the field names are capitalized.
+func GoType(ty Type) (reflect.Type, error) {
+	switch ty.Type() {
+	case T_Uint8:
+		return reflect.TypeOf(uint8(0)), nil
+	case T_Uint16:
+		return reflect.TypeOf(uint16(0)), nil
+	case T_Uint32:
+		return reflect.TypeOf(uint32(0)), nil
+	case T_Uint64:
+		return reflect.TypeOf(uint64(0)), nil
+	case T_Int8:
+		return reflect.TypeOf(int8(0)), nil
+	case T_Int16:
+		return reflect.TypeOf(int16(0)), nil
+	case T_Int32:
+		return reflect.TypeOf(int32(0)), nil
+	case T_Int64:
+		return reflect.TypeOf(int64(0)), nil
+	case T_Float64:
+		return reflect.TypeOf(float64(0)), nil
+	case T_Bool:
+		return reflect.TypeOf(false), nil
+	case T_Pointer:
+		return reflect.TypeOf(uintptr(0)), nil
+	case T_Array:
+		item, err := GoType(ty.(*TArray).ItemType)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(item), nil
+	case T_Slice:
+		item, err := GoType(ty.(*TSlice).ItemType)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(item), nil
+	case T_Struct:
+		return goStructType(ty.(*TStruct))
+	}
+	return nil, fmt.Errorf("Type %s can't be passed to or from Go", ty)
+}
+
+func goStructType(str *TStruct) (reflect.Type, error) {
+	fields := make([]reflect.StructField, len(str.Fields))
+	names := map[string]bool{}
+	for i, name := range str.Fields {
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if names[name] {
+			return nil, fmt.Errorf("Type %s can't be passed to or from Go: duplicate field %s", str, name)
+		}
+		names[name] = true
+		ty, err := goFieldType(str.FieldTypes[i])
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = reflect.StructField{Name: name, Type: ty}
+	}
+	result := reflect.StructOf(fields)
+	if int(result.Size()) != Sizeof(str) {
+		return nil, fmt.Errorf("Type %s can't be passed to or from Go: its layout differs", str)
+	}
+	return result, nil
+}
+
+// Inside structs arrays with a size are stored inline and arrays without
+// one and slices as pointers.
+func goFieldType(ty Type) (reflect.Type, error) {
+	switch t := ty.(type) {
+	case *TArray:
+		if t.Size == 0 {
+			return reflect.TypeOf(uintptr(0)), nil
+		}
+		item, err := goFieldType(t.ItemType)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ArrayOf(t.Size, item), nil
+	case *TSlice:
+		return reflect.TypeOf(uintptr(0)), nil
+	}
+	return GoType(ty)
+}
+
+// Returns the bits of a scalar value as they are passed in a register.
+func scalarBits(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.Float64:
+		return math.Float64bits(v.Float())
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int())
+	case reflect.Bool:
+		if v.Bool() {
+			return 1
+		}
+		return 0
+	case reflect.Slice:
+		return uint64(v.Pointer())
+	}
+	return v.Uint()
+}
+
+// Sets the scalar value to the bits in the lower bytes of a register.
+func setScalar(v reflect.Value, bits uint64) {
+	switch v.Kind() {
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(bits))
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(bits))
+	case reflect.Bool:
+		v.SetBool(uint8(bits) != 0)
+	default:
+		v.SetUint(bits)
+	}
+}
+
+// Returns the eightbytes of the struct in memory (see TStruct.Layout).
+func toEightbytes(str *TStruct, v reflect.Value) []uint64 {
+	memory := make([]uint8, (Sizeof(str)+7)&^7)
+	var store func(ty Type, v reflect.Value, offset int)
+	store = func(ty Type, v reflect.Value, offset int) {
+		if t, ok := ty.(*TStruct); ok {
+			for i, fieldOffset := range t.Layout().Offsets {
+				store(t.FieldTypes[i], v.Field(i), offset+fieldOffset)
+			}
+			return
+		}
+		if t, ok := ty.(*TArray); ok && t.Size > 0 {
+			for i := 0; i < t.Size; i++ {
+				store(t.ItemType, v.Index(i), offset+i*Sizeof(t.ItemType))
+			}
+			return
+		}
+		bytes := make([]uint8, 8)
+		binary.LittleEndian.PutUint64(bytes, scalarBits(v))
+		copy(memory[offset:offset+Sizeof(ty)], bytes)
+	}
+	store(str, v, 0)
+	result := make([]uint64, len(memory)/8)
+	for i := range result {
+		result[i] = binary.LittleEndian.Uint64(memory[i*8:])
+	}
+	return result
+}
+
+// Sets the struct to the value in the eightbytes.
+func fromEightbytes(str *TStruct, words []uint64, v reflect.Value) {
+	memory := make([]uint8, len(words)*8+8)
+	for i, word := range words {
+		binary.LittleEndian.PutUint64(memory[i*8:], word)
+	}
+	var load func(ty Type, v reflect.Value, offset int)
+	load = func(ty Type, v reflect.Value, offset int) {
+		if t, ok := ty.(*TStruct); ok {
+			for i, fieldOffset := range t.Layout().Offsets {
+				load(t.FieldTypes[i], v.Field(i), offset+fieldOffset)
+			}
+			return
+		}
+		if t, ok := ty.(*TArray); ok && t.Size > 0 {
+			for i := 0; i < t.Size; i++ {
+				load(t.ItemType, v.Index(i), offset+i*Sizeof(t.ItemType))
+			}
+			return
+		}
+		bytes := make([]uint8, 8)
+		copy(bytes, memory[offset:offset+Sizeof(ty)])
+		setScalar(v, binary.LittleEndian.Uint64(bytes))
+	}
+	load(str, v, 0)
+}
@@ -0,0 +1,127 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/elf"
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Compiles the statements into a relocatable object file, which exports the
+// functions that they define as global symbols so they can be linked into
+// other programs (e.g. with `cc main.c object.o`). Only the function bodies
+// end up in the .text section; the other statements are not part of the
+// object. The extern functions are undefined symbols of the object, so their
+// libraries have to be linked as well (e.g. with -lm).
+func CompileToObject(targetArchitecture Architecture, abi ABI, stmts []IR, debug bool, path string) error {
+	object, err := CompileObject(targetArchitecture, abi, stmts, debug)
+	if err != nil {
+		return err
+	}
+	return elf.CreateObjectFile(object, path)
+}
+
+// Like CompileToObject, but returns the object instead of writing it.
+func CompileObject(targetArchitecture Architecture, abi ABI, stmts []IR, debug bool) (*elf.Object, error) {
+	ctx := NewIRContext(targetArchitecture, abi)
+	stmts, err := transformSSA(stmts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := encode(stmts, debug, ctx); err != nil {
+		return nil, err
+	}
+	segments := ctx.Segments
+	object := &elf.Object{
+		Machine:      targetArchitecture.ELFMachine(),
+		Text:         segments.Segments[Executable].Data,
+		ReadOnlyData: segments.Segments[ReadOnly].Data,
+		Data:         segments.Segments[ReadWrite].Data,
+		Functions:    []*elf.ObjectFunction{},
+		Imports:      []string{},
+	}
+	// The slots of the imports are in the same order (see Segments.AddImport)
+	for _, i := range segments.Imports {
+		object.Imports = append(object.Imports, i.Name)
+	}
+	// The executable segment is at the start of the code (see
+	// Segments.GetAddress).
+	textEnd := len(object.Text)
+
+	relocations, err := objectRelocations(object.Machine, segments, 0, textEnd)
+	if err != nil {
+		return nil, err
+	}
+	object.Relocations = relocations
+
+	var walkErr error
+	for _, stmt := range stmts {
+		walkFunctions(stmt, func(name string, f *expr.IR_Function) {
+			if f.Address == nil || walkErr != nil {
+				return
+			}
+			address := segments.GetAddress(f.Address)
+			if address < 0 || address >= textEnd {
+				walkErr = fmt.Errorf("Function %s is not in the executable segment", name)
+				return
+			}
+			object.Functions = append(object.Functions, &elf.ObjectFunction{
+				Name:   name,
+				Offset: uint64(address),
+				Size:   uint64(f.Address.Size),
+			})
+		})
+	}
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	return object, nil
+}
+
+// Returns the relocations for the references from the code between start
+// and end, with offsets relative to start. References from the rest of the
+// code are dropped.
+func objectRelocations(machine elf.ELFMachine, segments *Segments, start, end int) ([]*elf.ObjectRelocation, error) {
+	result := []*elf.ObjectRelocation{}
+	for _, r := range segments.Relocations {
+		if r.Offset < start || r.Offset >= end {
+			continue
+		}
+		typ, err := elf.GetRelocationType(machine, r.Type)
+		if err != nil {
+			return nil, err
+		}
+		section, offset, err := objectSection(segments, r.Label.Address)
+		if err != nil {
+			return nil, fmt.Errorf("Can't relocate %s: %s", r.Label.Name, err.Error())
+		}
+		result = append(result, &elf.ObjectRelocation{
+			Type:    typ,
+			Offset:  uint64(r.Offset - start),
+			Section: section,
+			Addend:  int64(offset + r.Addend),
+		})
+	}
+	return result, nil
+}
+
+// Returns the section of the object that contains the address, together
+// with the offset of the address in that section (see Segments.GetAddress).
+func objectSection(segments *Segments, address int) (elf.ObjectSection, int, error) {
+	readOnly := len(segments.Segments[ReadOnly].Data)
+	executable := len(segments.Segments[Executable].Data)
+	data := len(segments.Segments[ReadWrite].Data)
+	imports := len(segments.Segments[Imports].Data)
+	switch {
+	case address < 0 && address >= -data:
+		return elf.DataSection, address + data, nil
+	case address < -data && address >= -data-imports:
+		return elf.GOTSection, address + data + imports, nil
+	case address < -data-imports && address >= -data-imports-readOnly:
+		return elf.ReadOnlyDataSection, address + data + imports + readOnly, nil
+	case address >= 0 && address < executable:
+		return elf.TextSection, address, nil
+	}
+	return 0, 0, fmt.Errorf("address 0x%x is not in a segment", address)
+}
@@ -200,6 +200,7 @@ func ParseSimpleType() Parser {
 		"int32":   shared.TInt32,
 		"int64":   shared.TInt64,
 		"float64": shared.TFloat64,
+		"bool":    shared.TBool,
 	}
 	return func(str string) *ParseResult {
 		for tyStr, typ := range types {
@@ -211,8 +212,16 @@ func ParseSimpleType() Parser {
 	}
 }
 func ParseTypeArray() Parser {
-	return ParseString("[]").And(Lazy(ParseType)).Fmap(func(p *ParseResult) *ParseResult {
-		return ParseSuccess(&shared.TArray{p.Result.(shared.Type), 0}, p.Rest)
+	return OneOf([]Parser{
+		ParseString("[]").And(Lazy(ParseType)).Fmap(func(p *ParseResult) *ParseResult {
+			return ParseSuccess(&shared.TSlice{ItemType: p.Result.(shared.Type)}, p.Rest)
+		}),
+		// Arrays with a size are stored inline in structs
+		ParseByte('[').And(ParseInt64()).AndThen(func(size *ParseResult) Parser {
+			return ParseByte(']').And(Lazy(ParseType)).Fmap(func(p *ParseResult) *ParseResult {
+				return ParseSuccess(&shared.TArray{ItemType: p.Result.(shared.Type), Size: int(size.Result.(*expr.IR_Int64).Value)}, p.Rest)
+			})
+		}),
 	})
 }
 
@@ -220,6 +229,10 @@ func ParseType() Parser {
 	return OneOf([]Parser{
 		ParseSimpleType(),
 		ParseTypeArray(),
+		ParseString("struct").And(ParseSpace()).And(Lazy(ParseStructType)),
+		ParseByte('*').And(Lazy(ParseType)).Fmap(func(p *ParseResult) *ParseResult {
+			return ParseSuccess(&shared.TPointer{Target: p.Result.(shared.Type)}, p.Rest)
+		}),
 	})
 }
 
@@ -415,14 +428,19 @@ func ParseOperator() Parser {
 			ParseString("-"),
 			ParseString("*"),
 			ParseString("/"),
+			ParseString("%"),
 			ParseString("=="),
 			ParseString("!="),
+			ParseString("<<"),
 			ParseString("<="),
 			ParseString("<"),
+			ParseString(">>"),
 			ParseString(">="),
 			ParseString(">"),
 			ParseString("&&"),
+			ParseString("&"),
 			ParseString("||"),
+			ParseString("|"),
 			ParseString("^"),
 		})).AndThen(func(op *ParseResult) Parser {
 			return ParseSpace().And(ParseExpression()).Fmap(func(op2 *ParseResult) *ParseResult {
@@ -450,6 +468,18 @@ func ParseOperator() Parser {
 					return ParseSuccess(expr.NewIR_GTE(op1.Result.(shared.IRExpression), op2.Result.(shared.IRExpression)), op2.Rest)
 				} else if op.Result.(string) == "!=" {
 					return ParseSuccess(expr.NewIR_Not(expr.NewIR_Equals(op1.Result.(shared.IRExpression), op2.Result.(shared.IRExpression))), op2.Rest)
+				} else if op.Result.(string) == "%" {
+					return ParseSuccess(expr.NewIR_Mod(op1.Result.(shared.IRExpression), op2.Result.(shared.IRExpression)), op2.Rest)
+				} else if op.Result.(string) == "&" {
+					return ParseSuccess(expr.NewIR_BitwiseAnd(op1.Result.(shared.IRExpression), op2.Result.(shared.IRExpression)), op2.Rest)
+				} else if op.Result.(string) == "|" {
+					return ParseSuccess(expr.NewIR_BitwiseOr(op1.Result.(shared.IRExpression), op2.Result.(shared.IRExpression)), op2.Rest)
+				} else if op.Result.(string) == "^" {
+					return ParseSuccess(expr.NewIR_BitwiseXor(op1.Result.(shared.IRExpression), op2.Result.(shared.IRExpression)), op2.Rest)
+				} else if op.Result.(string) == "<<" {
+					return ParseSuccess(expr.NewIR_ShiftLeft(op1.Result.(shared.IRExpression), op2.Result.(shared.IRExpression)), op2.Rest)
+				} else if op.Result.(string) == ">>" {
+					return ParseSuccess(expr.NewIR_ShiftRight(op1.Result.(shared.IRExpression), op2.Result.(shared.IRExpression)), op2.Rest)
 				}
 				return ParseError(errors.New("Unknown operator"))
 			})
@@ -471,15 +501,21 @@ func ParseSingleExpression() Parser {
 	return OneOf([]Parser{
 		ParseStructField(),
 		ParseStruct(),
+		ParseSlice(),
 		ParseArrayIndex(),
 		ParseBool(),
 		ParseFloat64(),
 		ParseInt64(),
+		ParseAlloc(),
 		ParseFunctionCall(),
 		ParseFunction(),
 		ParseVariable(),
 		ParseArray(),
 		ParseNotExpression(),
+		ParseBitwiseNotExpression(),
+		ParseAddressOf(),
+		ParseDereference(),
+		ParsePointerCast(),
 		ParseEnclosedExpression(),
 	})
 }
@@ -490,6 +526,61 @@ func ParseNotExpression() Parser {
 	})
 }
 
+func ParseBitwiseNotExpression() Parser {
+	return ParseByte('^').And(Lazy(ParseExpression)).Fmap(func(e *ParseResult) *ParseResult {
+		return ParseSuccess(expr.NewIR_BitwiseNot(e.Result.(shared.IRExpression)), e.Rest)
+	})
+}
+
+func ParseAddressOf() Parser {
+	return ParseByte('&').And(Lazy(ParseSingleExpression)).Fmap(func(e *ParseResult) *ParseResult {
+		return ParseSuccess(expr.NewIR_AddressOf(e.Result.(shared.IRExpression)), e.Rest)
+	})
+}
+
+func ParseDereference() Parser {
+	return ParseByte('*').And(Lazy(ParseSingleExpression)).Fmap(func(e *ParseResult) *ParseResult {
+		return ParseSuccess(expr.NewIR_Dereference(e.Result.(shared.IRExpression)), e.Rest)
+	})
+}
+
+// Parses a cast to a pointer type, which needs parentheses around the type:
+//
+//	(*struct {next uint64})(p.next)
+func ParsePointerCast() Parser {
+	pointerType := ParseByte('(').And(ParseByte('*')).And(ParseType()).AndThen(func(typ *ParseResult) Parser {
+		return ParseByte(')').Fmap(func(r *ParseResult) *ParseResult {
+			return ParseSuccess(&shared.TPointer{Target: typ.Result.(shared.Type)}, r.Rest)
+		})
+	})
+	return pointerType.AndThen(func(typ *ParseResult) Parser {
+		return Lazy(ParseEnclosedExpression).Fmap(func(e *ParseResult) *ParseResult {
+			return ParseSuccess(expr.NewIR_Cast(e.Result.(shared.IRExpression), typ.Result.(shared.Type)), e.Rest)
+		})
+	})
+}
+
+// Parses alloc(T) and alloc(T, count), which return a *T.
+func ParseAlloc() Parser {
+	count := OneOf([]Parser{
+		ParseByte(',').And(ParseSpace()).And(Lazy(ParseExpression)).Fmap(func(e *ParseResult) *ParseResult {
+			// Integer literals are counts, not int64s
+			if v, ok := e.Result.(*expr.IR_Int64); ok {
+				return ParseSuccess(expr.NewIR_Uint64(uint64(v.Value)), e.Rest)
+			}
+			return e
+		}),
+		ParseSpace().Success(expr.NewIR_Uint64(1)),
+	})
+	return ParseString("alloc(").And(ParseSpace()).And(ParseType()).AndThen(func(typ *ParseResult) Parser {
+		return ParseSpace().And(count).AndThen(func(n *ParseResult) Parser {
+			return ParseSpace().And(ParseByte(')')).Fmap(func(r *ParseResult) *ParseResult {
+				return ParseSuccess(expr.NewIR_Alloc(typ.Result.(shared.Type), n.Result.(shared.IRExpression)), r.Rest)
+			})
+		})
+	})
+}
+
 func ParseEnclosedExpression() Parser {
 	return ParseEnclosed(ParseSpace().And(ParseByte('(')).And(ParseSpace()), Lazy(ParseExpression), ParseSpace().And(ParseByte(')')))
 }
@@ -506,9 +597,12 @@ func ParseSingleStatement() Parser {
 		ParseIf(),
 		ParseAssignment(),
 		ParseArrayAssignment(),
+		ParseFieldAssignment(),
+		ParsePointerAssignment(),
 		ParseReturn(),
 		ParseWhile(),
 		ParseFunctionDef(),
+		ParseExtern(),
 	}))
 }
 
@@ -540,6 +634,24 @@ func ParseArrayAssignment() Parser {
 	})
 }
 
+func ParsePointerAssignment() Parser {
+	return ParseByte('*').And(ParseSingleExpression()).AndThen(func(pointer *ParseResult) Parser {
+		return ParseSpace().And(ParseByte('=')).And(ParseSpace()).And(ParseExpression()).Fmap(func(value *ParseResult) *ParseResult {
+			return ParseSuccess(statements.NewIR_PointerAssignment(pointer.Result.(shared.IRExpression), value.Result.(shared.IRExpression)), value.Rest)
+		})
+	})
+}
+
+// Assignments to struct fields store the value at the address of the field.
+func ParseFieldAssignment() Parser {
+	return ParseStructField().AndThen(func(field *ParseResult) Parser {
+		return ParseSpace().And(ParseByte('=')).And(ParseSpace()).And(ParseExpression()).Fmap(func(value *ParseResult) *ParseResult {
+			pointer := expr.NewIR_AddressOf(field.Result.(shared.IRExpression))
+			return ParseSuccess(statements.NewIR_PointerAssignment(pointer, value.Result.(shared.IRExpression)), value.Rest)
+		})
+	})
+}
+
 func ParseFunctionDefArgs() Parser {
 	itemParser := ParseVariable().AndThen(func(variable *ParseResult) Parser {
 		return ParseSpace1().And(ParseType()).Fmap(func(typ *ParseResult) *ParseResult {
@@ -550,22 +662,28 @@ func ParseFunctionDefArgs() Parser {
 	return ParseList(itemParser)
 }
 
+// Returns the signature for the results of ParseFunctionDefArgs and
+// ParseType.
+func newSignature(args, returns *ParseResult) *shared.TFunction {
+	argNames := []string{}
+	argTypes := []shared.Type{}
+	for _, pair := range args.Result.([]interface{}) {
+		lst := pair.([]interface{})
+		argNames = append(argNames, lst[0].(string))
+		argTypes = append(argTypes, lst[1].(shared.Type))
+	}
+	return &shared.TFunction{
+		ReturnType: returns.Result.(shared.Type),
+		Args:       argTypes,
+		ArgNames:   argNames,
+	}
+}
+
 func ParseFunction() Parser {
 	return ParseString("func").And(ParseSpace()).And(ParseByte('(')).And(ParseFunctionDefArgs()).AndThen(func(args *ParseResult) Parser {
 		return ParseByte(')').And(ParseSpace()).And(ParseType()).AndThen(func(returns *ParseResult) Parser {
 			return ParseBlock().Fmap(func(body *ParseResult) *ParseResult {
-				argNames := []string{}
-				argTypes := []shared.Type{}
-				for _, pair := range args.Result.([]interface{}) {
-					lst := pair.([]interface{})
-					argNames = append(argNames, lst[0].(string))
-					argTypes = append(argTypes, lst[1].(shared.Type))
-				}
-				signature := &shared.TFunction{
-					ReturnType: returns.Result.(shared.Type),
-					Args:       argTypes,
-					ArgNames:   argNames,
-				}
+				signature := newSignature(args, returns)
 				return ParseSuccess(expr.NewIR_Function(signature, body.Result.(shared.IR)), body.Rest)
 			})
 		})
@@ -577,19 +695,7 @@ func ParseFunctionDef() Parser {
 		return ParseSpace().And(ParseByte('(')).And(ParseFunctionDefArgs()).AndThen(func(args *ParseResult) Parser {
 			return ParseByte(')').And(ParseSpace()).And(ParseType()).AndThen(func(returns *ParseResult) Parser {
 				return ParseBlock().Fmap(func(body *ParseResult) *ParseResult {
-					argNames := []string{}
-					argTypes := []shared.Type{}
-					for _, pair := range args.Result.([]interface{}) {
-						lst := pair.([]interface{})
-						argNames = append(argNames, lst[0].(string))
-						argTypes = append(argTypes, lst[1].(shared.Type))
-					}
-					signature := &shared.TFunction{
-						ReturnType: returns.Result.(shared.Type),
-						Args:       argTypes,
-						ArgNames:   argNames,
-					}
-					f := expr.NewIR_Function(signature, body.Result.(shared.IR))
+					f := expr.NewIR_Function(newSignature(args, returns), body.Result.(shared.IR))
 					return ParseSuccess(statements.NewIR_FunctionDef(name.Result.(*expr.IR_Variable).Value, f), body.Rest)
 				})
 			})
@@ -597,6 +703,47 @@ func ParseFunctionDef() Parser {
 	})
 }
 
+// Parses a double quoted string, which can't contain escape sequences.
+func ParseQuotedString() Parser {
+	return func(str string) *ParseResult {
+		if len(str) == 0 || str[0] != '"' {
+			return NilParseResult(str)
+		}
+		end := strings.IndexByte(str[1:], '"')
+		if end < 0 {
+			return NilParseResult(str)
+		}
+		return ParseSuccess(str[1:end+1], str[end+2:])
+	}
+}
+
+// Parses the declaration of an external function, optionally followed by
+// the library that defines it:
+//
+//	extern func labs(i int64) int64
+//	extern "libm.so.6" func cos(x float64) float64
+func ParseExtern() Parser {
+	library := OneOf([]Parser{
+		ParseQuotedString().AndThen(func(lib *ParseResult) Parser {
+			return ParseSpace1().Fmap(func(r *ParseResult) *ParseResult {
+				return ParseSuccess(lib.Result, r.Rest)
+			})
+		}),
+		ParseSpace().Success(""),
+	})
+	return ParseString("extern").And(ParseSpace1()).And(library).AndThen(func(lib *ParseResult) Parser {
+		return ParseString("func").And(ParseSpace1()).And(ParseVariable()).AndThen(func(name *ParseResult) Parser {
+			return ParseSpace().And(ParseByte('(')).And(ParseFunctionDefArgs()).AndThen(func(args *ParseResult) Parser {
+				return ParseByte(')').And(ParseSpace()).And(ParseType()).Fmap(func(returns *ParseResult) *ParseResult {
+					name := name.Result.(*expr.IR_Variable).Value
+					extern := statements.NewIR_Extern(name, lib.Result.(string), newSignature(args, returns))
+					return ParseSuccess(extern, returns.Rest)
+				})
+			})
+		})
+	})
+}
+
 func ParseFunctionArgs() Parser {
 	return ParseList(ParseExpression())
 }
@@ -610,6 +757,11 @@ func ParseFunctionCall() Parser {
 				var result shared.IRExpression
 				if function == "syscall" {
 					result = expr.NewIR_Syscall(args[0], args[1:])
+				} else if function == "len" {
+					if len(args) != 1 {
+						return ParseError(fmt.Errorf("Expecting one parameter for call to len"))
+					}
+					result = expr.NewIR_Len(args[0])
 				} else {
 					result = expr.NewIR_Call(function, args)
 				}
@@ -681,7 +833,7 @@ func ParseStructType() Parser {
 			return ParseSuccess([]interface{}{field.Result, ty.Result}, ty.Rest)
 		})
 	})
-	return ParseEnclosed(ParseByte('{').And(ParseWhiteSpace()), ParseListWithSeparator(typ, ParseByte('\n')).Fmap(func(fields *ParseResult) *ParseResult {
+	return ParseEnclosed(ParseByte('{').And(ParseWhiteSpace()), ParseListWithSeparator(typ, OneOf([]Parser{ParseByte('\n'), ParseByte(';')})).Fmap(func(fields *ParseResult) *ParseResult {
 		result := &shared.TStruct{
 			FieldTypes: []shared.Type{},
 			Fields:     []string{},
@@ -697,17 +849,44 @@ func ParseStructType() Parser {
 }
 
 func ParseStruct() Parser {
+	itemParser := OneOf([]Parser{
+		ParseFloat64(),
+		ParseInt64(),
+		ParseBool(),
+		Lazy(ParseStruct),
+		ParseArray(),
+	})
 	return ParseString("struct").And(ParseSpace()).And(ParseStructType()).AndThen(func(fields *ParseResult) Parser {
-		return ParseEnclosed(ParseByte('{').And(ParseWhiteSpace()), ParseArrayItems().Fmap(func(items *ParseResult) *ParseResult {
-			return ParseSuccess(expr.NewIR_Struct(fields.Result.(*shared.TStruct), items.Result.([]shared.IRExpression)), items.Rest)
+		return ParseEnclosed(ParseByte('{').And(ParseWhiteSpace()), ParseList(itemParser).Fmap(func(items *ParseResult) *ParseResult {
+			str := fields.Result.(*shared.TStruct)
+			values := InterfaceArrayToIRExpressionArray(items.Result)
+			// "cast" the integers to the types of their fields (see ParseArray)
+			for i, v := range values {
+				if i < len(str.FieldTypes) {
+					if c, ok := v.(*expr.IR_Int64); ok && str.FieldTypes[i] == shared.TFloat64 {
+						values[i] = expr.NewIR_Float64(float64(c.Value))
+					} else if ok {
+						values[i] = ConvertInteger(str.FieldTypes[i], c.Value)
+					}
+				}
+			}
+			return ParseSuccess(expr.NewIR_Struct(str, values), items.Rest)
 		}), ParseWhiteSpace().And(ParseByte('}')))
 	})
 }
 
 func ParseStructField() Parser {
-	return ParseVariable().AndThen(func(v *ParseResult) Parser {
-		return ParseByte('.').And(ParseVariable()).Fmap(func(field *ParseResult) *ParseResult {
-			return ParseSuccess(expr.NewIR_StructField(v.Result.(shared.IRExpression), field.Result.(*expr.IR_Variable).Value), field.Rest)
+	return OneOf([]Parser{
+		ParseVariable(),
+		Lazy(ParseEnclosedExpression),
+	}).AndThen(func(v *ParseResult) Parser {
+		return ParseByte('.').And(ParseVariable()).Many1().Fmap(func(fields *ParseResult) *ParseResult {
+			// Fields of nested structs: a.b.c is (a.b).c
+			result := v.Result.(shared.IRExpression)
+			for _, field := range fields.Result.([]interface{}) {
+				result = expr.NewIR_StructField(result, field.(*expr.IR_Variable).Value)
+			}
+			return ParseSuccess(result, fields.Rest)
 		})
 	})
 }
@@ -726,6 +905,40 @@ func ParseArrayIndex() Parser {
 	})
 }
 
+// Parses a[low:high], where both bounds are optional. Integer literals are
+// converted to uint64s, like the counts of ParseAlloc.
+func ParseSlice() Parser {
+	bound := OneOf([]Parser{
+		ParseSpace().And(Lazy(ParseExpression)).Fmap(func(e *ParseResult) *ParseResult {
+			if v, ok := e.Result.(*expr.IR_Int64); ok {
+				return ParseSuccess(expr.NewIR_Uint64(uint64(v.Value)), e.Rest)
+			}
+			return e
+		}),
+		ParseSpace(),
+	})
+	return OneOf([]Parser{
+		ParseVariable(),
+		ParseArray(),
+		ParseEnclosedExpression(),
+	}).AndThen(func(e *ParseResult) Parser {
+		return ParseByte('[').And(bound).AndThen(func(low *ParseResult) Parser {
+			return ParseSpace().And(ParseByte(':')).And(bound).AndThen(func(high *ParseResult) Parser {
+				return ParseSpace().And(ParseByte(']')).Fmap(func(r *ParseResult) *ParseResult {
+					var lowExpr, highExpr shared.IRExpression
+					if e, ok := low.Result.(shared.IRExpression); ok {
+						lowExpr = e
+					}
+					if e, ok := high.Result.(shared.IRExpression); ok {
+						highExpr = e
+					}
+					return ParseSuccess(expr.NewIR_Slice(e.Result.(shared.IRExpression), lowExpr, highExpr), r.Rest)
+				})
+			})
+		})
+	})
+}
+
 func ParseIR(str string) (shared.IR, error) {
 	result := ParseStatement()(str)
 	if result.Error != nil {
@@ -1,6 +1,11 @@
 package ir
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+)
 
 func Test_Parser_Happy(t *testing.T) {
 	shouldParse := []string{
@@ -46,8 +51,37 @@ func Test_Parser_Happy(t *testing.T) {
 			 53,
 		 }`,
 		`a = b.Field`,
+		`a = b.Field.Nested`,
+		`a = struct {x [4]uint8; y bool; p struct {z int32}}{[]uint8{1, 2}, true, struct {z int32}{3}}`,
 		`a = (5 + 4) * 6`,
 		`a = ([]uint64{1,2,3})[2]`,
+		`a = (b & 255) | (c << 8)`,
+		`a = (b ^ c) >> uint64(3)`,
+		`a = ^b % 7`,
+		`extern func labs(i int64) int64`,
+		`extern "libm.so.6" func cos(x float64) float64; a = cos(1.0)`,
+		`extern func norm(p struct {x int64; y float64}) float64`,
+		`extern func origin() struct {
+			x int64
+			y float64
+		}`,
+		`a = alloc(struct {x int64; next *struct {x int64}}, 10)`,
+		`a = alloc([]*uint8)`,
+		`func f(p *int64, q *[4]uint8) *int64 { return p }`,
+		`*p = *q + 1`,
+		`*(p + 3) = 1`,
+		`p.next.x = 3`,
+		`(*p).x = 3`,
+		`a = &b[i + 1]`,
+		`a = &b.c`,
+		`a = (*struct {x int64})(b.next)`,
+		`a = b[1:3]`,
+		`a = b[:]`,
+		`a = b[i + 1:]`,
+		`a = b[:n]`,
+		`a = ([]uint64{1,2,3})[1:]`,
+		`a = len(b)`,
+		`func f(s []uint64) []uint64 { return s[1:] }`,
 	}
 	for _, p := range shouldParse {
 		_, err := ParseIR(p)
@@ -57,10 +91,44 @@ func Test_Parser_Happy(t *testing.T) {
 	}
 }
 
+func Test_Parser_Operators(t *testing.T) {
+	expected := map[string]shared.IRExpressionType{
+		"a = b & c":  shared.BitwiseAnd,
+		"a = b && c": shared.And,
+		"a = b | c":  shared.BitwiseOr,
+		"a = b || c": shared.Or,
+		"a = b ^ c":  shared.BitwiseXor,
+		"a = ^b":     shared.BitwiseNot,
+		"a = b << c": shared.ShiftLeft,
+		"a = b <= c": shared.LTE,
+		"a = b < c":  shared.LT,
+		"a = b >> c": shared.ShiftRight,
+		"a = b >= c": shared.GTE,
+		"a = b > c":  shared.GT,
+		"a = b % c":  shared.Mod,
+		"a = &b":     shared.AddressOf,
+		"a = *b":     shared.Dereference,
+		"a = *b.c":   shared.Dereference,
+		"a = b * c":  shared.Mul,
+		"a = b[1:2]": shared.Slice,
+		"a = len(b)": shared.Len,
+	}
+	for p, typ := range expected {
+		stmt, err := ParseIR(p)
+		if err != nil {
+			t.Fatalf("Failed to parse %v: %v", p, err)
+		}
+		if e := stmt.(*statements.IR_Assignment).Expr; e.Type() != typ {
+			t.Fatalf("Expecting %s for %v, got %s", typ, p, e.Type())
+		}
+	}
+}
+
 func Test_Parser_Sad(t *testing.T) {
 	shouldParse := []string{
 		"a123 = uint64(1, 2)",
 		"a123 = float64(1, 2)",
+		"a123 = len(a, b)",
 	}
 	for _, p := range shouldParse {
 		_, err := ParseIR(p)
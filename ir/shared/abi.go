@@ -1,12 +1,50 @@
 package shared
 
 import (
-	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
 	"github.com/bspaans/jit-compiler/lib"
 )
 
-// TODO: should only contain call setup and teardown; this makes no sense.
+// The calling convention of a target. The operands are those of the
+// target's architecture.
 type ABI interface {
-	GetRegistersForArgs(args []Type) []*encoding.Register
+	// Returns the operand in which a value of the given type is returned.
 	ReturnTypeToOperand(ty Type) lib.Operand
+	// Returns where the arguments and the return value of a call with the
+	// given signature are passed.
+	ClassifyCall(args []Type, returnType Type) *CallLayout
+	// Returns whether the register has to be preserved by the callee.
+	IsCalleeSaved(reg lib.Operand) bool
+	// Returns the operand in which the main code leaves its result when it
+	// gets executed in-process (see lib.MachineCode.Execute).
+	ExecuteReturnOperand() lib.Operand
+}
+
+// Where an argument or a return value is passed.
+type ValueLocation struct {
+	// The registers that hold the consecutive eightbytes of the value, or
+	// nil if the value is passed in memory.
+	Registers []lib.Operand
+	// The offset of the value from the stack pointer at the call, if it's
+	// passed in memory.
+	StackOffset int
+	// The size of the value in bytes, rounded up to a multiple of eight.
+	Size int
+	// If true, the address of a copy of the value is passed instead of the
+	// value itself, in the register or on the stack.
+	Indirect bool
+}
+
+func (v *ValueLocation) InMemory() bool {
+	return v.Registers == nil
+}
+
+// How the arguments and the return value of a call are passed.
+type CallLayout struct {
+	Args   []*ValueLocation
+	Return *ValueLocation
+	// If the return value is passed in memory, the register that holds the
+	// address of that memory.
+	ReturnPointer lib.Operand
+	// The size of the arguments that are passed on the stack.
+	StackSize int
 }
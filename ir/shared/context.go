@@ -1,7 +1,9 @@
 package shared
 
 import (
-	"github.com/bspaans/jit-compiler/asm/x86_64/encoding"
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/elf"
 	"github.com/bspaans/jit-compiler/lib"
 )
 
@@ -9,7 +11,13 @@ type Architecture interface {
 	EncodeExpression(expr IRExpression, ctx *IR_Context, target lib.Operand) ([]lib.Instruction, error)
 	EncodeStatement(stmt IR, ctx *IR_Context) ([]lib.Instruction, error)
 	EncodeDataSection(stmts []IR, ctx *IR_Context) (*Segments, error)
+	EncodePrologue(stmts []IR, ctx *IR_Context) ([]lib.Instruction, error)
+	// Returns the entry point of executables, which calls the code at the
+	// label and exits with the value that it returns.
+	EncodeEntry(main *lib.Label) []lib.Instruction
 	GetAllocator() Allocator
+	// Returns the machine that object files for this architecture target.
+	ELFMachine() elf.ELFMachine
 }
 
 type Allocator interface {
@@ -30,8 +38,16 @@ type IR_Context struct {
 	InstructionPointer uint
 	StackPointer       int
 	Commit             bool // if false turns AddInstruction into a noop
+	// Set while the data section of a function body is encoded. The array
+	// and struct literals in functions are materialized in the stack frame
+	// on each call, instead of in the shared ReadWrite segment.
+	InFunction bool
+	// Turns off the bounds checks on indexing and slicing, which otherwise
+	// trap when an index is out of range.
+	NoBoundsChecks bool
 
 	instructions []lib.Instruction
+	labels       *int // shared between copies, to keep the label names unique
 }
 
 func NewIRContext(arch Architecture, abi ABI) *IR_Context {
@@ -40,13 +56,17 @@ func NewIRContext(arch Architecture, abi ABI) *IR_Context {
 		ABI:                abi,
 		VariableMap:        map[string]lib.Operand{},
 		VariableTypes:      map[string]Type{},
-		ReturnOperandStack: []lib.Operand{&encoding.DisplacedRegister{encoding.Rsp, 8}},
-		InstructionPointer: 2,
+		ReturnOperandStack: []lib.Operand{},
+		InstructionPointer: 0,
 		StackPointer:       8,
 		Commit:             true,
 		instructions:       []lib.Instruction{},
+		labels:             new(int),
 	}
 	ctx.Allocator = arch.GetAllocator()
+	if abi != nil {
+		ctx.PushReturnOperand(abi.ExecuteReturnOperand())
+	}
 	return ctx
 }
 
@@ -91,7 +111,10 @@ func (i *IR_Context) Copy() *IR_Context {
 		InstructionPointer: i.InstructionPointer,
 		StackPointer:       i.StackPointer,
 		Commit:             i.Commit,
+		InFunction:         i.InFunction,
+		NoBoundsChecks:     i.NoBoundsChecks,
 		instructions:       instructions,
+		labels:             i.labels,
 	}
 }
 
@@ -105,6 +128,12 @@ func (i *IR_Context) AddInstruction(instr ...lib.Instruction) {
 	}
 }
 
+// Returns a new label. The name is made unique by adding a number.
+func (i *IR_Context) NewLabel(name string) *lib.Label {
+	*i.labels += 1
+	return lib.NewLabel(fmt.Sprintf("%s_%d", name, *i.labels))
+}
+
 func (i *IR_Context) GetInstructions() []lib.Instruction {
 	return i.instructions
 }
@@ -37,6 +37,13 @@ const (
 	Sub         IRExpressionType = iota
 	Mul         IRExpressionType = iota
 	Div         IRExpressionType = iota
+	Mod         IRExpressionType = iota
+	BitwiseAnd  IRExpressionType = iota
+	BitwiseOr   IRExpressionType = iota
+	BitwiseXor  IRExpressionType = iota
+	BitwiseNot  IRExpressionType = iota
+	ShiftLeft   IRExpressionType = iota
+	ShiftRight  IRExpressionType = iota
 	Variable    IRExpressionType = iota
 	Equals      IRExpressionType = iota
 	LT          IRExpressionType = iota
@@ -47,6 +54,11 @@ const (
 	Cast        IRExpressionType = iota
 	Function    IRExpressionType = iota
 	Call        IRExpressionType = iota
+	AddressOf   IRExpressionType = iota
+	Dereference IRExpressionType = iota
+	Alloc       IRExpressionType = iota
+	Slice       IRExpressionType = iota
+	Len         IRExpressionType = iota
 )
 
 type BaseIRExpression struct {
@@ -5,13 +5,16 @@ import "github.com/bspaans/jit-compiler/lib"
 type IRType int
 
 const (
-	Assignment      IRType = iota
-	ArrayAssignment IRType = iota
-	If              IRType = iota
-	While           IRType = iota
-	Return          IRType = iota
-	AndThen         IRType = iota
-	FunctionDef     IRType = iota
+	Assignment        IRType = iota
+	ArrayAssignment   IRType = iota
+	If                IRType = iota
+	While             IRType = iota
+	Return            IRType = iota
+	AndThen           IRType = iota
+	FunctionDef       IRType = iota
+	Extern            IRType = iota
+	PointerAssignment IRType = iota
+	Nop               IRType = iota
 )
 
 type IR interface {
@@ -44,6 +47,9 @@ func IR_Length(stmt IR, ctx *IR_Context) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	if err := lib.Instructions(instr).Resolve(int(ctx.InstructionPointer)); err != nil {
+		return 0, err
+	}
 	code, err := lib.Instructions(instr).Encode()
 	if err != nil {
 		return 0, err
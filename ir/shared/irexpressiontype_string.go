@@ -30,21 +30,33 @@ func _() {
 	_ = x[Sub-19]
 	_ = x[Mul-20]
 	_ = x[Div-21]
-	_ = x[Variable-22]
-	_ = x[Equals-23]
-	_ = x[LT-24]
-	_ = x[LTE-25]
-	_ = x[GT-26]
-	_ = x[GTE-27]
-	_ = x[Syscall-28]
-	_ = x[Cast-29]
-	_ = x[Function-30]
-	_ = x[Call-31]
+	_ = x[Mod-22]
+	_ = x[BitwiseAnd-23]
+	_ = x[BitwiseOr-24]
+	_ = x[BitwiseXor-25]
+	_ = x[BitwiseNot-26]
+	_ = x[ShiftLeft-27]
+	_ = x[ShiftRight-28]
+	_ = x[Variable-29]
+	_ = x[Equals-30]
+	_ = x[LT-31]
+	_ = x[LTE-32]
+	_ = x[GT-33]
+	_ = x[GTE-34]
+	_ = x[Syscall-35]
+	_ = x[Cast-36]
+	_ = x[Function-37]
+	_ = x[Call-38]
+	_ = x[AddressOf-39]
+	_ = x[Dereference-40]
+	_ = x[Alloc-41]
+	_ = x[Slice-42]
+	_ = x[Len-43]
 }
 
-const _IRExpressionType_name = "Uint8Uint16Uint32Uint64Int8Int16Int32Int64Float64ByteArrayStaticArrayArrayIndexBoolStructStructFieldAndOrNotAddSubMulDivVariableEqualsLTLTEGTGTESyscallCastFunctionCall"
+const _IRExpressionType_name = "Uint8Uint16Uint32Uint64Int8Int16Int32Int64Float64ByteArrayStaticArrayArrayIndexBoolStructStructFieldAndOrNotAddSubMulDivModBitwiseAndBitwiseOrBitwiseXorBitwiseNotShiftLeftShiftRightVariableEqualsLTLTEGTGTESyscallCastFunctionCallAddressOfDereferenceAllocSliceLen"
 
-var _IRExpressionType_index = [...]uint8{0, 5, 11, 17, 23, 27, 32, 37, 42, 49, 58, 69, 79, 83, 89, 100, 103, 105, 108, 111, 114, 117, 120, 128, 134, 136, 139, 141, 144, 151, 155, 163, 167}
+var _IRExpressionType_index = [...]uint16{0, 5, 11, 17, 23, 27, 32, 37, 42, 49, 58, 69, 79, 83, 89, 100, 103, 105, 108, 111, 114, 117, 120, 123, 133, 142, 152, 162, 171, 181, 189, 195, 197, 200, 202, 205, 212, 216, 224, 228, 237, 248, 253, 258, 261}
 
 func (i IRExpressionType) String() string {
 	if i < 0 || i >= IRExpressionType(len(_IRExpressionType_index)-1) {
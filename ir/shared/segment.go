@@ -3,6 +3,8 @@ package shared
 import (
 	"encoding/hex"
 	"strings"
+
+	"github.com/bspaans/jit-compiler/lib"
 )
 
 type Segment struct {
@@ -31,26 +33,47 @@ func (s Segment) String() string {
 type SegmentType uint8
 
 const (
+	// Constants that are never written to, like the float literals.
 	ReadOnly   SegmentType = 1
 	ReadWrite  SegmentType = 2
 	Executable SegmentType = 3
+	// The addresses of the external functions, which get filled in when the
+	// code is loaded (see Import).
+	Imports SegmentType = 4
 )
 
 type SegmentPointer struct {
 	SegmentType
 	Offset uint
+	Size   uint
+}
+
+// An external function, which is called through the address in its eight
+// byte slot in the Imports segment.
+type Import struct {
+	Name string
+	// The shared object that defines the function, e.g. libm.so.6
+	Library string
+	Address *SegmentPointer
 }
 
 type Segments struct {
 	Segments map[SegmentType]*Segment
+	// The references to fixed labels in the executable segment and in the
+	// code, relative to the start of the code (see lib.Relocatable).
+	Relocations []*lib.Relocation
+	Imports     []*Import
 }
 
 func NewSegments() *Segments {
-	return &Segments{map[SegmentType]*Segment{
-		ReadOnly:   NewSegment(),
-		ReadWrite:  NewSegment(),
-		Executable: NewSegment(),
-	}}
+	return &Segments{
+		Segments: map[SegmentType]*Segment{
+			ReadOnly:   NewSegment(),
+			ReadWrite:  NewSegment(),
+			Executable: NewSegment(),
+			Imports:    NewSegment(),
+		},
+	}
 }
 
 func (s *Segments) Add(ty SegmentType, data ...uint8) *SegmentPointer {
@@ -59,34 +82,114 @@ func (s *Segments) Add(ty SegmentType, data ...uint8) *SegmentPointer {
 	return &SegmentPointer{
 		SegmentType: ty,
 		Offset:      uint(offset),
+		Size:        uint(len(data)),
+	}
+}
+
+// Adds a slot for the address of the external function, or returns the
+// existing one if the function has already been imported.
+func (s *Segments) AddImport(name, library string) *SegmentPointer {
+	if i := s.GetImport(name); i != nil {
+		return i.Address
+	}
+	address := s.Add(Imports, make([]uint8, 8)...)
+	s.Imports = append(s.Imports, &Import{
+		Name:    name,
+		Library: library,
+		Address: address,
+	})
+	return address
+}
+
+// Returns the imported function with the given name, or nil.
+func (s *Segments) GetImport(name string) *Import {
+	for _, i := range s.Imports {
+		if i.Name == name {
+			return i
+		}
 	}
+	return nil
+}
+
+// The allocator behind IR_Alloc keeps its state in the last sixteen bytes of
+// the ReadWrite segment: the address of the next free byte, followed by the
+// end of the memory that it has mapped so far. Both start out as zero.
+//
+// The addresses of the other data depend on the size of the ReadWrite
+// segment, which keeps growing while the data section is encoded. The state
+// gets added after everything else, so that its address is known up front
+// (see HeapStateAddress).
+const HeapStateSize = 16
+
+func (s *Segments) AddHeapState() *SegmentPointer {
+	return s.Add(ReadWrite, make([]uint8, HeapStateSize)...)
+}
+
+// Returns the address of the allocator state relative to the start of the
+// code (see GetAddress).
+func HeapStateAddress() int {
+	return -HeapStateSize
 }
 
+// Encodes the executable segment, which is placed in front of the code. The
+// other segments can't be executable, so they are placed separately (see
+// lib.Program).
 func (s *Segments) Encode() []uint8 {
-	sub := append(s.Segments[ReadOnly].Data, s.Segments[ReadWrite].Data...)
-	return append(sub, s.Segments[Executable].Data...)
+	return s.Segments[Executable].Data
+}
+
+// Returns the data in the Imports and the ReadWrite segments, in that order.
+func (s *Segments) Data() []uint8 {
+	return append(append([]uint8{}, s.Segments[Imports].Data...), s.Segments[ReadWrite].Data...)
 }
 
+// Returns the address of the pointer relative to the start of the code. The
+// executable segment is at the start of the code, and the ReadWrite, Imports
+// and ReadOnly segments are in front of it, in that order. The read-only
+// segment ends up in pages of its own when the code gets loaded, so the
+// references to it have to be relocated (see ReadOnlyRelocations).
 func (s *Segments) GetAddress(p *SegmentPointer) int {
-	if p.SegmentType == ReadOnly {
-		return int(p.Offset) + 2
-	}
-	readOnly := uint(len(s.Segments[ReadOnly].Data))
-	if p.SegmentType == ReadWrite {
-		return int(readOnly+p.Offset) + 2
-	}
-	readWrite := uint(len(s.Segments[ReadWrite].Data))
-	if p.SegmentType == Executable {
-		return int(readOnly+readWrite+p.Offset) + 2
+	data := len(s.Segments[ReadWrite].Data)
+	imports := len(s.Segments[Imports].Data)
+	switch p.SegmentType {
+	case Executable:
+		return int(p.Offset)
+	case ReadWrite:
+		return int(p.Offset) - data
+	case Imports:
+		return int(p.Offset) - data - imports
+	case ReadOnly:
+		return int(p.Offset) - data - imports - len(s.Segments[ReadOnly].Data)
 	}
 	panic("Unknown segment type")
-	return 0
+}
+
+// Returns the references to the read-only segment, with the addresses of
+// their labels relative to the start of the segment (see lib.Program).
+func (s *Segments) ReadOnlyRelocations() []*lib.Relocation {
+	start := s.GetAddress(&SegmentPointer{SegmentType: ReadOnly})
+	end := start + len(s.Segments[ReadOnly].Data)
+	result := []*lib.Relocation{}
+	for _, r := range s.Relocations {
+		address := r.Label.Address
+		if address >= start && address < end {
+			result = append(result, &lib.Relocation{
+				Type:   r.Type,
+				Offset: r.Offset,
+				Addend: r.Addend,
+				Label:  lib.NewFixedLabel(r.Label.Name, address-start),
+			})
+		}
+	}
+	return result
 }
 
 func (s *Segments) String() string {
 	return strings.Join([]string{
 		".rodata",
 		s.Segments[ReadOnly].String(),
+		".got",
+		s.Segments[Imports].String(),
 		".data",
 		s.Segments[ReadWrite].String(),
 		".text",
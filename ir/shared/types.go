@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/bspaans/jit-compiler/lib"
@@ -23,6 +24,8 @@ const (
 	T_Array    TypeNr = iota
 	T_Function TypeNr = iota
 	T_Struct   TypeNr = iota
+	T_Pointer  TypeNr = iota
+	T_Slice    TypeNr = iota
 )
 
 type Type interface {
@@ -51,6 +54,12 @@ func IsFloat(b Type) bool {
 func IsNumber(b Type) bool {
 	return IsFloat(b) || IsInteger(b)
 }
+func IsPointer(b Type) bool {
+	return b.Type() == T_Pointer
+}
+func IsSlice(b Type) bool {
+	return b.Type() == T_Slice
+}
 
 func (b *BaseType) String() string {
 	return map[TypeNr]string{
@@ -97,6 +106,9 @@ var (
 	TBool    = &BaseType{T_Bool}
 )
 
+// Arrays evaluate to the address of their first item. Inside a struct an
+// array with a Size is stored inline, like a C array; an array without one
+// is stored as a pointer.
 type TArray struct {
 	ItemType Type
 	Size     int
@@ -106,12 +118,60 @@ func (t *TArray) Type() TypeNr {
 	return T_Array
 }
 func (b *TArray) String() string {
+	if b.Size > 0 {
+		return "[" + strconv.Itoa(b.Size) + "]" + b.ItemType.String()
+	}
 	return "[]" + b.ItemType.String()
 }
 func (b *TArray) Width() lib.Size {
 	return lib.QUADWORD
 }
 
+// A pointer to a value of the Target type in memory, e.g. to memory that
+// has been allocated with IR_Alloc or to the field of a struct.
+type TPointer struct {
+	Target Type
+}
+
+func (t *TPointer) Type() TypeNr {
+	return T_Pointer
+}
+func (b *TPointer) String() string {
+	return "*" + b.Target.String()
+}
+func (b *TPointer) Width() lib.Size {
+	return lib.QUADWORD
+}
+
+// Slices evaluate to the address of a header with the address of their
+// first item, their length and their capacity, in that order. Every slicing
+// expression writes its header to its own area in the stack frame, like
+// the arrays and structs that are declared in a function, so the header
+// stays valid until the function returns. Slices are returned like structs
+// that hold the header, which copies the header into the frame of the
+// caller.
+type TSlice struct {
+	ItemType Type
+}
+
+// The offsets of the fields in a slice header.
+const (
+	SliceItemsOffset    = 0
+	SliceLengthOffset   = 8
+	SliceCapacityOffset = 16
+	SliceHeaderSize     = 24
+)
+
+func (t *TSlice) Type() TypeNr {
+	return T_Slice
+}
+func (b *TSlice) String() string {
+	return "[]" + b.ItemType.String()
+}
+func (b *TSlice) Width() lib.Size {
+	return lib.QUADWORD
+}
+
 type TFunction struct {
 	ReturnType Type
 	Args       []Type
@@ -147,6 +207,121 @@ func (b *TStruct) String() string {
 	}
 	return "struct {" + strings.Join(args, ", ") + "}"
 }
+
+// Structs evaluate to their address, so their width is that of a pointer;
+// the size of the struct itself is given by its Layout.
 func (b *TStruct) Width() lib.Size {
 	return lib.QUADWORD
 }
+
+// Returns the index of the field with the given name, or -1.
+func (b *TStruct) FieldIndex(name string) int {
+	for i, f := range b.Fields {
+		if f == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// The layout of a struct in memory, which is the same as that of the
+// equivalent C struct: every field is aligned to its own alignment, and the
+// size is padded to a multiple of the largest one.
+type StructLayout struct {
+	Offsets []int
+	Size    int
+	Align   int
+}
+
+func (b *TStruct) Layout() *StructLayout {
+	result := &StructLayout{
+		Offsets: make([]int, len(b.FieldTypes)),
+		Align:   1,
+	}
+	for i, ty := range b.FieldTypes {
+		align := Alignof(ty)
+		result.Size = (result.Size + align - 1) &^ (align - 1)
+		result.Offsets[i] = result.Size
+		result.Size += Sizeof(ty)
+		if align > result.Align {
+			result.Align = align
+		}
+	}
+	result.Size = (result.Size + result.Align - 1) &^ (result.Align - 1)
+	return result
+}
+
+// Returns whether values of the type are stored inline, e.g. in a struct.
+// Such values evaluate to their address instead.
+func IsInline(t Type) bool {
+	switch ty := t.(type) {
+	case *TStruct:
+		return true
+	case *TArray:
+		return ty.Size > 0
+	}
+	return false
+}
+
+// Returns the number of bytes that a value of the type takes up in memory,
+// e.g. as the field of a struct (see StructLayout).
+func Sizeof(t Type) int {
+	switch ty := t.(type) {
+	case *TStruct:
+		return ty.Layout().Size
+	case *TArray:
+		if ty.Size > 0 {
+			return ty.Size * Sizeof(ty.ItemType)
+		}
+	}
+	return int(t.Width())
+}
+
+// Returns the alignment of a value of the type in memory.
+func Alignof(t Type) int {
+	switch ty := t.(type) {
+	case *TStruct:
+		return ty.Layout().Align
+	case *TArray:
+		if ty.Size > 0 {
+			return Alignof(ty.ItemType)
+		}
+	}
+	return int(t.Width())
+}
+
+// A scalar value inside a struct, at the given offset from its start.
+type ScalarField struct {
+	Offset int
+	Type   Type
+}
+
+// Returns the scalar values that make up a value of the type in memory, in
+// order of their offsets. The fields of nested structs and the items of
+// inline arrays are included, so that the calling conventions can classify
+// a struct by the values in each of its eightbytes.
+func Scalars(t Type) []*ScalarField {
+	switch ty := t.(type) {
+	case *TStruct:
+		result := []*ScalarField{}
+		layout := ty.Layout()
+		for i, field := range ty.FieldTypes {
+			for _, s := range Scalars(field) {
+				result = append(result, &ScalarField{layout.Offsets[i] + s.Offset, s.Type})
+			}
+		}
+		return result
+	case *TArray:
+		if ty.Size > 0 {
+			result := []*ScalarField{}
+			itemSize := Sizeof(ty.ItemType)
+			for i := 0; i < ty.Size; i++ {
+				for _, s := range Scalars(ty.ItemType) {
+					result = append(result, &ScalarField{i*itemSize + s.Offset, s.Type})
+				}
+			}
+			return result
+		}
+	}
+	return []*ScalarField{{0, t}}
+}
@@ -0,0 +1,353 @@
+package ssa
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+)
+
+// A Block is a basic block: a list of statements without control flow. If
+// the block has a Condition it's evaluated at the end of the block to choose
+// between the two successors; the first one is taken when the condition is
+// true. Otherwise the block has at most one successor.
+type Block struct {
+	ID        int
+	Phis      []*Phi
+	Stmts     []IR
+	Condition IRExpression
+	Succs     []*Block
+	Preds     []*Block
+
+	// Set by CFG.ComputeDominance
+	Idom      *Block
+	Dominated []*Block
+	Frontier  []*Block
+	rpo       int
+}
+
+// A Phi selects the value of Variable depending on the predecessor of the
+// block that control came from: Args[i] is the value when coming from
+// Preds[i]. An argument is nil if the variable is undefined on that path.
+type Phi struct {
+	Variable string
+	Args     []IRExpression
+}
+
+func (p *Phi) String() string {
+	args := []string{}
+	for _, arg := range p.Args {
+		if arg == nil {
+			args = append(args, "undefined")
+		} else {
+			args = append(args, arg.String())
+		}
+	}
+	return fmt.Sprintf("%s = phi(%s)", p.Variable, strings.Join(args, ", "))
+}
+
+func (b *Block) String() string {
+	result := []string{fmt.Sprintf("b%d:", b.ID)}
+	for _, phi := range b.Phis {
+		result = append(result, "  "+phi.String())
+	}
+	for _, stmt := range b.Stmts {
+		result = append(result, "  "+stmt.String())
+	}
+	succs := []string{}
+	for _, s := range b.Succs {
+		succs = append(succs, fmt.Sprintf("b%d", s.ID))
+	}
+	if b.Condition != nil {
+		result = append(result, fmt.Sprintf("  if %s goto %s", b.Condition.String(), strings.Join(succs, " else ")))
+	} else if len(succs) == 1 {
+		result = append(result, "  goto "+succs[0])
+	}
+	return strings.Join(result, "\n")
+}
+
+func (b *Block) isReachable() bool {
+	return b.rpo >= 0
+}
+
+// A CFG is the control flow graph of a function body or of a program. It
+// remembers the structured control flow that it was built from, so that it
+// can be turned back into IR (see CFG.IR).
+type CFG struct {
+	Entry  *Block
+	Blocks []*Block
+	// Whether the CFG is in SSA form.
+	SSA bool
+
+	root []node
+	// The versions of the variables in SSA form: every version has the name
+	// of the original variable followed by a version number.
+	original map[string]string
+	versions map[string]int
+}
+
+// The structured control flow: blocks, ifs and while loops.
+type node interface{}
+
+type ifNode struct {
+	Condition *Block
+	Then      []node
+	Else      []node
+}
+
+type whileNode struct {
+	Header *Block
+	Body   []node
+}
+
+// Builds the control flow graph of the statement.
+func NewCFG(stmt IR) (*CFG, error) {
+	g := &CFG{
+		original: map[string]string{},
+		versions: map[string]int{},
+	}
+	b := &cfgBuilder{cfg: g}
+	b.startBlock(&g.root)
+	g.Entry = b.current
+	if err := b.build(stmt, &g.root); err != nil {
+		return nil, err
+	}
+	g.removeUnreachable()
+	return g, nil
+}
+
+type cfgBuilder struct {
+	cfg     *CFG
+	current *Block
+}
+
+// Starts a new basic block at the end of the sequence.
+func (b *cfgBuilder) startBlock(seq *[]node) *Block {
+	block := &Block{ID: len(b.cfg.Blocks)}
+	b.cfg.Blocks = append(b.cfg.Blocks, block)
+	*seq = append(*seq, block)
+	b.current = block
+	return block
+}
+
+func addEdge(from, to *Block) {
+	from.Succs = append(from.Succs, to)
+	to.Preds = append(to.Preds, from)
+}
+
+func (b *cfgBuilder) build(stmt IR, seq *[]node) error {
+	switch v := stmt.(type) {
+	case *statements.IR_AndThen:
+		if err := b.build(v.Stmt1, seq); err != nil {
+			return err
+		}
+		return b.build(v.Stmt2, seq)
+	case *statements.IR_If:
+		condition := b.current
+		condition.Condition = v.Condition
+		node := &ifNode{Condition: condition}
+
+		addEdge(condition, b.startBlock(&node.Then))
+		if err := b.build(v.Stmt1, &node.Then); err != nil {
+			return err
+		}
+		thenExit := b.current
+
+		addEdge(condition, b.startBlock(&node.Else))
+		if err := b.build(v.Stmt2, &node.Else); err != nil {
+			return err
+		}
+		elseExit := b.current
+
+		*seq = append(*seq, node)
+		join := b.startBlock(seq)
+		addEdge(thenExit, join)
+		addEdge(elseExit, join)
+	case *statements.IR_While:
+		preheader := b.current
+		header := &Block{ID: len(b.cfg.Blocks), Condition: v.Condition}
+		b.cfg.Blocks = append(b.cfg.Blocks, header)
+		addEdge(preheader, header)
+		node := &whileNode{Header: header}
+
+		addEdge(header, b.startBlock(&node.Body))
+		if err := b.build(v.Stmt, &node.Body); err != nil {
+			return err
+		}
+		addEdge(b.current, header)
+
+		*seq = append(*seq, node)
+		addEdge(header, b.startBlock(seq))
+	case *statements.IR_Return:
+		b.current.Stmts = append(b.current.Stmts, v)
+		// Anything that follows is unreachable
+		b.startBlock(seq)
+	case *statements.IR_Nop:
+	case *statements.IR_Assignment, *statements.IR_ArrayAssignment, *statements.IR_PointerAssignment,
+		*statements.IR_FunctionDef, *statements.IR_Extern:
+		if _, _, err := StatementUses(stmt); err != nil {
+			return err
+		}
+		b.current.Stmts = append(b.current.Stmts, v)
+	default:
+		return fmt.Errorf("Unsupported statement '%s' in CFG", stmt.String())
+	}
+	return nil
+}
+
+// Numbers the reachable blocks in reverse postorder and disconnects the
+// blocks that can't be reached from the entry block, e.g. because they
+// follow a return statement.
+func (g *CFG) removeUnreachable() {
+	for _, b := range g.Blocks {
+		b.rpo = -1
+	}
+	postorder := []*Block{}
+	visited := map[*Block]bool{}
+	var visit func(b *Block)
+	visit = func(b *Block) {
+		visited[b] = true
+		for _, s := range b.Succs {
+			if !visited[s] {
+				visit(s)
+			}
+		}
+		postorder = append(postorder, b)
+	}
+	visit(g.Entry)
+	for i, b := range postorder {
+		b.rpo = len(postorder) - 1 - i
+	}
+	for _, b := range g.Blocks {
+		if b.isReachable() {
+			continue
+		}
+		for _, s := range b.Succs {
+			s.removePred(b)
+		}
+		b.Succs = nil
+	}
+}
+
+func (b *Block) removePred(pred *Block) {
+	for i, p := range b.Preds {
+		if p == pred {
+			b.Preds = append(b.Preds[:i], b.Preds[i+1:]...)
+			for _, phi := range b.Phis {
+				phi.Args = append(phi.Args[:i], phi.Args[i+1:]...)
+			}
+			return
+		}
+	}
+}
+
+// Returns the reachable blocks in reverse postorder, so that every block
+// comes after its dominators.
+func (g *CFG) ReversePostorder() []*Block {
+	result := []*Block{}
+	for _, b := range g.Blocks {
+		if b.isReachable() {
+			result = append(result, b)
+		}
+	}
+	for i := 1; i < len(result); i++ {
+		for j := i; j > 0 && result[j].rpo < result[j-1].rpo; j-- {
+			result[j], result[j-1] = result[j-1], result[j]
+		}
+	}
+	return result
+}
+
+func (g *CFG) String() string {
+	result := []string{}
+	for _, b := range g.ReversePostorder() {
+		result = append(result, b.String())
+	}
+	return strings.Join(result, "\n")
+}
+
+// Turns the CFG back into structured IR. The CFG can't be in SSA form, and
+// unreachable code is left out, apart from function definitions.
+func (g *CFG) IR() (IR, error) {
+	if g.SSA {
+		return nil, fmt.Errorf("The CFG has to be converted out of SSA form first")
+	}
+	stmts, err := g.nodesToIR(g.root)
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("Empty program")
+	}
+	return andThen(stmts), nil
+}
+
+func andThen(stmts []IR) IR {
+	result := stmts[0]
+	for _, stmt := range stmts[1:] {
+		result = statements.NewIR_AndThen(result, stmt)
+	}
+	return result
+}
+
+func (g *CFG) nodesToIR(nodes []node) ([]IR, error) {
+	result := []IR{}
+	for _, n := range nodes {
+		switch v := n.(type) {
+		case *Block:
+			for _, stmt := range v.Stmts {
+				if isDeclaration(stmt) || v.isReachable() {
+					result = append(result, stmt)
+				}
+			}
+		case *ifNode:
+			if !v.Condition.isReachable() {
+				continue
+			}
+			stmts1, err := g.nodesToIR(v.Then)
+			if err != nil {
+				return nil, err
+			}
+			stmts2, err := g.nodesToIR(v.Else)
+			if err != nil {
+				return nil, err
+			}
+			// Optimisations can empty both branches, in which case the if is
+			// only kept for the calls in its condition.
+			if len(stmts1) == 0 && len(stmts2) == 0 && !hasCalls(v.Condition.Condition) {
+				continue
+			}
+			result = append(result, statements.NewIR_If(v.Condition.Condition, branchToIR(stmts1), branchToIR(stmts2)))
+		case *whileNode:
+			if !v.Header.isReachable() {
+				continue
+			}
+			body, err := g.nodesToIR(v.Body)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, statements.NewIR_While(v.Header.Condition, branchToIR(body)))
+		}
+	}
+	return result, nil
+}
+
+// Branches that have been emptied are replaced by a nop, because if and while
+// statements always need a body.
+func branchToIR(stmts []IR) IR {
+	if len(stmts) == 0 {
+		return statements.NewIR_Nop()
+	}
+	return andThen(stmts)
+}
+
+// Function definitions and extern declarations are kept even when they can't
+// be reached, because they are global.
+func isDeclaration(stmt IR) bool {
+	switch stmt.(type) {
+	case *statements.IR_FunctionDef, *statements.IR_Extern:
+		return true
+	}
+	return false
+}
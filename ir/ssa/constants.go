@@ -0,0 +1,436 @@
+package ssa
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+)
+
+// A Pass that folds constant expressions and propagates the variables that
+// are assigned a constant into the expressions that read them, until nothing
+// changes anymore. The assignments and phi nodes of the propagated variables
+// are removed.
+func ConstantPropagation(g *CFG) error {
+	if !g.SSA {
+		return fmt.Errorf("The CFG is not in SSA form")
+	}
+	blocks := g.ReversePostorder()
+	constants := map[string]IRExpression{}
+	fold := func(e IRExpression) (IRExpression, error) {
+		if v, ok := e.(*expr.IR_Variable); ok {
+			if c, ok := constants[v.Value]; ok {
+				return c, nil
+			}
+			return v, nil
+		}
+		return foldExpression(e), nil
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range blocks {
+			for _, phi := range b.Phis {
+				var value IRExpression
+				agrees := true
+				for i, arg := range phi.Args {
+					if arg == nil {
+						continue
+					}
+					folded, err := MapExpression(arg, fold)
+					if err != nil {
+						return err
+					}
+					phi.Args[i] = folded
+					if value == nil {
+						value = folded
+					} else if !sameConstant(value, folded) {
+						agrees = false
+					}
+				}
+				// Undefined arguments can take any value, including the
+				// constant that all the other arguments agree on.
+				if _, found := constants[phi.Variable]; !found && agrees && value != nil && IsConstant(value) {
+					constants[phi.Variable] = value
+					changed = true
+				}
+			}
+			for i, stmt := range b.Stmts {
+				folded, err := MapStatement(stmt, fold)
+				if err != nil {
+					return err
+				}
+				b.Stmts[i] = folded
+				if a, ok := folded.(*statements.IR_Assignment); ok && IsConstant(a.Expr) {
+					if _, found := constants[a.Variable]; !found {
+						constants[a.Variable] = a.Expr
+						changed = true
+					}
+				}
+			}
+			if b.Condition != nil {
+				condition, err := MapExpression(b.Condition, fold)
+				if err != nil {
+					return err
+				}
+				b.Condition = condition
+			}
+		}
+	}
+
+	// Every read of a constant variable has been replaced by its value
+	for _, b := range blocks {
+		phis := []*Phi{}
+		for _, phi := range b.Phis {
+			if _, found := constants[phi.Variable]; !found {
+				phis = append(phis, phi)
+			}
+		}
+		b.Phis = phis
+		stmts := []IR{}
+		for _, stmt := range b.Stmts {
+			if a, ok := stmt.(*statements.IR_Assignment); ok {
+				if _, found := constants[a.Variable]; found {
+					continue
+				}
+			}
+			stmts = append(stmts, stmt)
+		}
+		b.Stmts = stmts
+	}
+	return nil
+}
+
+// Returns true if the expression is an integer, float or boolean literal.
+func IsConstant(e IRExpression) bool {
+	_, ok := newConstant(e)
+	return ok
+}
+
+// Folds the constant subexpressions of the expression, e.g. "3 + (2 * 25)"
+// becomes "53".
+func FoldConstants(e IRExpression) (IRExpression, error) {
+	return MapExpression(e, func(e IRExpression) (IRExpression, error) {
+		return foldExpression(e), nil
+	})
+}
+
+// A constant integer, float or boolean. Integers of every width are stored in
+// the bits of a uint64, truncated to their width.
+type constant struct {
+	Type    Type
+	Bits    uint64
+	Float   float64
+	Boolean bool
+}
+
+func newConstant(e IRExpression) (*constant, bool) {
+	switch v := e.(type) {
+	case *expr.IR_Uint8:
+		return &constant{Type: TUint8, Bits: uint64(v.Value)}, true
+	case *expr.IR_Uint16:
+		return &constant{Type: TUint16, Bits: uint64(v.Value)}, true
+	case *expr.IR_Uint32:
+		return &constant{Type: TUint32, Bits: uint64(v.Value)}, true
+	case *expr.IR_Uint64:
+		return &constant{Type: TUint64, Bits: v.Value}, true
+	case *expr.IR_Int8:
+		return newInteger(TInt8, uint64(v.Value)), true
+	case *expr.IR_Int16:
+		return newInteger(TInt16, uint64(v.Value)), true
+	case *expr.IR_Int32:
+		return newInteger(TInt32, uint64(v.Value)), true
+	case *expr.IR_Int64:
+		return newInteger(TInt64, uint64(v.Value)), true
+	case *expr.IR_Float64:
+		return &constant{Type: TFloat64, Float: v.Value}, true
+	case *expr.IR_Bool:
+		return &constant{Type: TBool, Boolean: v.Value}, true
+	}
+	return nil, false
+}
+
+// Returns the integer constant, wrapping the bits around the width of the
+// type like the machine code does.
+func newInteger(typ Type, bits uint64) *constant {
+	if typ.Width() < 8 {
+		bits &= 1<<(8*uint(typ.Width())) - 1
+	}
+	return &constant{Type: typ, Bits: bits}
+}
+
+// Returns the sign extended value of a signed integer.
+func (c *constant) signed() int64 {
+	shift := 64 - 8*uint(c.Type.Width())
+	return int64(c.Bits<<shift) >> shift
+}
+
+func (c *constant) expression() IRExpression {
+	switch c.Type {
+	case TUint8:
+		return expr.NewIR_Uint8(uint8(c.Bits))
+	case TUint16:
+		return expr.NewIR_Uint16(uint16(c.Bits))
+	case TUint32:
+		return expr.NewIR_Uint32(uint32(c.Bits))
+	case TUint64:
+		return expr.NewIR_Uint64(c.Bits)
+	case TInt8:
+		return expr.NewIR_Int8(int8(c.Bits))
+	case TInt16:
+		return expr.NewIR_Int16(int16(c.Bits))
+	case TInt32:
+		return expr.NewIR_Int32(int32(c.Bits))
+	case TInt64:
+		return expr.NewIR_Int64(int64(c.Bits))
+	case TFloat64:
+		return expr.NewIR_Float64(c.Float)
+	}
+	return expr.NewIR_Bool(c.Boolean)
+}
+
+func sameConstant(e1, e2 IRExpression) bool {
+	c1, ok1 := newConstant(e1)
+	c2, ok2 := newConstant(e2)
+	if !ok1 || !ok2 || c1.Type != c2.Type {
+		return false
+	}
+	return c1.Bits == c2.Bits && c1.Boolean == c2.Boolean &&
+		math.Float64bits(c1.Float) == math.Float64bits(c2.Float)
+}
+
+// Folds the expression if its operands are constant. Expressions that would
+// fail or trap at runtime, like a division by zero or an unsupported cast,
+// are left alone, so that they still do.
+func foldExpression(e IRExpression) IRExpression {
+	switch v := e.(type) {
+	case *expr.IR_Add:
+		return foldArithmetic(e, v.Op1, v.Op2,
+			func(a, b uint64) uint64 { return a + b },
+			func(a, b float64) float64 { return a + b })
+	case *expr.IR_Sub:
+		return foldArithmetic(e, v.Op1, v.Op2,
+			func(a, b uint64) uint64 { return a - b },
+			func(a, b float64) float64 { return a - b })
+	case *expr.IR_Mul:
+		return foldArithmetic(e, v.Op1, v.Op2,
+			func(a, b uint64) uint64 { return a * b },
+			func(a, b float64) float64 { return a * b })
+	case *expr.IR_Div:
+		return foldDiv(e, v.Op1, v.Op2, false)
+	case *expr.IR_Mod:
+		return foldDiv(e, v.Op1, v.Op2, true)
+	case *expr.IR_BitwiseAnd:
+		return foldBitwise(e, v.Op1, v.Op2, func(a, b uint64) uint64 { return a & b })
+	case *expr.IR_BitwiseOr:
+		return foldBitwise(e, v.Op1, v.Op2, func(a, b uint64) uint64 { return a | b })
+	case *expr.IR_BitwiseXor:
+		return foldBitwise(e, v.Op1, v.Op2, func(a, b uint64) uint64 { return a ^ b })
+	case *expr.IR_BitwiseNot:
+		if c, ok := newConstant(v.Op1); ok && IsInteger(c.Type) {
+			return newInteger(c.Type, ^c.Bits).expression()
+		}
+	case *expr.IR_ShiftLeft:
+		return foldShift(e, v.Op1, v.Op2, true)
+	case *expr.IR_ShiftRight:
+		return foldShift(e, v.Op1, v.Op2, false)
+	case *expr.IR_Equals:
+		return foldComparison(e, v.Op1, v.Op2, func(c int) bool { return c == 0 })
+	case *expr.IR_LT:
+		return foldComparison(e, v.Op1, v.Op2, func(c int) bool { return c < 0 })
+	case *expr.IR_LTE:
+		return foldComparison(e, v.Op1, v.Op2, func(c int) bool { return c <= 0 })
+	case *expr.IR_GT:
+		return foldComparison(e, v.Op1, v.Op2, func(c int) bool { return c > 0 })
+	case *expr.IR_GTE:
+		return foldComparison(e, v.Op1, v.Op2, func(c int) bool { return c >= 0 })
+	case *expr.IR_And:
+		return foldLogic(e, v.Op1, v.Op2, true)
+	case *expr.IR_Or:
+		return foldLogic(e, v.Op1, v.Op2, false)
+	case *expr.IR_Not:
+		if c, ok := newConstant(v.Op1); ok && c.Type == TBool {
+			return expr.NewIR_Bool(!c.Boolean)
+		}
+	case *expr.IR_Cast:
+		return foldCast(e, v.Value, v.CastToType)
+	}
+	return e
+}
+
+func constantOperands(op1, op2 IRExpression) (*constant, *constant, bool) {
+	c1, ok1 := newConstant(op1)
+	c2, ok2 := newConstant(op2)
+	if !ok1 || !ok2 || c1.Type != c2.Type {
+		return nil, nil, false
+	}
+	return c1, c2, true
+}
+
+func foldArithmetic(e, op1, op2 IRExpression, intOp func(a, b uint64) uint64, floatOp func(a, b float64) float64) IRExpression {
+	c1, c2, ok := constantOperands(op1, op2)
+	if !ok {
+		return e
+	}
+	if IsInteger(c1.Type) {
+		return newInteger(c1.Type, intOp(c1.Bits, c2.Bits)).expression()
+	} else if IsFloat(c1.Type) {
+		return expr.NewIR_Float64(floatOp(c1.Float, c2.Float))
+	}
+	return e
+}
+
+// Folds a division, or the remainder of one if remainder is true.
+func foldDiv(e, op1, op2 IRExpression, remainder bool) IRExpression {
+	c1, c2, ok := constantOperands(op1, op2)
+	if !ok {
+		return e
+	}
+	if IsFloat(c1.Type) && !remainder {
+		return expr.NewIR_Float64(c1.Float / c2.Float)
+	}
+	if !IsInteger(c1.Type) || c2.Bits == 0 {
+		return e
+	}
+	if IsSignedInteger(c1.Type) {
+		a, b := c1.signed(), c2.signed()
+		// The quotient of the smallest integer and -1 doesn't fit, which
+		// makes IDIV trap.
+		if b == -1 && a == -1<<(8*uint(c1.Type.Width())-1) {
+			return e
+		}
+		if remainder {
+			return newInteger(c1.Type, uint64(a%b)).expression()
+		}
+		return newInteger(c1.Type, uint64(a/b)).expression()
+	}
+	if remainder {
+		return newInteger(c1.Type, c1.Bits%c2.Bits).expression()
+	}
+	return newInteger(c1.Type, c1.Bits/c2.Bits).expression()
+}
+
+func foldBitwise(e, op1, op2 IRExpression, intOp func(a, b uint64) uint64) IRExpression {
+	c1, c2, ok := constantOperands(op1, op2)
+	if !ok || !IsInteger(c1.Type) {
+		return e
+	}
+	return newInteger(c1.Type, intOp(c1.Bits, c2.Bits)).expression()
+}
+
+// Folds a shift to the left (isLeft) or to the right. The shift count can
+// have a different integer type than the value and is taken modulo 32, or 64
+// for 64 bit values, like the machine code does.
+func foldShift(e, op1, op2 IRExpression, isLeft bool) IRExpression {
+	c1, ok1 := newConstant(op1)
+	c2, ok2 := newConstant(op2)
+	if !ok1 || !ok2 || !IsInteger(c1.Type) || !IsInteger(c2.Type) {
+		return e
+	}
+	count := c2.Bits & 31
+	if c1.Type.Width() == 8 {
+		count = c2.Bits & 63
+	}
+	if isLeft {
+		return newInteger(c1.Type, c1.Bits<<count).expression()
+	} else if IsSignedInteger(c1.Type) {
+		return newInteger(c1.Type, uint64(c1.signed()>>count)).expression()
+	}
+	return newInteger(c1.Type, c1.Bits>>count).expression()
+}
+
+func foldComparison(e, op1, op2 IRExpression, result func(int) bool) IRExpression {
+	c1, c2, ok := constantOperands(op1, op2)
+	if !ok {
+		return e
+	}
+	compare := func(less, equal bool) IRExpression {
+		if equal {
+			return expr.NewIR_Bool(result(0))
+		} else if less {
+			return expr.NewIR_Bool(result(-1))
+		}
+		return expr.NewIR_Bool(result(1))
+	}
+	if IsSignedInteger(c1.Type) {
+		return compare(c1.signed() < c2.signed(), c1.Bits == c2.Bits)
+	} else if IsInteger(c1.Type) {
+		return compare(c1.Bits < c2.Bits, c1.Bits == c2.Bits)
+	} else if IsFloat(c1.Type) {
+		// Every comparison with NaN is false, so "!(x == NaN)" is true
+		if math.IsNaN(c1.Float) || math.IsNaN(c2.Float) {
+			return expr.NewIR_Bool(false)
+		}
+		return compare(c1.Float < c2.Float, c1.Float == c2.Float)
+	} else if c1.Type == TBool {
+		if _, isEquals := e.(*expr.IR_Equals); isEquals {
+			return expr.NewIR_Bool(c1.Boolean == c2.Boolean)
+		}
+	}
+	return e
+}
+
+// Folds && (isAnd) or ||. If only one of the operands is constant the
+// expression can still be simplified: "true && x" becomes "x", and because
+// the second operand is only evaluated when it's needed, "false && x"
+// becomes "false". A constant second operand only drops out when it doesn't
+// matter for the result, as in "x && true"; "x && false" isn't folded,
+// because x may have side effects.
+func foldLogic(e, op1, op2 IRExpression, isAnd bool) IRExpression {
+	c1, ok1 := newConstant(op1)
+	c2, ok2 := newConstant(op2)
+	if ok1 && ok2 && c1.Type == TBool && c2.Type == TBool {
+		if isAnd {
+			return expr.NewIR_Bool(c1.Boolean && c2.Boolean)
+		}
+		return expr.NewIR_Bool(c1.Boolean || c2.Boolean)
+	}
+	if ok1 && c1.Type == TBool {
+		if c1.Boolean == isAnd {
+			return op2
+		}
+		return expr.NewIR_Bool(c1.Boolean)
+	}
+	if ok2 && c2.Type == TBool && c2.Boolean == isAnd {
+		return op1
+	}
+	return e
+}
+
+// Folds the casts that are supported by the backends. Conversions from
+// float64 truncate towards zero and conversions between float64 and uint64
+// go through a signed 64 bit integer, like CVTTSD2SI and CVTSI2SD do.
+func foldCast(e, value IRExpression, typ Type) IRExpression {
+	c, ok := newConstant(value)
+	if !ok {
+		return e
+	}
+	if c.Type == typ {
+		return value
+	}
+	isUnsigned := IsInteger(c.Type) && !IsSignedInteger(c.Type)
+	switch typ {
+	case TUint8, TUint16, TUint32:
+		if isUnsigned {
+			return newInteger(typ, c.Bits).expression()
+		}
+	case TUint64:
+		if isUnsigned {
+			return newInteger(typ, c.Bits).expression()
+		}
+		if c.Type == TFloat64 {
+			// Out of range values convert to the "integer indefinite"
+			// value, which isn't worth folding.
+			if math.IsNaN(c.Float) || c.Float >= math.Exp2(63) || c.Float < -math.Exp2(63) {
+				return e
+			}
+			return expr.NewIR_Uint64(uint64(int64(c.Float)))
+		}
+	case TFloat64:
+		if c.Type == TUint64 {
+			return expr.NewIR_Float64(float64(int64(c.Bits)))
+		}
+	}
+	return e
+}
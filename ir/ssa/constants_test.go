@@ -0,0 +1,146 @@
+package ssa_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bspaans/jit-compiler/ir"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/ssa"
+	. "github.com/bspaans/jit-compiler/ir/statements"
+)
+
+func Test_ConstantPropagation(t *testing.T) {
+	units := [][]string{
+		{"a = 3 + (2 * 25); return a", "return 53"},
+		{"a = uint8(100) / uint8(2); b = a * uint8(6); return b", "return 44"},
+		{"a = 2; if a == 2 { b = 3 } else { b = 4 }; return b", "if true {\n  b = 3\n} else {\n  b = 4\n}; return b"},
+		{"i = 0; n = 5; while i != n { i = i + 1 }; return n", "i = 0; while !(i == 5) {\n  i = i + 1\n}; return 5"},
+		{"a = true; b = a && c; return b", "b = c; return b"},
+		{"a = false; b = a && c; return b", "return false"},
+		{"a = true; b = a || c; return b", "return true"},
+		{"a = false; b = c && a; return b", "b = c && false; return b"},
+	}
+	for _, u := range units {
+		i := ir.MustParseIR(u[0])
+		result, err := ssa.Transform(i, ssa.ConstantPropagation)
+		if err != nil {
+			t.Fatal(err, "in", u[0])
+		}
+		if result.String() != ir.MustParseIR(u[1]).String() {
+			t.Fatal("Expecting", ir.MustParseIR(u[1]), "got", result, "in", u[0])
+		}
+	}
+}
+
+// The folded constants should be the same as the values that are calculated
+// by the machine code at runtime.
+func Test_ConstantPropagation_Execute(t *testing.T) {
+	units := []struct {
+		Type       string
+		ReturnType string
+		Runtime    string
+		Folded     string
+		Args       []interface{}
+	}{
+		{"uint8", "uint8", "a * b", "uint8(200) * uint8(3)", []interface{}{uint8(200), uint8(3)}},
+		{"uint8", "uint8", "a / b", "uint8(250) / uint8(7)", []interface{}{uint8(250), uint8(7)}},
+		{"uint16", "uint16", "a + b", "uint16(65535) + uint16(2)", []interface{}{uint16(65535), uint16(2)}},
+		{"uint32", "uint32", "a - b", "uint32(0) - uint32(1)", []interface{}{uint32(0), uint32(1)}},
+		{"uint64", "uint64", "a * b", "uint64(9223372036854775807) * uint64(3)", []interface{}{uint64(9223372036854775807), uint64(3)}},
+		{"int8", "int8", "a / b", "int8(-100) / int8(7)", []interface{}{int8(-100), int8(7)}},
+		{"int8", "int8", "a * b", "int8(-128) * int8(-1)", []interface{}{int8(-128), int8(-1)}},
+		{"int16", "int16", "a - b", "int16(-32768) - int16(1)", []interface{}{int16(-32768), int16(1)}},
+		{"int32", "int32", "a * b", "int32(2147483647) * int32(2)", []interface{}{int32(2147483647), int32(2)}},
+		{"int64", "int64", "a / b", "-9 / 2", []interface{}{int64(-9), int64(2)}},
+		{"uint8", "bool", "a < b", "uint8(200) < uint8(1)", []interface{}{uint8(200), uint8(1)}},
+		{"int8", "bool", "a < b", "int8(-1) < int8(1)", []interface{}{int8(-1), int8(1)}},
+		{"int32", "bool", "a >= b", "int32(-5) >= int32(-5)", []interface{}{int32(-5), int32(-5)}},
+		{"uint8", "uint64", "uint64(a) + uint64(b)", "uint64(uint8(255)) + uint64(uint8(-1))", []interface{}{uint8(255), uint8(255)}},
+		{"uint64", "float64", "float64(a) / float64(b)", "float64(uint64(1)) / float64(uint64(3))", []interface{}{uint64(1), uint64(3)}},
+		{"uint64", "float64", "float64(a) - float64(b) * 0.1", "float64(uint64(1)) - float64(uint64(3)) * 0.1", []interface{}{uint64(1), uint64(3)}},
+		{"uint64", "float64", "float64(a)", "float64(uint64(-1))", []interface{}{uint64(18446744073709551615), uint64(0)}},
+		{"uint64", "uint64", "uint64(float64(a) / float64(b) - 3.0)", "uint64(float64(uint64(1)) / float64(uint64(2)) - 3.0)", []interface{}{uint64(1), uint64(2)}},
+	}
+	for _, u := range units {
+		body := "return %s"
+		if u.ReturnType == "bool" {
+			// There's no bool type in the parser
+			u.ReturnType, body = "uint64", "if %s { return uint64(1) } else { return uint64(0) }"
+		}
+		source := fmt.Sprintf(`func runtime(a %s, b %s) %s { `+body+` }
+		func folded() %s { `+body+` }`, u.Type, u.Type, u.ReturnType, u.Runtime, u.ReturnType, u.Folded)
+		i := ir.MustParseIR(source)
+		transformed, err := ssa.Transform(i, ssa.ConstantPropagation)
+		if err != nil {
+			t.Fatal(err, "in", source)
+		}
+		var value IRExpression
+		switch f := transformed.(*IR_AndThen).Stmt2.(*IR_FunctionDef).Expr.Body.(type) {
+		case *IR_Return:
+			value = f.Expr
+		case *IR_If:
+			value = f.Condition
+		}
+		if !ssa.IsConstant(value) {
+			t.Fatal("Expecting", u.Folded, "to be folded, got", transformed)
+		}
+		module, err := ir.CompileModule(targetArch, targetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err, "in", source)
+		}
+		runtime, _ := module.Lookup("runtime")
+		expected, err := runtime.Call(u.Args...)
+		if err != nil {
+			t.Fatal(err, "in", source)
+		}
+		folded, _ := module.Lookup("folded")
+		result, err := folded.Call()
+		if err != nil {
+			t.Fatal(err, "in", source)
+		}
+		if result != expected {
+			t.Fatal("Expecting", expected, "got", result, "in", source)
+		}
+		module.Close()
+	}
+}
+
+// Optimisations can leave nothing in the branches of an if; the if should
+// then be dropped or get a nop, instead of failing to compile.
+func Test_ConstantPropagation_Empty_Branches(t *testing.T) {
+	units := []struct {
+		Source   string
+		Expected string
+		Results  []uint64
+	}{
+		{"func f(x uint64) uint64 { if x == uint64(1) { r = uint64(7) } else { r = uint64(3) }; return x }", "func f(x uint64) uint64 { return x }", []uint64{1, 2}},
+		{"func f(x uint64) uint64 { if x == uint64(1) { x = x + uint64(1) } else { r = uint64(3) }; return x }", "func f(x uint64) uint64 { if x == 1 { x = x + 1 } else { nop } ; return x }", []uint64{2, 2}},
+		{"func f(x uint64) uint64 { if x == uint64(1) { r = uint64(3) } else { x = x + uint64(1) }; return x }", "func f(x uint64) uint64 { if x == 1 { nop } else { x = x + 1 } ; return x }", []uint64{1, 3}},
+	}
+	for _, u := range units {
+		i := ir.MustParseIR(u.Source)
+		transformed, err := ssa.Transform(i, ssa.ConstantPropagation)
+		if err != nil {
+			t.Fatal(err, "in", u.Source)
+		}
+		if transformed.String() != u.Expected {
+			t.Fatal("Expecting", u.Expected, "got", transformed, "in", u.Source)
+		}
+		module, err := ir.CompileModule(targetArch, targetABI, []IR{i}, false)
+		if err != nil {
+			t.Fatal(err, "in", u.Source)
+		}
+		f, _ := module.Lookup("f")
+		for j, expected := range u.Results {
+			result, err := f.Call(uint64(j + 1))
+			if err != nil {
+				t.Fatal(err, "in", u.Source)
+			}
+			if result != expected {
+				t.Fatal("Expecting", expected, "got", result, "in", u.Source)
+			}
+		}
+		module.Close()
+	}
+}
@@ -0,0 +1,81 @@
+package ssa
+
+// Computes the immediate dominators, the dominator tree and the dominance
+// frontiers of the reachable blocks, using the iterative algorithm from "A
+// Simple, Fast Dominance Algorithm" by Cooper, Harvey and Kennedy.
+func (g *CFG) ComputeDominance() {
+	blocks := g.ReversePostorder()
+	for _, b := range g.Blocks {
+		b.Idom, b.Dominated, b.Frontier = nil, nil, nil
+	}
+	g.Entry.Idom = g.Entry
+	for changed := true; changed; {
+		changed = false
+		for _, b := range blocks[1:] {
+			var idom *Block
+			for _, p := range b.Preds {
+				if p.Idom == nil {
+					continue
+				}
+				if idom == nil {
+					idom = p
+				} else {
+					idom = intersect(p, idom)
+				}
+			}
+			if b.Idom != idom {
+				b.Idom = idom
+				changed = true
+			}
+		}
+	}
+	for _, b := range blocks[1:] {
+		b.Idom.Dominated = append(b.Idom.Dominated, b)
+	}
+	for _, b := range blocks {
+		if len(b.Preds) < 2 {
+			continue
+		}
+		for _, p := range b.Preds {
+			for runner := p; runner != b.Idom; runner = runner.Idom {
+				runner.addToFrontier(b)
+			}
+		}
+	}
+}
+
+func intersect(b1, b2 *Block) *Block {
+	for b1 != b2 {
+		for b1.rpo > b2.rpo {
+			b1 = b1.Idom
+		}
+		for b2.rpo > b1.rpo {
+			b2 = b2.Idom
+		}
+	}
+	return b1
+}
+
+func (b *Block) addToFrontier(f *Block) {
+	for _, existing := range b.Frontier {
+		if existing == f {
+			return
+		}
+	}
+	b.Frontier = append(b.Frontier, f)
+}
+
+// Returns true if every path from the entry to b goes through d. Only
+// meaningful after CFG.ComputeDominance.
+func (d *Block) Dominates(b *Block) bool {
+	for b != nil {
+		if b == d {
+			return true
+		}
+		if b.Idom == b {
+			return false
+		}
+		b = b.Idom
+	}
+	return false
+}
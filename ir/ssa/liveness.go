@@ -0,0 +1,105 @@
+package ssa
+
+// The variables that are live at the start and at the end of every
+// reachable block. The variables that are assigned by phi nodes are live
+// from the start of their block, but are not included in In; the arguments
+// of the phi nodes are included in Out of the corresponding predecessors.
+type Liveness struct {
+	In  map[*Block]map[string]bool
+	Out map[*Block]map[string]bool
+}
+
+func (g *CFG) ComputeLiveness() (*Liveness, error) {
+	blocks := g.ReversePostorder()
+	uses := map[*Block]map[string]bool{}
+	defs := map[*Block]map[string]bool{}
+	for _, b := range blocks {
+		uses[b], defs[b] = map[string]bool{}, map[string]bool{}
+		for _, phi := range b.Phis {
+			defs[b][phi.Variable] = true
+		}
+		for _, stmt := range b.Stmts {
+			used, defined, err := StatementUses(stmt)
+			if err != nil {
+				return nil, err
+			}
+			for _, u := range used {
+				if !defs[b][u] {
+					uses[b][u] = true
+				}
+			}
+			if defined != "" {
+				defs[b][defined] = true
+			}
+		}
+		if b.Condition != nil {
+			used, err := ExpressionUses(b.Condition)
+			if err != nil {
+				return nil, err
+			}
+			for _, u := range used {
+				if !defs[b][u] {
+					uses[b][u] = true
+				}
+			}
+		}
+	}
+
+	result := &Liveness{
+		In:  map[*Block]map[string]bool{},
+		Out: map[*Block]map[string]bool{},
+	}
+	for _, b := range blocks {
+		result.In[b], result.Out[b] = map[string]bool{}, map[string]bool{}
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := len(blocks) - 1; i >= 0; i-- {
+			b := blocks[i]
+			out := result.Out[b]
+			for _, s := range b.Succs {
+				for v := range result.In[s] {
+					if !out[v] {
+						out[v], changed = true, true
+					}
+				}
+				for _, v := range phiUses(s, b) {
+					if !out[v] {
+						out[v], changed = true, true
+					}
+				}
+			}
+			in := result.In[b]
+			for v := range uses[b] {
+				if !in[v] {
+					in[v], changed = true, true
+				}
+			}
+			for v := range out {
+				if !defs[b][v] && !in[v] {
+					in[v], changed = true, true
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// Returns the variables that are used by the phi nodes in b when coming from
+// pred.
+func phiUses(b, pred *Block) []string {
+	result := []string{}
+	for i, p := range b.Preds {
+		if p != pred {
+			continue
+		}
+		for _, phi := range b.Phis {
+			if phi.Args[i] == nil {
+				continue
+			}
+			used, _ := ExpressionUses(phi.Args[i])
+			result = append(result, used...)
+		}
+	}
+	return result
+}
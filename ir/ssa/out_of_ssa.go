@@ -0,0 +1,286 @@
+package ssa
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+)
+
+// Converts the CFG out of SSA form. The phi nodes are replaced by copies at
+// the end of the predecessors, after which the versions of every variable
+// that don't interfere with each other are merged back into one variable.
+func (g *CFG) ConvertFromSSA() error {
+	if !g.SSA {
+		return fmt.Errorf("The CFG is not in SSA form")
+	}
+	inserted := map[IR]bool{}
+	for _, b := range g.ReversePostorder() {
+		if len(b.Phis) == 0 {
+			continue
+		}
+		for i, p := range b.Preds {
+			if len(p.Succs) > 1 {
+				return fmt.Errorf("Critical edge from b%d to b%d", p.ID, b.ID)
+			}
+			copies := []*statements.IR_Assignment{}
+			for _, phi := range b.Phis {
+				arg := phi.Args[i]
+				if arg == nil {
+					continue
+				}
+				if v, ok := arg.(*expr.IR_Variable); ok && v.Value == phi.Variable {
+					continue
+				}
+				copies = append(copies, statements.NewIR_Assignment(phi.Variable, arg))
+			}
+			for _, stmt := range g.sequentializeCopies(copies) {
+				p.Stmts = append(p.Stmts, stmt)
+				inserted[stmt] = true
+			}
+		}
+		b.Phis = nil
+	}
+	g.SSA = false
+	return g.mergeVersions(inserted)
+}
+
+// The copies that replace the phi nodes of a block happen at the same time,
+// e.g. "a = b; b = a" swaps the variables. Returns the copies in an order
+// that has the same effect, using temporary variables to break cycles.
+func (g *CFG) sequentializeCopies(copies []*statements.IR_Assignment) []IR {
+	result := []IR{}
+	pending := []*statements.IR_Assignment{}
+	constants := []*statements.IR_Assignment{}
+	for _, c := range copies {
+		if _, ok := c.Expr.(*expr.IR_Variable); ok {
+			pending = append(pending, c)
+		} else {
+			// Constants don't read any of the variables, so they can go last
+			constants = append(constants, c)
+		}
+	}
+	isRead := func(v string) bool {
+		for _, c := range pending {
+			if c.Expr.(*expr.IR_Variable).Value == v {
+				return true
+			}
+		}
+		return false
+	}
+	for len(pending) > 0 {
+		progress := false
+		for i, c := range pending {
+			if !isRead(c.Variable) {
+				result = append(result, c)
+				pending = append(pending[:i], pending[i+1:]...)
+				progress = true
+				break
+			}
+		}
+		if progress {
+			continue
+		}
+		// Every remaining variable is still read by another copy, so
+		// one of them is saved in a temporary variable.
+		saved := pending[0].Variable
+		tmp := g.newVersion(g.OriginalName(saved))
+		result = append(result, statements.NewIR_Assignment(tmp, expr.NewIR_Variable(saved)))
+		for i, c := range pending {
+			if c.Expr.(*expr.IR_Variable).Value == saved {
+				pending[i] = statements.NewIR_Assignment(c.Variable, expr.NewIR_Variable(tmp))
+			}
+		}
+	}
+	for _, c := range constants {
+		result = append(result, c)
+	}
+	return result
+}
+
+// Merges the versions of every variable that don't interfere, i.e. that are
+// never live at the same time, and removes the copies that were inserted for
+// the phi nodes that become redundant. If the variable was defined before
+// the CFG, e.g. a function argument, its name is kept for that version.
+func (g *CFG) mergeVersions(inserted map[IR]bool) error {
+	interference, names, err := g.interference()
+	if err != nil {
+		return err
+	}
+	byOriginal := map[string][]string{}
+	for name := range names {
+		original := g.OriginalName(name)
+		byOriginal[original] = append(byOriginal[original], name)
+	}
+	originals := []string{}
+	for original := range byOriginal {
+		originals = append(originals, original)
+	}
+	sort.Strings(originals)
+
+	rename := map[string]string{}
+	for _, original := range originals {
+		versions := byOriginal[original]
+		sort.Slice(versions, func(i, j int) bool {
+			// The original name goes first
+			if versions[i] == original || versions[j] == original {
+				return versions[i] == original
+			}
+			return g.versionNumber(versions[i]) < g.versionNumber(versions[j])
+		})
+		// Every class is a set of versions that don't interfere, which
+		// are merged into one variable.
+		classes := [][]string{}
+		neighbours := []map[string]bool{}
+		for _, v := range versions {
+			merged := false
+			for i := range classes {
+				if neighbours[i][v] {
+					continue
+				}
+				classes[i] = append(classes[i], v)
+				for n := range interference[v] {
+					neighbours[i][n] = true
+				}
+				merged = true
+				break
+			}
+			if !merged {
+				classes = append(classes, []string{v})
+				n := map[string]bool{}
+				for k := range interference[v] {
+					n[k] = true
+				}
+				neighbours = append(neighbours, n)
+			}
+		}
+		for i, class := range classes {
+			name := class[0]
+			if i == 0 {
+				name = original
+			}
+			for _, v := range class {
+				rename[v] = name
+			}
+		}
+	}
+
+	renameVariable := func(v string) string {
+		if name, ok := rename[v]; ok {
+			return name
+		}
+		return v
+	}
+	for _, b := range g.ReversePostorder() {
+		stmts := []IR{}
+		for _, stmt := range b.Stmts {
+			renamed, err := RewriteStatement(stmt, renameVariable, renameVariable)
+			if err != nil {
+				return err
+			}
+			if inserted[stmt] {
+				a := renamed.(*statements.IR_Assignment)
+				if v, ok := a.Expr.(*expr.IR_Variable); ok && v.Value == a.Variable {
+					continue
+				}
+			}
+			stmts = append(stmts, renamed)
+		}
+		b.Stmts = stmts
+		if b.Condition != nil {
+			condition, err := RewriteExpression(b.Condition, renameVariable)
+			if err != nil {
+				return err
+			}
+			b.Condition = condition
+		}
+	}
+	return nil
+}
+
+func (g *CFG) versionNumber(v string) int {
+	number := 0
+	fmt.Sscanf(v[len(g.OriginalName(v))+1:], "%d", &number)
+	return number
+}
+
+// Returns the interference graph of the variables in a CFG that is not in SSA
+// form, and the names of all the variables. Two variables interfere when one
+// is assigned while the other one is live, unless the assignment copies the
+// other variable, in which case they hold the same value.
+func (g *CFG) interference() (map[string]map[string]bool, map[string]bool, error) {
+	live, err := g.ComputeLiveness()
+	if err != nil {
+		return nil, nil, err
+	}
+	interference := map[string]map[string]bool{}
+	names := map[string]bool{}
+	interfere := func(a, b string) {
+		if a == b {
+			return
+		}
+		if interference[a] == nil {
+			interference[a] = map[string]bool{}
+		}
+		if interference[b] == nil {
+			interference[b] = map[string]bool{}
+		}
+		interference[a][b] = true
+		interference[b][a] = true
+	}
+	for _, b := range g.ReversePostorder() {
+		liveNow := map[string]bool{}
+		for v := range live.Out[b] {
+			liveNow[v] = true
+			names[v] = true
+		}
+		if b.Condition != nil {
+			used, err := ExpressionUses(b.Condition)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, u := range used {
+				liveNow[u] = true
+				names[u] = true
+			}
+		}
+		for i := len(b.Stmts) - 1; i >= 0; i-- {
+			stmt := b.Stmts[i]
+			if _, ok := stmt.(*statements.IR_FunctionDef); ok {
+				continue
+			}
+			used, defined, err := StatementUses(stmt)
+			if err != nil {
+				return nil, nil, err
+			}
+			if defined != "" {
+				names[defined] = true
+				copied := ""
+				if a, ok := stmt.(*statements.IR_Assignment); ok {
+					if v, ok := a.Expr.(*expr.IR_Variable); ok {
+						copied = v.Value
+					}
+				}
+				for v := range liveNow {
+					if v != copied {
+						interfere(defined, v)
+					}
+				}
+				delete(liveNow, defined)
+			}
+			for _, u := range used {
+				liveNow[u] = true
+				names[u] = true
+			}
+		}
+	}
+	// The variables that are live at the entry are all defined before it.
+	for a := range live.In[g.Entry] {
+		for b := range live.In[g.Entry] {
+			interfere(a, b)
+		}
+	}
+	return interference, names, nil
+}
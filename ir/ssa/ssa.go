@@ -0,0 +1,169 @@
+package ssa
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+)
+
+// Converts the CFG into pruned SSA form, in which every variable is assigned
+// exactly once. Phi nodes are placed on the iterated dominance frontiers of
+// the assignments, but only where the variable is live.
+//
+// The versions of variable x are called x.1, x.2, etc. A read of x that isn't
+// preceded by an assignment, e.g. of a function argument, keeps the name x.
+// Function definitions are global and aren't renamed.
+func (g *CFG) ConvertToSSA() error {
+	if g.SSA {
+		return fmt.Errorf("The CFG is already in SSA form")
+	}
+	g.ComputeDominance()
+	live, err := g.ComputeLiveness()
+	if err != nil {
+		return err
+	}
+
+	assignedIn := map[string][]*Block{}
+	pinned := map[string]bool{}
+	for _, b := range g.ReversePostorder() {
+		for _, stmt := range b.Stmts {
+			if def, ok := stmt.(*statements.IR_FunctionDef); ok {
+				pinned[def.Name] = true
+				continue
+			}
+			_, defined, err := StatementUses(stmt)
+			if err != nil {
+				return err
+			}
+			if defined != "" {
+				assignedIn[defined] = append(assignedIn[defined], b)
+			}
+		}
+	}
+	variables := []string{}
+	for v := range assignedIn {
+		if !pinned[v] {
+			variables = append(variables, v)
+		}
+	}
+	sort.Strings(variables)
+
+	for _, v := range variables {
+		hasPhi := map[*Block]bool{}
+		worklist := append([]*Block{}, assignedIn[v]...)
+		for len(worklist) > 0 {
+			b := worklist[len(worklist)-1]
+			worklist = worklist[:len(worklist)-1]
+			for _, f := range b.Frontier {
+				if hasPhi[f] || !live.In[f][v] {
+					continue
+				}
+				f.Phis = append(f.Phis, &Phi{Variable: v, Args: make([]IRExpression, len(f.Preds))})
+				hasPhi[f] = true
+				worklist = append(worklist, f)
+			}
+		}
+	}
+
+	r := &renamer{
+		cfg: g,
+		// Variables that are live at the entry are defined elsewhere
+		defined: live.In[g.Entry],
+		pinned:  pinned,
+		stacks:  map[string][]string{},
+	}
+	if err := r.rename(g.Entry); err != nil {
+		return err
+	}
+	g.SSA = true
+	return nil
+}
+
+type renamer struct {
+	cfg     *CFG
+	defined map[string]bool
+	pinned  map[string]bool
+	stacks  map[string][]string
+}
+
+// Renames the variables in the block and in the blocks that it dominates,
+// and fills in the arguments of the phi nodes in its successors.
+func (r *renamer) rename(b *Block) error {
+	pushed := []string{}
+	newVersion := func(v string) string {
+		if r.pinned[v] {
+			return v
+		}
+		name := r.cfg.newVersion(v)
+		r.stacks[v] = append(r.stacks[v], name)
+		pushed = append(pushed, v)
+		return name
+	}
+	current := func(v string) string {
+		if stack := r.stacks[v]; len(stack) > 0 {
+			return stack[len(stack)-1]
+		}
+		return v
+	}
+
+	for _, phi := range b.Phis {
+		phi.Variable = newVersion(phi.Variable)
+	}
+	for i, stmt := range b.Stmts {
+		renamed, err := RewriteStatement(stmt, current, newVersion)
+		if err != nil {
+			return err
+		}
+		b.Stmts[i] = renamed
+	}
+	if b.Condition != nil {
+		condition, err := RewriteExpression(b.Condition, current)
+		if err != nil {
+			return err
+		}
+		b.Condition = condition
+	}
+	for _, s := range b.Succs {
+		for i, pred := range s.Preds {
+			if pred != b {
+				continue
+			}
+			for _, phi := range s.Phis {
+				v := r.cfg.OriginalName(phi.Variable)
+				if len(r.stacks[v]) > 0 {
+					phi.Args[i] = expr.NewIR_Variable(current(v))
+				} else if r.defined[v] {
+					phi.Args[i] = expr.NewIR_Variable(v)
+				}
+			}
+		}
+	}
+	for _, d := range b.Dominated {
+		if err := r.rename(d); err != nil {
+			return err
+		}
+	}
+	for _, v := range pushed {
+		r.stacks[v] = r.stacks[v][:len(r.stacks[v])-1]
+	}
+	return nil
+}
+
+// Returns a new version of the variable.
+func (g *CFG) newVersion(v string) string {
+	g.versions[v]++
+	name := fmt.Sprintf("%s.%d", v, g.versions[v])
+	g.original[name] = v
+	return name
+}
+
+// Returns the name of the variable that the SSA variable is a version of.
+func (g *CFG) OriginalName(v string) string {
+	if original, ok := g.original[v]; ok {
+		return original
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package ssa_test
+
+import (
+	"testing"
+
+	"github.com/bspaans/jit-compiler/ir"
+	"github.com/bspaans/jit-compiler/ir/encoding/x86_64"
+	. "github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/ssa"
+	. "github.com/bspaans/jit-compiler/ir/statements"
+)
+
+// These tests are outside of package ssa, because they parse and compile the
+// IR with package ir, which imports it.
+var targetArch = &x86_64.X86_64{}
+var targetABI = x86_64.NewABI_AMDSystemV()
+
+func Test_SSA_Dominance(t *testing.T) {
+	i := ir.MustParseIR("i = 0; while i != 10 { if i == 5 { i = i + 2 } else { i = i + 1 } }; return i")
+	g, err := ssa.NewCFG(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ComputeDominance()
+	// b0: i = 0, b1: while condition, b2: if condition, b3: then, b4: else,
+	// b5: end of if, b6: end of while
+	b := g.Blocks
+	expected := map[int]int{1: 0, 2: 1, 3: 2, 4: 2, 5: 2, 6: 1}
+	for block, idom := range expected {
+		if b[block].Idom != b[idom] {
+			t.Fatal("Expecting b", idom, "to be the immediate dominator of b", block, "got", b[block].Idom, "in", g)
+		}
+	}
+	if !b[1].Dominates(b[5]) || b[3].Dominates(b[5]) {
+		t.Fatal("Wrong dominance in", g)
+	}
+	frontiers := map[int][]int{3: {5}, 4: {5}, 5: {1}, 2: {1}, 1: {1}}
+	for block, frontier := range frontiers {
+		if len(b[block].Frontier) != len(frontier) {
+			t.Fatal("Expecting", frontier, "as the dominance frontier of b", block, "got", b[block].Frontier)
+		}
+		for j, f := range frontier {
+			if b[block].Frontier[j] != b[f] {
+				t.Fatal("Expecting", frontier, "as the dominance frontier of b", block, "got", b[block].Frontier)
+			}
+		}
+	}
+}
+
+func Test_SSA_Phis(t *testing.T) {
+	i := ir.MustParseIR("i = 0; f = 1; while i != 10 { j = i; f = f + j; i = i + 1 }; if f == 1 { f = 2; g = 3 } else { f = 4; g = 5 }; return f")
+	g, err := ssa.NewCFG(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.ConvertToSSA(); err != nil {
+		t.Fatal(err)
+	}
+	phis := map[string]int{}
+	assignments := map[string]int{}
+	for _, b := range g.Blocks {
+		for _, phi := range b.Phis {
+			phis[g.OriginalName(phi.Variable)]++
+			assignments[phi.Variable]++
+		}
+		for _, stmt := range b.Stmts {
+			if a, ok := stmt.(*IR_Assignment); ok {
+				assignments[a.Variable]++
+			}
+		}
+	}
+	// j and g are not live at the join points, so there are no phis for them
+	expected := map[string]int{"i": 1, "f": 2}
+	if len(phis) != len(expected) || phis["i"] != 1 || phis["f"] != 2 {
+		t.Fatal("Expecting phis", expected, "got", phis, "in", g)
+	}
+	for v, count := range assignments {
+		if count != 1 {
+			t.Fatal("Expecting a single assignment to", v, "in", g)
+		}
+	}
+	if err := g.ConvertFromSSA(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := g.IR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != i.String() {
+		t.Fatal("Expecting", i, "got", result)
+	}
+}
+
+// Copy propagation can make the phi nodes in a loop depend on each other,
+// e.g. a.2 = phi(a.1, b.2) and b.2 = phi(b.1, a.2), which requires a
+// temporary variable when converting out of SSA.
+func Test_SSA_Swap(t *testing.T) {
+	copyPropagation := func(g *ssa.CFG) error {
+		copies := map[string]string{}
+		for _, b := range g.Blocks {
+			for _, stmt := range b.Stmts {
+				if a, ok := stmt.(*IR_Assignment); ok {
+					if v, ok := a.Expr.(*IR_Variable); ok {
+						copies[a.Variable] = v.Value
+					}
+				}
+			}
+		}
+		resolve := func(v string) string {
+			for copies[v] != "" {
+				v = copies[v]
+			}
+			return v
+		}
+		keep := func(v string) string { return v }
+		for _, b := range g.ReversePostorder() {
+			for j, stmt := range b.Stmts {
+				rewritten, err := ssa.RewriteStatement(stmt, resolve, keep)
+				if err != nil {
+					return err
+				}
+				b.Stmts[j] = rewritten
+			}
+			for _, phi := range b.Phis {
+				for j, arg := range phi.Args {
+					if arg != nil {
+						phi.Args[j], _ = ssa.RewriteExpression(arg, resolve)
+					}
+				}
+			}
+		}
+		return nil
+	}
+	i := ir.MustParseIR("func swap(n uint64) uint64 { a = uint64(1); b = uint64(2); i = uint64(0); while i != n { t = a; a = b; b = t; i = i + uint64(1) }; return a }")
+	transformed, err := ssa.Transform(i, copyPropagation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := ir.CompileModule(targetArch, targetABI, []IR{transformed}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer module.Close()
+	var swap func(uint64) uint64
+	f, err := module.Lookup("swap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Bind(&swap); err != nil {
+		t.Fatal(err)
+	}
+	for n, expected := range []uint64{1, 2, 1, 2, 1} {
+		if swap(uint64(n)) != expected {
+			t.Fatal("Expecting", expected, "got", swap(uint64(n)), "in", transformed)
+		}
+	}
+}
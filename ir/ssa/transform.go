@@ -0,0 +1,89 @@
+package ssa
+
+import (
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+)
+
+// A Pass transforms a CFG in SSA form, e.g. to optimise it.
+type Pass func(g *CFG) error
+
+// Converts the statement into SSA form, runs the passes, and converts the
+// result back into IR. The bodies of the functions that are defined in the
+// statement are transformed separately, because they have their own scope.
+func Transform(stmt IR, passes ...Pass) (IR, error) {
+	stmt, err := transformFunctions(stmt, passes)
+	if err != nil {
+		return nil, err
+	}
+	g, err := NewCFG(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.ConvertToSSA(); err != nil {
+		return nil, err
+	}
+	for _, pass := range passes {
+		if err := pass(g); err != nil {
+			return nil, err
+		}
+	}
+	if err := g.ConvertFromSSA(); err != nil {
+		return nil, err
+	}
+	return g.IR()
+}
+
+func transformFunctions(stmt IR, passes []Pass) (IR, error) {
+	transform := func(f *expr.IR_Function) (*expr.IR_Function, error) {
+		body, err := Transform(f.Body, passes...)
+		if err != nil {
+			return nil, err
+		}
+		return expr.NewIR_Function(f.Signature, body), nil
+	}
+	switch v := stmt.(type) {
+	case *statements.IR_AndThen:
+		stmt1, err := transformFunctions(v.Stmt1, passes)
+		if err != nil {
+			return nil, err
+		}
+		stmt2, err := transformFunctions(v.Stmt2, passes)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_AndThen(stmt1, stmt2), nil
+	case *statements.IR_If:
+		stmt1, err := transformFunctions(v.Stmt1, passes)
+		if err != nil {
+			return nil, err
+		}
+		stmt2, err := transformFunctions(v.Stmt2, passes)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_If(v.Condition, stmt1, stmt2), nil
+	case *statements.IR_While:
+		body, err := transformFunctions(v.Stmt, passes)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_While(v.Condition, body), nil
+	case *statements.IR_FunctionDef:
+		f, err := transform(v.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_FunctionDef(v.Name, f), nil
+	case *statements.IR_Assignment:
+		if f, ok := v.Expr.(*expr.IR_Function); ok {
+			f, err := transform(f)
+			if err != nil {
+				return nil, err
+			}
+			return statements.NewIR_Assignment(v.Variable, f), nil
+		}
+	}
+	return stmt, nil
+}
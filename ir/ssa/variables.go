@@ -0,0 +1,297 @@
+package ssa
+
+import (
+	"fmt"
+
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+	"github.com/bspaans/jit-compiler/ir/statements"
+)
+
+// Returns a copy of the expression in which every subexpression e has been
+// replaced by f(e), starting at the leaves. Function literals are left alone,
+// because their bodies have a scope of their own.
+func MapExpression(e IRExpression, f func(IRExpression) (IRExpression, error)) (IRExpression, error) {
+	mapAll := func(exprs []IRExpression) ([]IRExpression, error) {
+		result := make([]IRExpression, len(exprs))
+		for i, e := range exprs {
+			r, err := MapExpression(e, f)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = r
+		}
+		return result, nil
+	}
+	binary := func(op1, op2 IRExpression, constructor func(op1, op2 IRExpression) IRExpression) (IRExpression, error) {
+		ops, err := mapAll([]IRExpression{op1, op2})
+		if err != nil {
+			return nil, err
+		}
+		return f(constructor(ops[0], ops[1]))
+	}
+	switch v := e.(type) {
+	case *expr.IR_Add:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_Add(a, b) })
+	case *expr.IR_Sub:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_Sub(a, b) })
+	case *expr.IR_Mul:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_Mul(a, b) })
+	case *expr.IR_Div:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_Div(a, b) })
+	case *expr.IR_Mod:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_Mod(a, b) })
+	case *expr.IR_BitwiseAnd:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_BitwiseAnd(a, b) })
+	case *expr.IR_BitwiseOr:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_BitwiseOr(a, b) })
+	case *expr.IR_BitwiseXor:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_BitwiseXor(a, b) })
+	case *expr.IR_ShiftLeft:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_ShiftLeft(a, b) })
+	case *expr.IR_ShiftRight:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_ShiftRight(a, b) })
+	case *expr.IR_And:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_And(a, b) })
+	case *expr.IR_Or:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_Or(a, b) })
+	case *expr.IR_Equals:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_Equals(a, b) })
+	case *expr.IR_LT:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_LT(a, b) })
+	case *expr.IR_LTE:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_LTE(a, b) })
+	case *expr.IR_GT:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_GT(a, b) })
+	case *expr.IR_GTE:
+		return binary(v.Op1, v.Op2, func(a, b IRExpression) IRExpression { return expr.NewIR_GTE(a, b) })
+	case *expr.IR_ArrayIndex:
+		return binary(v.Array, v.Index, func(a, b IRExpression) IRExpression { return expr.NewIR_ArrayIndex(a, b) })
+	case *expr.IR_Not:
+		op, err := MapExpression(v.Op1, f)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_Not(op))
+	case *expr.IR_BitwiseNot:
+		op, err := MapExpression(v.Op1, f)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_BitwiseNot(op))
+	case *expr.IR_Cast:
+		value, err := MapExpression(v.Value, f)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_Cast(value, v.CastToType))
+	case *expr.IR_StructField:
+		s, err := MapExpression(v.Struct, f)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_StructField(s, v.Field))
+	case *expr.IR_AddressOf:
+		value, err := MapExpression(v.Value, f)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_AddressOf(value))
+	case *expr.IR_Dereference:
+		pointer, err := MapExpression(v.Pointer, f)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_Dereference(pointer))
+	case *expr.IR_Alloc:
+		count, err := MapExpression(v.Count, f)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_Alloc(v.ItemType, count))
+	case *expr.IR_Slice:
+		value, err := MapExpression(v.Value, f)
+		if err != nil {
+			return nil, err
+		}
+		low, err := MapExpression(v.Low, f)
+		if err != nil {
+			return nil, err
+		}
+		high := v.High
+		if high != nil {
+			if high, err = MapExpression(high, f); err != nil {
+				return nil, err
+			}
+		}
+		return f(expr.NewIR_Slice(value, low, high))
+	case *expr.IR_Len:
+		value, err := MapExpression(v.Value, f)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_Len(value))
+	case *expr.IR_Call:
+		args, err := mapAll(v.Args)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_Call(v.Function, args))
+	case *expr.IR_Syscall:
+		syscall, err := MapExpression(v.Syscall, f)
+		if err != nil {
+			return nil, err
+		}
+		args, err := mapAll(v.Args)
+		if err != nil {
+			return nil, err
+		}
+		return f(expr.NewIR_Syscall(syscall, args))
+	case *expr.IR_Variable, *expr.IR_Uint8, *expr.IR_Uint16, *expr.IR_Uint32, *expr.IR_Uint64,
+		*expr.IR_Int8, *expr.IR_Int16, *expr.IR_Int32, *expr.IR_Int64,
+		*expr.IR_Float64, *expr.IR_Bool, *expr.IR_ByteArray,
+		*expr.IR_StaticArray, *expr.IR_Struct:
+		return f(e)
+	case *expr.IR_Function:
+		return e, nil
+	}
+	return nil, fmt.Errorf("Unsupported expression '%s' in SSA", e.String())
+}
+
+// Returns a copy of the expression in which every variable that is read has
+// been replaced by f(variable), including the functions that are called.
+func RewriteExpression(e IRExpression, f func(string) string) (IRExpression, error) {
+	return MapExpression(e, func(e IRExpression) (IRExpression, error) {
+		switch v := e.(type) {
+		case *expr.IR_Variable:
+			return expr.NewIR_Variable(f(v.Value)), nil
+		case *expr.IR_Call:
+			return expr.NewIR_Call(f(v.Function), v.Args), nil
+		}
+		return e, nil
+	})
+}
+
+// Returns whether the expression calls a function anywhere.
+func hasCalls(e IRExpression) bool {
+	found := false
+	MapExpression(e, func(e IRExpression) (IRExpression, error) {
+		if _, ok := e.(*expr.IR_Call); ok {
+			found = true
+		}
+		return e, nil
+	})
+	return found
+}
+
+// Returns the variables that are read by the expression.
+func ExpressionUses(e IRExpression) ([]string, error) {
+	uses := []string{}
+	_, err := RewriteExpression(e, func(v string) string {
+		uses = append(uses, v)
+		return v
+	})
+	return uses, err
+}
+
+// Returns a copy of the statement in which the variables that are read have
+// been replaced by use(variable) and the variable that is assigned by
+// def(variable). Statements that affect control flow aren't supported; they
+// are represented by the edges of the CFG.
+func RewriteStatement(stmt IR, use, def func(string) string) (IR, error) {
+	switch v := stmt.(type) {
+	case *statements.IR_Assignment:
+		e, err := RewriteExpression(v.Expr, use)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_Assignment(def(v.Variable), e), nil
+	case *statements.IR_ArrayAssignment:
+		// Writing to an array doesn't redefine the variable that points to it.
+		index, err := RewriteExpression(v.Index, use)
+		if err != nil {
+			return nil, err
+		}
+		e, err := RewriteExpression(v.Expr, use)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_ArrayAssignment(use(v.Variable), index, e), nil
+	case *statements.IR_PointerAssignment:
+		pointer, err := RewriteExpression(v.Pointer, use)
+		if err != nil {
+			return nil, err
+		}
+		e, err := RewriteExpression(v.Expr, use)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_PointerAssignment(pointer, e), nil
+	case *statements.IR_Return:
+		e, err := RewriteExpression(v.Expr, use)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_Return(e), nil
+	case *statements.IR_FunctionDef, *statements.IR_Extern:
+		// Function definitions are global and never renamed.
+		return v, nil
+	}
+	return nil, fmt.Errorf("Unsupported statement '%s' in basic block", stmt.String())
+}
+
+// Returns the variables that are read by the statement and the variable that
+// is assigned, if any.
+func StatementUses(stmt IR) ([]string, string, error) {
+	uses, defined := []string{}, ""
+	_, err := RewriteStatement(stmt, func(v string) string {
+		uses = append(uses, v)
+		return v
+	}, func(v string) string {
+		defined = v
+		return v
+	})
+	return uses, defined, err
+}
+
+// Returns a copy of the statement in which the expressions have been mapped
+// with MapExpression. The variables that are written to are left alone.
+func MapStatement(stmt IR, f func(IRExpression) (IRExpression, error)) (IR, error) {
+	switch v := stmt.(type) {
+	case *statements.IR_Assignment:
+		e, err := MapExpression(v.Expr, f)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_Assignment(v.Variable, e), nil
+	case *statements.IR_ArrayAssignment:
+		index, err := MapExpression(v.Index, f)
+		if err != nil {
+			return nil, err
+		}
+		e, err := MapExpression(v.Expr, f)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_ArrayAssignment(v.Variable, index, e), nil
+	case *statements.IR_PointerAssignment:
+		pointer, err := MapExpression(v.Pointer, f)
+		if err != nil {
+			return nil, err
+		}
+		e, err := MapExpression(v.Expr, f)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_PointerAssignment(pointer, e), nil
+	case *statements.IR_Return:
+		e, err := MapExpression(v.Expr, f)
+		if err != nil {
+			return nil, err
+		}
+		return statements.NewIR_Return(e), nil
+	case *statements.IR_FunctionDef, *statements.IR_Extern:
+		return v, nil
+	}
+	return nil, fmt.Errorf("Unsupported statement '%s' in basic block", stmt.String())
+}
@@ -0,0 +1,35 @@
+package statements
+
+import (
+	"github.com/bspaans/jit-compiler/ir/expr"
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Returns an error if the statement calls an unknown function, because the
+// type of such a call can't be determined (see IR_Call.ReturnType). Nested
+// statements are checked when they are encoded themselves.
+func CheckCalls(stmt IR, ctx *IR_Context) error {
+	exprs := []IRExpression{}
+	switch v := stmt.(type) {
+	case *IR_ArrayAssignment:
+		exprs = append(exprs, v.Index, v.Expr)
+	case *IR_Assignment:
+		exprs = append(exprs, v.Expr)
+	case *IR_If:
+		exprs = append(exprs, v.Condition)
+	case *IR_PointerAssignment:
+		exprs = append(exprs, v.Pointer, v.Expr)
+	case *IR_Return:
+		exprs = append(exprs, v.Expr)
+	case *IR_While:
+		exprs = append(exprs, v.Condition)
+	}
+	for _, e := range exprs {
+		if call, ok := e.(*expr.IR_Call); ok {
+			if _, err := call.CheckCall(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package statements
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Declares a function that is defined in a shared object, so that it can be
+// called like any other function. Its address gets looked up when the code
+// is loaded (see Segments.AddImport).
+type IR_Extern struct {
+	*BaseIR
+	Name      string
+	Library   string
+	Signature *TFunction
+	// The slot that holds the address of the function.
+	Address *SegmentPointer
+}
+
+// The library that external functions are looked up in by default.
+const DefaultLibrary = "libc.so.6"
+
+func NewIR_Extern(name, library string, signature *TFunction) *IR_Extern {
+	if library == "" {
+		library = DefaultLibrary
+	}
+	return &IR_Extern{
+		BaseIR:    NewBaseIR(Extern),
+		Name:      name,
+		Library:   library,
+		Signature: signature,
+	}
+}
+
+func (i *IR_Extern) String() string {
+	args := []string{}
+	for j, arg := range i.Signature.ArgNames {
+		args = append(args, arg+" "+i.Signature.Args[j].String())
+	}
+	return fmt.Sprintf("extern %q func %s(%s) %s", i.Library, i.Name, strings.Join(args, ", "), i.Signature.ReturnType.String())
+}
+
+func (i *IR_Extern) SSA_Transform(ctx *SSA_Context) IR {
+	return i
+}
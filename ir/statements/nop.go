@@ -0,0 +1,26 @@
+package statements
+
+import (
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// A statement that does nothing. It takes the place of the branches of if
+// and while statements that the optimisations have removed all statements
+// from (see ssa.CFG.IR).
+type IR_Nop struct {
+	*BaseIR
+}
+
+func NewIR_Nop() *IR_Nop {
+	return &IR_Nop{
+		BaseIR: NewBaseIR(Nop),
+	}
+}
+
+func (i *IR_Nop) String() string {
+	return "nop"
+}
+
+func (i *IR_Nop) SSA_Transform(ctx *SSA_Context) IR {
+	return i
+}
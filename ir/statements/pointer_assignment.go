@@ -0,0 +1,68 @@
+package statements
+
+import (
+	"fmt"
+
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Stores the value of Expr in the memory that Pointer points to.
+type IR_PointerAssignment struct {
+	*BaseIR
+	Pointer IRExpression
+	Expr    IRExpression
+}
+
+func NewIR_PointerAssignment(pointer IRExpression, expr IRExpression) *IR_PointerAssignment {
+	return &IR_PointerAssignment{
+		BaseIR:  NewBaseIR(PointerAssignment),
+		Pointer: pointer,
+		Expr:    expr,
+	}
+}
+
+func (i *IR_PointerAssignment) String() string {
+	return fmt.Sprintf("*(%s) = %s", i.Pointer.String(), i.Expr.String())
+}
+
+// Returns the type of the value that the pointer points to, after checking
+// that the value can be stored there. Integers can be stored in integers of
+// any width, and get truncated. Structs and arrays can't be copied; their
+// fields and items have to be assigned one by one.
+func (i *IR_PointerAssignment) TargetType(ctx *IR_Context) (Type, error) {
+	pointerType, ok := i.Pointer.ReturnType(ctx).(*TPointer)
+	if !ok {
+		return nil, fmt.Errorf("Expecting a pointer in %s", i.String())
+	}
+	targetType, valueType := pointerType.Target, i.Expr.ReturnType(ctx)
+	if IsInline(targetType) || IsInline(valueType) {
+		return nil, fmt.Errorf("Can't assign %s values in %s", valueType, i.String())
+	}
+	if IsInteger(targetType) && IsInteger(valueType) {
+		return targetType, nil
+	}
+	if targetType.Type() != valueType.Type() || (IsPointer(targetType) && targetType.String() != valueType.String()) {
+		return nil, fmt.Errorf("Can't assign %s to %s in %s", valueType, targetType, i.String())
+	}
+	return targetType, nil
+}
+
+func (i *IR_PointerAssignment) AddToDataSection(ctx *IR_Context) error {
+	if err := i.Pointer.AddToDataSection(ctx); err != nil {
+		return err
+	}
+	return i.Expr.AddToDataSection(ctx)
+}
+
+func (i *IR_PointerAssignment) SSA_Transform(ctx *SSA_Context) IR {
+	rewrites, expr := i.Pointer.SSA_Transform(ctx)
+	rewrites2, expr2 := i.Expr.SSA_Transform(ctx)
+	for _, rw := range rewrites2 {
+		rewrites = append(rewrites, rw)
+	}
+	ir := SSA_Rewrites_to_IR(rewrites)
+	if ir == nil {
+		return i
+	}
+	return NewIR_AndThen(ir, NewIR_PointerAssignment(expr, expr2))
+}
@@ -0,0 +1,31 @@
+package statements
+
+import (
+	. "github.com/bspaans/jit-compiler/ir/shared"
+)
+
+// Returns the variables and functions that get assigned in the statement.
+// The bodies of function literals are not descended into, because they have
+// their own variable scope.
+func AssignedVariables(stmt IR) map[string]bool {
+	result := map[string]bool{}
+	var visit func(stmt IR)
+	visit = func(stmt IR) {
+		switch v := stmt.(type) {
+		case *IR_AndThen:
+			visit(v.Stmt1)
+			visit(v.Stmt2)
+		case *IR_Assignment:
+			result[v.Variable] = true
+		case *IR_FunctionDef:
+			result[v.Name] = true
+		case *IR_If:
+			visit(v.Stmt1)
+			visit(v.Stmt2)
+		case *IR_While:
+			visit(v.Stmt)
+		}
+	}
+	visit(stmt)
+	return result
+}
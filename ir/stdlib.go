@@ -2,10 +2,10 @@ package ir
 
 const Stdlib = `
 func Write(fid uint64, str []uint8, len uint64) int64 { 
-	return syscall(1, fid, str, len) 
+	return syscall(1, fid, &str[0], len) 
 } 
 func Open(filename []uint8, flags uint64, mode uint64) int64 { 
-	return syscall(2, filename, flags, mode) 
+	return syscall(2, &filename[0], flags, mode) 
 } 
 func Close(fid uint64) int64 { 
 	return syscall(3, fid) 
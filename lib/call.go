@@ -0,0 +1,13 @@
+package lib
+
+// The maximum number of eightbytes that can be passed on the stack. The
+// called code also uses the trampoline's frame as its stack, so the
+// arguments can only take up a small part of it.
+const MaxStackArguments = 512
+
+// The registers in which values are returned: rax and rdx, and xmm0 and
+// xmm1.
+type SysVResult struct {
+	Ints   [2]uint64
+	Floats [2]float64
+}
@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+)
+
+// Implemented in call_amd64.s
+func callSysV(address uintptr, ints *[6]uint64, floats *[8]float64, stack *uint64, n int, result *[4]uint64)
+
+// Calls the machine code at the given address following the System V AMD64
+// calling convention. The integer and pointer arguments are passed in rdi,
+// rsi, rdx, rcx, r8 and r9 and the floating point arguments in xmm0-7.
+// Returns the contents of rax and xmm0.
+func CallSysV(address uintptr, ints []uint64, floats []float64) (uint64, float64, error) {
+	result, err := CallSysVStack(address, ints, floats, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	return result.Ints[0], result.Floats[0], nil
+}
+
+// Calls the machine code at the given address like CallSysV, but with the
+// eightbytes in stack on the stack, the first one at the lowest address.
+// Returns all the registers that can hold (a part of) the return value.
+func CallSysVStack(address uintptr, ints []uint64, floats []float64, stack []uint64) (*SysVResult, error) {
+	intArgs, floatArgs, result := [6]uint64{}, [8]float64{}, [4]uint64{}
+	if len(ints) > len(intArgs) || len(floats) > len(floatArgs) || len(stack) > MaxStackArguments {
+		return nil, fmt.Errorf("Too many arguments: %d integers, %d floats and %d eightbytes on the stack", len(ints), len(floats), len(stack))
+	}
+	copy(intArgs[:], ints)
+	copy(floatArgs[:], floats)
+	var stackArgs *uint64
+	if len(stack) > 0 {
+		stackArgs = &stack[0]
+	}
+	callSysV(address, &intArgs, &floatArgs, stackArgs, len(stack), &result)
+	return &SysVResult{
+		Ints:   [2]uint64{result[0], result[1]},
+		Floats: [2]float64{math.Float64frombits(result[2]), math.Float64frombits(result[3])},
+	}, nil
+}
@@ -0,0 +1,67 @@
+#include "textflag.h"
+
+// func callSysV(address uintptr, ints *[6]uint64, floats *[8]float64, stack *uint64, n int, result *[4]uint64)
+//
+// The frame is much larger than what is needed here, so that the Go runtime
+// grows the goroutine stack before the call; the called code uses the
+// reserved space as its stack.
+TEXT ·callSysV(SB), $65536-48
+	MOVQ address+0(FP), AX
+	MOVQ ints+8(FP), R11
+	MOVQ floats+16(FP), R10
+	MOVQ stack+24(FP), R9
+	MOVQ n+32(FP), CX
+	MOVQ result+40(FP), R13
+
+	// Move the stack pointer to the top of the frame and align it to 16
+	// bytes, as required at call sites. The original stack pointer and the
+	// result pointer are kept at the bottom of the new stack.
+	MOVQ SP, R12
+	ADDQ $65504, SP
+	ANDQ $~15, SP
+	SUBQ $16, SP
+	MOVQ R12, 0(SP)
+	MOVQ R13, 8(SP)
+
+	// The stack arguments go below that, padded to keep the alignment. Their
+	// size is kept in rbx, which the called code has to preserve.
+	MOVQ CX, BX
+	SHLQ $3, BX
+	ADDQ $15, BX
+	ANDQ $~15, BX
+	SUBQ BX, SP
+	XORQ DX, DX
+copy:
+	CMPQ DX, CX
+	JGE copied
+	MOVQ (R9)(DX*8), R8
+	MOVQ R8, (SP)(DX*8)
+	INCQ DX
+	JMP copy
+copied:
+
+	MOVQ 0(R11), DI
+	MOVQ 8(R11), SI
+	MOVQ 16(R11), DX
+	MOVQ 24(R11), CX
+	MOVQ 32(R11), R8
+	MOVQ 40(R11), R9
+	MOVSD 0(R10), X0
+	MOVSD 8(R10), X1
+	MOVSD 16(R10), X2
+	MOVSD 24(R10), X3
+	MOVSD 32(R10), X4
+	MOVSD 40(R10), X5
+	MOVSD 48(R10), X6
+	MOVSD 56(R10), X7
+
+	CALL AX
+
+	ADDQ BX, SP
+	MOVQ 8(SP), R13
+	MOVQ AX, 0(R13)
+	MOVQ DX, 8(R13)
+	MOVSD X0, 16(R13)
+	MOVSD X1, 24(R13)
+	MOVQ 0(SP), SP
+	RET
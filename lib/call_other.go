@@ -0,0 +1,17 @@
+//go:build !amd64
+// +build !amd64
+
+package lib
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func CallSysV(address uintptr, ints []uint64, floats []float64) (uint64, float64, error) {
+	return 0, 0, fmt.Errorf("Calling compiled code is not supported on %s", runtime.GOARCH)
+}
+
+func CallSysVStack(address uintptr, ints []uint64, floats []float64, stack []uint64) (*SysVResult, error) {
+	return nil, fmt.Errorf("Calling compiled code is not supported on %s", runtime.GOARCH)
+}
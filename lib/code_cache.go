@@ -0,0 +1,258 @@
+package lib
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// A CodeCache holds executable machine code. Code is written into pages that
+// are readable and writable, which are then made readable and executable with
+// mprotect, so that memory is never writable and executable at the same time.
+//
+// The pages are taken from arenas that are shared between the blocks of code
+// in the cache. Arenas are mapped when they are needed and unmapped again
+// once all their blocks have been freed.
+type CodeCache struct {
+	// The size of new arenas in pages. Code that doesn't fit in an arena
+	// gets an arena of its own.
+	ArenaPages int
+	arenas     []*arena
+	pageSize   int
+	mutex      sync.Mutex
+}
+
+// The cache that is used by Program.Execute and MachineCode.Execute.
+var DefaultCodeCache = NewCodeCache()
+
+func NewCodeCache() *CodeCache {
+	return &CodeCache{
+		ArenaPages: 64,
+		pageSize:   syscall.Getpagesize(),
+	}
+}
+
+// A CodeBlock is a Program that has been added to a CodeCache.
+//
+// Blocks start at a page boundary, because the protection of memory can only
+// be changed per page: the pages of code that might be running are never made
+// writable again when other code is added.
+type CodeBlock struct {
+	// The executable copy of the machine code.
+	Code MachineCode
+	// The offset of the entry point in the code.
+	Entry int
+	// The writable copy of the data, which ends where the code starts.
+	Data []uint8
+	// The read-only copy of the read-only data, which ends at the page
+	// boundary in front of the data.
+	ReadOnlyData []uint8
+	cache        *CodeCache
+	arena        *arena
+	start        int
+	pages        int
+}
+
+// Copies the machine code of the program into executable memory. The data,
+// which can be empty, is copied into writable memory that ends where the code
+// starts, so that the code can refer to it relative to the instruction
+// pointer. The read-only data is copied into the pages in front of that, and
+// the references to it are relocated before the code is made executable.
+func (c *CodeCache) Add(p *Program) (*CodeBlock, error) {
+	code, data, readOnly := p.Code, p.Data, p.ReadOnlyData
+	if len(code) == 0 {
+		return nil, fmt.Errorf("Can't add empty machine code to the code cache")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	readOnlyPages := (len(readOnly) + c.pageSize - 1) / c.pageSize
+	dataPages := (len(data) + c.pageSize - 1) / c.pageSize
+	codePages := (len(code) + c.pageSize - 1) / c.pageSize
+	pages := readOnlyPages + dataPages + codePages
+	arena, start, err := c.allocate(pages)
+	if err != nil {
+		return nil, err
+	}
+	memory := arena.memory[start*c.pageSize : (start+pages)*c.pageSize]
+	readOnlyEnd := readOnlyPages * c.pageSize
+	codeStart := readOnlyEnd + dataPages*c.pageSize
+	readOnlyMemory := memory[readOnlyEnd-len(readOnly) : readOnlyEnd]
+	dataMemory := memory[codeStart-len(data) : codeStart]
+	codeMemory := memory[codeStart:]
+	fail := func(err error) (*CodeBlock, error) {
+		syscall.Mprotect(memory, syscall.PROT_NONE)
+		arena.release(start, pages)
+		return nil, err
+	}
+	if err := syscall.Mprotect(memory, syscall.PROT_READ|syscall.PROT_WRITE); err != nil {
+		return fail(err)
+	}
+	copy(readOnlyMemory, readOnly)
+	copy(dataMemory, data)
+	copy(codeMemory, code)
+	// The address of the read-only data relative to the start of the code
+	readOnlyAddress := readOnlyEnd - len(readOnly) - codeStart
+	for _, r := range p.Relocations {
+		if r.Offset < 0 || r.Offset >= len(code) {
+			return fail(fmt.Errorf("Relocation at 0x%x is outside of the code", r.Offset))
+		}
+		value := readOnlyAddress + r.Label.Address + r.Addend - r.Offset
+		if err := r.Apply(codeMemory[r.Offset:len(code)], value); err != nil {
+			return fail(err)
+		}
+	}
+	if readOnlyPages > 0 {
+		if err := syscall.Mprotect(memory[:readOnlyEnd], syscall.PROT_READ); err != nil {
+			return fail(err)
+		}
+	}
+	if err := syscall.Mprotect(codeMemory, syscall.PROT_READ|syscall.PROT_EXEC); err != nil {
+		return fail(err)
+	}
+	return &CodeBlock{
+		Code:         codeMemory[:len(code)],
+		Entry:        p.Entry,
+		Data:         dataMemory,
+		ReadOnlyData: readOnlyMemory,
+		cache:        c,
+		arena:        arena,
+		start:        start,
+		pages:        pages,
+	}, nil
+}
+
+// Finds free pages in one of the arenas, mapping a new arena if there are
+// none.
+func (c *CodeCache) allocate(pages int) (*arena, int, error) {
+	for _, a := range c.arenas {
+		if start, ok := a.allocate(pages); ok {
+			return a, start, nil
+		}
+	}
+	arenaPages := c.ArenaPages
+	if pages > arenaPages {
+		arenaPages = pages
+	}
+	// The pages are inaccessible until they are allocated.
+	memory, err := syscall.Mmap(-1, 0, arenaPages*c.pageSize, syscall.PROT_NONE, syscall.MAP_PRIVATE|mmapFlags)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Failed to map code cache arena: %s", err.Error())
+	}
+	a := &arena{
+		memory: memory,
+		pages:  arenaPages,
+		free:   []pageRange{{0, arenaPages}},
+	}
+	c.arenas = append(c.arenas, a)
+	start, _ := a.allocate(pages)
+	return a, start, nil
+}
+
+// Releases all the arenas. The blocks in the cache can't be used afterwards,
+// but new code can still be added.
+func (c *CodeCache) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var result error
+	for _, a := range c.arenas {
+		if err := syscall.Munmap(a.memory); err != nil && result == nil {
+			result = err
+		}
+		a.memory = nil
+	}
+	c.arenas = nil
+	return result
+}
+
+// Returns the address of the start of the code, which isn't necessarily the
+// entry point.
+func (b *CodeBlock) Address() uintptr {
+	return uintptr(unsafe.Pointer(&b.Code[0]))
+}
+
+// Returns the pages of the block to the cache. The pages are made
+// inaccessible, so that calling freed code faults instead of running whatever
+// is added to the cache next.
+func (b *CodeBlock) Free() error {
+	c := b.cache
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if b.arena == nil {
+		return fmt.Errorf("Code block has already been freed")
+	}
+	a := b.arena
+	b.arena, b.Code, b.Data, b.ReadOnlyData = nil, nil, nil, nil
+	if a.memory == nil {
+		// The cache has been closed
+		return nil
+	}
+	memory := a.memory[b.start*c.pageSize : (b.start+b.pages)*c.pageSize]
+	if err := syscall.Mprotect(memory, syscall.PROT_NONE); err != nil {
+		return err
+	}
+	a.release(b.start, b.pages)
+	if a.isEmpty() && len(c.arenas) > 1 {
+		for i, other := range c.arenas {
+			if other == a {
+				c.arenas = append(c.arenas[:i], c.arenas[i+1:]...)
+				break
+			}
+		}
+		err := syscall.Munmap(a.memory)
+		a.memory = nil
+		return err
+	}
+	return nil
+}
+
+type arena struct {
+	memory []uint8
+	pages  int
+	// The unused pages, ordered by their start page.
+	free []pageRange
+}
+
+type pageRange struct {
+	start  int
+	length int
+}
+
+func (a *arena) allocate(pages int) (int, bool) {
+	for i, r := range a.free {
+		if r.length < pages {
+			continue
+		}
+		if r.length == pages {
+			a.free = append(a.free[:i], a.free[i+1:]...)
+		} else {
+			a.free[i] = pageRange{r.start + pages, r.length - pages}
+		}
+		return r.start, true
+	}
+	return 0, false
+}
+
+// Returns pages to the free list, merging them with adjacent unused pages.
+func (a *arena) release(start, pages int) {
+	i := 0
+	for i < len(a.free) && a.free[i].start < start {
+		i++
+	}
+	a.free = append(a.free, pageRange{})
+	copy(a.free[i+1:], a.free[i:])
+	a.free[i] = pageRange{start, pages}
+	if i+1 < len(a.free) && a.free[i].start+a.free[i].length == a.free[i+1].start {
+		a.free[i].length += a.free[i+1].length
+		a.free = append(a.free[:i+1], a.free[i+2:]...)
+	}
+	if i > 0 && a.free[i-1].start+a.free[i-1].length == a.free[i].start {
+		a.free[i-1].length += a.free[i].length
+		a.free = append(a.free[:i], a.free[i+1:]...)
+	}
+}
+
+func (a *arena) isEmpty() bool {
+	return len(a.free) == 1 && a.free[0].length == a.pages
+}
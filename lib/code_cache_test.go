@@ -0,0 +1,171 @@
+package lib
+
+import (
+	"reflect"
+	"runtime/debug"
+	"testing"
+)
+
+// Returns a program with code that spans the given number of pages. The code
+// is never run.
+func programWithPages(c *CodeCache, pages int) *Program {
+	return &Program{Code: make(MachineCode, (pages-1)*c.pageSize+1)}
+}
+
+func addBlock(t *testing.T, c *CodeCache, p *Program) *CodeBlock {
+	block, err := c.Add(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// Reads from memory go here, so that they aren't optimised away.
+var sink uint8
+
+// Returns whether f accesses memory that it isn't allowed to.
+func faults(f func()) (faulted bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		faulted = recover() != nil
+	}()
+	f()
+	return false
+}
+
+func Test_CodeCache_Reuse(t *testing.T) {
+	c := NewCodeCache()
+	c.ArenaPages = 4
+	defer c.Close()
+
+	blocks := []*CodeBlock{}
+	for i := 0; i < 3; i++ {
+		blocks = append(blocks, addBlock(t, c, programWithPages(c, 1)))
+	}
+	for i, b := range blocks {
+		if b.arena != c.arenas[0] || b.start != i {
+			t.Fatal("Expecting block", i, "to start at page", i, "of the first arena, got", b.start)
+		}
+	}
+
+	// The pages of a freed block are used for the next block that fits
+	if err := blocks[1].Free(); err != nil {
+		t.Fatal(err)
+	}
+	reused := addBlock(t, c, programWithPages(c, 1))
+	if reused.arena != c.arenas[0] || reused.start != 1 {
+		t.Fatal("Expecting the freed page to be reused, got page", reused.start)
+	}
+
+	// Adjacent free pages are merged, so that larger blocks fit in them
+	for _, b := range []*CodeBlock{blocks[0], reused} {
+		if err := b.Free(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []pageRange{{0, 2}, {3, 1}}
+	if !reflect.DeepEqual(c.arenas[0].free, expected) {
+		t.Fatal("Expecting free pages", expected, "got", c.arenas[0].free)
+	}
+	merged := addBlock(t, c, programWithPages(c, 2))
+	if len(c.arenas) != 1 || merged.start != 0 {
+		t.Fatal("Expecting the merged pages to be reused, got page", merged.start, "of", len(c.arenas), "arenas")
+	}
+
+	// Code that doesn't fit gets an arena of its own, which is unmapped
+	// once its blocks are freed
+	large := addBlock(t, c, programWithPages(c, 5))
+	if len(c.arenas) != 2 || large.arena.pages != 5 {
+		t.Fatal("Expecting an arena for the large block, got", len(c.arenas), "arenas")
+	}
+	memory := large.arena.memory
+	if err := large.Free(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.arenas) != 1 || !faults(func() { sink = memory[0] }) {
+		t.Fatal("Expecting the arena of the large block to be unmapped")
+	}
+
+	// The last arena is kept, even when it's empty
+	for _, b := range []*CodeBlock{merged, blocks[2]} {
+		if err := b.Free(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(c.arenas) != 1 || !c.arenas[0].isEmpty() {
+		t.Fatal("Expecting one empty arena, got", c.arenas)
+	}
+	if err := blocks[2].Free(); err == nil {
+		t.Fatal("Expecting an error when freeing a block twice")
+	}
+}
+
+func Test_CodeCache_Close(t *testing.T) {
+	c := NewCodeCache()
+	c.ArenaPages = 2
+	blocks := []*CodeBlock{}
+	for i := 0; i < 3; i++ {
+		blocks = append(blocks, addBlock(t, c, programWithPages(c, 1)))
+	}
+	arenas := c.arenas
+	if len(arenas) != 2 {
+		t.Fatal("Expecting 2 arenas, got", len(arenas))
+	}
+	code := blocks[0].Code
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.arenas) != 0 {
+		t.Fatal("Expecting no arenas after closing, got", len(c.arenas))
+	}
+	for _, a := range arenas {
+		if a.memory != nil {
+			t.Fatal("Expecting the memory of the arenas to be released")
+		}
+	}
+	if !faults(func() { sink = code[0] }) {
+		t.Fatal("Expecting the code to be unmapped")
+	}
+	// Freeing the blocks of a closed cache doesn't touch the memory
+	for _, b := range blocks {
+		if err := b.Free(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	block := addBlock(t, c, programWithPages(c, 1))
+	if err := block.Free(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_CodeCache_Protection(t *testing.T) {
+	c := NewCodeCache()
+	defer c.Close()
+	block := addBlock(t, c, &Program{
+		Code:         MachineCode{0xc3},
+		Data:         []uint8{1, 2, 3},
+		ReadOnlyData: []uint8{4, 5},
+	})
+	if !reflect.DeepEqual([]uint8(block.Code), []uint8{0xc3}) || !reflect.DeepEqual(block.Data, []uint8{1, 2, 3}) || !reflect.DeepEqual(block.ReadOnlyData, []uint8{4, 5}) {
+		t.Fatal("Expecting the program to be copied, got", block.Code, block.Data, block.ReadOnlyData)
+	}
+	if !faults(func() { block.Code[0] = 0x90 }) {
+		t.Fatal("Expecting the code not to be writable")
+	}
+	if !faults(func() { block.ReadOnlyData[0] = 0 }) {
+		t.Fatal("Expecting the read-only data not to be writable")
+	}
+	if faults(func() { block.Data[0] = 53 }) || block.Data[0] != 53 {
+		t.Fatal("Expecting the data to be writable")
+	}
+	code := block.Code
+	if err := block.Free(); err != nil {
+		t.Fatal(err)
+	}
+	if !faults(func() { sink = code[0] }) {
+		t.Fatal("Expecting freed code to be inaccessible")
+	}
+}
@@ -42,6 +42,9 @@ func (i Instructions) String() string {
 }
 
 func CompileInstruction(instr []Instruction, debug bool) (MachineCode, error) {
+	if err := Instructions(instr).Resolve(0); err != nil {
+		return nil, err
+	}
 	result := []uint8{}
 	address := 0
 	for _, i := range instr {
@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"fmt"
+)
+
+// A Label names a position in a list of Instructions, so that jumps and
+// other relative references can refer to it before its address is known.
+// Labels are compared by identity; the name is only used for display.
+//
+// Labels that point outside of the instructions, for example into a data
+// section, are Fixed and have an Address relative to the same origin as the
+// address that gets passed to Instructions.Resolve.
+type Label struct {
+	Name    string
+	Fixed   bool
+	Address int
+}
+
+func NewLabel(name string) *Label {
+	return &Label{Name: name}
+}
+
+func NewFixedLabel(name string, address int) *Label {
+	return &Label{Name: name, Fixed: true, Address: address}
+}
+
+func (l *Label) Type() Type {
+	return T_Label
+}
+
+func (l *Label) String() string {
+	return l.Name
+}
+
+func (l *Label) Width() Size {
+	return QUADWORD
+}
+
+// Marks the position of a Label in a list of Instructions. It doesn't encode
+// to any bytes.
+type LabelDefinition struct {
+	Label *Label
+}
+
+func DefineLabel(label *Label) Instruction {
+	return &LabelDefinition{label}
+}
+
+func (l *LabelDefinition) Encode() (MachineCode, error) {
+	return []uint8{}, nil
+}
+
+func (l *LabelDefinition) String() string {
+	return l.Label.Name + ":"
+}
+
+// The addresses of the labels defined in a list of Instructions.
+type LabelAddresses map[*Label]int
+
+func (l LabelAddresses) Get(label *Label) (int, error) {
+	if label.Fixed {
+		return label.Address, nil
+	}
+	address, ok := l[label]
+	if !ok {
+		return 0, fmt.Errorf("Undefined label '%s'", label.Name)
+	}
+	return address, nil
+}
+
+// A RelativeInstruction refers to the address of a Label, e.g. a jump.
+// Its encoding is provisional until Instructions.Resolve has been called.
+type RelativeInstruction interface {
+	Instruction
+	// Updates the instruction now that the address of the instruction and
+	// the addresses of the labels are known. Returns true if the length of
+	// the encoding changed, in which case the addresses have to be
+	// recalculated.
+	Resolve(address int, labels LabelAddresses) (bool, error)
+}
+
+// Resolves the labels in the instructions, assuming the first instruction
+// gets placed at the given address. Relative instructions start out with
+// their shortest encoding and only grow when their target is out of range;
+// because instructions never shrink, this terminates.
+func (i Instructions) Resolve(address int) error {
+	for {
+		labels := LabelAddresses{}
+		addresses := make([]int, len(i))
+		current := address
+		for j, instr := range i {
+			if def, ok := instr.(*LabelDefinition); ok {
+				labels[def.Label] = current
+			}
+			length, err := Instruction_Length(instr)
+			if err != nil {
+				return err
+			}
+			addresses[j] = current
+			current += length
+		}
+		changed := false
+		for j, instr := range i {
+			if rel, ok := instr.(RelativeInstruction); ok {
+				c, err := rel.Resolve(addresses[j], labels)
+				if err != nil {
+					return err
+				}
+				changed = changed || c
+			}
+		}
+		if !changed {
+			return nil
+		}
+	}
+}
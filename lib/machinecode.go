@@ -3,7 +3,6 @@ package lib
 import (
 	"encoding/hex"
 	"fmt"
-	"syscall"
 	"unsafe"
 )
 
@@ -24,17 +23,15 @@ func (m MachineCode) String() string {
 	return string(result)
 }
 
+// Executes the machine code. The code is added to the DefaultCodeCache for
+// the duration of the call.
 func (m MachineCode) Execute(debug bool) int {
-	mmapFunc, err := syscall.Mmap(
-		-1,
-		0,
-		len(m),
-		syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC, syscall.MAP_PRIVATE|mmapFlags,
-	)
-	if err != nil {
-		fmt.Printf("mmap err: %v", err)
-	}
-	copy(mmapFunc, m)
+	return (&Program{Code: m}).Execute(debug)
+}
+
+// Calls the entry point of the block as a function without arguments.
+func execute(block *CodeBlock, debug bool) int {
+	mmapFunc := []uint8(block.Code[block.Entry:])
 	type execFunc func() int
 	unsafeFunc := (uintptr)(unsafe.Pointer(&mmapFunc))
 	f := *(*execFunc)(unsafe.Pointer(&unsafeFunc))
@@ -42,7 +39,7 @@ func (m MachineCode) Execute(debug bool) int {
 	if debug {
 		fmt.Println("\nResult :", value)
 		fmt.Printf("Hex    : %x\n", value)
-		fmt.Printf("Size   : %d bytes\n\n", len(m))
+		fmt.Printf("Size   : %d bytes\n\n", len(block.Code))
 	}
 	return value
 }
@@ -18,6 +18,7 @@ const (
 	T_Int32                Type = iota
 	T_Float32              Type = iota
 	T_Float64              Type = iota
+	T_Label                Type = iota // e.g. loop
 )
 
 type Operand interface {
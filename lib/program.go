@@ -0,0 +1,37 @@
+package lib
+
+// A Program is machine code together with the data that it refers to. When
+// the program is loaded the mutable data is placed directly in front of the
+// code (see CodeCache.Add), which refers to it relative to the instruction
+// pointer. The read-only data gets pages of its own, which are neither
+// writable nor executable, so the references to it are patched once it has
+// been placed.
+type Program struct {
+	Code MachineCode
+	Data []uint8
+	// The offset of the entry point in the code.
+	Entry        int
+	ReadOnlyData []uint8
+	// The references from the code to the read-only data. The addresses of
+	// their labels are offsets in ReadOnlyData.
+	Relocations []*Relocation
+}
+
+func (p *Program) String() string {
+	return p.Code.String()
+}
+
+// Executes the program. The program is added to the DefaultCodeCache for the
+// duration of the call.
+func (p *Program) Execute(debug bool) int {
+	block, err := DefaultCodeCache.Add(p)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := block.Free(); err != nil {
+			panic(err)
+		}
+	}()
+	return execute(block, debug)
+}
@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// The way in which a Relocation is encoded in the machine code.
+type RelocationType uint8
+
+const (
+	// A 32 bit displacement relative to the address of the displacement
+	// itself, e.g. a RIP relative operand on x86_64.
+	R_PCRelative32 RelocationType = iota
+	// The 21 bit displacement of an aarch64 ADR instruction, relative to
+	// the address of the instruction.
+	R_ADR21
+)
+
+// A Relocation is a reference in the machine code to a fixed Label, for
+// example the address of some data. When the code and the data get placed
+// independently of each other, e.g. in an object file, the reference has to
+// be patched once the addresses are known.
+type Relocation struct {
+	Type RelocationType
+	// The offset of the encoded reference in the machine code.
+	Offset int
+	// The value that gets added to the address of the label, on top of the
+	// address of the reference itself for relative relocations.
+	Addend int
+	Label  *Label
+}
+
+// Instructions that refer to a Label can tell where in their encoding the
+// reference is.
+type Relocatable interface {
+	// Returns the relocation for the reference in the instruction, with the
+	// Offset relative to the start of the instruction, or nil if the
+	// instruction doesn't have one.
+	Relocation() (*Relocation, error)
+}
+
+// Returns the relocations for the references to fixed labels in the
+// instructions, assuming the first instruction gets placed at the given
+// address. The instructions should have been resolved.
+func (i Instructions) Relocations(address int) ([]*Relocation, error) {
+	result := []*Relocation{}
+	for _, instr := range i {
+		if rel, ok := instr.(Relocatable); ok {
+			relocation, err := rel.Relocation()
+			if err != nil {
+				return nil, err
+			}
+			if relocation != nil && relocation.Label.Fixed {
+				relocation.Offset += address
+				result = append(result, relocation)
+			}
+		}
+		length, err := Instruction_Length(instr)
+		if err != nil {
+			return nil, err
+		}
+		address += length
+	}
+	return result, nil
+}
+
+// Patches the reference, which is at the start of code, so that it refers
+// to the given value. For the relative relocations the value is the address
+// of the label, plus the Addend, minus the address of the reference.
+func (r *Relocation) Apply(code []uint8, value int) error {
+	switch r.Type {
+	case R_PCRelative32:
+		if value < -(1<<31) || value >= 1<<31 || len(code) < 4 {
+			return fmt.Errorf("Can't apply %d to 32 bit relocation", value)
+		}
+		binary.LittleEndian.PutUint32(code, uint32(int32(value)))
+		return nil
+	case R_ADR21:
+		if value < -(1<<20) || value >= 1<<20 || len(code) < 4 {
+			return fmt.Errorf("Can't apply %d to 21 bit relocation", value)
+		}
+		// immlo is in bits 29-30 and immhi in bits 5-23 of the ADR instruction
+		instr := binary.LittleEndian.Uint32(code) &^ (0x3<<29 | 0x7ffff<<5)
+		instr |= uint32(value&0x3)<<29 | uint32((value>>2)&0x7ffff)<<5
+		binary.LittleEndian.PutUint32(code, instr)
+		return nil
+	}
+	return fmt.Errorf("Unsupported relocation type %d", r.Type)
+}
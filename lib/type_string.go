@@ -21,11 +21,12 @@ func _() {
 	_ = x[T_Int32-10]
 	_ = x[T_Float32-11]
 	_ = x[T_Float64-12]
+	_ = x[T_Label-13]
 }
 
-const _Type_name = "T_RegisterT_IndirectRegisterT_RIPRelativeT_SIBRegisterT_DisplacedRegisterT_DisplacedSIBRegisterT_Uint8T_Uint16T_Uint32T_Uint64T_Int32T_Float32T_Float64"
+const _Type_name = "T_RegisterT_IndirectRegisterT_RIPRelativeT_SIBRegisterT_DisplacedRegisterT_DisplacedSIBRegisterT_Uint8T_Uint16T_Uint32T_Uint64T_Int32T_Float32T_Float64T_Label"
 
-var _Type_index = [...]uint8{0, 10, 28, 41, 54, 73, 95, 102, 110, 118, 126, 133, 142, 151}
+var _Type_index = [...]uint8{0, 10, 28, 41, 54, 73, 95, 102, 110, 118, 126, 133, 142, 151, 158}
 
 func (i Type) String() string {
 	if i >= Type(len(_Type_index)-1) {